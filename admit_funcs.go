@@ -1,24 +1,67 @@
 package admissioncontrol
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/santhosh-tekuri/jsonschema/v5"
 	"golang.org/x/xerrors"
 
 	admission "k8s.io/api/admission/v1"
 	apps "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
 	batch "k8s.io/api/batch/v1"
 	core "k8s.io/api/core/v1"
 	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	networking "k8s.io/api/networking/v1"
+	storage "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 )
 
+// ErrUnsupportedKind is returned (wrapped) by the built-in AdmitFuncs for
+// requests with an object kind that they cannot evaluate. See
+// AdmissionHandler.UnknownKinds.
+var ErrUnsupportedKind = xerrors.New("the submitted Kind is not supported by this admission handler")
+
+// unsupportedKind is an ErrUnsupportedKind for the named kind.
+type unsupportedKind string
+
+func (kind unsupportedKind) Error() string {
+	return fmt.Sprintf("%s: %s", ErrUnsupportedKind, string(kind))
+}
+
+// Is reports whether the target is ErrUnsupportedKind.
+func (kind unsupportedKind) Is(target error) bool {
+	return target == ErrUnsupportedKind
+}
+
 var (
 	podDeniedError       = "the submitted Pods are missing required annotations:"
-	unsupportedKindError = "the submitted Kind is not supported by this admission handler:"
+	namespaceDeniedError = "the submitted Namespace is missing required annotations:"
+	unsupportedKindError = ErrUnsupportedKind.Error() + ":"
 )
 
 // CloudProvider represents supported cloud platforms for provider-specific
@@ -47,6 +90,26 @@ var ilbAnnotations = map[CloudProvider]map[string]string{
 	OpenStack: {"service.beta.kubernetes.io/openstack-internal-load-balancer": "true"},
 }
 
+// ImmutableConfigAnnotation marks a ConfigMap or Secret as immutable by policy.
+// Objects carrying this annotation (with a value of "true") are rejected on
+// UPDATE by DenyMutableConfigData.
+const ImmutableConfigAnnotation = "config.corp/immutable"
+
+// ImmutableConfigContactAnnotation names who to contact (e.g. a team or
+// channel) to change an immutable ConfigMap or Secret. DenyMutableConfigData
+// names it in its denials.
+const ImmutableConfigContactAnnotation = "config.corp/contact"
+
+// AllowedNetworkCapabilitiesAnnotation lists (comma-separated) the raw network
+// capabilities - e.g. "NET_ADMIN,NET_RAW" - that the containers of a Pod (or of
+// a workload's Pod template) may add. See EnforceNetworkCapabilities.
+const AllowedNetworkCapabilitiesAnnotation = "config.corp/allowed-network-capabilities"
+
+// universalDeserializer decodes the objects submitted for admission into their
+// (typed) Go structs. It is shared by the built-in AdmitFuncs, as creating a
+// deserializer per request is costly, and is safe for concurrent use.
+var universalDeserializer = serializer.NewCodecFactory(runtime.NewScheme()).UniversalDeserializer()
+
 // newDefaultDenyResponse returns an AdmissionResponse with a Result sub-object,
 // and defaults to allowed = false.
 func newDefaultDenyResponse() *admission.AdmissionResponse {
@@ -71,8 +134,7 @@ func DenyIngresses(ignoredNamespaces []string) AdmitFunc {
 		switch kind {
 		case "Ingress":
 			ingress := extensionsv1beta1.Ingress{}
-			deserializer := serializer.NewCodecFactory(runtime.NewScheme()).UniversalDeserializer()
-			if _, _, err := deserializer.Decode(admissionReview.Request.Object.Raw, nil, &ingress); err != nil {
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &ingress); err != nil {
 				return nil, err
 			}
 
@@ -112,8 +174,7 @@ func DenyPublicLoadBalancers(ignoredNamespaces []string, provider CloudProvider)
 		resp := newDefaultDenyResponse()
 
 		service := core.Service{}
-		deserializer := serializer.NewCodecFactory(runtime.NewScheme()).UniversalDeserializer()
-		if _, _, err := deserializer.Decode(admissionReview.Request.Object.Raw, nil, &service); err != nil {
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &service); err != nil {
 			return nil, err
 		}
 
@@ -163,16 +224,15 @@ func DenyPublicLoadBalancers(ignoredNamespaces []string, provider CloudProvider)
 // EnforcePodAnnotations can inspect Pods, Deployments, StatefulSets, DaemonSets &
 // Jobs.
 //
-// Unknown object kinds are rejected. You can create multiple versions of
-// this AdmitFunc for a given ValidatingAdmissionWebhook configuration if you
-// wish to apply different configurations per kind or namespace.
+// Unknown object kinds are answered according to AdmissionHandler.UnknownKinds
+// (allowed by default). You can create multiple versions of this AdmitFunc for
+// a given ValidatingAdmissionWebhook configuration if you wish to apply
+// different configurations per kind or namespace.
 func EnforcePodAnnotations(ignoredNamespaces []string, requiredAnnotations map[string]func(string) bool) AdmitFunc {
 	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
 		kind := admissionReview.Request.Kind.Kind
 		resp := newDefaultDenyResponse()
 
-		deserializer := serializer.NewCodecFactory(runtime.NewScheme()).UniversalDeserializer()
-
 		// We handle all built-in Kinds that include a PodTemplateSpec, as described here:
 		// https://kubernetes.io/docs/reference/generated/kubernetes-api/v1.15/#pod-v1-core
 		var namespace string
@@ -181,7 +241,7 @@ func EnforcePodAnnotations(ignoredNamespaces []string, requiredAnnotations map[s
 		switch kind {
 		case "Pod":
 			pod := core.Pod{}
-			if _, _, err := deserializer.Decode(admissionReview.Request.Object.Raw, nil, &pod); err != nil {
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &pod); err != nil {
 				return nil, err
 			}
 
@@ -189,7 +249,7 @@ func EnforcePodAnnotations(ignoredNamespaces []string, requiredAnnotations map[s
 			annotations = pod.GetAnnotations()
 		case "Deployment":
 			deployment := apps.Deployment{}
-			if _, _, err := deserializer.Decode(admissionReview.Request.Object.Raw, nil, &deployment); err != nil {
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &deployment); err != nil {
 				return nil, err
 			}
 
@@ -197,7 +257,7 @@ func EnforcePodAnnotations(ignoredNamespaces []string, requiredAnnotations map[s
 			annotations = deployment.Spec.Template.GetAnnotations()
 		case "StatefulSet":
 			statefulset := apps.StatefulSet{}
-			if _, _, err := deserializer.Decode(admissionReview.Request.Object.Raw, nil, &statefulset); err != nil {
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &statefulset); err != nil {
 				return nil, err
 			}
 
@@ -205,7 +265,7 @@ func EnforcePodAnnotations(ignoredNamespaces []string, requiredAnnotations map[s
 			annotations = statefulset.Spec.Template.GetAnnotations()
 		case "DaemonSet":
 			daemonset := apps.DaemonSet{}
-			if _, _, err := deserializer.Decode(admissionReview.Request.Object.Raw, nil, &daemonset); err != nil {
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &daemonset); err != nil {
 				return nil, err
 			}
 
@@ -213,7 +273,7 @@ func EnforcePodAnnotations(ignoredNamespaces []string, requiredAnnotations map[s
 			annotations = daemonset.Spec.Template.GetAnnotations()
 		case "Job":
 			job := batch.Job{}
-			if _, _, err := deserializer.Decode(admissionReview.Request.Object.Raw, nil, &job); err != nil {
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &job); err != nil {
 				return nil, err
 			}
 
@@ -221,7 +281,7 @@ func EnforcePodAnnotations(ignoredNamespaces []string, requiredAnnotations map[s
 			annotations = job.Spec.Template.GetAnnotations()
 		default:
 			// TODO(matt): except for whitelisted namespaces
-			return nil, xerrors.Errorf("the submitted Kind is not supported by this admission handler: %s", kind)
+			return nil, unsupportedKind(kind)
 		}
 
 		// Ignore objects in whitelisted namespaces.
@@ -233,34 +293,5398 @@ func EnforcePodAnnotations(ignoredNamespaces []string, requiredAnnotations map[s
 			}
 		}
 
-		missing := make(map[string]string)
-		// We check whether the (strictly matched) annotation key exists, and then run
-		// our user-provided matchFunc against it. If we're missing any keys, or the
-		// value for a key does not match, admission is rejected.
-		for requiredKey, matchFunc := range requiredAnnotations {
-			if matchFunc == nil {
-				return resp, xerrors.Errorf("cannot validate annotations (%s) with a nil matchFunc", requiredKey)
+		missing, err := matchAnnotations(requiredAnnotations, annotations)
+		if err != nil {
+			return resp, err
+		}
+
+		if len(missing) > 0 {
+			return resp, xerrors.Errorf("%s %v", podDeniedError, missing)
+		}
+
+		// No missing or invalid annotations; allow admission
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// DenyMutableConfigData denies UPDATE operations against ConfigMaps and
+// Secrets that were marked as immutable via the ImmutableConfigAnnotation. This
+// guards configuration that is versioned (and rolled out) outside of the
+// cluster.
+//
+// The annotations & data of the existing (old) object are compared against the
+// submitted object: any change - including removing the annotation itself - is
+// rejected.
+//
+// Denials name who to contact to change the object: the value of the
+// ImmutableConfigContactAnnotation on the existing object (e.g.
+// "#platform-config" or "platform-team@example.com"), or otherwise the
+// administrators of its namespace.
+//
+// Kinds other than ConfigMap and Secret, and operations other than UPDATE, will
+// be allowed.
+func DenyMutableConfigData(ignoredNamespaces []string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if admissionReview.Request.Operation != admission.Update {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		var oldMeta, newMeta metav1.ObjectMeta
+		var oldData, newData []interface{}
+		switch kind {
+		case "ConfigMap":
+			oldConfigMap, newConfigMap := core.ConfigMap{}, core.ConfigMap{}
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.OldObject.Raw, nil, &oldConfigMap); err != nil {
+				return nil, err
+			}
+
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &newConfigMap); err != nil {
+				return nil, err
+			}
+
+			oldMeta, newMeta = oldConfigMap.ObjectMeta, newConfigMap.ObjectMeta
+			oldData = []interface{}{oldConfigMap.Data, oldConfigMap.BinaryData}
+			newData = []interface{}{newConfigMap.Data, newConfigMap.BinaryData}
+		case "Secret":
+			oldSecret, newSecret := core.Secret{}, core.Secret{}
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.OldObject.Raw, nil, &oldSecret); err != nil {
+				return nil, err
+			}
+
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &newSecret); err != nil {
+				return nil, err
+			}
+
+			oldMeta, newMeta = oldSecret.ObjectMeta, newSecret.ObjectMeta
+			oldData = []interface{}{oldSecret.Data, oldSecret.StringData, oldSecret.Type}
+			newData = []interface{}{newSecret.Data, newSecret.StringData, newSecret.Type}
+		default:
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		if isIgnoredNamespace(newMeta.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", newMeta.Namespace)
+			return resp, nil
+		}
+
+		// Only objects that were previously marked as immutable are protected.
+		if oldMeta.Annotations[ImmutableConfigAnnotation] != "true" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		if !reflect.DeepEqual(oldMeta.Annotations, newMeta.Annotations) || !reflect.DeepEqual(oldData, newData) {
+			owner := fmt.Sprintf("the administrators of the %s namespace", newMeta.Namespace)
+			if annotated := oldMeta.Annotations[ImmutableConfigContactAnnotation]; annotated != "" {
+				owner = annotated
+			}
+
+			return resp, xerrors.Errorf(
+				"%s %s/%s is immutable by policy (%s: \"true\"): contact %s to change it",
+				kind,
+				newMeta.Namespace,
+				newMeta.Name,
+				ImmutableConfigAnnotation,
+				owner,
+			)
+		}
+
+		// Metadata other than the annotations (e.g. labels) may still change.
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// CronJobPolicy configures the constraints enforced by EnforceCronJobPolicy.
+type CronJobPolicy struct {
+	// DenyAllowConcurrent rejects CronJobs with a concurrencyPolicy of "Allow",
+	// which can start overlapping Jobs indefinitely.
+	DenyAllowConcurrent bool
+	// MaxSuccessfulJobsHistoryLimit is the upper bound for
+	// .spec.successfulJobsHistoryLimit. A value of 0 only requires that the field
+	// is set.
+	MaxSuccessfulJobsHistoryLimit int32
+	// MaxFailedJobsHistoryLimit is the upper bound for
+	// .spec.failedJobsHistoryLimit. A value of 0 only requires that the field is
+	// set.
+	MaxFailedJobsHistoryLimit int32
+}
+
+// EnforceCronJobPolicy validates that CronJobs set a concurrencyPolicy, bounded
+// successful & failed Job history limits and a startingDeadlineSeconds, so that
+// CronJobs cannot fill the cluster with stale Jobs.
+//
+// Both batch/v1 and batch/v1beta1 CronJobs are supported. Kinds other than
+// CronJob will be allowed.
+func EnforceCronJobPolicy(ignoredNamespaces []string, config CronJobPolicy) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "CronJob" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		// The batch/v1beta1 CronJobSpec is identical to the batch/v1 CronJobSpec
+		// for the fields we validate, and so both are decoded as a batch/v1 CronJob.
+		cronJob := batch.CronJob{}
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &cronJob); err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(cronJob.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", cronJob.Namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		spec := cronJob.Spec
+		switch {
+		case spec.ConcurrencyPolicy == "":
+			violations.Add("spec.concurrencyPolicy", "must be set")
+		case config.DenyAllowConcurrent && spec.ConcurrencyPolicy == batch.AllowConcurrent:
+			violations.Add("spec.concurrencyPolicy", "must be Forbid or Replace (got Allow)")
+		}
+
+		checkBoundedField(&violations, "spec.successfulJobsHistoryLimit", spec.SuccessfulJobsHistoryLimit, config.MaxSuccessfulJobsHistoryLimit)
+		checkBoundedField(&violations, "spec.failedJobsHistoryLimit", spec.FailedJobsHistoryLimit, config.MaxFailedJobsHistoryLimit)
+
+		if spec.StartingDeadlineSeconds == nil {
+			violations.Add("spec.startingDeadlineSeconds", "must be set")
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("CronJob %s/%s violates the CronJob policy", cronJob.Namespace, cronJob.Name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// JobPolicy configures the constraints enforced by EnforceJobPolicy.
+type JobPolicy struct {
+	// MaxBackoffLimit is the upper bound for .spec.backoffLimit. A value of 0
+	// only requires that the field is set.
+	MaxBackoffLimit int32
+	// MaxTTLSecondsAfterFinished is the upper bound for
+	// .spec.ttlSecondsAfterFinished. A value of 0 only requires that the field is
+	// set.
+	MaxTTLSecondsAfterFinished int32
+}
+
+// EnforceJobPolicy validates that Jobs set a bounded backoffLimit and a
+// ttlSecondsAfterFinished, so that finished Jobs (and their Pods) are garbage
+// collected rather than accumulating in the cluster.
+//
+// Kinds other than Job will be allowed.
+func EnforceJobPolicy(ignoredNamespaces []string, config JobPolicy) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "Job" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		job := batch.Job{}
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &job); err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(job.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", job.Namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		checkBoundedField(&violations, "spec.backoffLimit", job.Spec.BackoffLimit, config.MaxBackoffLimit)
+		checkBoundedField(&violations, "spec.ttlSecondsAfterFinished", job.Spec.TTLSecondsAfterFinished, config.MaxTTLSecondsAfterFinished)
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("Job %s/%s violates the Job policy", job.Namespace, job.Name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// checkBoundedField validates that an optional field is set, and does not
+// exceed max (if non-zero), adding any violation to the list.
+func checkBoundedField(violations *ViolationList, field string, value *int32, max int32) {
+	if value == nil {
+		violations.Add(field, "must be set")
+		return
+	}
+
+	if max > 0 && *value > max {
+		violations.Add(field, fmt.Sprintf("must be <= %d (got %d)", max, *value))
+	}
+}
+
+// DenyHostPorts denies containers that declare a hostPort not included in the
+// allowedPorts. HostPorts bypass Services and constrain scheduling, as only one
+// Pod per node can bind a given port.
+//
+// Providing an empty/nil list of allowedPorts will reject all host ports.
+//
+// DenyHostPorts can inspect Pods and the Pod templates of Deployments,
+// StatefulSets, DaemonSets, ReplicaSets, ReplicationControllers, Jobs &
+// CronJobs. Unknown object kinds are answered according to
+// AdmissionHandler.UnknownKinds (allowed by default).
+func DenyHostPorts(ignoredNamespaces []string, allowedPorts []int32) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		allowed := make(map[int32]bool, len(allowedPorts))
+		for _, port := range allowedPorts {
+			allowed[port] = true
+		}
+
+		var violations ViolationList
+		for _, container := range pod.containers() {
+			for i, port := range container.Ports {
+				if port.HostPort != 0 && !allowed[port.HostPort] {
+					violations.Add(fmt.Sprintf("%s.ports[%d].hostPort", container.field, i), fmt.Sprintf("%d is not allowed", port.HostPort))
+				}
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s declares host ports that are not allowed", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// ServiceTypeTransition represents a change of a Service's .spec.type.
+type ServiceTypeTransition struct {
+	From core.ServiceType
+	To   core.ServiceType
+}
+
+// DenyServiceTypeChange denies UPDATE operations that change the .spec.type of
+// a Service - e.g. from ClusterIP to LoadBalancer - which can provision
+// (unexpected, and billable) cloud load balancers.
+//
+// Specific transitions can be permitted by passing them as allowedTransitions.
+//
+// Kinds other than Service, and operations other than UPDATE, will be allowed.
+func DenyServiceTypeChange(ignoredNamespaces []string, allowedTransitions ...ServiceTypeTransition) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "Service" || admissionReview.Request.Operation != admission.Update {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		oldService, service := core.Service{}, core.Service{}
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.OldObject.Raw, nil, &oldService); err != nil {
+			return nil, err
+		}
+
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &service); err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(service.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", service.Namespace)
+			return resp, nil
+		}
+
+		transition := ServiceTypeTransition{From: oldService.Spec.Type, To: service.Spec.Type}
+		if transition.From == transition.To {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		for _, allowed := range allowedTransitions {
+			if transition == allowed {
+				resp.Allowed = true
+				resp.Result.Message = fmt.Sprintf("allowing admission: Service type change from %s to %s is allowed", transition.From, transition.To)
+				return resp, nil
+			}
+		}
+
+		return resp, xerrors.Errorf(
+			"Service %s/%s cannot change type from %s to %s: delete and re-create the Service if this change is intended",
+			service.Namespace,
+			service.Name,
+			transition.From,
+			transition.To,
+		)
+	}
+}
+
+// loadBalancerSourceRangesAnnotation is the (legacy) annotation equivalent of
+// .spec.loadBalancerSourceRanges.
+const loadBalancerSourceRangesAnnotation = "service.beta.kubernetes.io/load-balancer-source-ranges"
+
+// RequireLoadBalancerSourceRanges denies Services of type: LoadBalancer that do
+// not restrict client access via .spec.loadBalancerSourceRanges (or the
+// equivalent service.beta.kubernetes.io/load-balancer-source-ranges
+// annotation). Without it, a load balancer accepts traffic from any address -
+// e.g. the Internet, if routable. Ranges that allow all addresses (0.0.0.0/0 or
+// ::/0) are also rejected.
+//
+// Services that are public by design can bypass this check by setting any of
+// the publicAnnotations to "true".
+//
+// This pairs well with DenyPublicLoadBalancers, which rejects load balancers
+// not annotated as internal-only. Services with a .spec.type other than
+// LoadBalancer will not be rejected by this handler.
+func RequireLoadBalancerSourceRanges(ignoredNamespaces []string, publicAnnotations ...string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "Service" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		service := core.Service{}
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &service); err != nil {
+			return nil, err
+		}
+
+		if service.Spec.Type != core.ServiceTypeLoadBalancer {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		if isIgnoredNamespace(service.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", service.Namespace)
+			return resp, nil
+		}
+
+		for _, key := range publicAnnotations {
+			if service.Annotations[key] == "true" {
+				resp.Allowed = true
+				resp.Result.Message = fmt.Sprintf("allowing admission: Service is annotated as public (%s)", key)
+				return resp, nil
+			}
+		}
+
+		sourceRanges := service.Spec.LoadBalancerSourceRanges
+		if len(sourceRanges) == 0 && service.Annotations[loadBalancerSourceRangesAnnotation] != "" {
+			sourceRanges = strings.Split(service.Annotations[loadBalancerSourceRangesAnnotation], ",")
+		}
+
+		if len(sourceRanges) == 0 {
+			return resp, xerrors.Errorf(
+				"Service %s/%s of type: LoadBalancer must restrict access via .spec.loadBalancerSourceRanges",
+				service.Namespace,
+				service.Name,
+			)
+		}
+
+		for _, sourceRange := range sourceRanges {
+			if sourceRange := strings.TrimSpace(sourceRange); sourceRange == "0.0.0.0/0" || sourceRange == "::/0" {
+				return resp, xerrors.Errorf(
+					"Service %s/%s of type: LoadBalancer must restrict access via .spec.loadBalancerSourceRanges: %s allows all addresses",
+					service.Namespace,
+					service.Name,
+					sourceRange,
+				)
 			}
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// DenyAnnotations denies objects of any kind carrying an annotation whose key
+// matches one of the denied patterns. This can be used to block deprecated or
+// dangerous annotations cluster-wide.
+//
+// Patterns are matched using path.Match syntax: e.g.
+// "scheduler.alpha.kubernetes.io/*" matches any annotation with that prefix.
+// An error is returned if any of the patterns are invalid.
+//
+// Only the top-level metadata.annotations of the submitted object are
+// inspected.
+func DenyAnnotations(ignoredNamespaces []string, denied []string) (AdmitFunc, error) {
+	for _, pattern := range denied {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, xerrors.Errorf("invalid denied annotation pattern %q: %w", pattern, err)
+		}
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		object, err := decodeObjectMeta(admissionReview)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(object.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", object.Namespace)
+			return resp, nil
+		}
+
+		keys := make([]string, 0, len(object.Annotations))
+		for key := range object.Annotations {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
 
-			if existingVal, ok := annotations[requiredKey]; !ok {
-				// Key does not exist; add it to the missing annotations list
-				missing[requiredKey] = "key was not found"
-			} else {
-				if matched := matchFunc(existingVal); !matched {
-					missing[requiredKey] = "value did not match"
+		var violations ViolationList
+		for _, key := range keys {
+			for _, pattern := range denied {
+				// The patterns were validated above.
+				if matched, _ := path.Match(pattern, key); matched {
+					violations.Add(fmt.Sprintf("metadata.annotations[%s]", key), fmt.Sprintf("denied by the pattern %q", pattern))
+					break
 				}
-				// Key exists & matchFunc returned OK.
 			}
 		}
 
-		if len(missing) > 0 {
-			return resp, xerrors.Errorf("%s %v", podDeniedError, missing)
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s carries annotations that are not allowed", kind, qualifiedName(object)))
 		}
 
-		// No missing or invalid annotations; allow admission
 		resp.Allowed = true
 		return resp, nil
+	}, nil
+}
+
+// ValidateAgainstJSONSchema validates objects of the given GroupVersionKind
+// against the provided JSON Schema, and denies admission if validation fails.
+// This allows structural constraints to be enforced on CRDs that lack a full
+// OpenAPI validation schema.
+//
+// The schema is compiled when ValidateAgainstJSONSchema is called, and an
+// invalid schema returns an error. Schemas cannot reference remote ($ref) URLs.
+//
+// Kinds other than the given GroupVersionKind will be allowed.
+func ValidateAgainstJSONSchema(gvk schema.GroupVersionKind, schemaJSON []byte) (AdmitFunc, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, xerrors.Errorf("cannot load %s: remote schema references are not supported", url)
 	}
+
+	schemaURL := fmt.Sprintf("%s.schema.json", strings.ToLower(gvk.Kind))
+	if err := compiler.AddResource(schemaURL, bytes.NewReader(schemaJSON)); err != nil {
+		return nil, xerrors.Errorf("invalid JSON Schema for %s: %w", gvk, err)
+	}
+
+	compiled, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid JSON Schema for %s: %w", gvk, err)
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind.Group != gvk.Group || kind.Version != gvk.Version || kind.Kind != gvk.Kind {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		// Numbers are decoded as json.Number to retain their precision.
+		var object interface{}
+		decoder := json.NewDecoder(bytes.NewReader(admissionReview.Request.Object.Raw))
+		decoder.UseNumber()
+		if err := decoder.Decode(&object); err != nil {
+			return nil, err
+		}
+
+		err := compiled.Validate(object)
+		if err == nil {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		var validationErr *jsonschema.ValidationError
+		if !xerrors.As(err, &validationErr) {
+			return nil, err
+		}
+
+		// Report the most specific (leaf) violations, rather than each of the
+		// schema keywords that enclose them.
+		var violations ViolationList
+		var collect func(*jsonschema.ValidationError)
+		collect = func(ve *jsonschema.ValidationError) {
+			if len(ve.Causes) == 0 {
+				location := ve.InstanceLocation
+				if location == "" {
+					location = "/"
+				}
+				violations.Add(location, ve.Message)
+			}
+
+			for _, cause := range ve.Causes {
+				collect(cause)
+			}
+		}
+		collect(validationErr)
+		sort.Slice(violations, func(i, j int) bool {
+			return violations[i].String() < violations[j].String()
+		})
+
+		objectMeta, err := decodeObjectMeta(admissionReview)
+		if err != nil {
+			return nil, err
+		}
+
+		return resp, violations.deny(resp, fmt.Sprintf("%s %s does not match the schema for %s", kind.Kind, objectMeta.Name, gvk))
+	}, nil
+}
+
+// decodeObjectMeta decodes the metadata of the submitted object, regardless of
+// its kind. The namespace is defaulted from the request if unset - e.g. for
+// objects created without an explicit metadata.namespace.
+func decodeObjectMeta(admissionReview *admission.AdmissionReview) (*metav1.ObjectMeta, error) {
+	object := metav1.PartialObjectMetadata{}
+	if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &object); err != nil {
+		return nil, err
+	}
+
+	if object.Namespace == "" {
+		object.Namespace = admissionReview.Request.Namespace
+	}
+
+	return &object.ObjectMeta, nil
+}
+
+// qualifiedName returns the "namespace/name" of the object, or the name alone
+// for cluster-scoped objects.
+func qualifiedName(object *metav1.ObjectMeta) string {
+	if object.Namespace == "" {
+		return object.Name
+	}
+
+	return object.Namespace + "/" + object.Name
+}
+
+// EnforceMaxPVCsPerNamespace denies the creation of PersistentVolumeClaims that
+// would take the number of PersistentVolumeClaims in a namespace beyond the
+// limit. This provides a quota-style control for namespaces without a
+// ResourceQuota.
+//
+// The existing PersistentVolumeClaims are read from the provided
+// SharedInformerFactory (see ClientOption), whose client must be authorized to
+// list & watch persistentvolumeclaims across all namespaces. As the cache is
+// eventually consistent, concurrent creations may briefly exceed the limit.
+//
+// PersistentVolumeClaims that are already being deleted (Terminating) do not
+// count towards the limit. An error is returned if the limit is less than 1.
+//
+// Kinds other than PersistentVolumeClaim, and operations other than CREATE,
+// will be allowed.
+func EnforceMaxPVCsPerNamespace(factory informers.SharedInformerFactory, limit int, opts ...ClientOption) (AdmitFunc, error) {
+	if limit < 1 {
+		return nil, xerrors.Errorf("invalid PersistentVolumeClaim limit %d: the limit must be at least 1", limit)
+	}
+
+	pvcs := factory.Core().V1().PersistentVolumeClaims()
+	lister := pvcs.Lister()
+	synced := pvcs.Informer().HasSynced
+	policy := newClientPolicy(opts)
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "PersistentVolumeClaim" || admissionReview.Request.Operation != admission.Create {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		if !policy.waitForCacheSync(synced) {
+			return policy.unavailable(resp, "the PersistentVolumeClaim cache has not synced: cannot count existing claims")
+		}
+
+		namespace := admissionReview.Request.Namespace
+		claims, err := lister.PersistentVolumeClaims(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+
+		var existing int
+		for _, claim := range claims {
+			if claim.DeletionTimestamp == nil {
+				existing++
+			}
+		}
+
+		if existing >= limit {
+			return resp, xerrors.Errorf(
+				"namespace %s already has %d PersistentVolumeClaims: creating another would exceed the limit of %d",
+				namespace,
+				existing,
+				limit,
+			)
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}, nil
+}
+
+// EnforceMaxPodsPerNamespace denies the creation of Pods that would take the
+// number of Pods in a namespace beyond the limit: a lightweight alternative to
+// a ResourceQuota on the Pod count, for namespaces without one.
+//
+// Pods created by controllers (e.g. the ReplicaSets of a Deployment, or Jobs)
+// count towards - and are subject to - the limit, as Pods created directly are.
+// When their Pods are denied, controllers record a FailedCreate event and retry
+// with a backoff: a workload scaled beyond the limit runs fewer replicas than
+// desired, rather than failing to update.
+//
+// The existing Pods are read from the provided SharedInformerFactory (see
+// ClientOption), whose client must be authorized to list & watch pods across
+// all namespaces. As the cache is eventually consistent, concurrent creations
+// may briefly exceed the limit.
+//
+// As with a ResourceQuota, Pods that have terminated (Succeeded or Failed) or
+// are being deleted (Terminating) do not count towards the limit. An error is
+// returned if the limit is less than 1.
+//
+// Kinds other than Pod, and operations other than CREATE, will be allowed.
+func EnforceMaxPodsPerNamespace(factory informers.SharedInformerFactory, limit int, opts ...ClientOption) (AdmitFunc, error) {
+	if limit < 1 {
+		return nil, xerrors.Errorf("invalid Pod limit %d: the limit must be at least 1", limit)
+	}
+
+	pods := factory.Core().V1().Pods()
+	lister := pods.Lister()
+	synced := pods.Informer().HasSynced
+	policy := newClientPolicy(opts)
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "Pod" || admissionReview.Request.Operation != admission.Create {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		pod := core.Pod{}
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &pod); err != nil {
+			return nil, err
+		}
+
+		if !policy.waitForCacheSync(synced) {
+			return policy.unavailable(resp, "the Pod cache has not synced: cannot count existing Pods")
+		}
+
+		namespace := admissionReview.Request.Namespace
+		existingPods, err := lister.Pods(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+
+		var existing int
+		for _, existingPod := range existingPods {
+			terminated := existingPod.Status.Phase == core.PodSucceeded || existingPod.Status.Phase == core.PodFailed
+			if existingPod.DeletionTimestamp == nil && !terminated {
+				existing++
+			}
+		}
+
+		if existing >= limit {
+			creator := ""
+			if owner := metav1.GetControllerOf(&pod); owner != nil {
+				creator = fmt.Sprintf(" (for %s %s)", owner.Kind, owner.Name)
+			}
+
+			return resp, xerrors.Errorf(
+				"namespace %s already has %d Pods: creating another%s would exceed the limit of %d",
+				namespace,
+				existing,
+				creator,
+				limit,
+			)
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}, nil
+}
+
+// EnforceContainerNaming denies Pods with containers whose names do not match
+// the pattern (a regular expression), or that are named one of the (generic)
+// deniedNames - e.g. "app". The pattern must match the entire container name.
+//
+// An error is returned if the pattern is not a valid regular expression.
+//
+// EnforceContainerNaming inspects init, regular & ephemeral containers, in Pods
+// and the Pod templates of Deployments, StatefulSets, DaemonSets, ReplicaSets,
+// ReplicationControllers, Jobs & CronJobs. Unknown object kinds are answered
+// according to AdmissionHandler.UnknownKinds (allowed by default).
+func EnforceContainerNaming(ignoredNamespaces []string, pattern string, deniedNames ...string) (AdmitFunc, error) {
+	nameRegexp, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, xerrors.Errorf("invalid container naming pattern %q: %w", pattern, err)
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+	containers:
+		for _, container := range pod.containers() {
+			for _, denied := range deniedNames {
+				if container.Name == denied {
+					violations.Add(container.field+".name", fmt.Sprintf("%q is not an allowed name", container.Name))
+					continue containers
+				}
+			}
+
+			if !nameRegexp.MatchString(container.Name) {
+				violations.Add(container.field+".name", fmt.Sprintf("%q does not match the pattern", container.Name))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf(
+				"%s %s/%s has containers that do not match the naming convention (%s)",
+				kind,
+				pod.namespace,
+				pod.name,
+				pattern,
+			))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}, nil
+}
+
+// EnforceImageTagPattern requires container images to be referenced by a tag
+// matching the pattern (a regular expression) - such as a strict semantic
+// version (`v\d+\.\d+\.\d+`) or a git SHA - rejecting mutable-looking tags
+// such as "latest", "stable" or "main". The pattern must match the entire tag.
+//
+// Images without a tag (which implicitly refer to "latest") are rejected, and
+// images pinned to a digest are allowed regardless of their tag. An error is
+// returned if the pattern is not a valid regular expression.
+//
+// EnforceImageTagPattern inspects init, regular & ephemeral containers, in Pods
+// and the Pod templates of Deployments, StatefulSets, DaemonSets, ReplicaSets,
+// ReplicationControllers, Jobs & CronJobs. Unknown object kinds are answered
+// according to AdmissionHandler.UnknownKinds (allowed by default).
+func EnforceImageTagPattern(ignoredNamespaces []string, pattern string) (AdmitFunc, error) {
+	tagRegexp, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, xerrors.Errorf("invalid image tag pattern %q: %w", pattern, err)
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		for _, container := range pod.containers() {
+			ref := parseImageReference(container.Image)
+			if ref.digest != "" {
+				continue
+			}
+
+			if ref.tag == "" || !tagRegexp.MatchString(ref.tag) {
+				violations.Add(container.field+".image", fmt.Sprintf("%q does not have a tag matching the pattern", container.Image))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf(
+				"%s %s/%s has images with tags that do not match the required pattern (%s)",
+				kind,
+				pod.namespace,
+				pod.name,
+				pattern,
+			))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}, nil
+}
+
+// sha256DigestRegexp matches a well-formed sha256 image digest.
+var sha256DigestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// RequireImageDigest denies containers whose image reference is not pinned to a
+// sha256 digest (e.g. "registry.example.com:5000/team/app@sha256:...").
+// Tag-only references are mutable, and are rejected even if the tag looks
+// immutable.
+//
+// RequireImageDigest inspects init, regular & ephemeral containers, in Pods and
+// the Pod templates of Deployments, StatefulSets, DaemonSets, ReplicaSets,
+// ReplicationControllers, Jobs & CronJobs. Unknown object kinds are answered
+// according to AdmissionHandler.UnknownKinds (allowed by default).
+func RequireImageDigest(ignoredNamespaces []string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		for _, container := range pod.containers() {
+			if ref := parseImageReference(container.Image); !sha256DigestRegexp.MatchString(ref.digest) {
+				violations.Add(container.field+".image", fmt.Sprintf("%q is not pinned to a sha256 digest", container.Image))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s has images that are not pinned to a sha256 digest", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// EnforcePullPolicyForDigestImages denies containers whose image is pinned to a
+// digest, but that set imagePullPolicy: Always: as the image at a digest cannot
+// change, pulling it on each container start only wastes time (and registry
+// bandwidth), and fails the start if the registry is unavailable. Set
+// imagePullPolicy: IfNotPresent instead.
+//
+// Conversely, containers whose image is only referenced by a tag, and that set
+// imagePullPolicy: IfNotPresent, are allowed with a warning: a node that has
+// pulled the tag before runs its cached image, even if the tag has since been
+// moved. Set imagePullPolicy: Always, or pin the image to a digest.
+//
+// EnforcePullPolicyForDigestImages inspects init, regular & ephemeral
+// containers, in Pods and the Pod templates of Deployments, StatefulSets,
+// DaemonSets, ReplicaSets, ReplicationControllers, Jobs & CronJobs. Unknown
+// object kinds are answered according to AdmissionHandler.UnknownKinds (allowed
+// by default).
+func EnforcePullPolicyForDigestImages(ignoredNamespaces []string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		for _, container := range pod.containers() {
+			pinned := parseImageReference(container.Image).digest != ""
+			switch {
+			case pinned && container.ImagePullPolicy == core.PullAlways:
+				violations.Add(container.field+".imagePullPolicy", fmt.Sprintf("%s is pinned to a digest, which cannot change: set imagePullPolicy: %s rather than %s", container.Image, core.PullIfNotPresent, core.PullAlways))
+			case !pinned && container.ImagePullPolicy == core.PullIfNotPresent:
+				resp.Warnings = append(resp.Warnings, fmt.Sprintf("%s.imagePullPolicy: %s is not pinned to a digest, and nodes may run a stale image if its tag is moved: set imagePullPolicy: %s rather than %s, or pin the image to a digest", container.field, container.Image, core.PullAlways, core.PullIfNotPresent))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s always pulls images that are pinned to a digest", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// imageReference is a container image reference - e.g.
+// "registry.example.com:5000/team/app:v1.0.0@sha256:..." - split into its
+// components.
+type imageReference struct {
+	// The repository, including the registry host (and port) if present.
+	repository string
+	tag        string
+	digest     string
+}
+
+// parseImageReference splits an image reference into its repository, tag and
+// digest. The tag & digest are empty if not present. A registry port is not
+// mistaken for a tag, as the tag can only appear after the last path component
+// separator ("/").
+func parseImageReference(image string) imageReference {
+	ref := imageReference{repository: image}
+	if i := strings.Index(ref.repository, "@"); i >= 0 {
+		ref.repository, ref.digest = ref.repository[:i], ref.repository[i+1:]
+	}
+
+	if i := strings.LastIndex(ref.repository, ":"); i > strings.LastIndex(ref.repository, "/") {
+		ref.repository, ref.tag = ref.repository[:i], ref.repository[i+1:]
+	}
+
+	return ref
+}
+
+// EnforceTerminationGracePeriod denies Pods with a
+// terminationGracePeriodSeconds outside of the (inclusive) min & max bounds.
+// Zero-second grace periods force-kill containers, which can corrupt stateful
+// workloads, whilst very long grace periods stall node drains.
+//
+// Pods that do not set terminationGracePeriodSeconds are evaluated using the
+// Kubernetes default of 30 seconds.
+//
+// EnforceTerminationGracePeriod can inspect Pods and the Pod templates of
+// Deployments, StatefulSets, DaemonSets, ReplicaSets, ReplicationControllers,
+// Jobs & CronJobs. Unknown object kinds are answered according to
+// AdmissionHandler.UnknownKinds (allowed by default).
+func EnforceTerminationGracePeriod(ignoredNamespaces []string, min, max int64) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		gracePeriod := int64(core.DefaultTerminationGracePeriodSeconds)
+		if pod.spec.TerminationGracePeriodSeconds != nil {
+			gracePeriod = *pod.spec.TerminationGracePeriodSeconds
+		}
+
+		if gracePeriod < min || gracePeriod > max {
+			return resp, xerrors.Errorf(
+				"%s %s/%s requests a terminationGracePeriodSeconds of %d: it must be between %d and %d seconds",
+				kind,
+				pod.namespace,
+				pod.name,
+				gracePeriod,
+				min,
+				max,
+			)
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// EnforceAntiAffinityTopologyKey requires the pod anti-affinity terms of Pods to
+// use the requiredKey as their topologyKey - e.g. "kubernetes.io/hostname" or
+// "topology.kubernetes.io/zone". Anti-affinity against a topology key that
+// nodes are not labeled with silently has no effect.
+//
+// Both required and preferred anti-affinity terms are inspected. Pods that do
+// not declare pod anti-affinity are allowed.
+//
+// EnforceAntiAffinityTopologyKey inspects Pods and the Pod templates of
+// Deployments, StatefulSets, DaemonSets, ReplicaSets, ReplicationControllers,
+// Jobs & CronJobs. Unknown object kinds are answered according to
+// AdmissionHandler.UnknownKinds (allowed by default).
+func EnforceAntiAffinityTopologyKey(ignoredNamespaces []string, requiredKey string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		if pod.spec.Affinity == nil || pod.spec.Affinity.PodAntiAffinity == nil {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		antiAffinity := pod.spec.Affinity.PodAntiAffinity
+		field := pod.specPath + ".affinity.podAntiAffinity"
+
+		var violations ViolationList
+		for i, term := range antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			if term.TopologyKey != requiredKey {
+				violations.Add(
+					fmt.Sprintf("%s.requiredDuringSchedulingIgnoredDuringExecution[%d].topologyKey", field, i),
+					fmt.Sprintf("%q is not the required topology key", term.TopologyKey),
+				)
+			}
+		}
+
+		for i, term := range antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+			if term.PodAffinityTerm.TopologyKey != requiredKey {
+				violations.Add(
+					fmt.Sprintf("%s.preferredDuringSchedulingIgnoredDuringExecution[%d].podAffinityTerm.topologyKey", field, i),
+					fmt.Sprintf("%q is not the required topology key", term.PodAffinityTerm.TopologyKey),
+				)
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s must use the %q topology key for pod anti-affinity", kind, pod.namespace, pod.name, requiredKey))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// controlPlaneNodeRoles are the labels (and taints) of control-plane nodes,
+// including the legacy "master" role.
+var controlPlaneNodeRoles = []string{
+	"node-role.kubernetes.io/control-plane",
+	"node-role.kubernetes.io/master",
+}
+
+// isControlPlaneNodeRole reports whether the key is a control-plane node role.
+func isControlPlaneNodeRole(key string) bool {
+	for _, role := range controlPlaneNodeRoles {
+		if key == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DenyControlPlaneScheduling denies Pods that could be scheduled onto
+// control-plane nodes, which are reserved for cluster components. Pods are
+// denied if they:
+//
+//   - tolerate the control-plane (or legacy master) taint, including via a
+//     toleration with an empty key & the Exists operator, which tolerates all
+//     taints;
+//   - select control-plane nodes via their nodeSelector; or
+//   - target control-plane nodes via (required or preferred) node affinity.
+//
+// System components that must run on control-plane nodes should be allowed via
+// the ignoredNamespaces (e.g. kube-system).
+//
+// DenyControlPlaneScheduling inspects Pods and the Pod templates of
+// Deployments, StatefulSets, DaemonSets, ReplicaSets, ReplicationControllers,
+// Jobs & CronJobs. Unknown object kinds are answered according to
+// AdmissionHandler.UnknownKinds (allowed by default).
+func DenyControlPlaneScheduling(ignoredNamespaces []string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		for i, toleration := range pod.spec.Tolerations {
+			field := fmt.Sprintf("%s.tolerations[%d]", pod.specPath, i)
+			switch {
+			case toleration.Key == "" && toleration.Operator == core.TolerationOpExists:
+				violations.Add(field, "tolerates all taints, including the control-plane taint")
+			case isControlPlaneNodeRole(toleration.Key):
+				violations.Add(field, fmt.Sprintf("tolerates the %s taint", toleration.Key))
+			}
+		}
+
+		keys := make([]string, 0, len(pod.spec.NodeSelector))
+		for key := range pod.spec.NodeSelector {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if isControlPlaneNodeRole(key) {
+				violations.Add(fmt.Sprintf("%s.nodeSelector[%s]", pod.specPath, key), "selects control-plane nodes")
+			}
+		}
+
+		if affinity := pod.spec.Affinity; affinity != nil && affinity.NodeAffinity != nil {
+			field := pod.specPath + ".affinity.nodeAffinity"
+			if required := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution; required != nil {
+				for i, term := range required.NodeSelectorTerms {
+					checkControlPlaneNodeSelectorTerm(&violations, fmt.Sprintf("%s.requiredDuringSchedulingIgnoredDuringExecution.nodeSelectorTerms[%d]", field, i), term)
+				}
+			}
+
+			for i, preferred := range affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+				checkControlPlaneNodeSelectorTerm(&violations, fmt.Sprintf("%s.preferredDuringSchedulingIgnoredDuringExecution[%d].preference", field, i), preferred.Preference)
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s targets control-plane nodes, which are reserved for cluster components", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// checkControlPlaneNodeSelectorTerm adds a violation for each expression of the
+// node selector term that targets control-plane nodes.
+func checkControlPlaneNodeSelectorTerm(violations *ViolationList, field string, term core.NodeSelectorTerm) {
+	for i, expression := range term.MatchExpressions {
+		if !isControlPlaneNodeRole(expression.Key) {
+			continue
+		}
+
+		// NotIn & DoesNotExist steer Pods away from control-plane nodes.
+		if expression.Operator == core.NodeSelectorOpIn || expression.Operator == core.NodeSelectorOpExists {
+			violations.Add(fmt.Sprintf("%s.matchExpressions[%d]", field, i), fmt.Sprintf("selects control-plane nodes (%s %s)", expression.Key, expression.Operator))
+		}
+	}
+}
+
+// RequireOwnerReferences denies the creation of objects of the given kind that
+// do not have an ownerReference, so that they are garbage collected along with
+// their owner - e.g. the child objects of a custom resource.
+//
+// If allowedOwners are provided, an ownerReference to one of the allowed owner
+// kinds is required. Objects are matched on the group & kind of the gvk, in any
+// version.
+//
+// Kinds other than the given kind, and operations other than CREATE, will be
+// allowed.
+func RequireOwnerReferences(ignoredNamespaces []string, gvk schema.GroupVersionKind, allowedOwners ...schema.GroupKind) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind.Group != gvk.Group || kind.Kind != gvk.Kind || admissionReview.Request.Operation != admission.Create {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		object, err := decodeObjectMeta(admissionReview)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(object.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", object.Namespace)
+			return resp, nil
+		}
+
+		if len(object.OwnerReferences) == 0 {
+			return resp, xerrors.Errorf(
+				"%s %s/%s must have an ownerReference, so that it is garbage collected along with its owner",
+				kind.Kind,
+				object.Namespace,
+				object.Name,
+			)
+		}
+
+		if len(allowedOwners) == 0 {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		for _, owner := range object.OwnerReferences {
+			gv, err := schema.ParseGroupVersion(owner.APIVersion)
+			if err != nil {
+				continue
+			}
+
+			for _, allowed := range allowedOwners {
+				if gv.Group == allowed.Group && owner.Kind == allowed.Kind {
+					resp.Allowed = true
+					return resp, nil
+				}
+			}
+		}
+
+		owners := make([]string, 0, len(allowedOwners))
+		for _, allowed := range allowedOwners {
+			owners = append(owners, allowed.String())
+		}
+
+		return resp, xerrors.Errorf(
+			"%s %s/%s must have an ownerReference to one of %s, so that it is garbage collected along with its owner",
+			kind.Kind,
+			object.Namespace,
+			object.Name,
+			strings.Join(owners, ", "),
+		)
+	}
+}
+
+// ProtectFinalizers denies UPDATE operations that remove any of the
+// protectedFinalizers from an object. Finalizers guard the cleanup of external
+// (e.g. cloud) resources: removing them by hand orphans those resources.
+//
+// The controller responsible for a finalizer must still be able to remove it:
+// wrap the AdmitFunc with BypassForServiceAccounts to allow its service
+// account, e.g.
+//
+//	BypassForServiceAccounts(
+//		ProtectFinalizers(nil, []string{"example.com/cleanup"}),
+//		"example-system/example-controller",
+//	)
+//
+// Objects of any kind are inspected. Operations other than UPDATE will be
+// allowed.
+func ProtectFinalizers(ignoredNamespaces []string, protectedFinalizers []string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if admissionReview.Request.Operation != admission.Update {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		object, err := decodeObjectMeta(admissionReview)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(object.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", object.Namespace)
+			return resp, nil
+		}
+
+		oldObject := metav1.PartialObjectMetadata{}
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.OldObject.Raw, nil, &oldObject); err != nil {
+			return nil, err
+		}
+
+		remaining := make(map[string]bool, len(object.Finalizers))
+		for _, finalizer := range object.Finalizers {
+			remaining[finalizer] = true
+		}
+
+		var removed []string
+		for _, finalizer := range oldObject.Finalizers {
+			if remaining[finalizer] {
+				continue
+			}
+
+			for _, protected := range protectedFinalizers {
+				if finalizer == protected {
+					removed = append(removed, fmt.Sprintf("%q", finalizer))
+					break
+				}
+			}
+		}
+
+		if len(removed) > 0 {
+			return resp, xerrors.Errorf(
+				"%s %s/%s: removing the %s finalizer(s) is not allowed: protected finalizers ensure that external resources are cleaned up, and may only be removed by their controller",
+				kind,
+				object.Namespace,
+				object.Name,
+				strings.Join(removed, ", "),
+			)
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// EnforceContainerArgLimits caps the number of environment variables, and the
+// total length (in bytes) of the command & args, of each container. Generated
+// manifests with thousands of environment variables (or huge arguments) bloat
+// the objects stored in etcd.
+//
+// A maxEnvVars or maxArgLength of 0 disables the respective check. Environment
+// variables imported via envFrom are not counted.
+//
+// EnforceContainerArgLimits inspects init, regular & ephemeral containers, in
+// Pods and the Pod templates of Deployments, StatefulSets, DaemonSets,
+// ReplicaSets, ReplicationControllers, Jobs & CronJobs. Unknown object kinds
+// are answered according to AdmissionHandler.UnknownKinds (allowed by default).
+func EnforceContainerArgLimits(ignoredNamespaces []string, maxEnvVars int, maxArgLength int) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		for _, container := range pod.containers() {
+			if maxEnvVars > 0 && len(container.Env) > maxEnvVars {
+				violations.Add(container.field+".env", fmt.Sprintf("declares %d environment variables (limit: %d)", len(container.Env), maxEnvVars))
+			}
+
+			var argLength int
+			for _, arg := range container.Command {
+				argLength += len(arg)
+			}
+			for _, arg := range container.Args {
+				argLength += len(arg)
+			}
+
+			if maxArgLength > 0 && argLength > maxArgLength {
+				violations.Add(container.field+".args", fmt.Sprintf("the command & args total %d bytes (limit: %d)", argLength, maxArgLength))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s has containers that exceed the environment & argument limits", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// ValidateResourceQuantities denies containers whose resource requests exceed
+// their limits (e.g. "requests.cpu 2 > limits.cpu 1"), or that specify zero or
+// negative quantities. Such containers are unschedulable, or starved of
+// resources once running.
+//
+// ValidateResourceQuantities inspects init, regular & ephemeral containers, in
+// Pods and the Pod templates of Deployments, StatefulSets, DaemonSets,
+// ReplicaSets, ReplicationControllers, Jobs & CronJobs. Unknown object kinds
+// are answered according to AdmissionHandler.UnknownKinds (allowed by default).
+func ValidateResourceQuantities(ignoredNamespaces []string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		for _, container := range pod.containers() {
+			field := container.field + ".resources"
+			requests, limits := container.Resources.Requests, container.Resources.Limits
+
+			for _, name := range sortedResourceNames(requests) {
+				if quantity := requests[name]; quantity.Sign() <= 0 {
+					violations.Add(field, fmt.Sprintf("requests.%s must be positive (got %s)", name, quantity.String()))
+				}
+			}
+
+			for _, name := range sortedResourceNames(limits) {
+				limit := limits[name]
+				if limit.Sign() <= 0 {
+					violations.Add(field, fmt.Sprintf("limits.%s must be positive (got %s)", name, limit.String()))
+					continue
+				}
+
+				if request, ok := requests[name]; ok && request.Cmp(limit) > 0 {
+					violations.Add(field, fmt.Sprintf("requests.%s %s > limits.%s %s", name, request.String(), name, limit.String()))
+				}
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s has containers with invalid resource quantities", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// sortedResourceNames returns the (sorted) resource names of the list.
+func sortedResourceNames(resources core.ResourceList) []core.ResourceName {
+	names := make([]core.ResourceName, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	return names
+}
+
+// RequireIngressHTTPSRedirect denies Ingresses that do not carry the HTTPS
+// (SSL) redirect annotations of the cluster's ingress controller(s), so that
+// plaintext requests are never served. As the annotation differs between
+// controllers, the controllerAnnotations map each required annotation key to
+// its expected value - e.g.
+//
+//	map[string]string{"nginx.ingress.kubernetes.io/ssl-redirect": "true"}
+//
+// Each of the controllerAnnotations is required.
+//
+// Kinds other than Ingress will be allowed.
+func RequireIngressHTTPSRedirect(ignoredNamespaces []string, controllerAnnotations map[string]string) AdmitFunc {
+	keys := make([]string, 0, len(controllerAnnotations))
+	for key := range controllerAnnotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "Ingress" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		ingress, err := decodeObjectMeta(admissionReview)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(ingress.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", ingress.Namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		for _, key := range keys {
+			expected := controllerAnnotations[key]
+			if value, ok := ingress.Annotations[key]; !ok || value != expected {
+				violations.Add(fmt.Sprintf("metadata.annotations[%s]", key), fmt.Sprintf("must be %q to redirect plaintext requests to HTTPS", expected))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s does not redirect to HTTPS", kind, ingress.Namespace, ingress.Name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// EnforceIngressAnnotations validates the ingress controller annotations of
+// Ingresses, which configure the controller directly: some annotations - such
+// as the ingress-nginx "nginx.ingress.kubernetes.io/configuration-snippet" -
+// allow arbitrary configuration to be injected into the controller, and others
+// (e.g. rate limits) must be bounded.
+//
+// Annotations in denied are always rejected. The allowed map is an allowlist
+// for each controller: the prefix of each of its keys (e.g.
+// "nginx.ingress.kubernetes.io/") identifies a controller, and annotations with
+// that prefix must be in the map, with a value accepted by its func. A nil func
+// allows any value. Annotations of other controllers, and other annotations,
+// are not evaluated.
+//
+// Kinds other than Ingress will be allowed.
+func EnforceIngressAnnotations(ignoredNamespaces []string, allowed map[string]func(string) bool, denied []string) AdmitFunc {
+	prefixes := make(map[string]bool)
+	for key := range allowed {
+		if i := strings.Index(key, "/"); i >= 0 {
+			prefixes[key[:i+1]] = true
+		}
+	}
+
+	isDenied := make(map[string]bool, len(denied))
+	for _, key := range denied {
+		isDenied[key] = true
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "Ingress" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		ingress, err := decodeObjectMeta(admissionReview)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(ingress.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", ingress.Namespace)
+			return resp, nil
+		}
+
+		keys := make([]string, 0, len(ingress.Annotations))
+		for key := range ingress.Annotations {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var violations ViolationList
+		for _, key := range keys {
+			field := fmt.Sprintf("metadata.annotations[%s]", key)
+			if isDenied[key] {
+				violations.Add(field, "the annotation is denied")
+				continue
+			}
+
+			matchFunc, ok := allowed[key]
+			if !ok {
+				if i := strings.Index(key, "/"); i >= 0 && prefixes[key[:i+1]] {
+					violations.Add(field, "the annotation is not in the allowlist for its ingress controller")
+				}
+				continue
+			}
+
+			if value := ingress.Annotations[key]; matchFunc != nil && !matchFunc(value) {
+				violations.Add(field, fmt.Sprintf("the value %q is invalid", value))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s has denied or invalid ingress controller annotations", kind, ingress.Namespace, ingress.Name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// nginxSnippetAnnotations are the ingress-nginx annotations that inject raw
+// configuration into the controller.
+var nginxSnippetAnnotations = []string{
+	"nginx.ingress.kubernetes.io/configuration-snippet",
+	"nginx.ingress.kubernetes.io/server-snippet",
+	"nginx.ingress.kubernetes.io/stream-snippet",
+}
+
+// DenyNginxSnippetAnnotations denies Ingresses carrying the ingress-nginx
+// configuration-snippet, server-snippet or stream-snippet annotations. Snippets
+// are inserted verbatim into the NGINX configuration of the controller, which
+// allows anyone who can create an Ingress to read the Secrets (including the
+// service account token) of the controller - see CVE-2021-25742.
+//
+// Namespaces that must use snippets (e.g. as a break-glass) can be allowed via
+// ignoredNamespaces.
+//
+// Kinds other than Ingress will be allowed.
+func DenyNginxSnippetAnnotations(ignoredNamespaces []string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "Ingress" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		ingress, err := decodeObjectMeta(admissionReview)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(ingress.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", ingress.Namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		for _, key := range nginxSnippetAnnotations {
+			if _, ok := ingress.Annotations[key]; ok {
+				violations.Add(fmt.Sprintf("metadata.annotations[%s]", key), "snippet annotations are not allowed")
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf(
+				"%s %s/%s uses ingress-nginx snippets, which inject raw NGINX configuration and can expose the controller's credentials (CVE-2021-25742)",
+				kind,
+				ingress.Namespace,
+				ingress.Name,
+			))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// decodeIngress decodes an Ingress of any version - networking.k8s.io/v1, or
+// the (deprecated) networking.k8s.io/v1beta1 & extensions/v1beta1 - into the
+// v1 type. As the versions differ only in how backends are declared, the hosts,
+// paths & TLS configuration of the Ingress are decoded, but its backends may
+// not be.
+func decodeIngress(raw []byte) (*networking.Ingress, error) {
+	ingress := networking.Ingress{}
+	if _, _, err := universalDeserializer.Decode(raw, nil, &ingress); err != nil {
+		return nil, err
+	}
+
+	return &ingress, nil
+}
+
+// EnforceIngressPathType restricts the pathType of each of the paths of an
+// Ingress to the allowed types - e.g. Exact & Prefix, disallowing
+// ImplementationSpecific, whose matching varies across (and between versions
+// of) ingress controllers. Paths without a pathType (allowed by the v1beta1
+// APIs) are ImplementationSpecific.
+//
+// Kinds other than Ingress will be allowed.
+func EnforceIngressPathType(ignoredNamespaces []string, allowed []networking.PathType) AdmitFunc {
+	allowedTypes := make([]string, 0, len(allowed))
+	for _, pathType := range allowed {
+		allowedTypes = append(allowedTypes, string(pathType))
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "Ingress" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		ingress, err := decodeIngress(admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(ingress.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", ingress.Namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		for i, rule := range ingress.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+
+			for j, ingressPath := range rule.HTTP.Paths {
+				pathType := networking.PathTypeImplementationSpecific
+				if ingressPath.PathType != nil {
+					pathType = *ingressPath.PathType
+				}
+
+				isAllowed := false
+				for _, allowedType := range allowed {
+					if pathType == allowedType {
+						isAllowed = true
+						break
+					}
+				}
+
+				if !isAllowed {
+					host := rule.Host
+					if host == "" {
+						host = "*"
+					}
+
+					violations.Add(
+						fmt.Sprintf("spec.rules[%d].http.paths[%d].pathType", i, j),
+						fmt.Sprintf("the path %s%s has the pathType %s: must be one of %s", host, ingressPath.Path, pathType, strings.Join(allowedTypes, ", ")),
+					)
+				}
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s has paths with disallowed pathTypes", kind, ingress.Namespace, ingress.Name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// EnforceMaxReplicas denies Deployments, StatefulSets and ReplicaSets that
+// request more than max replicas, guarding the cluster against typos (e.g.
+// "replicas: 1000") that would exhaust its capacity. Workloads that do not set
+// .spec.replicas default to a single replica.
+//
+// Changes to the replica count made via the scale subresource (e.g. kubectl
+// scale) are not seen by this AdmitFunc, unless the webhook is also registered
+// for the "deployments/scale" (etc.) subresources.
+//
+// Kinds other than Deployment, StatefulSet and ReplicaSet will be allowed.
+func EnforceMaxReplicas(ignoredNamespaces []string, max int32) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		var object metav1.Object
+		var replicas *int32
+		switch kind {
+		case "Deployment":
+			deployment := apps.Deployment{}
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &deployment); err != nil {
+				return nil, err
+			}
+
+			object, replicas = &deployment, deployment.Spec.Replicas
+		case "StatefulSet":
+			statefulset := apps.StatefulSet{}
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &statefulset); err != nil {
+				return nil, err
+			}
+
+			object, replicas = &statefulset, statefulset.Spec.Replicas
+		case "ReplicaSet":
+			replicaset := apps.ReplicaSet{}
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &replicaset); err != nil {
+				return nil, err
+			}
+
+			object, replicas = &replicaset, replicaset.Spec.Replicas
+		default:
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		if isIgnoredNamespace(object.GetNamespace(), ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", object.GetNamespace())
+			return resp, nil
+		}
+
+		requested := int32(1)
+		if replicas != nil {
+			requested = *replicas
+		}
+
+		if requested > max {
+			return resp, xerrors.Errorf(
+				"%s %s/%s requests %d replicas: the maximum is %d",
+				kind,
+				object.GetNamespace(),
+				object.GetName(),
+				requested,
+				max,
+			)
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// hpaResourceMetric is a resource utilization metric that a
+// HorizontalPodAutoscaler scales on. An empty container applies the metric to
+// every container in the Pod.
+type hpaResourceMetric struct {
+	resource  core.ResourceName
+	container string
+}
+
+// RequireResourceRequestsForHPA denies HorizontalPodAutoscalers that scale a
+// Deployment on resource utilization (e.g. CPU) if the Deployment's containers
+// do not declare requests for that resource: the utilization is computed
+// relative to the requests, so such HorizontalPodAutoscalers silently never
+// scale.
+//
+// HorizontalPodAutoscalers are supported at autoscaling/v1 (which scales on
+// CPU utilization) and autoscaling/v2beta2. The Deployments are read from the
+// provided SharedInformerFactory (see ClientOption), whose client must be
+// authorized to list & watch deployments across all namespaces.
+//
+// HorizontalPodAutoscalers created before their Deployment, and those that
+// target other kinds, will be allowed. Kinds other than
+// HorizontalPodAutoscaler, and operations other than CREATE and UPDATE, will
+// be allowed.
+func RequireResourceRequestsForHPA(factory informers.SharedInformerFactory, opts ...ClientOption) AdmitFunc {
+	deployments := factory.Apps().V1().Deployments()
+	lister := deployments.Lister()
+	synced := deployments.Informer().HasSynced
+	policy := newClientPolicy(opts)
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind
+		resp := newDefaultDenyResponse()
+
+		operation := admissionReview.Request.Operation
+		if kind.Kind != "HorizontalPodAutoscaler" || (operation != admission.Create && operation != admission.Update) {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		hpa, target, metrics, err := decodeHorizontalPodAutoscaler(kind.Version, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if target.Kind != "Deployment" || len(metrics) == 0 {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		if !policy.waitForCacheSync(synced) {
+			return policy.unavailable(resp, "the Deployment cache has not synced: cannot look up the scale target")
+		}
+
+		deployment, err := lister.Deployments(hpa.Namespace).Get(target.Name)
+		if apierrors.IsNotFound(err) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: the scale target Deployment %s/%s does not exist", hpa.Namespace, target.Name)
+			return resp, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		var violations ViolationList
+		var resources []string
+		for _, metric := range metrics {
+			resources = append(resources, string(metric.resource))
+			// Utilization is computed across the (regular) containers of the Pods.
+			for _, container := range deployment.Spec.Template.Spec.Containers {
+				if metric.container != "" && container.Name != metric.container {
+					continue
+				}
+
+				if _, ok := container.Resources.Requests[metric.resource]; !ok {
+					violations.Add(fmt.Sprintf("spec.template.spec.containers[%s].resources.requests.%s", container.Name, metric.resource), "must be set")
+				}
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf(
+				"HorizontalPodAutoscaler %s/%s scales on %s utilization, which requires resource requests on the containers of Deployment %s/%s",
+				hpa.Namespace,
+				hpa.Name,
+				strings.Join(resources, " & "),
+				deployment.Namespace,
+				deployment.Name,
+			))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// decodeHorizontalPodAutoscaler decodes a HorizontalPodAutoscaler of the given
+// (autoscaling) API version, returning its metadata, its scale target, and the
+// resource utilization metrics that it scales on.
+func decodeHorizontalPodAutoscaler(version string, raw []byte) (metav1.ObjectMeta, autoscalingv1.CrossVersionObjectReference, []hpaResourceMetric, error) {
+	switch version {
+	case "v1":
+		hpa := autoscalingv1.HorizontalPodAutoscaler{}
+		if _, _, err := universalDeserializer.Decode(raw, nil, &hpa); err != nil {
+			return metav1.ObjectMeta{}, autoscalingv1.CrossVersionObjectReference{}, nil, err
+		}
+
+		// autoscaling/v1 always scales on CPU utilization (defaulting to 80%).
+		return hpa.ObjectMeta, hpa.Spec.ScaleTargetRef, []hpaResourceMetric{{resource: core.ResourceCPU}}, nil
+	case "v2beta2":
+		hpa := autoscalingv2beta2.HorizontalPodAutoscaler{}
+		if _, _, err := universalDeserializer.Decode(raw, nil, &hpa); err != nil {
+			return metav1.ObjectMeta{}, autoscalingv1.CrossVersionObjectReference{}, nil, err
+		}
+
+		target := autoscalingv1.CrossVersionObjectReference(hpa.Spec.ScaleTargetRef)
+
+		// Without metrics, the HorizontalPodAutoscaler defaults to scaling on CPU
+		// utilization.
+		if len(hpa.Spec.Metrics) == 0 {
+			return hpa.ObjectMeta, target, []hpaResourceMetric{{resource: core.ResourceCPU}}, nil
+		}
+
+		var metrics []hpaResourceMetric
+		for _, metric := range hpa.Spec.Metrics {
+			switch {
+			case metric.Type == autoscalingv2beta2.ResourceMetricSourceType && metric.Resource != nil &&
+				metric.Resource.Target.Type == autoscalingv2beta2.UtilizationMetricType:
+				metrics = append(metrics, hpaResourceMetric{resource: metric.Resource.Name})
+			case metric.Type == autoscalingv2beta2.ContainerResourceMetricSourceType && metric.ContainerResource != nil &&
+				metric.ContainerResource.Target.Type == autoscalingv2beta2.UtilizationMetricType:
+				metrics = append(metrics, hpaResourceMetric{resource: metric.ContainerResource.Name, container: metric.ContainerResource.Container})
+			}
+		}
+
+		return hpa.ObjectMeta, target, metrics, nil
+	default:
+		return metav1.ObjectMeta{}, autoscalingv1.CrossVersionObjectReference{}, nil, unsupportedKind("HorizontalPodAutoscaler " + version)
+	}
+}
+
+const (
+	// defaultTokenExpirationSeconds is the expiration of projected service
+	// account tokens that do not set expirationSeconds.
+	defaultTokenExpirationSeconds = 3600
+	// injectedTokenExpirationSeconds is the expiration of the token that the
+	// API server projects into the kube-api-access-* volume of each Pod.
+	injectedTokenExpirationSeconds = 3607
+	// defaultMaxTokenExpiration is the maximum expiration of projected service
+	// account tokens if none is configured.
+	defaultMaxTokenExpiration = time.Hour * 24
+)
+
+// DenyServiceAccountTokenHostMount denies Pods that project service account
+// tokens with an expirationSeconds beyond maxExpiration, or that mount a
+// projected service account token writable. Long-lived (and modifiable)
+// tokens are a credential theft risk. Tokens without an expirationSeconds
+// expire after an hour.
+//
+// A maxExpiration of zero (or less) defaults to 24 hours. The expiration of
+// 3607 seconds that the API server sets on the kube-api-access-* volume it
+// injects into every Pod is always allowed, as the kubelet refreshes that
+// token long before it expires.
+//
+// DenyServiceAccountTokenHostMount inspects Pods and the Pod templates of
+// Deployments, StatefulSets, DaemonSets, ReplicaSets, ReplicationControllers,
+// Jobs & CronJobs. Unknown object kinds are answered according to
+// AdmissionHandler.UnknownKinds (allowed by default).
+func DenyServiceAccountTokenHostMount(ignoredNamespaces []string, maxExpiration time.Duration) AdmitFunc {
+	if maxExpiration <= 0 {
+		maxExpiration = defaultMaxTokenExpiration
+	}
+	maxExpirationSeconds := int64(maxExpiration / time.Second)
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		tokenVolumes := make(map[string]bool)
+		for _, volume := range pod.spec.Volumes {
+			if volume.Projected == nil {
+				continue
+			}
+
+			for i, source := range volume.Projected.Sources {
+				if source.ServiceAccountToken == nil {
+					continue
+				}
+				tokenVolumes[volume.Name] = true
+
+				expirationSeconds := int64(defaultTokenExpirationSeconds)
+				if source.ServiceAccountToken.ExpirationSeconds != nil {
+					expirationSeconds = *source.ServiceAccountToken.ExpirationSeconds
+				}
+
+				if expirationSeconds > maxExpirationSeconds && expirationSeconds != injectedTokenExpirationSeconds {
+					violations.Add(
+						fmt.Sprintf("%s.volumes[%s].projected.sources[%d].serviceAccountToken.expirationSeconds", pod.specPath, volume.Name, i),
+						fmt.Sprintf("%d exceeds the maximum of %d", expirationSeconds, maxExpirationSeconds),
+					)
+				}
+			}
+		}
+
+		for _, container := range pod.containers() {
+			for _, mount := range container.VolumeMounts {
+				if tokenVolumes[mount.Name] && !mount.ReadOnly {
+					violations.Add(fmt.Sprintf("%s.volumeMounts[%s]", container.field, mount.Name), "service account tokens must be mounted read-only")
+				}
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s projects service account tokens insecurely", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// RequireInitContainer denies Pods that carry the triggerAnnotation, but do not
+// declare an init container with the given name: e.g. a mandatory init
+// container that fetches secrets. This complements a mutating webhook that
+// injects the init container, for teams that prefer explicit configuration.
+//
+// The annotation is read from the Pod, or from the Pod template of a workload
+// controller: its value is ignored.
+//
+// RequireInitContainer inspects Pods and the Pod templates of Deployments,
+// StatefulSets, DaemonSets, ReplicaSets, ReplicationControllers, Jobs &
+// CronJobs. Unknown object kinds are answered according to
+// AdmissionHandler.UnknownKinds (allowed by default).
+func RequireInitContainer(ignoredNamespaces []string, name string, triggerAnnotation string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		if _, ok := pod.meta.Annotations[triggerAnnotation]; !ok {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		for _, container := range pod.spec.InitContainers {
+			if container.Name == name {
+				resp.Allowed = true
+				return resp, nil
+			}
+		}
+
+		return resp, xerrors.Errorf(
+			"%s %s/%s carries the %s annotation, and must declare the init container %q (in %s.initContainers)",
+			kind,
+			pod.namespace,
+			pod.name,
+			triggerAnnotation,
+			name,
+			pod.specPath,
+		)
+	}
+}
+
+// DenyDuplicateContainerPorts denies Pods where two containers (or a container
+// twice) declare the same containerPort & protocol, or the same port name,
+// which leads to confusing behavior: e.g. a Service targeting a named port that
+// resolves to the "wrong" container.
+//
+// DenyDuplicateContainerPorts inspects init, regular & ephemeral containers, in
+// Pods and the Pod templates of Deployments, StatefulSets, DaemonSets,
+// ReplicaSets, ReplicationControllers, Jobs & CronJobs. Unknown object kinds
+// are answered according to AdmissionHandler.UnknownKinds (allowed by default).
+func DenyDuplicateContainerPorts(ignoredNamespaces []string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		// The field of the first declaration of each port, and of each port name.
+		ports := make(map[string]string)
+		names := make(map[string]string)
+
+		var violations ViolationList
+		for _, container := range pod.containers() {
+			for i, port := range container.Ports {
+				field := fmt.Sprintf("%s.ports[%d]", container.field, i)
+
+				protocol := port.Protocol
+				if protocol == "" {
+					protocol = core.ProtocolTCP
+				}
+
+				key := fmt.Sprintf("%d/%s", port.ContainerPort, protocol)
+				if declared, ok := ports[key]; ok {
+					violations.Add(field, fmt.Sprintf("containerPort %s is also declared by %s", key, declared))
+				} else {
+					ports[key] = field
+				}
+
+				if port.Name == "" {
+					continue
+				}
+
+				if declared, ok := names[port.Name]; ok {
+					violations.Add(field, fmt.Sprintf("the port name %q is also declared by %s", port.Name, declared))
+				} else {
+					names[port.Name] = field
+				}
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s declares conflicting container ports", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// DenyServicesWithoutSelector denies Services with an empty .spec.selector,
+// which (without manually managed Endpoints) never route traffic: a common
+// source of "the Service returns nothing" tickets.
+//
+// If allowHeadlessAndExternalName is true, headless Services (clusterIP: None)
+// and Services of type ExternalName may omit their selector - e.g. for
+// Services backed by a StatefulSet's manually managed Endpoints, or an external
+// DNS name.
+//
+// Kinds other than Service will be allowed.
+func DenyServicesWithoutSelector(ignoredNamespaces []string, allowHeadlessAndExternalName bool) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "Service" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		service := core.Service{}
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &service); err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(service.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", service.Namespace)
+			return resp, nil
+		}
+
+		if len(service.Spec.Selector) > 0 {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		headless := service.Spec.ClusterIP == core.ClusterIPNone
+		if allowHeadlessAndExternalName && (headless || service.Spec.Type == core.ServiceTypeExternalName) {
+			resp.Allowed = true
+			resp.Result.Message = "allowing admission: headless & ExternalName Services may omit their selector"
+			return resp, nil
+		}
+
+		return resp, xerrors.Errorf(
+			"Service %s/%s has an empty .spec.selector: it will not route traffic to any Pods, and must select the Pods that back it",
+			service.Namespace,
+			service.Name,
+		)
+	}
+}
+
+// defaultStorageClassAnnotation marks the default StorageClass of a cluster.
+const defaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+
+// ValidatePVCAccessModes denies PersistentVolumeClaims that request an access
+// mode that the provisioner of their StorageClass cannot satisfy - e.g.
+// ReadWriteMany on a block storage class - which otherwise remain Pending
+// forever. As provisioners do not advertise the access modes they support, the
+// provisionerAccessModes map each provisioner (e.g. "ebs.csi.aws.com") to its
+// supported access modes. Claims for StorageClasses with other provisioners
+// are allowed.
+//
+// Claims without a storageClassName use the cluster's default StorageClass,
+// and claims with an empty storageClassName (binding to pre-provisioned
+// PersistentVolumes) are allowed, as are claims for StorageClasses that do not
+// exist (yet).
+//
+// The StorageClasses are read from the provided SharedInformerFactory (see
+// ClientOption), whose client must be authorized to list & watch
+// storageclasses.
+//
+// Kinds other than PersistentVolumeClaim, and operations other than CREATE,
+// will be allowed.
+func ValidatePVCAccessModes(factory informers.SharedInformerFactory, ignoredNamespaces []string, provisionerAccessModes map[string][]core.PersistentVolumeAccessMode, opts ...ClientOption) AdmitFunc {
+	storageClasses := factory.Storage().V1().StorageClasses()
+	lister := storageClasses.Lister()
+	synced := storageClasses.Informer().HasSynced
+	policy := newClientPolicy(opts)
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "PersistentVolumeClaim" || admissionReview.Request.Operation != admission.Create {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		claim := core.PersistentVolumeClaim{}
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &claim); err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(claim.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", claim.Namespace)
+			return resp, nil
+		}
+
+		if claim.Spec.StorageClassName != nil && *claim.Spec.StorageClassName == "" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		if !policy.waitForCacheSync(synced) {
+			return policy.unavailable(resp, "the StorageClass cache has not synced: cannot look up the storage class")
+		}
+
+		var class *storage.StorageClass
+		if claim.Spec.StorageClassName != nil {
+			var err error
+			class, err = lister.Get(*claim.Spec.StorageClassName)
+			if apierrors.IsNotFound(err) {
+				resp.Allowed = true
+				resp.Result.Message = fmt.Sprintf("allowing admission: the StorageClass %s does not exist", *claim.Spec.StorageClassName)
+				return resp, nil
+			} else if err != nil {
+				return nil, err
+			}
+		} else {
+			classes, err := lister.List(labels.Everything())
+			if err != nil {
+				return nil, err
+			}
+
+			for _, candidate := range classes {
+				if candidate.Annotations[defaultStorageClassAnnotation] == "true" {
+					class = candidate
+					break
+				}
+			}
+
+			if class == nil {
+				resp.Allowed = true
+				resp.Result.Message = "allowing admission: the cluster has no default StorageClass"
+				return resp, nil
+			}
+		}
+
+		supported, ok := provisionerAccessModes[class.Provisioner]
+		if !ok {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		var unsupported []string
+		for _, mode := range claim.Spec.AccessModes {
+			if !containsAccessMode(supported, mode) {
+				unsupported = append(unsupported, string(mode))
+			}
+		}
+
+		if len(unsupported) > 0 {
+			supportedModes := make([]string, 0, len(supported))
+			for _, mode := range supported {
+				supportedModes = append(supportedModes, string(mode))
+			}
+
+			return resp, xerrors.Errorf(
+				"PersistentVolumeClaim %s/%s requests the %s access mode(s), which the StorageClass %s (provisioner %s) cannot satisfy: the supported access modes are %s",
+				claim.Namespace,
+				claim.Name,
+				strings.Join(unsupported, ", "),
+				class.Name,
+				class.Provisioner,
+				strings.Join(supportedModes, ", "),
+			)
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// containsAccessMode returns true if the mode is one of the modes.
+func containsAccessMode(modes []core.PersistentVolumeAccessMode, mode core.PersistentVolumeAccessMode) bool {
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EnforceMetadataLimits denies objects (of any kind) with more than maxLabels
+// labels or maxAnnotations annotations, or whose annotations (keys & values)
+// total more than maxAnnotationBytes. Oversized metadata - e.g. large
+// generated annotations - bloats etcd and slows down the API server. A limit
+// of 0 disables the corresponding check.
+func EnforceMetadataLimits(ignoredNamespaces []string, maxLabels, maxAnnotations, maxAnnotationBytes int) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		object, err := decodeObjectMeta(admissionReview)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(object.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", object.Namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		if maxLabels > 0 && len(object.Labels) > maxLabels {
+			violations.Add("metadata.labels", fmt.Sprintf("%d labels exceed the maximum of %d", len(object.Labels), maxLabels))
+		}
+
+		if maxAnnotations > 0 && len(object.Annotations) > maxAnnotations {
+			violations.Add("metadata.annotations", fmt.Sprintf("%d annotations exceed the maximum of %d", len(object.Annotations), maxAnnotations))
+		}
+
+		if maxAnnotationBytes > 0 {
+			var size int
+			for key, value := range object.Annotations {
+				size += len(key) + len(value)
+			}
+
+			if size > maxAnnotationBytes {
+				violations.Add("metadata.annotations", fmt.Sprintf("%d bytes of annotations exceed the maximum of %d bytes", size, maxAnnotationBytes))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s exceeds the metadata limits", kind, qualifiedName(object)))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// ValidateEnvValueFrom denies Pods with environment variables that reference a
+// key of a ConfigMap or Secret (via env[].valueFrom) that does not exist in the
+// referenced object, unless the reference is optional. Such typos otherwise
+// surface as containers that fail to start (CreateContainerConfigError).
+//
+// References to ConfigMaps and Secrets that do not exist (yet) are allowed, as
+// they may be created after the Pod: only the keys of existing objects are
+// validated.
+//
+// The ConfigMaps and Secrets are read from the provided SharedInformerFactory
+// (see ClientOption), whose client must be authorized to list & watch
+// configmaps and secrets across all namespaces. Note that this caches the
+// contents of every Secret in the webhook's memory.
+//
+// ValidateEnvValueFrom inspects init, regular & ephemeral containers, in Pods
+// and the Pod templates of Deployments, StatefulSets, DaemonSets, ReplicaSets,
+// ReplicationControllers, Jobs & CronJobs. Unknown object kinds are answered
+// according to AdmissionHandler.UnknownKinds (allowed by default).
+func ValidateEnvValueFrom(factory informers.SharedInformerFactory, ignoredNamespaces []string, opts ...ClientOption) AdmitFunc {
+	configMaps := factory.Core().V1().ConfigMaps()
+	secrets := factory.Core().V1().Secrets()
+	configMapLister, secretLister := configMaps.Lister(), secrets.Lister()
+	synced := []cache.InformerSynced{configMaps.Informer().HasSynced, secrets.Informer().HasSynced}
+	policy := newClientPolicy(opts)
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		namespace := pod.namespace
+		if namespace == "" {
+			namespace = admissionReview.Request.Namespace
+		}
+
+		if isIgnoredNamespace(namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", namespace)
+			return resp, nil
+		}
+
+		if !policy.waitForCacheSync(synced...) {
+			return policy.unavailable(resp, "the ConfigMap & Secret caches have not synced: cannot validate the referenced keys")
+		}
+
+		var violations ViolationList
+		for _, container := range pod.containers() {
+			for i, env := range container.Env {
+				if env.ValueFrom == nil {
+					continue
+				}
+				field := fmt.Sprintf("%s.env[%d].valueFrom", container.field, i)
+
+				if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil && (ref.Optional == nil || !*ref.Optional) {
+					configMap, err := configMapLister.ConfigMaps(namespace).Get(ref.Name)
+					if apierrors.IsNotFound(err) {
+						continue
+					} else if err != nil {
+						return nil, err
+					}
+
+					_, inData := configMap.Data[ref.Key]
+					_, inBinaryData := configMap.BinaryData[ref.Key]
+					if !inData && !inBinaryData {
+						violations.Add(field+".configMapKeyRef", fmt.Sprintf("the key %q does not exist in ConfigMap %s (for %s)", ref.Key, ref.Name, env.Name))
+					}
+				}
+
+				if ref := env.ValueFrom.SecretKeyRef; ref != nil && (ref.Optional == nil || !*ref.Optional) {
+					secret, err := secretLister.Secrets(namespace).Get(ref.Name)
+					if apierrors.IsNotFound(err) {
+						continue
+					} else if err != nil {
+						return nil, err
+					}
+
+					if _, ok := secret.Data[ref.Key]; !ok {
+						violations.Add(field+".secretKeyRef", fmt.Sprintf("the key %q does not exist in Secret %s (for %s)", ref.Key, ref.Name, env.Name))
+					}
+				}
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s references keys that do not exist", kind, namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// EnforceRuntimeClasses restricts the .spec.runtimeClassName of Pods to the
+// allowed RuntimeClasses - e.g. a sandboxed runtime such as gVisor for
+// untrusted tenants. If requireRuntimeClass is true, Pods must also set a
+// runtimeClassName, rather than using the node's default runtime.
+//
+// EnforceRuntimeClasses inspects Pods and the Pod templates of Deployments,
+// StatefulSets, DaemonSets, ReplicaSets, ReplicationControllers, Jobs &
+// CronJobs. Unknown object kinds are answered according to
+// AdmissionHandler.UnknownKinds (allowed by default).
+func EnforceRuntimeClasses(ignoredNamespaces []string, allowed []string, requireRuntimeClass bool) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		runtimeClass := pod.spec.RuntimeClassName
+		if runtimeClass == nil || *runtimeClass == "" {
+			if requireRuntimeClass {
+				return resp, xerrors.Errorf(
+					"%s %s/%s must set %s.runtimeClassName: the allowed RuntimeClasses are %s",
+					kind,
+					pod.namespace,
+					pod.name,
+					pod.specPath,
+					strings.Join(allowed, ", "),
+				)
+			}
+
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		for _, class := range allowed {
+			if *runtimeClass == class {
+				resp.Allowed = true
+				return resp, nil
+			}
+		}
+
+		return resp, xerrors.Errorf(
+			"%s %s/%s requests the RuntimeClass %q, which is not allowed: the allowed RuntimeClasses are %s",
+			kind,
+			pod.namespace,
+			pod.name,
+			*runtimeClass,
+			strings.Join(allowed, ", "),
+		)
+	}
+}
+
+// DefaultDeniedDownwardAPIFieldPaths are the downward API field paths denied by
+// DenyDownwardAPISensitiveFields when no field paths are provided: the Pod's
+// annotations, which tooling often uses to carry credentials or configuration
+// (e.g. kubectl.kubernetes.io/last-applied-configuration).
+var DefaultDeniedDownwardAPIFieldPaths = []string{"metadata.annotations"}
+
+// DenyDownwardAPISensitiveFields denies Pods that expose any of the
+// deniedFieldPaths to their containers via the downward API: either as
+// environment variables (env[].valueFrom.fieldRef), or as files in downwardAPI
+// (or projected) volumes. A denied field path also denies the individual keys
+// beneath it: e.g. "metadata.annotations" denies
+// "metadata.annotations['example.com/token']". If deniedFieldPaths is empty,
+// the DefaultDeniedDownwardAPIFieldPaths are denied.
+//
+// DenyDownwardAPISensitiveFields inspects Pods and the Pod templates of
+// Deployments, StatefulSets, DaemonSets, ReplicaSets, ReplicationControllers,
+// Jobs & CronJobs. Unknown object kinds are answered according to
+// AdmissionHandler.UnknownKinds (allowed by default).
+func DenyDownwardAPISensitiveFields(ignoredNamespaces []string, deniedFieldPaths []string) AdmitFunc {
+	if len(deniedFieldPaths) == 0 {
+		deniedFieldPaths = DefaultDeniedDownwardAPIFieldPaths
+	}
+
+	isDenied := func(fieldPath string) bool {
+		for _, denied := range deniedFieldPaths {
+			if fieldPath == denied || strings.HasPrefix(fieldPath, denied+"[") {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		checkItems := func(field string, items []core.DownwardAPIVolumeFile) {
+			for i, item := range items {
+				if item.FieldRef != nil && isDenied(item.FieldRef.FieldPath) {
+					violations.Add(fmt.Sprintf("%s.items[%d].fieldRef.fieldPath", field, i), fmt.Sprintf("%s must not be exposed via the downward API", item.FieldRef.FieldPath))
+				}
+			}
+		}
+
+		for _, container := range pod.containers() {
+			for i, env := range container.Env {
+				if env.ValueFrom != nil && env.ValueFrom.FieldRef != nil && isDenied(env.ValueFrom.FieldRef.FieldPath) {
+					violations.Add(fmt.Sprintf("%s.env[%d].valueFrom.fieldRef.fieldPath", container.field, i), fmt.Sprintf("%s must not be exposed via the downward API", env.ValueFrom.FieldRef.FieldPath))
+				}
+			}
+		}
+
+		for _, volume := range pod.spec.Volumes {
+			field := fmt.Sprintf("%s.volumes[%s]", pod.specPath, volume.Name)
+			if volume.DownwardAPI != nil {
+				checkItems(field+".downwardAPI", volume.DownwardAPI.Items)
+			}
+
+			if volume.Projected != nil {
+				for i, source := range volume.Projected.Sources {
+					if source.DownwardAPI != nil {
+						checkItems(fmt.Sprintf("%s.projected.sources[%d].downwardAPI", field, i), source.DownwardAPI.Items)
+					}
+				}
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s exposes sensitive fields via the downward API", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// RequirePDBForWorkload denies the creation of Deployments and StatefulSets
+// with at least minReplicas replicas unless a PodDisruptionBudget in the same
+// namespace selects their Pods. Without a PodDisruptionBudget, a node drain can
+// evict all of the replicas of a "highly available" workload at once.
+// Workloads that do not set .spec.replicas default to a single replica.
+//
+// The PodDisruptionBudgets (policy/v1, served by Kubernetes 1.21+) are read
+// from the provided SharedInformerFactory (see ClientOption), whose client must
+// be authorized to list & watch poddisruptionbudgets across all namespaces.
+//
+// Kinds other than Deployment and StatefulSet, and operations other than
+// CREATE, will be allowed.
+func RequirePDBForWorkload(factory informers.SharedInformerFactory, ignoredNamespaces []string, minReplicas int32, opts ...ClientOption) AdmitFunc {
+	pdbs := factory.Policy().V1().PodDisruptionBudgets()
+	lister := pdbs.Lister()
+	synced := pdbs.Informer().HasSynced
+	policy := newClientPolicy(opts)
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if admissionReview.Request.Operation != admission.Create {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		var object metav1.Object
+		var replicas *int32
+		var template *core.PodTemplateSpec
+		switch kind {
+		case "Deployment":
+			deployment := apps.Deployment{}
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &deployment); err != nil {
+				return nil, err
+			}
+
+			object, replicas, template = &deployment, deployment.Spec.Replicas, &deployment.Spec.Template
+		case "StatefulSet":
+			statefulset := apps.StatefulSet{}
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &statefulset); err != nil {
+				return nil, err
+			}
+
+			object, replicas, template = &statefulset, statefulset.Spec.Replicas, &statefulset.Spec.Template
+		default:
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		namespace := object.GetNamespace()
+		if namespace == "" {
+			namespace = admissionReview.Request.Namespace
+		}
+
+		if isIgnoredNamespace(namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", namespace)
+			return resp, nil
+		}
+
+		requested := int32(1)
+		if replicas != nil {
+			requested = *replicas
+		}
+
+		if requested < minReplicas {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		if !policy.waitForCacheSync(synced) {
+			return policy.unavailable(resp, "the PodDisruptionBudget cache has not synced: cannot look up the PodDisruptionBudgets")
+		}
+
+		budgets, err := lister.PodDisruptionBudgets(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+
+		podLabels := labels.Set(template.Labels)
+		for _, budget := range budgets {
+			// A nil selector selects no Pods.
+			if budget.Spec.Selector == nil {
+				continue
+			}
+
+			selector, err := metav1.LabelSelectorAsSelector(budget.Spec.Selector)
+			if err != nil {
+				continue
+			}
+
+			if selector.Matches(podLabels) {
+				resp.Allowed = true
+				return resp, nil
+			}
+		}
+
+		return resp, xerrors.Errorf(
+			"%s %s/%s runs %d replicas, but no PodDisruptionBudget selects its Pods: create a PodDisruptionBudget (e.g. with maxUnavailable: 1) that selects %s",
+			kind,
+			namespace,
+			object.GetName(),
+			requested,
+			podLabels.String(),
+		)
+	}
+}
+
+// DenyMemoryBackedEmptyDir denies Pods (and the Pod templates of workloads)
+// with emptyDir volumes that use the Memory medium (tmpfs) without a sizeLimit,
+// or with a sizeLimit above maxSize. Memory-backed emptyDirs count against the
+// memory of the Pod (and the node): an unbounded tmpfs - e.g. one that
+// collects logs - can OOM the node.
+//
+// A zero maxSize only requires a sizeLimit to be set.
+func DenyMemoryBackedEmptyDir(ignoredNamespaces []string, maxSize resource.Quantity) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		for _, volume := range pod.spec.Volumes {
+			emptyDir := volume.EmptyDir
+			if emptyDir == nil || emptyDir.Medium != core.StorageMediumMemory {
+				continue
+			}
+
+			field := fmt.Sprintf("%s.volumes[%s].emptyDir", pod.specPath, volume.Name)
+			switch {
+			case emptyDir.SizeLimit == nil:
+				violations.Add(field+".sizeLimit", fmt.Sprintf("the emptyDir volume %s with medium %s must set a sizeLimit", volume.Name, emptyDir.Medium))
+			case !maxSize.IsZero() && emptyDir.SizeLimit.Cmp(maxSize) > 0:
+				violations.Add(field+".sizeLimit", fmt.Sprintf("the emptyDir volume %s with medium %s has a sizeLimit of %s: the maximum is %s", volume.Name, emptyDir.Medium, emptyDir.SizeLimit.String(), maxSize.String()))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s has unbounded memory-backed emptyDir volumes", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// DenyDefaultServiceAccount denies Pods (and the Pod templates of workloads)
+// that run under the "default" ServiceAccount of their namespace - either
+// explicitly, or by omitting serviceAccountName (which the API server defaults
+// to "default"). Every workload should run under a dedicated, least-privilege
+// ServiceAccount, rather than sharing (and accumulating the permissions of) the
+// default one.
+func DenyDefaultServiceAccount(ignoredNamespaces []string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		serviceAccount := pod.spec.ServiceAccountName
+		if serviceAccount == "" {
+			// The deprecated serviceAccount field is an alias of serviceAccountName.
+			serviceAccount = pod.spec.DeprecatedServiceAccount
+		}
+
+		if serviceAccount == "" || serviceAccount == "default" {
+			return resp, xerrors.Errorf(
+				"%s %s/%s uses the default ServiceAccount: create a dedicated ServiceAccount for the workload and reference it in %s.serviceAccountName",
+				kind,
+				pod.namespace,
+				pod.name,
+				pod.specPath,
+			)
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// RequireReadinessGates denies Pods (and the Pod templates of workloads) that
+// do not declare a readiness gate (spec.readinessGates) for each of the
+// requiredConditionTypes - e.g. the conditions set by a controller that
+// manages traffic to the Pods. Without the readiness gate, a Pod is considered
+// Ready (and receives traffic) before the controller has set its condition.
+func RequireReadinessGates(ignoredNamespaces []string, requiredConditionTypes []string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		declared := make(map[core.PodConditionType]bool, len(pod.spec.ReadinessGates))
+		for _, gate := range pod.spec.ReadinessGates {
+			declared[gate.ConditionType] = true
+		}
+
+		var missing []string
+		for _, conditionType := range requiredConditionTypes {
+			if !declared[core.PodConditionType(conditionType)] {
+				missing = append(missing, conditionType)
+			}
+		}
+
+		if len(missing) > 0 {
+			return resp, xerrors.Errorf(
+				"%s %s/%s is missing the required readiness gates in %s.readinessGates: %s",
+				kind,
+				pod.namespace,
+				pod.name,
+				pod.specPath,
+				strings.Join(missing, ", "),
+			)
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// EnforceLoadBalancerClass denies Services of type LoadBalancer with a
+// spec.loadBalancerClass that is not in the allowed list, so that Services
+// cannot be provisioned by an unapproved load balancer implementation when
+// several (e.g. MetalLB and a cloud provider) run in the same cluster.
+//
+// Services that do not set a loadBalancerClass are provisioned by the default
+// (cloud provider) implementation, and are allowed. Kinds other than Service,
+// and Services of other types, will be allowed.
+func EnforceLoadBalancerClass(ignoredNamespaces []string, allowed []string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "Service" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		service := core.Service{}
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &service); err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(service.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", service.Namespace)
+			return resp, nil
+		}
+
+		if service.Spec.Type != core.ServiceTypeLoadBalancer || service.Spec.LoadBalancerClass == nil {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		class := *service.Spec.LoadBalancerClass
+		for _, allowedClass := range allowed {
+			if class == allowedClass {
+				resp.Allowed = true
+				return resp, nil
+			}
+		}
+
+		return resp, xerrors.Errorf(
+			"Service %s/%s requests the load balancer class %q: the allowed classes are [%s]",
+			service.Namespace,
+			service.Name,
+			class,
+			strings.Join(allowed, ", "),
+		)
+	}
+}
+
+// EnforceGuaranteedQoSForPinnedWorkloads denies Pods (and the Pod templates of
+// workloads) that carry the triggerAnnotation - requesting exclusive
+// (pinned) CPUs - but would not be pinned by the kubelet's static CPU manager
+// policy. The static policy only pins the containers of Pods in the Guaranteed
+// QoS class that request an integer number of CPUs: each container must set
+// CPU & memory limits, with requests equal to those limits, and the CPU limit
+// must be a whole number of CPUs. Otherwise, the Pod runs on the shared CPU
+// pool without any indication that it was not pinned.
+//
+// The annotation is read from the Pod, or from the Pod template of a workload
+// controller: its value is ignored. Each init container and container is
+// evaluated.
+func EnforceGuaranteedQoSForPinnedWorkloads(ignoredNamespaces []string, triggerAnnotation string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		if _, ok := pod.meta.Annotations[triggerAnnotation]; !ok {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		var violations ViolationList
+		check := func(field string, container core.Container) {
+			for _, name := range []core.ResourceName{core.ResourceCPU, core.ResourceMemory} {
+				limit, ok := container.Resources.Limits[name]
+				if !ok {
+					violations.Add(field+".resources.limits", fmt.Sprintf("limits.%s must be set", name))
+					continue
+				}
+
+				// Requests default to the limits when they are omitted.
+				if request, ok := container.Resources.Requests[name]; ok && request.Cmp(limit) != 0 {
+					violations.Add(field+".resources.requests", fmt.Sprintf("requests.%s %s must equal limits.%s %s", name, request.String(), name, limit.String()))
+				}
+
+				if name == core.ResourceCPU && limit.MilliValue()%1000 != 0 {
+					violations.Add(field+".resources.limits", fmt.Sprintf("limits.cpu %s must be a whole number of CPUs", limit.String()))
+				}
+			}
+		}
+
+		for _, container := range pod.spec.InitContainers {
+			check(fmt.Sprintf("%s.initContainers[%s]", pod.specPath, container.Name), container)
+		}
+
+		for _, container := range pod.spec.Containers {
+			check(fmt.Sprintf("%s.containers[%s]", pod.specPath, container.Name), container)
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf(
+				"%s %s/%s requests CPU pinning (%s), but will not be pinned: pinning requires the Guaranteed QoS class, with integer CPU requests equal to the CPU limits, and memory requests equal to the memory limits, for every container",
+				kind,
+				pod.namespace,
+				pod.name,
+				triggerAnnotation,
+			))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// networkCapabilities are the raw network capabilities denied by
+// EnforceNetworkCapabilities.
+var networkCapabilities = []string{"NET_ADMIN", "NET_RAW"}
+
+// EnforceNetworkCapabilities denies containers that add the raw network
+// capabilities NET_RAW or NET_ADMIN (including via "ALL") to their security
+// context, unless the capability is listed in the
+// AllowedNetworkCapabilitiesAnnotation of the Pod (or of the Pod template of a
+// workload). NET_RAW allows a container to craft arbitrary packets - e.g. to
+// spoof ARP replies on the Pod network - and NET_ADMIN to reconfigure the
+// network of the Pod.
+//
+// Capabilities may be given with or without the "CAP_" prefix. Note that some
+// container runtimes grant NET_RAW by default: pair this with a policy that
+// requires dropping it.
+func EnforceNetworkCapabilities(ignoredNamespaces []string) AdmitFunc {
+	normalize := func(capability string) string {
+		return strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(capability)), "CAP_")
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		allowed := make(map[string]bool)
+		if value, ok := pod.meta.Annotations[AllowedNetworkCapabilitiesAnnotation]; ok {
+			for _, capability := range strings.Split(value, ",") {
+				allowed[normalize(capability)] = true
+			}
+		}
+
+		var violations ViolationList
+		for _, container := range pod.containers() {
+			if container.SecurityContext == nil || container.SecurityContext.Capabilities == nil {
+				continue
+			}
+
+			added := make(map[string]bool)
+			for _, capability := range container.SecurityContext.Capabilities.Add {
+				added[normalize(string(capability))] = true
+			}
+
+			for _, capability := range networkCapabilities {
+				if (added[capability] || added["ALL"]) && !allowed[capability] {
+					violations.Add(
+						container.field+".securityContext.capabilities.add",
+						fmt.Sprintf("container %s adds the %s capability: add it to the %s annotation to allow it", container.Name, capability, AllowedNetworkCapabilitiesAnnotation),
+					)
+				}
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s adds raw network capabilities", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// EnforceAllowedSecretTypes denies the creation of Secrets with a type that is
+// not in the allowed list - e.g. to prevent manually created
+// kubernetes.io/service-account-token Secrets, or custom types that confuse the
+// controllers that consume them. Secrets that omit their type default to
+// Opaque.
+//
+// Kinds other than Secret, and operations other than CREATE, will be allowed:
+// the type of a Secret is immutable.
+func EnforceAllowedSecretTypes(ignoredNamespaces []string, allowed []core.SecretType) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "Secret" || admissionReview.Request.Operation != admission.Create {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		secret := core.Secret{}
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &secret); err != nil {
+			return nil, err
+		}
+
+		namespace := secret.Namespace
+		if namespace == "" {
+			namespace = admissionReview.Request.Namespace
+		}
+
+		if isIgnoredNamespace(namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", namespace)
+			return resp, nil
+		}
+
+		secretType := secret.Type
+		if secretType == "" {
+			secretType = core.SecretTypeOpaque
+		}
+
+		allowedTypes := make([]string, 0, len(allowed))
+		for _, allowedType := range allowed {
+			if secretType == allowedType {
+				resp.Allowed = true
+				return resp, nil
+			}
+			allowedTypes = append(allowedTypes, string(allowedType))
+		}
+
+		return resp, xerrors.Errorf(
+			"Secret %s/%s has the type %q, which is not allowed: the allowed types are [%s]",
+			namespace,
+			secret.Name,
+			secretType,
+			strings.Join(allowedTypes, ", "),
+		)
+	}
+}
+
+// EnforceDNSPolicy denies Pods (and the Pod templates of workloads) with a
+// spec.dnsPolicy that is not in the allowed list. Pods that omit their
+// dnsPolicy default to ClusterFirst.
+//
+// If allowedNameserverCIDRs is non-nil, the nameservers of a custom
+// spec.dnsConfig must also fall within one of the CIDRs (e.g. the Service CIDR
+// of the cluster DNS): a Pod with the "None" policy and an off-cluster
+// nameserver can otherwise bypass DNS-based egress controls. An error is
+// returned if a CIDR is invalid.
+func EnforceDNSPolicy(ignoredNamespaces []string, allowed []core.DNSPolicy, allowedNameserverCIDRs []string) (AdmitFunc, error) {
+	var nameserverNets []*net.IPNet
+	for _, cidr := range allowedNameserverCIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid nameserver CIDR %q: %w", cidr, err)
+		}
+		nameserverNets = append(nameserverNets, ipNet)
+	}
+
+	allowedPolicies := make([]string, 0, len(allowed))
+	for _, policy := range allowed {
+		allowedPolicies = append(allowedPolicies, string(policy))
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		dnsPolicy := pod.spec.DNSPolicy
+		if dnsPolicy == "" {
+			dnsPolicy = core.DNSClusterFirst
+		}
+
+		policyAllowed := false
+		for _, policy := range allowed {
+			if dnsPolicy == policy {
+				policyAllowed = true
+				break
+			}
+		}
+
+		if !policyAllowed {
+			violations.Add(pod.specPath+".dnsPolicy", fmt.Sprintf("the DNS policy %q is not allowed: the allowed policies are [%s]", dnsPolicy, strings.Join(allowedPolicies, ", ")))
+		}
+
+		if allowedNameserverCIDRs != nil && pod.spec.DNSConfig != nil {
+			for i, nameserver := range pod.spec.DNSConfig.Nameservers {
+				ip := net.ParseIP(nameserver)
+				inRange := false
+				for _, ipNet := range nameserverNets {
+					if ip != nil && ipNet.Contains(ip) {
+						inRange = true
+						break
+					}
+				}
+
+				if !inRange {
+					violations.Add(
+						fmt.Sprintf("%s.dnsConfig.nameservers[%d]", pod.specPath, i),
+						fmt.Sprintf("the nameserver %s is outside of the allowed ranges [%s]", nameserver, strings.Join(allowedNameserverCIDRs, ", ")),
+					)
+				}
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s has a disallowed DNS configuration", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}, nil
+}
+
+// RequirePreStopHook denies Pods (and the Pod templates of workloads) that
+// carry the triggerAnnotation - marking them as requiring a graceful shutdown -
+// but have containers without a lifecycle.preStop hook. The preStop hook lets a
+// container drain its connections before it is sent SIGTERM: without it,
+// in-flight requests fail during rollouts.
+//
+// The annotation is read from the Pod, or from the Pod template of a workload
+// controller: its value is ignored. Init and ephemeral containers do not
+// support lifecycle hooks, and are not evaluated.
+func RequirePreStopHook(ignoredNamespaces []string, triggerAnnotation string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		if _, ok := pod.meta.Annotations[triggerAnnotation]; !ok {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		var violations ViolationList
+		for _, container := range pod.spec.Containers {
+			if container.Lifecycle == nil || container.Lifecycle.PreStop == nil {
+				violations.Add(
+					fmt.Sprintf("%s.containers[%s].lifecycle.preStop", pod.specPath, container.Name),
+					fmt.Sprintf("container %s must define a preStop hook", container.Name),
+				)
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s requires a graceful shutdown (%s)", kind, pod.namespace, pod.name, triggerAnnotation))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// RolloutStrategyLimits configures the bounds enforced by
+// EnforceRolloutStrategy. Each bound is either an absolute number of Pods
+// (e.g. 1) or a percentage of the desired replicas (e.g. "25%"), and is
+// resolved against the replicas of the Deployment in the same way as the
+// Deployment controller: rounding up for maxSurge, and down for
+// maxUnavailable.
+type RolloutStrategyLimits struct {
+	// MaxSurge is the upper bound for .spec.strategy.rollingUpdate.maxSurge. A
+	// nil MaxSurge is not enforced.
+	MaxSurge *intstr.IntOrString
+	// MaxUnavailable is the upper bound for
+	// .spec.strategy.rollingUpdate.maxUnavailable. A nil MaxUnavailable is not
+	// enforced.
+	MaxUnavailable *intstr.IntOrString
+	// AllowRecreate allows the Recreate strategy, which terminates every Pod
+	// before creating the new ones, when MaxUnavailable is set.
+	AllowRecreate bool
+}
+
+// EnforceRolloutStrategy validates that the rolling update strategy of
+// Deployments stays within the configured limits - e.g. a maxUnavailable of at
+// most 25% - so that a rollout cannot take down the capacity of the
+// Deployment. Omitted values default to 25%, as they do in the API server. An
+// error is returned if a limit is not a valid number or percentage.
+//
+// Kinds other than Deployment will be allowed.
+func EnforceRolloutStrategy(ignoredNamespaces []string, config RolloutStrategyLimits) (AdmitFunc, error) {
+	for _, limit := range []*intstr.IntOrString{config.MaxSurge, config.MaxUnavailable} {
+		if limit == nil {
+			continue
+		}
+
+		if _, err := intstr.GetScaledValueFromIntOrPercent(limit, 100, true); err != nil {
+			return nil, xerrors.Errorf("invalid rollout strategy limit %q: %w", limit.String(), err)
+		}
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "Deployment" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		deployment := apps.Deployment{}
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &deployment); err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(deployment.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", deployment.Namespace)
+			return resp, nil
+		}
+
+		replicas := 1
+		if deployment.Spec.Replicas != nil {
+			replicas = int(*deployment.Spec.Replicas)
+		}
+
+		var violations ViolationList
+		strategy := deployment.Spec.Strategy
+		if strategy.Type == apps.RecreateDeploymentStrategyType {
+			if config.MaxUnavailable != nil && !config.AllowRecreate {
+				violations.Add("spec.strategy.type", fmt.Sprintf("the Recreate strategy makes every replica unavailable: the maximum is %s", config.MaxUnavailable.String()))
+			}
+		} else {
+			defaultValue := intstr.FromString("25%")
+			maxSurge, maxUnavailable := &defaultValue, &defaultValue
+			if strategy.RollingUpdate != nil {
+				if strategy.RollingUpdate.MaxSurge != nil {
+					maxSurge = strategy.RollingUpdate.MaxSurge
+				}
+
+				if strategy.RollingUpdate.MaxUnavailable != nil {
+					maxUnavailable = strategy.RollingUpdate.MaxUnavailable
+				}
+			}
+
+			check := func(field string, value *intstr.IntOrString, limit *intstr.IntOrString, roundUp bool) {
+				if limit == nil {
+					return
+				}
+
+				got, err := intstr.GetScaledValueFromIntOrPercent(value, replicas, roundUp)
+				if err != nil {
+					violations.Add(field, fmt.Sprintf("%s is not a valid number or percentage", value.String()))
+					return
+				}
+
+				// The limit was validated when the AdmitFunc was created.
+				max, _ := intstr.GetScaledValueFromIntOrPercent(limit, replicas, roundUp)
+				if got > max {
+					violations.Add(field, fmt.Sprintf("%s (%d of %d replicas) exceeds the maximum of %s (%d replicas)", value.String(), got, replicas, limit.String(), max))
+				}
+			}
+
+			check("spec.strategy.rollingUpdate.maxSurge", maxSurge, config.MaxSurge, true)
+			check("spec.strategy.rollingUpdate.maxUnavailable", maxUnavailable, config.MaxUnavailable, false)
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("Deployment %s/%s has an unsafe rollout strategy", deployment.Namespace, deployment.Name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}, nil
+}
+
+// workloadKinds are the kinds that decodePodObject supports: Pods, and the
+// built-in workload controllers that create them.
+var workloadKinds = map[string]bool{
+	"Pod":                   true,
+	"Deployment":            true,
+	"StatefulSet":           true,
+	"DaemonSet":             true,
+	"ReplicaSet":            true,
+	"ReplicationController": true,
+	"Job":                   true,
+	"CronJob":               true,
+}
+
+// RequireResourceQuota denies the creation of workloads (Pods, and the built-in
+// workload controllers) in namespaces without a ResourceQuota, so that every
+// tenant namespace is bounded by a quota before it runs anything. The denial
+// points to the quotaTemplate (e.g. the URL of the platform's ResourceQuota
+// template), if provided.
+//
+// Namespaces themselves are not checked: a ResourceQuota can only be created
+// once its Namespace exists, and so a Namespace is never created with one.
+// Create the ResourceQuota alongside the Namespace (e.g. in the same manifest,
+// or from the controller that provisions namespaces) instead: as the cache is
+// eventually consistent, a workload created in the moments after its
+// namespace's ResourceQuota may be denied, and should be retried - as the
+// workload controllers and most deployment tools do.
+//
+// The ResourceQuotas are read from the provided SharedInformerFactory (see
+// ClientOption), whose client must be authorized to list & watch
+// resourcequotas across all namespaces.
+//
+// Other kinds, and operations other than CREATE, will be allowed.
+func RequireResourceQuota(factory informers.SharedInformerFactory, ignoredNamespaces []string, quotaTemplate string, opts ...ClientOption) AdmitFunc {
+	quotas := factory.Core().V1().ResourceQuotas()
+	lister := quotas.Lister()
+	synced := quotas.Informer().HasSynced
+	policy := newClientPolicy(opts)
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if !workloadKinds[kind] || admissionReview.Request.Operation != admission.Create {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		namespace := admissionReview.Request.Namespace
+		if isIgnoredNamespace(namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", namespace)
+			return resp, nil
+		}
+
+		if !policy.waitForCacheSync(synced) {
+			return policy.unavailable(resp, "the ResourceQuota cache has not synced: cannot look up the ResourceQuotas")
+		}
+
+		existing, err := lister.ResourceQuotas(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+
+		if len(existing) > 0 {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		message := fmt.Sprintf(
+			"namespace %s has no ResourceQuota: every namespace must be bounded by a ResourceQuota before %s objects can be created in it",
+			namespace,
+			kind,
+		)
+		if quotaTemplate != "" {
+			message = fmt.Sprintf("%s - see %s", message, quotaTemplate)
+		}
+
+		return resp, xerrors.New(message)
+	}
+}
+
+// DefaultReservedLabels are the labels that the built-in workload controllers
+// set on the Pods (and Pod templates) that they manage.
+var DefaultReservedLabels = []string{
+	"pod-template-hash",
+	"controller-revision-hash",
+	"statefulset.kubernetes.io/pod-name",
+}
+
+// DenyReservedLabels denies Pods (and the Pod templates of workloads) with
+// labels that are reserved for the workload controllers, such as
+// pod-template-hash. A manually set reserved label breaks the controller's
+// accounting of the Pods it owns - e.g. a Deployment's rollout status. If
+// reserved is nil, the DefaultReservedLabels are used.
+//
+// Objects that are managed by a controller (i.e. that have a controller
+// ownerReference, such as the ReplicaSets of a Deployment, or the Pods of a
+// StatefulSet) are allowed, as the controller sets these labels itself.
+//
+// DenyReservedLabels inspects Pods and the Pod templates of Deployments,
+// StatefulSets, DaemonSets, ReplicaSets, ReplicationControllers, Jobs &
+// CronJobs. Unknown object kinds are answered according to
+// AdmissionHandler.UnknownKinds (allowed by default).
+func DenyReservedLabels(ignoredNamespaces []string, reserved []string) AdmitFunc {
+	if reserved == nil {
+		reserved = DefaultReservedLabels
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		if pod.controlled {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s %s/%s is managed by a controller", kind, pod.namespace, pod.name)
+			return resp, nil
+		}
+
+		field := "metadata.labels"
+		if pod.specPath != "spec" {
+			field = strings.TrimSuffix(pod.specPath, ".spec") + ".metadata.labels"
+		}
+
+		var violations ViolationList
+		for _, label := range reserved {
+			if _, ok := pod.meta.Labels[label]; ok {
+				violations.Add(fmt.Sprintf("%s[%s]", field, label), fmt.Sprintf("the %s label is reserved for the workload controllers, and must not be set", label))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s sets reserved labels", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// appArmorAnnotationPrefix is the prefix of the (deprecated) per-container
+// AppArmor profile annotations, which are suffixed with the container name.
+const appArmorAnnotationPrefix = "container.apparmor.security.beta.kubernetes.io/"
+
+// appArmorProfile is the appArmorProfile field of a (Pod or container)
+// securityContext, added in Kubernetes 1.30. It is decoded from the raw object,
+// as it post-dates the vendored k8s.io/api types.
+type appArmorProfile struct {
+	Type             string `json:"type"`
+	LocalhostProfile string `json:"localhostProfile,omitempty"`
+}
+
+// String returns the profile in the form used by the AppArmor annotations:
+// "runtime/default", "localhost/<profile>" or "unconfined".
+func (p appArmorProfile) String() string {
+	switch p.Type {
+	case "RuntimeDefault":
+		return "runtime/default"
+	case "Localhost":
+		return "localhost/" + p.LocalhostProfile
+	case "Unconfined":
+		return "unconfined"
+	default:
+		return p.Type
+	}
+}
+
+// appArmorSecurityContext is the subset of a securityContext that holds the
+// appArmorProfile.
+type appArmorSecurityContext struct {
+	AppArmorProfile *appArmorProfile `json:"appArmorProfile,omitempty"`
+}
+
+// appArmorPodSpec is the subset of a PodSpec that holds the appArmorProfile
+// fields.
+type appArmorPodSpec struct {
+	SecurityContext *appArmorSecurityContext `json:"securityContext,omitempty"`
+	Containers      []appArmorContainer      `json:"containers,omitempty"`
+	InitContainers  []appArmorContainer      `json:"initContainers,omitempty"`
+	// EphemeralContainers share the fields of containers.
+	EphemeralContainers []appArmorContainer `json:"ephemeralContainers,omitempty"`
+}
+
+type appArmorContainer struct {
+	Name            string                   `json:"name"`
+	SecurityContext *appArmorSecurityContext `json:"securityContext,omitempty"`
+}
+
+// decodeAppArmorPodSpec decodes the appArmorProfile fields of the PodSpec at
+// the specPath (e.g. "spec.template.spec") of the raw object.
+func decodeAppArmorPodSpec(raw []byte, specPath string) (*appArmorPodSpec, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, err
+	}
+
+	segments := strings.Split(specPath, ".")
+	for _, segment := range segments[:len(segments)-1] {
+		next, ok := object[segment]
+		if !ok {
+			return &appArmorPodSpec{}, nil
+		}
+
+		object = nil
+		if err := json.Unmarshal(next, &object); err != nil {
+			return nil, err
+		}
+	}
+
+	spec := &appArmorPodSpec{}
+	if rawSpec, ok := object[segments[len(segments)-1]]; ok {
+		if err := json.Unmarshal(rawSpec, spec); err != nil {
+			return nil, err
+		}
+	}
+
+	return spec, nil
+}
+
+// EnforceAppArmorProfile requires every container of a Pod (or of the Pod
+// template of a workload) to run under an AppArmor profile from the allowed
+// list, given in the annotation form: "runtime/default", or
+// "localhost/<profile>". Containers without a profile - which may run
+// unconfined - are rejected, as is the "unconfined" profile, even if allowed.
+//
+// The profile of a container is read from (in order of precedence) the
+// appArmorProfile field of its securityContext, the
+// container.apparmor.security.beta.kubernetes.io/<container> annotation, and
+// the appArmorProfile field of the Pod's securityContext. The fields were
+// added in Kubernetes 1.30, and supersede the annotations.
+//
+// EnforceAppArmorProfile inspects init, regular & ephemeral containers, in Pods
+// and the Pod templates of Deployments, StatefulSets, DaemonSets, ReplicaSets,
+// ReplicationControllers, Jobs & CronJobs. Unknown object kinds are answered
+// according to AdmissionHandler.UnknownKinds (allowed by default).
+func EnforceAppArmorProfile(ignoredNamespaces []string, allowed []string) AdmitFunc {
+	allowedProfiles := make(map[string]bool, len(allowed))
+	for _, profile := range allowed {
+		allowedProfiles[profile] = true
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		spec, err := decodeAppArmorPodSpec(admissionReview.Request.Object.Raw, pod.specPath)
+		if err != nil {
+			return nil, err
+		}
+
+		var podProfile string
+		if spec.SecurityContext != nil && spec.SecurityContext.AppArmorProfile != nil {
+			podProfile = spec.SecurityContext.AppArmorProfile.String()
+		}
+
+		containerProfiles := make(map[string]string)
+		for _, containers := range [][]appArmorContainer{spec.InitContainers, spec.Containers, spec.EphemeralContainers} {
+			for _, container := range containers {
+				if container.SecurityContext != nil && container.SecurityContext.AppArmorProfile != nil {
+					containerProfiles[container.Name] = container.SecurityContext.AppArmorProfile.String()
+				}
+			}
+		}
+
+		var violations ViolationList
+		for _, container := range pod.containers() {
+			profile, ok := containerProfiles[container.Name]
+			if !ok {
+				profile, ok = pod.meta.Annotations[appArmorAnnotationPrefix+container.Name]
+			}
+			if !ok {
+				profile = podProfile
+			}
+
+			field := container.field + ".securityContext.appArmorProfile"
+			switch {
+			case profile == "":
+				violations.Add(field, fmt.Sprintf("container %s must set an AppArmor profile: the allowed profiles are [%s]", container.Name, strings.Join(allowed, ", ")))
+			case profile == "unconfined":
+				violations.Add(field, fmt.Sprintf("container %s must not run unconfined", container.Name))
+			case !allowedProfiles[profile]:
+				violations.Add(field, fmt.Sprintf("container %s requests the AppArmor profile %q: the allowed profiles are [%s]", container.Name, profile, strings.Join(allowed, ", ")))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s does not use an allowed AppArmor profile", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// RequireEnvVars denies Pods (and the Pod templates of workloads) that carry
+// the triggerAnnotation, but have containers that do not define each of the
+// required environment variables (e.g. DATADOG_API_KEY) - which would
+// otherwise only surface as a crash at runtime.
+//
+// The annotation is read from the Pod, or from the Pod template of a workload
+// controller: its value is ignored. Only the variables listed in the env of a
+// container are considered: the keys of the ConfigMaps & Secrets referenced via
+// envFrom are not known at admission time, and so required variables must be
+// declared explicitly (e.g. with a secretKeyRef). Init and ephemeral containers
+// are not evaluated.
+func RequireEnvVars(ignoredNamespaces []string, required []string, triggerAnnotation string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		if _, ok := pod.meta.Annotations[triggerAnnotation]; !ok {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		var violations ViolationList
+		for _, container := range pod.spec.Containers {
+			defined := make(map[string]bool, len(container.Env))
+			for _, env := range container.Env {
+				defined[env.Name] = true
+			}
+
+			var missing []string
+			for _, name := range required {
+				if !defined[name] {
+					missing = append(missing, name)
+				}
+			}
+
+			if len(missing) > 0 {
+				violations.Add(
+					fmt.Sprintf("%s.containers[%s].env", pod.specPath, container.Name),
+					fmt.Sprintf("container %s is missing the environment variables %s", container.Name, strings.Join(missing, ", ")),
+				)
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s is missing required environment variables (%s)", kind, pod.namespace, pod.name, triggerAnnotation))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// ValidateServiceMonitor validates the endpoints of Prometheus Operator
+// ServiceMonitors (monitoring.coreos.com), which would otherwise allow
+// monitoring configuration to scrape (and so exfiltrate data from, or
+// overload) arbitrary targets.
+//
+// If allowedSchemes are provided (e.g. "https"), each endpoint must use one of
+// them: endpoints without a scheme default to "http". Endpoints must not scrape
+// any of the deniedTargets - either via their proxyUrl, or via a relabeling
+// that rewrites the __address__ of the target. Targets are hostnames or IPs,
+// matched using path.Match syntax (e.g. "*.example.com"), or CIDRs (e.g.
+// "0.0.0.0/0"). An error is returned if any of the deniedTargets are invalid.
+//
+// ServiceMonitors are decoded as unstructured objects, so that any version of
+// the CRD is supported. Kinds other than ServiceMonitor will be allowed.
+func ValidateServiceMonitor(ignoredNamespaces []string, allowedSchemes, deniedTargets []string) (AdmitFunc, error) {
+	var deniedNetworks []*net.IPNet
+	var deniedHosts []string
+	for _, target := range deniedTargets {
+		if strings.Contains(target, "/") {
+			_, network, err := net.ParseCIDR(target)
+			if err != nil {
+				return nil, xerrors.Errorf("invalid denied target %q: %w", target, err)
+			}
+			deniedNetworks = append(deniedNetworks, network)
+			continue
+		}
+
+		if _, err := path.Match(target, ""); err != nil {
+			return nil, xerrors.Errorf("invalid denied target pattern %q: %w", target, err)
+		}
+		deniedHosts = append(deniedHosts, target)
+	}
+
+	isDeniedTarget := func(host string) bool {
+		if ip := net.ParseIP(host); ip != nil {
+			for _, network := range deniedNetworks {
+				if network.Contains(ip) {
+					return true
+				}
+			}
+		}
+
+		for _, pattern := range deniedHosts {
+			// The patterns were validated above.
+			if matched, _ := path.Match(pattern, host); matched {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind.Group != "monitoring.coreos.com" || kind.Kind != "ServiceMonitor" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		object := unstructured.Unstructured{}
+		if err := object.UnmarshalJSON(admissionReview.Request.Object.Raw); err != nil {
+			return nil, err
+		}
+
+		namespace := object.GetNamespace()
+		if namespace == "" {
+			namespace = admissionReview.Request.Namespace
+		}
+
+		if isIgnoredNamespace(namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", namespace)
+			return resp, nil
+		}
+
+		endpoints, _, err := unstructured.NestedSlice(object.Object, "spec", "endpoints")
+		if err != nil {
+			return nil, err
+		}
+
+		var violations ViolationList
+		for i, item := range endpoints {
+			endpoint, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, xerrors.Errorf("invalid ServiceMonitor endpoint: spec.endpoints[%d] is not an object", i)
+			}
+
+			field := fmt.Sprintf("spec.endpoints[%d]", i)
+			name := serviceMonitorEndpointName(endpoint, i)
+
+			if len(allowedSchemes) > 0 {
+				scheme, _, _ := unstructured.NestedString(endpoint, "scheme")
+				if scheme == "" {
+					scheme = "http"
+				}
+
+				allowed := false
+				for _, allowedScheme := range allowedSchemes {
+					if strings.EqualFold(scheme, allowedScheme) {
+						allowed = true
+						break
+					}
+				}
+
+				if !allowed {
+					violations.Add(field+".scheme", fmt.Sprintf("endpoint %s uses the scheme %s: must be one of %s", name, scheme, strings.Join(allowedSchemes, ", ")))
+				}
+			}
+
+			if proxyURL, _, _ := unstructured.NestedString(endpoint, "proxyUrl"); proxyURL != "" {
+				if parsed, err := url.Parse(proxyURL); err == nil && isDeniedTarget(parsed.Hostname()) {
+					violations.Add(field+".proxyUrl", fmt.Sprintf("endpoint %s is scraped via the denied target %s", name, parsed.Hostname()))
+				}
+			}
+
+			relabelings, _, _ := unstructured.NestedSlice(endpoint, "relabelings")
+			for j, item := range relabelings {
+				relabeling, ok := item.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				targetLabel, _, _ := unstructured.NestedString(relabeling, "targetLabel")
+				action, _, _ := unstructured.NestedString(relabeling, "action")
+				replacement, _, _ := unstructured.NestedString(relabeling, "replacement")
+				if targetLabel != "__address__" || (action != "" && !strings.EqualFold(action, "replace")) || replacement == "" {
+					continue
+				}
+
+				host := replacement
+				if h, _, err := net.SplitHostPort(replacement); err == nil {
+					host = h
+				}
+
+				if isDeniedTarget(host) {
+					violations.Add(fmt.Sprintf("%s.relabelings[%d]", field, j), fmt.Sprintf("endpoint %s rewrites its target to the denied target %s", name, replacement))
+				}
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s has disallowed endpoints", kind.Kind, namespace, object.GetName()))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}, nil
+}
+
+// serviceMonitorEndpointName identifies a ServiceMonitor endpoint by its
+// (target) port, or by its index if it has neither.
+func serviceMonitorEndpointName(endpoint map[string]interface{}, index int) string {
+	if port, _, _ := unstructured.NestedString(endpoint, "port"); port != "" {
+		return port
+	}
+
+	if targetPort, ok := endpoint["targetPort"]; ok {
+		return fmt.Sprint(targetPort)
+	}
+
+	return fmt.Sprintf("#%d", index)
+}
+
+// IDRange is an inclusive range of user or group IDs.
+type IDRange struct {
+	Min int64
+	Max int64
+}
+
+func (r IDRange) String() string {
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+// EnforceFSGroupRange denies Pods (and the Pod templates of workloads) whose
+// user & group IDs fall outside of the allowed ranges, replicating the
+// MustRunAs ranges of a PodSecurityPolicy. The fsGroup, runAsUser, runAsGroup
+// & supplementalGroups of the Pod's securityContext, and the runAsUser &
+// runAsGroup of each container's securityContext, must each be within one of
+// the ranges. An error is returned if no ranges are provided, or if a range's
+// Min is greater than its Max.
+//
+// Unlike a PodSecurityPolicy, EnforceFSGroupRange does not default the IDs of
+// Pods that do not set them: unset IDs are not evaluated. Pair it with
+// a mutating webhook (or runAsNonRoot) to ensure that the IDs are set.
+//
+// EnforceFSGroupRange inspects init, regular & ephemeral containers, in Pods
+// and the Pod templates of Deployments, StatefulSets, DaemonSets, ReplicaSets,
+// ReplicationControllers, Jobs & CronJobs. Unknown object kinds are answered
+// according to AdmissionHandler.UnknownKinds (allowed by default).
+func EnforceFSGroupRange(ignoredNamespaces []string, ranges ...IDRange) (AdmitFunc, error) {
+	if len(ranges) == 0 {
+		return nil, xerrors.New("at least one ID range must be provided")
+	}
+
+	allowed := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		if r.Min > r.Max {
+			return nil, xerrors.Errorf("invalid ID range %s: the min must not be greater than the max", r)
+		}
+		allowed = append(allowed, r.String())
+	}
+	allowedRanges := strings.Join(allowed, ", ")
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		checkID := func(field string, id *int64) {
+			if id == nil {
+				return
+			}
+
+			for _, r := range ranges {
+				if *id >= r.Min && *id <= r.Max {
+					return
+				}
+			}
+
+			violations.Add(field, fmt.Sprintf("%d is outside of the allowed ranges [%s]", *id, allowedRanges))
+		}
+
+		if sc := pod.spec.SecurityContext; sc != nil {
+			field := pod.specPath + ".securityContext"
+			checkID(field+".fsGroup", sc.FSGroup)
+			checkID(field+".runAsUser", sc.RunAsUser)
+			checkID(field+".runAsGroup", sc.RunAsGroup)
+			for i := range sc.SupplementalGroups {
+				checkID(fmt.Sprintf("%s.supplementalGroups[%d]", field, i), &sc.SupplementalGroups[i])
+			}
+		}
+
+		for _, container := range pod.containers() {
+			if sc := container.SecurityContext; sc != nil {
+				checkID(container.field+".securityContext.runAsUser", sc.RunAsUser)
+				checkID(container.field+".securityContext.runAsGroup", sc.RunAsGroup)
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s runs with user or group IDs outside of the allowed ranges", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}, nil
+}
+
+// EnforceEphemeralStorageLimits denies Pods (and the Pod templates of
+// workloads) with containers whose ephemeral-storage limit (or request) is
+// above max and, if requireLimit is set, with containers that do not set an
+// ephemeral-storage limit. Containers without a limit can fill the disk of
+// their node, which evicts the other Pods on it.
+//
+// A zero max only requires a limit to be set (if requireLimit is set).
+// Init & regular containers are evaluated: ephemeral containers cannot set
+// resources.
+func EnforceEphemeralStorageLimits(ignoredNamespaces []string, max resource.Quantity, requireLimit bool) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		// The ephemeral containers are listed last.
+		containers := pod.containers()[:len(pod.spec.InitContainers)+len(pod.spec.Containers)]
+		for _, container := range containers {
+			field := container.field + ".resources"
+			limit, hasLimit := container.Resources.Limits[core.ResourceEphemeralStorage]
+			if !hasLimit && requireLimit {
+				violations.Add(field+".limits.ephemeral-storage", fmt.Sprintf("container %s must set an ephemeral-storage limit", container.Name))
+			}
+
+			if max.IsZero() {
+				continue
+			}
+
+			if hasLimit && limit.Cmp(max) > 0 {
+				violations.Add(field+".limits.ephemeral-storage", fmt.Sprintf("container %s has an ephemeral-storage limit of %s: the maximum is %s", container.Name, limit.String(), max.String()))
+			}
+
+			if request, ok := container.Resources.Requests[core.ResourceEphemeralStorage]; ok && request.Cmp(max) > 0 {
+				violations.Add(field+".requests.ephemeral-storage", fmt.Sprintf("container %s requests %s of ephemeral-storage: the maximum is %s", container.Name, request.String(), max.String()))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s has disallowed ephemeral-storage resources", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// EnforceMaxResourceLimits denies Pods (and the Pod templates of workloads)
+// with containers whose CPU or memory requests or limits are above maxCPU or
+// maxMemory - e.g. a container requesting 500Gi of memory on a cluster of 64Gi
+// nodes, which would remain Pending. A zero maxCPU or maxMemory is not
+// enforced.
+//
+// If capPodTotal is set, the total resources of the Pod are also capped: the
+// sum across its containers, or the largest of its init containers (which run
+// one at a time) if that is larger - as accounted for by the scheduler.
+//
+// Init & regular containers are evaluated: ephemeral containers cannot set
+// resources.
+func EnforceMaxResourceLimits(ignoredNamespaces []string, maxCPU, maxMemory resource.Quantity, capPodTotal bool) AdmitFunc {
+	caps := core.ResourceList{}
+	if !maxCPU.IsZero() {
+		caps[core.ResourceCPU] = maxCPU
+	}
+	if !maxMemory.IsZero() {
+		caps[core.ResourceMemory] = maxMemory
+	}
+	capped := sortedResourceNames(caps)
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		checkCaps := func(field, name string, requests, limits core.ResourceList) {
+			for _, resourceName := range capped {
+				max := caps[resourceName]
+				if request, ok := requests[resourceName]; ok && request.Cmp(max) > 0 {
+					violations.Add(field+".requests."+string(resourceName), fmt.Sprintf("%s requests %s of %s: the maximum is %s", name, request.String(), resourceName, max.String()))
+				}
+
+				if limit, ok := limits[resourceName]; ok && limit.Cmp(max) > 0 {
+					violations.Add(field+".limits."+string(resourceName), fmt.Sprintf("%s has a %s limit of %s: the maximum is %s", name, resourceName, limit.String(), max.String()))
+				}
+			}
+		}
+
+		// The ephemeral containers are listed last.
+		containers := pod.containers()[:len(pod.spec.InitContainers)+len(pod.spec.Containers)]
+		for _, container := range containers {
+			checkCaps(container.field+".resources", "container "+container.Name, container.Resources.Requests, container.Resources.Limits)
+		}
+
+		if capPodTotal {
+			totalRequests, totalLimits := core.ResourceList{}, core.ResourceList{}
+			for _, resourceName := range capped {
+				var requests, limits resource.Quantity
+				for _, container := range pod.spec.Containers {
+					if request, ok := container.Resources.Requests[resourceName]; ok {
+						requests.Add(request)
+					}
+					if limit, ok := container.Resources.Limits[resourceName]; ok {
+						limits.Add(limit)
+					}
+				}
+
+				for _, container := range pod.spec.InitContainers {
+					if request, ok := container.Resources.Requests[resourceName]; ok && request.Cmp(requests) > 0 {
+						requests = request.DeepCopy()
+					}
+					if limit, ok := container.Resources.Limits[resourceName]; ok && limit.Cmp(limits) > 0 {
+						limits = limit.DeepCopy()
+					}
+				}
+
+				totalRequests[resourceName], totalLimits[resourceName] = requests, limits
+			}
+
+			checkCaps(pod.specPath+".containers[*].resources", "the Pod in total", totalRequests, totalLimits)
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s requests resources above the allowed maximums", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// EnforceInitContainerLimits denies Pods (and the Pod templates of workloads)
+// with init containers whose resource requests or limits are above those of
+// max: e.g. max.Requests of {cpu: 500m, memory: 1Gi}. As init containers run
+// one at a time, before the regular containers, the scheduler reserves the
+// largest of their requests - or the sum of the regular containers' requests,
+// if that is larger - for the lifetime of the Pod: an over-requested init
+// container wastes those resources long after it has completed. Resources that
+// are not in max are not enforced.
+//
+// Use EnforceMaxResourceLimits to cap the resources of all containers.
+func EnforceInitContainerLimits(ignoredNamespaces []string, max core.ResourceRequirements) AdmitFunc {
+	maxRequests, maxLimits := sortedResourceNames(max.Requests), sortedResourceNames(max.Limits)
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		// The init containers are listed first.
+		for _, container := range pod.containers()[:len(pod.spec.InitContainers)] {
+			field := container.field + ".resources"
+			for _, resourceName := range maxRequests {
+				limit := max.Requests[resourceName]
+				if request, ok := container.Resources.Requests[resourceName]; ok && request.Cmp(limit) > 0 {
+					violations.Add(field+".requests."+string(resourceName), fmt.Sprintf("init container %s requests %s of %s: the maximum is %s", container.Name, request.String(), resourceName, limit.String()))
+				}
+			}
+
+			for _, resourceName := range maxLimits {
+				limit := max.Limits[resourceName]
+				if value, ok := container.Resources.Limits[resourceName]; ok && value.Cmp(limit) > 0 {
+					violations.Add(field+".limits."+string(resourceName), fmt.Sprintf("init container %s has a %s limit of %s: the maximum is %s", container.Name, resourceName, value.String(), limit.String()))
+				}
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s has init containers with resources above the allowed maximums", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// ValidateImagePullSecretsExist denies Pods (and the Pod templates of
+// workloads) whose imagePullSecrets reference Secrets that do not exist in
+// their namespace, which would otherwise surface as an ImagePullBackOff once
+// the Pod is scheduled. As a result, the pull Secrets must be created before
+// the Pods (or workloads) that use them.
+//
+// Referenced Secrets that are not of type kubernetes.io/dockerconfigjson or
+// kubernetes.io/dockercfg cannot be used to pull images: the request is
+// allowed, with a warning for each such Secret.
+//
+// The Secrets are read from the provided SharedInformerFactory (see
+// ClientOption), whose client must be authorized to list & watch secrets across
+// all namespaces. Note that this caches the contents of every Secret in the
+// webhook's memory.
+func ValidateImagePullSecretsExist(factory informers.SharedInformerFactory, ignoredNamespaces []string, opts ...ClientOption) AdmitFunc {
+	secrets := factory.Core().V1().Secrets()
+	lister, synced := secrets.Lister(), secrets.Informer().HasSynced
+	policy := newClientPolicy(opts)
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		namespace := pod.namespace
+		if namespace == "" {
+			namespace = admissionReview.Request.Namespace
+		}
+
+		if isIgnoredNamespace(namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", namespace)
+			return resp, nil
+		}
+
+		if len(pod.spec.ImagePullSecrets) == 0 {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		if !policy.waitForCacheSync(synced) {
+			return policy.unavailable(resp, "the Secret cache has not synced: cannot validate the imagePullSecrets")
+		}
+
+		var violations ViolationList
+		for i, ref := range pod.spec.ImagePullSecrets {
+			field := fmt.Sprintf("%s.imagePullSecrets[%d]", pod.specPath, i)
+			secret, err := lister.Secrets(namespace).Get(ref.Name)
+			if apierrors.IsNotFound(err) {
+				violations.Add(field, fmt.Sprintf("the pull Secret %s does not exist in namespace %s", ref.Name, namespace))
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			if secret.Type != core.SecretTypeDockerConfigJson && secret.Type != core.SecretTypeDockercfg {
+				resp.Warnings = append(resp.Warnings, fmt.Sprintf("%s: the Secret %s is of type %s, and cannot be used to pull images", field, ref.Name, secret.Type))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s references pull Secrets that do not exist", kind, namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// LimitConcurrentRollouts denies updates to Deployments that would start a
+// rollout - by changing the Pod template of an unpaused Deployment, or by
+// resuming a paused Deployment - when maxInProgress other Deployments in the
+// namespace are already rolling out. Simultaneous rollouts of many Deployments
+// can overwhelm the nodes (and registries) of a cluster. An error is returned
+// if maxInProgress is less than 1.
+//
+// A Deployment is rolling out until its controller has observed its latest
+// generation, and each of its replicas has been updated & is available - as
+// reported by "kubectl rollout status". Paused Deployments are not rolling out.
+//
+// The Deployments are read from the provided SharedInformerFactory (see
+// ClientOption), whose client must be authorized to list & watch deployments
+// (in the "apps" API group) across all namespaces.
+//
+// Kinds other than Deployment, and operations other than UPDATE, will be
+// allowed.
+func LimitConcurrentRollouts(factory informers.SharedInformerFactory, maxInProgress int, opts ...ClientOption) (AdmitFunc, error) {
+	if maxInProgress < 1 {
+		return nil, xerrors.Errorf("invalid rollout limit %d: the limit must be at least 1", maxInProgress)
+	}
+
+	deployments := factory.Apps().V1().Deployments()
+	lister := deployments.Lister()
+	synced := deployments.Informer().HasSynced
+	policy := newClientPolicy(opts)
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "Deployment" || admissionReview.Request.Operation != admission.Update {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		deployment, oldDeployment := apps.Deployment{}, apps.Deployment{}
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &deployment); err != nil {
+			return nil, err
+		}
+
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.OldObject.Raw, nil, &oldDeployment); err != nil {
+			return nil, err
+		}
+
+		resumed := oldDeployment.Spec.Paused && !deployment.Spec.Paused
+		templateChanged := !equality.Semantic.DeepEqual(oldDeployment.Spec.Template, deployment.Spec.Template)
+		if deployment.Spec.Paused || (!resumed && !templateChanged) {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		namespace := deployment.Namespace
+		if namespace == "" {
+			namespace = admissionReview.Request.Namespace
+		}
+
+		if !policy.waitForCacheSync(synced) {
+			return policy.unavailable(resp, "the Deployment cache has not synced: cannot count the in-progress rollouts")
+		}
+
+		existing, err := lister.Deployments(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+
+		var inProgress []string
+		for _, other := range existing {
+			if other.Name != deployment.Name && isRollingOut(other) {
+				inProgress = append(inProgress, other.Name)
+			}
+		}
+
+		if len(inProgress) >= maxInProgress {
+			sort.Strings(inProgress)
+			return resp, xerrors.Errorf(
+				"namespace %s already has %d Deployments rolling out (%s): rolling out Deployment %s would exceed the limit of %d concurrent rollouts - retry once a rollout completes",
+				namespace,
+				len(inProgress),
+				strings.Join(inProgress, ", "),
+				deployment.Name,
+				maxInProgress,
+			)
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}, nil
+}
+
+// isRollingOut reports whether the Deployment is rolling out, in the same way
+// as "kubectl rollout status".
+func isRollingOut(deployment *apps.Deployment) bool {
+	if deployment.Spec.Paused {
+		return false
+	}
+
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+
+	status := deployment.Status
+	return status.ObservedGeneration < deployment.Generation ||
+		status.UpdatedReplicas < replicas ||
+		status.Replicas > status.UpdatedReplicas ||
+		status.AvailableReplicas < status.UpdatedReplicas
+}
+
+// DenyStatefulSetSelectorChange denies UPDATE operations that change the
+// .spec.selector of a StatefulSet or Deployment. Selectors are immutable in
+// apps/v1: the API server rejects such changes, but with an error that does
+// not explain how to proceed. Denying them at admission surfaces a clear
+// message, suggesting that the object is re-created instead.
+//
+// Kinds other than StatefulSet and Deployment, and operations other than
+// UPDATE, will be allowed.
+func DenyStatefulSetSelectorChange(ignoredNamespaces []string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if admissionReview.Request.Operation != admission.Update {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		var namespace, name string
+		var oldSelector, selector *metav1.LabelSelector
+		switch kind {
+		case "StatefulSet":
+			oldStatefulSet, statefulSet := apps.StatefulSet{}, apps.StatefulSet{}
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.OldObject.Raw, nil, &oldStatefulSet); err != nil {
+				return nil, err
+			}
+
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &statefulSet); err != nil {
+				return nil, err
+			}
+
+			namespace, name = statefulSet.Namespace, statefulSet.Name
+			oldSelector, selector = oldStatefulSet.Spec.Selector, statefulSet.Spec.Selector
+		case "Deployment":
+			oldDeployment, deployment := apps.Deployment{}, apps.Deployment{}
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.OldObject.Raw, nil, &oldDeployment); err != nil {
+				return nil, err
+			}
+
+			if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &deployment); err != nil {
+				return nil, err
+			}
+
+			namespace, name = deployment.Namespace, deployment.Name
+			oldSelector, selector = oldDeployment.Spec.Selector, deployment.Spec.Selector
+		default:
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		if isIgnoredNamespace(namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", namespace)
+			return resp, nil
+		}
+
+		if equality.Semantic.DeepEqual(oldSelector, selector) {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		return resp, xerrors.Errorf(
+			"%s %s/%s cannot change .spec.selector from %q to %q: selectors are immutable - delete and re-create the %s (e.g. with kubectl replace --force) if this change is intended",
+			kind,
+			namespace,
+			name,
+			metav1.FormatLabelSelector(oldSelector),
+			metav1.FormatLabelSelector(selector),
+			kind,
+		)
+	}
+}
+
+// defaultPrivilegedPortThreshold is the port below which ports are privileged
+// on Linux nodes, and used by node-level services (e.g. SSH on port 22).
+const defaultPrivilegedPortThreshold int32 = 1024
+
+// DenyPrivilegedServicePorts denies Services that expose privileged ports - those
+// below the threshold - on the cluster's nodes, where they can conflict with
+// node-level services such as SSH. A threshold of 0 uses the default of 1024;
+// an error is returned for a threshold outside of 0-65536.
+//
+// This denies NodePort & LoadBalancer Services with a nodePort below the
+// threshold (which requires the API server's --service-node-port-range to
+// include it), and Services with externalIPs that expose a port below the
+// threshold, as kube-proxy intercepts the traffic to each externalIP:port on
+// every node.
+//
+// Kinds other than Service will be allowed.
+func DenyPrivilegedServicePorts(ignoredNamespaces []string, threshold int32) (AdmitFunc, error) {
+	if threshold < 0 || threshold > 65536 {
+		return nil, xerrors.Errorf("invalid privileged port threshold %d: the threshold must be between 0 and 65536", threshold)
+	}
+
+	if threshold == 0 {
+		threshold = defaultPrivilegedPortThreshold
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "Service" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		service := core.Service{}
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &service); err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(service.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", service.Namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		for i, port := range service.Spec.Ports {
+			if port.NodePort != 0 && port.NodePort < threshold {
+				violations.Add(fmt.Sprintf("spec.ports[%d].nodePort", i), fmt.Sprintf("%d is a privileged port (below %d) on the nodes", port.NodePort, threshold))
+			}
+
+			if len(service.Spec.ExternalIPs) > 0 && port.Port < threshold {
+				violations.Add(fmt.Sprintf("spec.ports[%d].port", i), fmt.Sprintf("%d is a privileged port (below %d) exposed on the externalIPs %s", port.Port, threshold, strings.Join(service.Spec.ExternalIPs, ", ")))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("Service %s/%s exposes privileged ports on the nodes", service.Namespace, service.Name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}, nil
+}
+
+// DenyStaleUpdates denies UPDATE operations on objects of the given kinds -
+// e.g. the custom resource of a controller - that are not based on the current
+// version of the object, to prevent lost updates: e.g. between replicas of a
+// controller that reconcile the same object. An update is stale if:
+//
+//   - it does not set a metadata.resourceVersion: an unconditional update,
+//     which overwrites any concurrent change. Custom resources always require
+//     one, but most built-in kinds do not.
+//   - its metadata.resourceVersion differs from that of the current object.
+//   - its metadata.managedFields record an update by a field manager that is
+//     older - by more than maxClockSkew - than that manager's last update of
+//     the current object: the client sent back a copy of the object that it
+//     read before that update. The timestamps are set by the API server(s)
+//     that served each update, so maxClockSkew should cover the skew between
+//     the clocks of the API server replicas (e.g. a few seconds).
+//   - it moves status.observedGeneration backwards, as a controller writing
+//     the status it computed from an older generation of the object would.
+//
+// An error is returned if no kinds are given, or if maxClockSkew is negative.
+// Requests for other kinds, and operations other than UPDATE, are allowed.
+//
+// Limitations: resourceVersions are opaque, and are only compared for
+// equality - not ordered. The API server rejects a mismatched resourceVersion
+// with a 409 Conflict before calling validating webhooks, and so the second
+// check only applies when DenyStaleUpdates is served by a mutating webhook.
+// The managedFields check only detects clients that send back the
+// managedFields of the copy they read: clients that omit them (or use server-
+// side apply) are not checked. The time at which the client read the object is
+// not part of the request, and cannot be enforced.
+func DenyStaleUpdates(maxClockSkew time.Duration, kinds ...metav1.GroupKind) (AdmitFunc, error) {
+	if len(kinds) == 0 {
+		return nil, xerrors.New("at least one kind must be provided")
+	}
+
+	if maxClockSkew < 0 {
+		return nil, xerrors.Errorf("invalid maxClockSkew %s: the maxClockSkew cannot be negative", maxClockSkew)
+	}
+
+	enforced := make(map[metav1.GroupKind]bool, len(kinds))
+	for _, kind := range kinds {
+		enforced[kind] = true
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if admissionReview.Request.Operation != admission.Update || !enforced[metav1.GroupKind{Group: admissionReview.Request.Kind.Group, Kind: kind}] {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		object, oldObject := unstructured.Unstructured{}, unstructured.Unstructured{}
+		if err := object.UnmarshalJSON(admissionReview.Request.Object.Raw); err != nil {
+			return nil, err
+		}
+
+		if err := oldObject.UnmarshalJSON(admissionReview.Request.OldObject.Raw); err != nil {
+			return nil, err
+		}
+
+		namespace := object.GetNamespace()
+		if namespace == "" {
+			namespace = admissionReview.Request.Namespace
+		}
+
+		name := qualifiedName(&metav1.ObjectMeta{Namespace: namespace, Name: object.GetName()})
+		resourceVersion, current := object.GetResourceVersion(), oldObject.GetResourceVersion()
+		if resourceVersion == "" {
+			return resp, xerrors.Errorf(
+				"%s %s: unconditional updates are not allowed: set metadata.resourceVersion to the version that the update is based on - read the latest %s and retry",
+				kind,
+				name,
+				kind,
+			)
+		}
+
+		if resourceVersion != current {
+			return resp, xerrors.Errorf(
+				"%s %s: the update is based on a stale resourceVersion %s (the current resourceVersion is %s) - read the latest %s and retry",
+				kind,
+				name,
+				resourceVersion,
+				current,
+				kind,
+			)
+		}
+
+		if stale, ok := staleManagedFieldsEntry(object.GetManagedFields(), oldObject.GetManagedFields(), maxClockSkew); ok {
+			return resp, xerrors.Errorf(
+				"%s %s: the update is based on a copy of the %s from before its %s by %s at %s - read the latest %s and retry",
+				kind,
+				name,
+				kind,
+				strings.ToLower(string(stale.Operation)),
+				stale.Manager,
+				stale.Time.UTC().Format(time.RFC3339),
+				kind,
+			)
+		}
+
+		observed, found, _ := unstructured.NestedInt64(object.Object, "status", "observedGeneration")
+		oldObserved, oldFound, _ := unstructured.NestedInt64(oldObject.Object, "status", "observedGeneration")
+		if found && oldFound && observed < oldObserved {
+			return resp, xerrors.Errorf(
+				"%s %s: status.observedGeneration cannot move back from %d to %d: the status was computed from a stale view of the %s - read the latest %s and retry",
+				kind,
+				name,
+				oldObserved,
+				observed,
+				kind,
+				kind,
+			)
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}, nil
+}
+
+// staleManagedFieldsEntry returns the managedFields entry of the current object
+// that the updated object predates: the first entry whose time is more than
+// maxClockSkew after that of the same manager (and operation) in the update.
+func staleManagedFieldsEntry(updated, current []metav1.ManagedFieldsEntry, maxClockSkew time.Duration) (metav1.ManagedFieldsEntry, bool) {
+	type managerKey struct {
+		manager   string
+		operation metav1.ManagedFieldsOperationType
+	}
+
+	times := make(map[managerKey]time.Time, len(updated))
+	for _, entry := range updated {
+		if entry.Time != nil {
+			times[managerKey{entry.Manager, entry.Operation}] = entry.Time.Time
+		}
+	}
+
+	for _, entry := range current {
+		if entry.Time == nil {
+			continue
+		}
+
+		updatedTime, ok := times[managerKey{entry.Manager, entry.Operation}]
+		if ok && entry.Time.Time.Sub(updatedTime) > maxClockSkew {
+			return entry, true
+		}
+	}
+
+	return metav1.ManagedFieldsEntry{}, false
+}
+
+// EnforceRegistryPerNamespace denies Pods (and the Pod templates of workloads)
+// with container images from registries that their namespace is not entitled
+// to, so that each tenant's registries are managed via the metadata of their
+// namespace rather than a single global list. The registries are read from the
+// annotationKey annotation of the namespace: a comma-separated list of
+// registry hosts (e.g. "registry.example.com") or repository prefixes (e.g.
+// "ghcr.io/example"). Images without a registry host are from docker.io.
+//
+// Namespaces without the annotation are not entitled to any registry: their
+// Pods are denied. The namespace annotations are read from the lookup:
+// requests are rejected if their namespace does not exist, and answered
+// according to the lookup's ClientFailurePolicy if the lookup's cache has not
+// synced.
+//
+// EnforceRegistryPerNamespace inspects init, regular & ephemeral containers, in
+// Pods and the Pod templates of Deployments, StatefulSets, DaemonSets,
+// ReplicaSets, ReplicationControllers, Jobs & CronJobs. Unknown object kinds
+// are answered according to AdmissionHandler.UnknownKinds (allowed by default).
+func EnforceRegistryPerNamespace(lookup *NamespaceLookup, ignoredNamespaces []string, annotationKey string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		namespace := pod.namespace
+		if namespace == "" {
+			namespace = admissionReview.Request.Namespace
+		}
+
+		if isIgnoredNamespace(namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", namespace)
+			return resp, nil
+		}
+
+		annotations, err := lookup.NamespaceAnnotations(namespace)
+		if xerrors.Is(err, ErrCacheNotSynced) {
+			return lookup.policy.unavailable(resp, fmt.Sprintf("could not read the annotations of namespace %s: %s", namespace, err))
+		} else if err != nil {
+			return nil, xerrors.Errorf("could not read the annotations of namespace %s: %w", namespace, err)
+		}
+
+		var registries []string
+		for _, registry := range strings.Split(annotations[annotationKey], ",") {
+			if registry = strings.TrimSuffix(strings.TrimSpace(registry), "/"); registry != "" {
+				registries = append(registries, registry)
+			}
+		}
+
+		if len(registries) == 0 {
+			return resp, xerrors.Errorf(
+				"%s %s/%s cannot be admitted: namespace %s is not entitled to any registries (set the %s annotation of the namespace)",
+				kind,
+				namespace,
+				pod.name,
+				namespace,
+				annotationKey,
+			)
+		}
+
+		var violations ViolationList
+		for _, container := range pod.containers() {
+			repository := imageRepository(container.Image)
+
+			entitled := false
+			for _, registry := range registries {
+				if repository == registry || strings.HasPrefix(repository, registry+"/") {
+					entitled = true
+					break
+				}
+			}
+
+			if !entitled {
+				violations.Add(container.field+".image", fmt.Sprintf("%s is not from a registry that namespace %s is entitled to (%s)", container.Image, namespace, strings.Join(registries, ", ")))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s has images from registries that are not allowed", kind, namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// imageRepository returns the repository of the image, including its registry
+// host: images without a registry host (e.g. "nginx" or "team/app") are from
+// docker.io, with official images under "library/".
+func imageRepository(image string) string {
+	repository := parseImageReference(image).repository
+	i := strings.Index(repository, "/")
+	if i >= 0 {
+		if host := repository[:i]; strings.ContainsAny(host, ".:") || host == "localhost" {
+			return repository
+		}
+
+		return "docker.io/" + repository
+	}
+
+	return "docker.io/library/" + repository
+}
+
+// EnforceContainerPortRange denies containers that declare a containerPort
+// outside of the (inclusive) min & max bounds. Network policy tooling that keys
+// off port ranges silently leaves ports outside of its range unprotected.
+//
+// EnforceContainerPortRange can inspect Pods and the Pod templates of
+// Deployments, StatefulSets, DaemonSets, ReplicaSets, ReplicationControllers,
+// Jobs & CronJobs. Unknown object kinds are answered according to
+// AdmissionHandler.UnknownKinds (allowed by default).
+func EnforceContainerPortRange(ignoredNamespaces []string, min, max int32) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var violations ViolationList
+		for _, container := range pod.containers() {
+			for i, port := range container.Ports {
+				if port.ContainerPort < min || port.ContainerPort > max {
+					violations.Add(
+						fmt.Sprintf("%s.ports[%d].containerPort", container.field, i),
+						fmt.Sprintf("container %s declares port %d, outside of the allowed range %d-%d", container.Name, port.ContainerPort, min, max),
+					)
+				}
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s declares container ports outside of the allowed range", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// EnforceMaxVolumes denies Pods (and the Pod templates of workloads) with more
+// than maxVolumes volumes, or more than maxClaims persistent volumes. Cloud
+// providers cap the number of volumes that can be attached to each node: a Pod
+// that claims more than a node can attach remains Pending.
+//
+// The claims are the persistentVolumeClaim volumes, and the generic ephemeral
+// volumes (which are provisioned as PersistentVolumeClaims): these are the
+// attach-limited volumes. A limit <= 0 is not enforced.
+//
+// EnforceMaxVolumes can inspect Pods and the Pod templates of Deployments,
+// StatefulSets, DaemonSets, ReplicaSets, ReplicationControllers, Jobs &
+// CronJobs. Unknown object kinds are answered according to
+// AdmissionHandler.UnknownKinds (allowed by default). The volumeClaimTemplates
+// of a StatefulSet are not counted.
+func EnforceMaxVolumes(ignoredNamespaces []string, maxVolumes, maxClaims int) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		var claims int
+		for _, volume := range pod.spec.Volumes {
+			if volume.PersistentVolumeClaim != nil || volume.Ephemeral != nil {
+				claims++
+			}
+		}
+
+		field := pod.specPath + ".volumes"
+		var violations ViolationList
+		if maxVolumes > 0 && len(pod.spec.Volumes) > maxVolumes {
+			violations.Add(field, fmt.Sprintf("%d volumes exceed the limit of %d", len(pod.spec.Volumes), maxVolumes))
+		}
+
+		if maxClaims > 0 && claims > maxClaims {
+			violations.Add(field, fmt.Sprintf("%d persistent volumes exceed the limit of %d that can be attached per Pod", claims, maxClaims))
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s has too many volumes", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// DenyPVCStorageClassChange denies UPDATE operations that change the
+// storageClassName of a PersistentVolumeClaim. The storage class of a claim is
+// immutable once it is set: the change would be rejected by the API server
+// with a generic "field is immutable" error, or - when applied from a manifest
+// - be mistaken for having moved the volume to the new class.
+//
+// Setting the storageClassName of a claim that has none is allowed, as the API
+// server does when it assigns the default StorageClass to an unbound claim.
+//
+// Kinds other than PersistentVolumeClaim, and operations other than UPDATE,
+// will be allowed.
+func DenyPVCStorageClassChange(ignoredNamespaces []string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "PersistentVolumeClaim" || admissionReview.Request.Operation != admission.Update {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		oldClaim, claim := core.PersistentVolumeClaim{}, core.PersistentVolumeClaim{}
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.OldObject.Raw, nil, &oldClaim); err != nil {
+			return nil, err
+		}
+
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &claim); err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(claim.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", claim.Namespace)
+			return resp, nil
+		}
+
+		if oldClaim.Spec.StorageClassName == nil {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		from, to := *oldClaim.Spec.StorageClassName, ""
+		if claim.Spec.StorageClassName != nil {
+			to = *claim.Spec.StorageClassName
+		}
+
+		if from == to {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		return resp, xerrors.Errorf(
+			"PersistentVolumeClaim %s/%s cannot change storageClassName from %q to %q: the storage class of a claim is immutable after creation - create a new claim with the storage class, and migrate the data to it",
+			claim.Namespace,
+			claim.Name,
+			from,
+			to,
+		)
+	}
+}
+
+// tlsProtocolVersions orders the SSL/TLS protocol versions, keyed by their
+// normalized names (see parseTLSProtocol).
+var tlsProtocolVersions = map[string]int{
+	"sslv2": 0x0200,
+	"sslv3": 0x0300,
+	"1.0":   0x0301,
+	"1.1":   0x0302,
+	"1.2":   0x0303,
+	"1.3":   0x0304,
+}
+
+// parseTLSProtocol returns the version of a (case-insensitive) protocol name,
+// as configured across ingress controllers: e.g. "TLSv1.2" (ingress-nginx), or
+// "1.2" and "TLS1.2". "TLSv1" is TLS 1.0.
+func parseTLSProtocol(name string) (int, bool) {
+	normalized := strings.ToLower(name)
+	if !strings.HasPrefix(normalized, "ssl") {
+		normalized = strings.TrimPrefix(strings.TrimPrefix(normalized, "tls"), "v")
+	}
+
+	if normalized == "1" {
+		normalized = "1.0"
+	}
+
+	version, ok := tlsProtocolVersions[normalized]
+	return version, ok
+}
+
+// EnforceIngressTLSVersion requires Ingresses that terminate TLS (those with a
+// .spec.tls section) to restrict their TLS protocols to minVersion (e.g.
+// "TLSv1.2") or later, via the controllerAnnotation annotation - e.g.
+// "nginx.ingress.kubernetes.io/ssl-protocols" for ingress-nginx. The
+// annotation lists the enabled protocols, separated by spaces or commas: each
+// of them must be at least minVersion, so that TLS 1.0 & 1.1 (or SSL) cannot be
+// negotiated.
+//
+// An error is returned if minVersion is not a TLS version, or the annotation
+// key is empty. Ingresses without the annotation are denied, as the protocols
+// enabled by default vary across controllers (and their versions).
+//
+// Kinds other than Ingress will be allowed.
+func EnforceIngressTLSVersion(ignoredNamespaces []string, minVersion string, controllerAnnotation string) (AdmitFunc, error) {
+	min, ok := parseTLSProtocol(minVersion)
+	if !ok || min < tlsProtocolVersions["1.0"] {
+		return nil, xerrors.Errorf("invalid minimum TLS version %q: must be one of TLSv1, TLSv1.1, TLSv1.2 or TLSv1.3", minVersion)
+	}
+
+	if controllerAnnotation == "" {
+		return nil, xerrors.New("the annotation that configures the TLS protocols must be provided")
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "Ingress" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		ingress, err := decodeIngress(admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(ingress.Namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", ingress.Namespace)
+			return resp, nil
+		}
+
+		if len(ingress.Spec.TLS) == 0 {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		field := fmt.Sprintf("metadata.annotations[%s]", controllerAnnotation)
+		configured, ok := ingress.Annotations[controllerAnnotation]
+		protocols := strings.FieldsFunc(configured, func(r rune) bool {
+			return r == ' ' || r == ','
+		})
+
+		var violations ViolationList
+		if !ok || len(protocols) == 0 {
+			violations.Add(field, fmt.Sprintf("must restrict the TLS protocols to %s or later", minVersion))
+		}
+
+		for _, protocol := range protocols {
+			if version, ok := parseTLSProtocol(protocol); !ok {
+				violations.Add(field, fmt.Sprintf("%s is not a known TLS protocol (configured: %q)", protocol, configured))
+			} else if version < min {
+				violations.Add(field, fmt.Sprintf("%s is older than the minimum of %s (configured: %q)", protocol, minVersion, configured))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("Ingress %s/%s allows TLS protocols older than %s", ingress.Namespace, ingress.Name, minVersion))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}, nil
+}
+
+// EnforceMaxSkew requires the topologySpreadConstraints of Pods to be
+// meaningful: each constraint must have a maxSkew of at most maxSkew - a loose
+// skew (e.g. 100) does not spread the Pods at all - and one of the constraints
+// must spread the Pods across the topologyKey (e.g.
+// "topology.kubernetes.io/zone"). Constraints across other keys (e.g.
+// "kubernetes.io/hostname" as well as the zone) are allowed. An empty
+// topologyKey does not require a key.
+//
+// An error is returned if maxSkew is less than 1. Pods that do not declare
+// topologySpreadConstraints are allowed.
+//
+// EnforceMaxSkew inspects Pods and the Pod templates of Deployments,
+// StatefulSets, DaemonSets, ReplicaSets, ReplicationControllers, Jobs &
+// CronJobs. Unknown object kinds are answered according to
+// AdmissionHandler.UnknownKinds (allowed by default).
+func EnforceMaxSkew(ignoredNamespaces []string, topologyKey string, maxSkew int32) (AdmitFunc, error) {
+	if maxSkew < 1 {
+		return nil, xerrors.Errorf("invalid maxSkew %d: the maxSkew must be at least 1", maxSkew)
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		constraints := pod.spec.TopologySpreadConstraints
+		if len(constraints) == 0 {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		field := pod.specPath + ".topologySpreadConstraints"
+		spreadsAcrossKey := topologyKey == ""
+
+		var violations ViolationList
+		for i, constraint := range constraints {
+			if constraint.TopologyKey == topologyKey {
+				spreadsAcrossKey = true
+			}
+
+			if constraint.MaxSkew > maxSkew {
+				violations.Add(
+					fmt.Sprintf("%s[%d].maxSkew", field, i),
+					fmt.Sprintf("the constraint across %q has a maxSkew of %d, above the maximum of %d", constraint.TopologyKey, constraint.MaxSkew, maxSkew),
+				)
+			}
+		}
+
+		if !spreadsAcrossKey {
+			violations.Add(field, fmt.Sprintf("no constraint spreads the Pods across the required topology key %q", topologyKey))
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s has ineffective topologySpreadConstraints", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}, nil
+}
+
+// podObject holds the PodSpec (and the associated Pod metadata) of a Pod, or of
+// the PodTemplateSpec of a workload controller.
+type podObject struct {
+	// The namespace & name of the submitted object.
+	namespace string
+	name      string
+	// The metadata of the Pod, or the Pod template.
+	meta metav1.ObjectMeta
+	spec core.PodSpec
+	// The path of the PodSpec within the object - e.g. "spec.template.spec".
+	specPath string
+	// controlled is true if the submitted object is managed by a controller
+	// (i.e. it has a controller ownerReference).
+	controlled bool
+}
+
+// decodePodObject decodes the PodSpec out of the supported kinds: Pods, and the
+// built-in Kinds that include a PodTemplateSpec. Unknown kinds return an error.
+func decodePodObject(kind string, raw []byte) (*podObject, error) {
+	var object metav1.Object
+	var template *core.PodTemplateSpec
+	specPath := "spec.template.spec"
+	switch kind {
+	case "Pod":
+		pod := core.Pod{}
+		if _, _, err := universalDeserializer.Decode(raw, nil, &pod); err != nil {
+			return nil, err
+		}
+
+		object, template = &pod, &core.PodTemplateSpec{ObjectMeta: pod.ObjectMeta, Spec: pod.Spec}
+		specPath = "spec"
+	case "Deployment":
+		deployment := apps.Deployment{}
+		if _, _, err := universalDeserializer.Decode(raw, nil, &deployment); err != nil {
+			return nil, err
+		}
+
+		object, template = &deployment, &deployment.Spec.Template
+	case "StatefulSet":
+		statefulset := apps.StatefulSet{}
+		if _, _, err := universalDeserializer.Decode(raw, nil, &statefulset); err != nil {
+			return nil, err
+		}
+
+		object, template = &statefulset, &statefulset.Spec.Template
+	case "DaemonSet":
+		daemonset := apps.DaemonSet{}
+		if _, _, err := universalDeserializer.Decode(raw, nil, &daemonset); err != nil {
+			return nil, err
+		}
+
+		object, template = &daemonset, &daemonset.Spec.Template
+	case "ReplicaSet":
+		replicaset := apps.ReplicaSet{}
+		if _, _, err := universalDeserializer.Decode(raw, nil, &replicaset); err != nil {
+			return nil, err
+		}
+
+		object, template = &replicaset, &replicaset.Spec.Template
+	case "ReplicationController":
+		controller := core.ReplicationController{}
+		if _, _, err := universalDeserializer.Decode(raw, nil, &controller); err != nil {
+			return nil, err
+		}
+
+		// The template is optional for ReplicationControllers.
+		if controller.Spec.Template == nil {
+			controller.Spec.Template = &core.PodTemplateSpec{}
+		}
+
+		object, template = &controller, controller.Spec.Template
+	case "Job":
+		job := batch.Job{}
+		if _, _, err := universalDeserializer.Decode(raw, nil, &job); err != nil {
+			return nil, err
+		}
+
+		object, template = &job, &job.Spec.Template
+	case "CronJob":
+		cronJob := batch.CronJob{}
+		if _, _, err := universalDeserializer.Decode(raw, nil, &cronJob); err != nil {
+			return nil, err
+		}
+
+		object, template = &cronJob, &cronJob.Spec.JobTemplate.Spec.Template
+		specPath = "spec.jobTemplate.spec.template.spec"
+	default:
+		return nil, unsupportedKind(kind)
+	}
+
+	return &podObject{
+		namespace:  object.GetNamespace(),
+		name:       object.GetName(),
+		meta:       template.ObjectMeta,
+		spec:       template.Spec,
+		specPath:   specPath,
+		controlled: metav1.GetControllerOfNoCopy(object) != nil,
+	}, nil
+}
+
+// podContainer is a container declared in a PodSpec, along with its field path
+// within the submitted object - e.g. "spec.template.spec.containers[nginx]".
+type podContainer struct {
+	core.Container
+	field string
+}
+
+// containers returns the init, regular and ephemeral containers declared in
+// the PodSpec.
+func (p *podObject) containers() []podContainer {
+	spec := &p.spec
+	containers := make([]podContainer, 0, len(spec.InitContainers)+len(spec.Containers)+len(spec.EphemeralContainers))
+	for _, container := range spec.InitContainers {
+		containers = append(containers, podContainer{container, fmt.Sprintf("%s.initContainers[%s]", p.specPath, container.Name)})
+	}
+
+	for _, container := range spec.Containers {
+		containers = append(containers, podContainer{container, fmt.Sprintf("%s.containers[%s]", p.specPath, container.Name)})
+	}
+
+	for _, ephemeral := range spec.EphemeralContainers {
+		containers = append(containers, podContainer{core.Container(ephemeral.EphemeralContainerCommon), fmt.Sprintf("%s.ephemeralContainers[%s]", p.specPath, ephemeral.Name)})
+	}
+
+	return containers
+}
+
+// isIgnoredNamespace reports whether the namespace is one of the (case-sensitive)
+// ignoredNamespaces.
+func isIgnoredNamespace(namespace string, ignoredNamespaces []string) bool {
+	for _, ns := range ignoredNamespaces {
+		if namespace == ns {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EnforceNamespaceAnnotations ensures that Namespaces have the required
+// annotations, using the same strict (case-sensitive) key-match and matchFunc
+// semantics as EnforcePodAnnotations. This allows tooling that keys off
+// namespace annotations - e.g. a network policy tier - to rely on them being
+// present & valid from creation.
+//
+// Kinds other than Namespace will be allowed.
+func EnforceNamespaceAnnotations(requiredAnnotations map[string]func(string) bool) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		if kind != "Namespace" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		namespace := core.Namespace{}
+		if _, _, err := universalDeserializer.Decode(admissionReview.Request.Object.Raw, nil, &namespace); err != nil {
+			return nil, err
+		}
+
+		missing, err := matchAnnotations(requiredAnnotations, namespace.GetAnnotations())
+		if err != nil {
+			return resp, err
+		}
+
+		if len(missing) > 0 {
+			return resp, xerrors.Errorf("%s %v", namespaceDeniedError, missing)
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// matchAnnotations checks whether each of the (strictly matched) required
+// annotation keys exists, and then runs the user-provided matchFunc against its
+// value. It returns a map of the keys that were missing, or whose value did not
+// match, and the reason.
+func matchAnnotations(requiredAnnotations map[string]func(string) bool, annotations map[string]string) (map[string]string, error) {
+	missing := make(map[string]string)
+	for requiredKey, matchFunc := range requiredAnnotations {
+		if matchFunc == nil {
+			return nil, xerrors.Errorf("cannot validate annotations (%s) with a nil matchFunc", requiredKey)
+		}
+
+		if existingVal, ok := annotations[requiredKey]; !ok {
+			// Key does not exist; add it to the missing annotations list
+			missing[requiredKey] = "key was not found"
+		} else {
+			if matched := matchFunc(existingVal); !matched {
+				missing[requiredKey] = "value did not match"
+			}
+			// Key exists & matchFunc returned OK.
+		}
+	}
+
+	return missing, nil
 }
 
 // ensureHasAnnotations checks whether the provided ObjectMeta has the required
@@ -5,13 +5,24 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	admission "k8s.io/api/admission/v1"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 var (
@@ -19,6 +30,10 @@ var (
 	testErrMessageMismatch   = "error message does not match: got %q - expected %q"
 )
 
+// objectTest is a table test case for runObjectTests. The kind, object,
+// oldObject, operation and namespace fields populate the AdmissionRequest:
+// inputs specific to an AdmitFunc belong in the closure passed to
+// runObjectTests.
 type objectTest struct {
 	testName            string
 	admitFunc           AdmitFunc
@@ -27,6 +42,9 @@ type objectTest struct {
 	kind                meta.GroupVersionKind
 	object              interface{}
 	rawObject           []byte
+	oldObject           interface{}
+	operation           admission.Operation
+	namespace           string
 	ignoredNamespaces   []string
 	expectedMessage     string
 	shouldAllow         bool
@@ -46,6 +64,78 @@ func newTestAdmissionRequest(kind meta.GroupVersionKind, object []byte, expected
 	return ar
 }
 
+// runObjectTests runs each objectTest against the AdmitFunc returned by
+// newAdmitFunc, checking both the admission decision and any denial message.
+func runObjectTests(t *testing.T, tests []objectTest, newAdmitFunc func(tt objectTest) AdmitFunc) {
+	t.Helper()
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			incomingReview := admission.AdmissionReview{
+				Request: &admission.AdmissionRequest{},
+			}
+			incomingReview.Request.Kind = tt.kind
+			incomingReview.Request.Operation = tt.operation
+			incomingReview.Request.Namespace = tt.namespace
+			incomingReview.Request.Object.Raw = marshalTestObject(t, tt.object, tt.rawObject)
+			incomingReview.Request.OldObject.Raw = marshalTestObject(t, tt.oldObject, nil)
+
+			resp, err := newAdmitFunc(tt)(&incomingReview)
+			if err != nil {
+				if tt.expectedMessage != err.Error() {
+					t.Fatalf(testErrMessageMismatch, err.Error(), tt.expectedMessage)
+				}
+
+				if tt.shouldAllow {
+					t.Fatalf("incorrectly rejected admission for Kind: %v: %s", tt.kind, err.Error())
+				}
+
+				t.Logf("correctly rejected admission for Kind: %v: %s", tt.kind, err.Error())
+				return
+			}
+
+			if resp.Allowed != tt.shouldAllow {
+				t.Fatalf(testErrAdmissionMismatch, tt.kind, resp.Allowed, tt.shouldAllow)
+			}
+
+			// AdmitFuncs may also deny admission without returning an error.
+			if !resp.Allowed && resp.Result.Message != tt.expectedMessage {
+				t.Fatalf(testErrMessageMismatch, resp.Result.Message, tt.expectedMessage)
+			}
+		})
+	}
+}
+
+// marshalTestObject returns the raw object if set, and otherwise serializes the
+// (typed) object.
+func marshalTestObject(t *testing.T, object interface{}, raw []byte) []byte {
+	if raw != nil || object == nil {
+		return raw
+	}
+
+	serialized, err := json.Marshal(object)
+	if err != nil {
+		t.Fatalf("could not marshal k8s API object: %v", err)
+	}
+
+	return serialized
+}
+
+// newTestPodWithImages returns a Pod named "web", with a container (named
+// "container-<index>") for each of the images.
+func newTestPodWithImages(namespace string, images ...string) *corev1.Pod {
+	pod := &corev1.Pod{
+		TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+		ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace},
+	}
+	for i, image := range images {
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: fmt.Sprintf("container-%d", i), Image: image})
+	}
+
+	return pod
+}
+
 // TestDenyIngress validates that the DenyIngress AdmitFunc correctly rejects
 // admission of Ingress objects to a cluster.
 func TestDenyIngress(t *testing.T) {
@@ -616,3 +706,5163 @@ func TestEnforcePodAnnotations(t *testing.T) {
 	}
 
 }
+
+func TestDenyMutableConfigData(t *testing.T) {
+	t.Parallel()
+
+	immutableMeta := meta.ObjectMeta{
+		Name:        "app-config",
+		Namespace:   "default",
+		Annotations: map[string]string{ImmutableConfigAnnotation: "true"},
+	}
+	configMapKind := meta.GroupVersionKind{Group: "", Kind: "ConfigMap", Version: "v1"}
+	secretKind := meta.GroupVersionKind{Group: "", Kind: "Secret", Version: "v1"}
+	immutableMessage := "ConfigMap default/app-config is immutable by policy (config.corp/immutable: \"true\"): contact the administrators of the default namespace to change it"
+
+	var denyTests = []objectTest{
+		{
+			testName:  "Reject changes to the data of an immutable ConfigMap",
+			kind:      configMapKind,
+			operation: admission.Update,
+			oldObject: &corev1.ConfigMap{
+				TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+				ObjectMeta: immutableMeta,
+				Data:       map[string]string{"log-level": "info"},
+			},
+			object: &corev1.ConfigMap{
+				TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+				ObjectMeta: immutableMeta,
+				Data:       map[string]string{"log-level": "debug"},
+			},
+			expectedMessage: immutableMessage,
+			shouldAllow:     false,
+		},
+		{
+			testName:  "Reject removing the immutable annotation",
+			kind:      configMapKind,
+			operation: admission.Update,
+			oldObject: &corev1.ConfigMap{
+				TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+				ObjectMeta: immutableMeta,
+				Data:       map[string]string{"log-level": "info"},
+			},
+			object: &corev1.ConfigMap{
+				TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "app-config", Namespace: "default"},
+				Data:       map[string]string{"log-level": "info"},
+			},
+			expectedMessage: immutableMessage,
+			shouldAllow:     false,
+		},
+		{
+			testName:  "Reject changes to the data of an immutable Secret",
+			kind:      secretKind,
+			operation: admission.Update,
+			oldObject: &corev1.Secret{
+				TypeMeta:   meta.TypeMeta{Kind: "Secret", APIVersion: "v1"},
+				ObjectMeta: immutableMeta,
+				Data:       map[string][]byte{"password": []byte("hunter2")},
+			},
+			object: &corev1.Secret{
+				TypeMeta:   meta.TypeMeta{Kind: "Secret", APIVersion: "v1"},
+				ObjectMeta: immutableMeta,
+				Data:       map[string][]byte{"password": []byte("hunter3")},
+			},
+			expectedMessage: "Secret default/app-config is immutable by policy (config.corp/immutable: \"true\"): contact the administrators of the default namespace to change it",
+			shouldAllow:     false,
+		},
+		{
+			testName:  "Name the contact annotated on the existing object",
+			kind:      configMapKind,
+			operation: admission.Update,
+			oldObject: &corev1.ConfigMap{
+				TypeMeta: meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{
+					Name:      "app-config",
+					Namespace: "default",
+					Annotations: map[string]string{
+						ImmutableConfigAnnotation:        "true",
+						ImmutableConfigContactAnnotation: "payments-team@example.com",
+					},
+				},
+				Data: map[string]string{"log-level": "info"},
+			},
+			object: &corev1.ConfigMap{
+				TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+				ObjectMeta: immutableMeta,
+				Data:       map[string]string{"log-level": "info"},
+			},
+			expectedMessage: "ConfigMap default/app-config is immutable by policy (config.corp/immutable: \"true\"): contact payments-team@example.com to change it",
+			shouldAllow:     false,
+		},
+		{
+			testName:  "Allow label-only changes to an immutable ConfigMap",
+			kind:      configMapKind,
+			operation: admission.Update,
+			oldObject: &corev1.ConfigMap{
+				TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+				ObjectMeta: immutableMeta,
+				Data:       map[string]string{"log-level": "info"},
+			},
+			object: &corev1.ConfigMap{
+				TypeMeta: meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{
+					Name:        "app-config",
+					Namespace:   "default",
+					Labels:      map[string]string{"team": "platform"},
+					Annotations: map[string]string{ImmutableConfigAnnotation: "true"},
+				},
+				Data: map[string]string{"log-level": "info"},
+			},
+			shouldAllow: true,
+		},
+		{
+			testName:  "Allow changes to a ConfigMap without the immutable annotation",
+			kind:      configMapKind,
+			operation: admission.Update,
+			oldObject: &corev1.ConfigMap{
+				TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "app-config", Namespace: "default"},
+				Data:       map[string]string{"log-level": "info"},
+			},
+			object: &corev1.ConfigMap{
+				TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "app-config", Namespace: "default"},
+				Data:       map[string]string{"log-level": "debug"},
+			},
+			shouldAllow: true,
+		},
+		{
+			testName:          "Allow changes to an immutable ConfigMap in a whitelisted namespace",
+			kind:              configMapKind,
+			operation:         admission.Update,
+			ignoredNamespaces: []string{"default"},
+			oldObject: &corev1.ConfigMap{
+				TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+				ObjectMeta: immutableMeta,
+				Data:       map[string]string{"log-level": "info"},
+			},
+			object: &corev1.ConfigMap{
+				TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+				ObjectMeta: immutableMeta,
+				Data:       map[string]string{"log-level": "debug"},
+			},
+			shouldAllow: true,
+		},
+		{
+			testName:  "Allow creating an immutable ConfigMap",
+			kind:      configMapKind,
+			operation: admission.Create,
+			object: &corev1.ConfigMap{
+				TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+				ObjectMeta: immutableMeta,
+				Data:       map[string]string{"log-level": "info"},
+			},
+			shouldAllow: true,
+		},
+		{
+			testName:        "Don't reject Services",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "Service", Version: "v1"},
+			operation:       admission.Update,
+			rawObject:       nil,
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return DenyMutableConfigData(tt.ignoredNamespaces)
+	})
+}
+
+func TestEnforceCronJobPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy := CronJobPolicy{
+		DenyAllowConcurrent:           true,
+		MaxSuccessfulJobsHistoryLimit: 3,
+		MaxFailedJobsHistoryLimit:     1,
+	}
+	cronJobKind := meta.GroupVersionKind{Group: "batch", Kind: "CronJob", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a CronJob that satisfies the policy",
+			kind:            cronJobKind,
+			rawObject:       []byte(`{"kind":"CronJob","apiVersion":"batch/v1","metadata":{"name":"backup","namespace":"default"},"spec":{"schedule":"*/5 * * * *","concurrencyPolicy":"Forbid","successfulJobsHistoryLimit":3,"failedJobsHistoryLimit":1,"startingDeadlineSeconds":60}}`),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow a batch/v1beta1 CronJob that satisfies the policy",
+			kind:            meta.GroupVersionKind{Group: "batch", Kind: "CronJob", Version: "v1beta1"},
+			rawObject:       []byte(`{"kind":"CronJob","apiVersion":"batch/v1beta1","metadata":{"name":"backup","namespace":"default"},"spec":{"schedule":"*/5 * * * *","concurrencyPolicy":"Replace","successfulJobsHistoryLimit":1,"failedJobsHistoryLimit":1,"startingDeadlineSeconds":60}}`),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a CronJob allowing concurrent Jobs",
+			kind:            cronJobKind,
+			rawObject:       []byte(`{"kind":"CronJob","apiVersion":"batch/v1","metadata":{"name":"backup","namespace":"default"},"spec":{"schedule":"*/5 * * * *","concurrencyPolicy":"Allow","successfulJobsHistoryLimit":3,"failedJobsHistoryLimit":1,"startingDeadlineSeconds":60}}`),
+			expectedMessage: "CronJob default/backup violates the CronJob policy: spec.concurrencyPolicy: must be Forbid or Replace (got Allow)",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a batch/v1beta1 CronJob with unbounded history",
+			kind:            meta.GroupVersionKind{Group: "batch", Kind: "CronJob", Version: "v1beta1"},
+			rawObject:       []byte(`{"kind":"CronJob","apiVersion":"batch/v1beta1","metadata":{"name":"backup","namespace":"default"},"spec":{"schedule":"*/5 * * * *","concurrencyPolicy":"Forbid","successfulJobsHistoryLimit":100,"startingDeadlineSeconds":60}}`),
+			expectedMessage: "CronJob default/backup violates the CronJob policy: spec.successfulJobsHistoryLimit: must be <= 3 (got 100); spec.failedJobsHistoryLimit: must be set",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a CronJob without a startingDeadlineSeconds or concurrencyPolicy",
+			kind:            cronJobKind,
+			rawObject:       []byte(`{"kind":"CronJob","apiVersion":"batch/v1","metadata":{"name":"backup","namespace":"default"},"spec":{"schedule":"*/5 * * * *","successfulJobsHistoryLimit":3,"failedJobsHistoryLimit":1}}`),
+			expectedMessage: "CronJob default/backup violates the CronJob policy: spec.concurrencyPolicy: must be set; spec.startingDeadlineSeconds: must be set",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow a non-compliant CronJob in a whitelisted namespace",
+			kind:              cronJobKind,
+			ignoredNamespaces: []string{"batch-jobs"},
+			rawObject:         []byte(`{"kind":"CronJob","apiVersion":"batch/v1","metadata":{"name":"backup","namespace":"batch-jobs"},"spec":{"schedule":"*/5 * * * *","concurrencyPolicy":"Allow"}}`),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+		{
+			testName:        "Don't reject Jobs",
+			kind:            meta.GroupVersionKind{Group: "batch", Kind: "Job", Version: "v1"},
+			rawObject:       nil,
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceCronJobPolicy(tt.ignoredNamespaces, policy)
+	})
+}
+
+func TestEnforceJobPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy := JobPolicy{
+		MaxBackoffLimit:            6,
+		MaxTTLSecondsAfterFinished: 86400,
+	}
+	jobKind := meta.GroupVersionKind{Group: "batch", Kind: "Job", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a Job that satisfies the policy",
+			kind:            jobKind,
+			rawObject:       []byte(`{"kind":"Job","apiVersion":"batch/v1","metadata":{"name":"migrate","namespace":"default"},"spec":{"backoffLimit":3,"ttlSecondsAfterFinished":3600,"template":{"spec":{"containers":[{"name":"migrate","image":"migrate:v1.0.0"}]}}}}`),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a Job without a ttlSecondsAfterFinished",
+			kind:            jobKind,
+			rawObject:       []byte(`{"kind":"Job","apiVersion":"batch/v1","metadata":{"name":"migrate","namespace":"default"},"spec":{"backoffLimit":3,"template":{"spec":{"containers":[{"name":"migrate","image":"migrate:v1.0.0"}]}}}}`),
+			expectedMessage: "Job default/migrate violates the Job policy: spec.ttlSecondsAfterFinished: must be set",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a Job with an unbounded backoffLimit & TTL",
+			kind:            jobKind,
+			rawObject:       []byte(`{"kind":"Job","apiVersion":"batch/v1","metadata":{"name":"migrate","namespace":"default"},"spec":{"backoffLimit":1000,"ttlSecondsAfterFinished":604800,"template":{"spec":{"containers":[{"name":"migrate","image":"migrate:v1.0.0"}]}}}}`),
+			expectedMessage: "Job default/migrate violates the Job policy: spec.backoffLimit: must be <= 6 (got 1000); spec.ttlSecondsAfterFinished: must be <= 86400 (got 604800)",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a Job without a backoffLimit",
+			kind:            jobKind,
+			rawObject:       []byte(`{"kind":"Job","apiVersion":"batch/v1","metadata":{"name":"migrate","namespace":"default"},"spec":{"ttlSecondsAfterFinished":3600,"template":{"spec":{"containers":[{"name":"migrate","image":"migrate:v1.0.0"}]}}}}`),
+			expectedMessage: "Job default/migrate violates the Job policy: spec.backoffLimit: must be set",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow a non-compliant Job in a whitelisted namespace",
+			kind:              jobKind,
+			ignoredNamespaces: []string{"kube-system"},
+			rawObject:         []byte(`{"kind":"Job","apiVersion":"batch/v1","metadata":{"name":"migrate","namespace":"kube-system"},"spec":{"template":{"spec":{"containers":[{"name":"migrate","image":"migrate:v1.0.0"}]}}}}`),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+		{
+			testName:        "Don't reject Pods",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			rawObject:       nil,
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceJobPolicy(tt.ignoredNamespaces, policy)
+	})
+}
+
+func TestDenyHostPorts(t *testing.T) {
+	t.Parallel()
+
+	allowedPorts := []int32{9100}
+
+	var denyTests = []objectTest{
+		{
+			testName: "Allow Pods without host ports",
+			kind:     meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			object: &corev1.Pod{
+				TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "nginx", Image: "nginx:latest", Ports: []corev1.ContainerPort{{ContainerPort: 80}}},
+				}},
+			},
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName: "Reject Pods with a host port",
+			kind:     meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			object: &corev1.Pod{
+				TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "nginx", Image: "nginx:latest", Ports: []corev1.ContainerPort{{ContainerPort: 80, HostPort: 80}}},
+				}},
+			},
+			expectedMessage: `Pod default/web declares host ports that are not allowed: spec.containers[nginx].ports[0].hostPort: 80 is not allowed`,
+			shouldAllow:     false,
+		},
+		{
+			testName: "Allow Pods with an allowed host port",
+			kind:     meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			object: &corev1.Pod{
+				TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "node-exporter", Namespace: "default"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "node-exporter", Image: "node-exporter:v1.0.0", Ports: []corev1.ContainerPort{{ContainerPort: 9100, HostPort: 9100}}},
+				}},
+			},
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName: "Reject host ports in the init containers of a DaemonSet",
+			kind:     meta.GroupVersionKind{Group: "apps", Kind: "DaemonSet", Version: "v1"},
+			object: &appsv1.DaemonSet{
+				TypeMeta:   meta.TypeMeta{Kind: "DaemonSet", APIVersion: "apps/v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "node-exporter", Namespace: "monitoring"},
+				Spec: appsv1.DaemonSetSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{
+						{Name: "setup", Image: "setup:v1.0.0", Ports: []corev1.ContainerPort{{ContainerPort: 8080, HostPort: 8080}}},
+					},
+					Containers: []corev1.Container{
+						{Name: "node-exporter", Image: "node-exporter:v1.0.0", Ports: []corev1.ContainerPort{{ContainerPort: 9100, HostPort: 9100}}},
+					},
+				}}},
+			},
+			expectedMessage: `DaemonSet monitoring/node-exporter declares host ports that are not allowed: spec.template.spec.initContainers[setup].ports[0].hostPort: 8080 is not allowed`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject host ports in the Pod template of a CronJob",
+			kind:            meta.GroupVersionKind{Group: "batch", Kind: "CronJob", Version: "v1"},
+			rawObject:       []byte(`{"kind":"CronJob","apiVersion":"batch/v1","metadata":{"name":"backup","namespace":"default"},"spec":{"schedule":"* * * * *","jobTemplate":{"spec":{"template":{"spec":{"containers":[{"name":"backup","image":"backup:v1.0.0","ports":[{"containerPort":8080,"hostPort":8080}]}]}}}}}}`),
+			expectedMessage: `CronJob default/backup declares host ports that are not allowed: spec.jobTemplate.spec.template.spec.containers[backup].ports[0].hostPort: 8080 is not allowed`,
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow host ports in a whitelisted namespace",
+			ignoredNamespaces: []string{"kube-system"},
+			kind:              meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			object: &corev1.Pod{
+				TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "kube-proxy", Namespace: "kube-system"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "kube-proxy", Image: "kube-proxy:v1.21.0", Ports: []corev1.ContainerPort{{ContainerPort: 10249, HostPort: 10249}}},
+				}},
+			},
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Unhandled Kinds (Service) are correctly rejected",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "Service", Version: "v1"},
+			rawObject:       []byte(`{"kind":"Service","apiVersion":"v1","metadata":{"name":"hello-service","namespace":"default","annotations":{}},"spec":{"ports":[{"protocol":"TCP","port":8000,"targetPort":8080}],"selector":{"app":"hello-app"}}}`),
+			expectedMessage: fmt.Sprintf("%s %s", unsupportedKindError, "Service"),
+			shouldAllow:     false,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return DenyHostPorts(tt.ignoredNamespaces, allowedPorts)
+	})
+}
+
+func TestEnforceMaxPVCsPerNamespace(t *testing.T) {
+	t.Parallel()
+
+	newPVC := func(namespace string, name string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			TypeMeta:   meta.TypeMeta{Kind: "PersistentVolumeClaim", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: name, Namespace: namespace},
+		}
+	}
+
+	terminating := newPVC("default", "data-old")
+	terminating.DeletionTimestamp = &meta.Time{Time: time.Now()}
+
+	client := fake.NewSimpleClientset(
+		newPVC("full", "data-0"),
+		newPVC("full", "data-1"),
+		newPVC("default", "data-0"),
+		terminating,
+	)
+	factory := informers.NewSharedInformerFactory(client, 0)
+	admitFunc, err := EnforceMaxPVCsPerNamespace(factory, 2)
+	if err != nil {
+		t.Fatalf("failed to create the AdmitFunc: %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory.Start(stopCh)
+
+	for _, limit := range []int{0, -1} {
+		if _, err := EnforceMaxPVCsPerNamespace(factory, limit); err == nil {
+			t.Fatalf("expected an error for a limit of %d", limit)
+		}
+	}
+
+	pvcKind := meta.GroupVersionKind{Group: "", Kind: "PersistentVolumeClaim", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a PersistentVolumeClaim within the limit, excluding Terminating claims",
+			kind:            pvcKind,
+			operation:       admission.Create,
+			namespace:       "default",
+			object:          newPVC("default", "data-1"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a PersistentVolumeClaim beyond the limit",
+			kind:            pvcKind,
+			operation:       admission.Create,
+			namespace:       "full",
+			object:          newPVC("full", "data-2"),
+			expectedMessage: "namespace full already has 2 PersistentVolumeClaims: creating another would exceed the limit of 2",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow updates to existing PersistentVolumeClaims",
+			kind:            pvcKind,
+			operation:       admission.Update,
+			namespace:       "full",
+			object:          newPVC("full", "data-1"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Don't reject Pods",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			operation:       admission.Create,
+			namespace:       "full",
+			rawObject:       nil,
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+}
+
+func TestEnforceNamespaceAnnotations(t *testing.T) {
+	t.Parallel()
+
+	requiredAnnotations := map[string]func(string) bool{
+		"network-policy-tier": func(s string) bool { return s == "restricted" || s == "baseline" },
+	}
+	namespaceKind := meta.GroupVersionKind{Group: "", Kind: "Namespace", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a Namespace with the required annotations",
+			kind:            namespaceKind,
+			rawObject:       []byte(`{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"team-a","annotations":{"network-policy-tier":"restricted"}}}`),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a Namespace with a missing annotation",
+			kind:            namespaceKind,
+			rawObject:       []byte(`{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"team-a","annotations":{}}}`),
+			expectedMessage: fmt.Sprintf("%s %s", namespaceDeniedError, "map[network-policy-tier:key was not found]"),
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a Namespace with an invalid annotation value",
+			kind:            namespaceKind,
+			rawObject:       []byte(`{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"team-a","annotations":{"network-policy-tier":"open"}}}`),
+			expectedMessage: fmt.Sprintf("%s %s", namespaceDeniedError, "map[network-policy-tier:value did not match]"),
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a Namespace where only a label is set",
+			kind:            namespaceKind,
+			rawObject:       []byte(`{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"team-a","labels":{"network-policy-tier":"restricted"}}}`),
+			expectedMessage: fmt.Sprintf("%s %s", namespaceDeniedError, "map[network-policy-tier:key was not found]"),
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Don't reject Pods",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			rawObject:       nil,
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceNamespaceAnnotations(requiredAnnotations)
+	})
+}
+
+func TestDenyServiceTypeChange(t *testing.T) {
+	t.Parallel()
+
+	newService := func(namespace string, serviceType corev1.ServiceType) *corev1.Service {
+		return &corev1.Service{
+			TypeMeta:   meta.TypeMeta{Kind: "Service", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace},
+			Spec:       corev1.ServiceSpec{Type: serviceType},
+		}
+	}
+	serviceKind := meta.GroupVersionKind{Group: "", Kind: "Service", Version: "v1"}
+	allowedTransitions := []ServiceTypeTransition{
+		{From: corev1.ServiceTypeClusterIP, To: corev1.ServiceTypeNodePort},
+	}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Reject changing a ClusterIP Service to a LoadBalancer",
+			kind:            serviceKind,
+			operation:       admission.Update,
+			oldObject:       newService("default", corev1.ServiceTypeClusterIP),
+			object:          newService("default", corev1.ServiceTypeLoadBalancer),
+			expectedMessage: "Service default/web cannot change type from ClusterIP to LoadBalancer: delete and re-create the Service if this change is intended",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow an explicitly allowed transition",
+			kind:            serviceKind,
+			operation:       admission.Update,
+			oldObject:       newService("default", corev1.ServiceTypeClusterIP),
+			object:          newService("default", corev1.ServiceTypeNodePort),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject the reverse of an allowed transition",
+			kind:            serviceKind,
+			operation:       admission.Update,
+			oldObject:       newService("default", corev1.ServiceTypeNodePort),
+			object:          newService("default", corev1.ServiceTypeClusterIP),
+			expectedMessage: "Service default/web cannot change type from NodePort to ClusterIP: delete and re-create the Service if this change is intended",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow updates that don't change the type",
+			kind:            serviceKind,
+			operation:       admission.Update,
+			oldObject:       newService("default", corev1.ServiceTypeLoadBalancer),
+			object:          newService("default", corev1.ServiceTypeLoadBalancer),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:          "Allow type changes in a whitelisted namespace",
+			kind:              serviceKind,
+			operation:         admission.Update,
+			ignoredNamespaces: []string{"ingress"},
+			oldObject:         newService("ingress", corev1.ServiceTypeClusterIP),
+			object:            newService("ingress", corev1.ServiceTypeLoadBalancer),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+		{
+			testName:        "Allow creating a LoadBalancer Service",
+			kind:            serviceKind,
+			operation:       admission.Create,
+			object:          newService("default", corev1.ServiceTypeLoadBalancer),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return DenyServiceTypeChange(tt.ignoredNamespaces, allowedTransitions...)
+	})
+}
+
+func TestEnforceTerminationGracePeriod(t *testing.T) {
+	t.Parallel()
+
+	gracePeriod := func(seconds int64) *int64 { return &seconds }
+
+	var denyTests = []objectTest{
+		{
+			testName: "Allow Pods within the bounds",
+			kind:     meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			object: &corev1.Pod{
+				TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "db", Namespace: "default"},
+				Spec: corev1.PodSpec{
+					TerminationGracePeriodSeconds: gracePeriod(60),
+					Containers:                    []corev1.Container{{Name: "postgres", Image: "postgres:13"}},
+				},
+			},
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName: "Allow Pods using the default grace period",
+			kind:     meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			object: &corev1.Pod{
+				TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "db", Namespace: "default"},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "postgres", Image: "postgres:13"}}},
+			},
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName: "Reject Pods with a zero grace period",
+			kind:     meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			object: &corev1.Pod{
+				TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "db", Namespace: "default"},
+				Spec: corev1.PodSpec{
+					TerminationGracePeriodSeconds: gracePeriod(0),
+					Containers:                    []corev1.Container{{Name: "postgres", Image: "postgres:13"}},
+				},
+			},
+			expectedMessage: "Pod default/db requests a terminationGracePeriodSeconds of 0: it must be between 10 and 300 seconds",
+			shouldAllow:     false,
+		},
+		{
+			testName: "Reject StatefulSets with an excessive grace period",
+			kind:     meta.GroupVersionKind{Group: "apps", Kind: "StatefulSet", Version: "v1"},
+			object: &appsv1.StatefulSet{
+				TypeMeta:   meta.TypeMeta{Kind: "StatefulSet", APIVersion: "apps/v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "db", Namespace: "default"},
+				Spec: appsv1.StatefulSetSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					TerminationGracePeriodSeconds: gracePeriod(86400),
+					Containers:                    []corev1.Container{{Name: "postgres", Image: "postgres:13"}},
+				}}},
+			},
+			expectedMessage: "StatefulSet default/db requests a terminationGracePeriodSeconds of 86400: it must be between 10 and 300 seconds",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow a zero grace period in a whitelisted namespace",
+			kind:              meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			ignoredNamespaces: []string{"ci"},
+			object: &corev1.Pod{
+				TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "runner", Namespace: "ci"},
+				Spec: corev1.PodSpec{
+					TerminationGracePeriodSeconds: gracePeriod(0),
+					Containers:                    []corev1.Container{{Name: "runner", Image: "runner:v1.0.0"}},
+				},
+			},
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceTerminationGracePeriod(tt.ignoredNamespaces, 10, 300)
+	})
+}
+
+func TestRequireLoadBalancerSourceRanges(t *testing.T) {
+	t.Parallel()
+
+	newService := func(serviceType corev1.ServiceType, annotations map[string]string, sourceRanges ...string) *corev1.Service {
+		return &corev1.Service{
+			TypeMeta:   meta.TypeMeta{Kind: "Service", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default", Annotations: annotations},
+			Spec:       corev1.ServiceSpec{Type: serviceType, LoadBalancerSourceRanges: sourceRanges},
+		}
+	}
+	serviceKind := meta.GroupVersionKind{Group: "", Kind: "Service", Version: "v1"}
+	missingRangesMessage := "Service default/web of type: LoadBalancer must restrict access via .spec.loadBalancerSourceRanges"
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow LoadBalancers with source ranges",
+			kind:            serviceKind,
+			object:          newService(corev1.ServiceTypeLoadBalancer, nil, "10.0.0.0/8"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow LoadBalancers with the source ranges annotation",
+			kind:            serviceKind,
+			object:          newService(corev1.ServiceTypeLoadBalancer, map[string]string{loadBalancerSourceRangesAnnotation: "10.0.0.0/8,192.168.0.0/16"}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject LoadBalancers without source ranges",
+			kind:            serviceKind,
+			object:          newService(corev1.ServiceTypeLoadBalancer, nil),
+			expectedMessage: missingRangesMessage,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject LoadBalancers with source ranges that allow all addresses",
+			kind:            serviceKind,
+			object:          newService(corev1.ServiceTypeLoadBalancer, nil, "10.0.0.0/8", "0.0.0.0/0"),
+			expectedMessage: missingRangesMessage + ": 0.0.0.0/0 allows all addresses",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow LoadBalancers annotated as public",
+			kind:            serviceKind,
+			object:          newService(corev1.ServiceTypeLoadBalancer, map[string]string{"questionable.services/public": "true"}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:          "Allow LoadBalancers without source ranges in a whitelisted namespace",
+			kind:              serviceKind,
+			ignoredNamespaces: []string{"default"},
+			object:            newService(corev1.ServiceTypeLoadBalancer, nil),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+		{
+			testName:        "Don't reject ClusterIP Services",
+			kind:            serviceKind,
+			object:          newService(corev1.ServiceTypeClusterIP, nil),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return RequireLoadBalancerSourceRanges(tt.ignoredNamespaces, "questionable.services/public")
+	})
+}
+
+func TestDenyAnnotations(t *testing.T) {
+	t.Parallel()
+
+	denied := []string{"scheduler.alpha.kubernetes.io/*", "seccomp.security.alpha.kubernetes.io/pod"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow objects without denied annotations",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			rawObject:       []byte(`{"kind":"Pod","apiVersion":"v1","metadata":{"name":"web","namespace":"default","annotations":{"buildVersion":"v1.0.0"}},"spec":{"containers":[{"name":"nginx","image":"nginx:latest"}]}}`),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject objects with an annotation matching a prefix pattern",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			rawObject:       []byte(`{"kind":"Pod","apiVersion":"v1","metadata":{"name":"web","namespace":"default","annotations":{"scheduler.alpha.kubernetes.io/critical-pod":"","buildVersion":"v1.0.0"}},"spec":{"containers":[{"name":"nginx","image":"nginx:latest"}]}}`),
+			expectedMessage: "Pod default/web carries annotations that are not allowed: metadata.annotations[scheduler.alpha.kubernetes.io/critical-pod]: denied by the pattern \"scheduler.alpha.kubernetes.io/*\"",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject any kind with denied annotations, listing each key",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "ConfigMap", Version: "v1"},
+			rawObject:       []byte(`{"kind":"ConfigMap","apiVersion":"v1","metadata":{"name":"app-config","namespace":"default","annotations":{"seccomp.security.alpha.kubernetes.io/pod":"unconfined","scheduler.alpha.kubernetes.io/tolerations":"[]"}},"data":{}}`),
+			expectedMessage: "ConfigMap default/app-config carries annotations that are not allowed: metadata.annotations[scheduler.alpha.kubernetes.io/tolerations]: denied by the pattern \"scheduler.alpha.kubernetes.io/*\"; metadata.annotations[seccomp.security.alpha.kubernetes.io/pod]: denied by the pattern \"seccomp.security.alpha.kubernetes.io/pod\"",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow denied annotations in a whitelisted namespace",
+			kind:              meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			ignoredNamespaces: []string{"kube-system"},
+			namespace:         "kube-system",
+			rawObject:         []byte(`{"kind":"Pod","apiVersion":"v1","metadata":{"name":"web","annotations":{"scheduler.alpha.kubernetes.io/critical-pod":""}},"spec":{"containers":[{"name":"nginx","image":"nginx:latest"}]}}`),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+		{
+			testName:        "Reject cluster-scoped objects with denied annotations",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "Namespace", Version: "v1"},
+			rawObject:       []byte(`{"kind":"Namespace","apiVersion":"v1","metadata":{"name":"payments","annotations":{"scheduler.alpha.kubernetes.io/node-selector":"pool=payments"}}}`),
+			expectedMessage: "Namespace payments carries annotations that are not allowed: metadata.annotations[scheduler.alpha.kubernetes.io/node-selector]: denied by the pattern \"scheduler.alpha.kubernetes.io/*\"",
+			shouldAllow:     false,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		admitFunc, err := DenyAnnotations(tt.ignoredNamespaces, denied)
+		if err != nil {
+			t.Fatalf("failed to create the AdmitFunc: %v", err)
+		}
+
+		return admitFunc
+	})
+
+	t.Run("Invalid patterns are rejected", func(t *testing.T) {
+		if _, err := DenyAnnotations(nil, []string{"scheduler.alpha.kubernetes.io/[critical"}); err == nil {
+			t.Fatalf("expected an error for an invalid pattern")
+		}
+	})
+}
+
+func TestValidateAgainstJSONSchema(t *testing.T) {
+	t.Parallel()
+
+	widgetGVK := schema.GroupVersionKind{Group: "questionable.services", Version: "v1", Kind: "Widget"}
+	widgetSchema := []byte(`{
+		"type": "object",
+		"required": ["spec"],
+		"properties": {
+			"spec": {
+				"type": "object",
+				"required": ["size"],
+				"properties": {
+					"size": {"type": "integer", "minimum": 1, "maximum": 10},
+					"color": {"enum": ["red", "blue"]}
+				}
+			}
+		}
+	}`)
+
+	admitFunc, err := ValidateAgainstJSONSchema(widgetGVK, widgetSchema)
+	if err != nil {
+		t.Fatalf("failed to compile the JSON Schema: %v", err)
+	}
+
+	t.Run("Invalid schemas return an error", func(t *testing.T) {
+		if _, err := ValidateAgainstJSONSchema(widgetGVK, []byte(`{"type": "not-a-type"}`)); err == nil {
+			t.Fatalf("an invalid JSON Schema did not return an error")
+		}
+
+		if _, err := ValidateAgainstJSONSchema(widgetGVK, []byte(`{"type":`)); err == nil {
+			t.Fatalf("malformed JSON did not return an error")
+		}
+	})
+
+	widgetKind := meta.GroupVersionKind{Group: "questionable.services", Version: "v1", Kind: "Widget"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow objects matching the schema",
+			kind:            widgetKind,
+			rawObject:       []byte(`{"kind":"Widget","apiVersion":"questionable.services/v1","metadata":{"name":"gizmo","namespace":"default"},"spec":{"size":3,"color":"red"}}`),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject objects missing a required field",
+			kind:            widgetKind,
+			rawObject:       []byte(`{"kind":"Widget","apiVersion":"questionable.services/v1","metadata":{"name":"gizmo","namespace":"default"},"spec":{"color":"red"}}`),
+			expectedMessage: "Widget gizmo does not match the schema for questionable.services/v1, Kind=Widget: /spec: missing properties: 'size'",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject objects with multiple violations",
+			kind:            widgetKind,
+			rawObject:       []byte(`{"kind":"Widget","apiVersion":"questionable.services/v1","metadata":{"name":"gizmo","namespace":"default"},"spec":{"size":100,"color":"green"}}`),
+			expectedMessage: "Widget gizmo does not match the schema for questionable.services/v1, Kind=Widget: /spec/color: value must be one of \"red\", \"blue\"; /spec/size: must be <= 10 but found 100",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Don't reject other versions of the Kind",
+			kind:            meta.GroupVersionKind{Group: "questionable.services", Version: "v2", Kind: "Widget"},
+			rawObject:       []byte(`{"kind":"Widget","apiVersion":"questionable.services/v2","metadata":{"name":"gizmo","namespace":"default"},"spec":{}}`),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+}
+
+func TestEnforceContainerNaming(t *testing.T) {
+	t.Parallel()
+
+	pattern := `[a-z]+(-[a-z]+)*`
+	namingMessage := "has containers that do not match the naming convention ([a-z]+(-[a-z]+)*)"
+
+	var denyTests = []objectTest{
+		{
+			testName: "Allow containers matching the naming convention",
+			kind:     meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			object: &corev1.Pod{
+				TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{Name: "fetch-secrets", Image: "fetch:v1.0.0"}},
+					Containers:     []corev1.Container{{Name: "nginx", Image: "nginx:latest"}, {Name: "log-shipper", Image: "shipper:v1.0.0"}},
+				},
+			},
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName: "Reject containers that don't match the naming convention",
+			kind:     meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			object: &corev1.Pod{
+				TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{Name: "init1", Image: "fetch:v1.0.0"}},
+					Containers:     []corev1.Container{{Name: "nginx", Image: "nginx:latest"}, {Name: "sidecar-2", Image: "shipper:v1.0.0"}},
+				},
+			},
+			expectedMessage: "Pod default/web " + namingMessage + `: spec.initContainers[init1].name: "init1" does not match the pattern; spec.containers[sidecar-2].name: "sidecar-2" does not match the pattern`,
+			shouldAllow:     false,
+		},
+		{
+			testName: "Reject generically named containers in a Deployment",
+			kind:     meta.GroupVersionKind{Group: "apps", Kind: "Deployment", Version: "v1"},
+			object: &appsv1.Deployment{
+				TypeMeta:   meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app", Image: "web:v1.0.0"}},
+				}}},
+			},
+			expectedMessage: "Deployment default/web " + namingMessage + `: spec.template.spec.containers[app].name: "app" is not an allowed name`,
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow non-conforming containers in a whitelisted namespace",
+			kind:              meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+			ignoredNamespaces: []string{"kube-system"},
+			object: &corev1.Pod{
+				TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "coredns", Namespace: "kube-system"},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "coredns1", Image: "coredns:1.8.0"}}},
+			},
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		admitFunc, err := EnforceContainerNaming(tt.ignoredNamespaces, pattern, "app")
+		if err != nil {
+			t.Fatalf("failed to create the AdmitFunc: %v", err)
+		}
+
+		return admitFunc
+	})
+
+	t.Run("Invalid patterns are rejected", func(t *testing.T) {
+		if _, err := EnforceContainerNaming(nil, `[a-z`); err == nil {
+			t.Fatalf("expected an error for an invalid naming pattern")
+		}
+	})
+}
+
+func TestParseImageReference(t *testing.T) {
+	t.Parallel()
+	var referenceTests = []struct {
+		image    string
+		expected imageReference
+	}{
+		{image: "nginx", expected: imageReference{repository: "nginx"}},
+		{image: "nginx:1.19", expected: imageReference{repository: "nginx", tag: "1.19"}},
+		{image: "gcr.io/project/team/app:v1.0.0", expected: imageReference{repository: "gcr.io/project/team/app", tag: "v1.0.0"}},
+		{image: "registry.local:5000/team/app", expected: imageReference{repository: "registry.local:5000/team/app"}},
+		{image: "registry.local:5000/team/app:v1.0.0", expected: imageReference{repository: "registry.local:5000/team/app", tag: "v1.0.0"}},
+		{image: "registry.local:5000/app@sha256:abc123", expected: imageReference{repository: "registry.local:5000/app", digest: "sha256:abc123"}},
+		{image: "app:v1.0.0@sha256:abc123", expected: imageReference{repository: "app", tag: "v1.0.0", digest: "sha256:abc123"}},
+	}
+
+	for _, tt := range referenceTests {
+		if ref := parseImageReference(tt.image); ref != tt.expected {
+			t.Fatalf("image reference does not match for %q: got %+v - expected %+v", tt.image, ref, tt.expected)
+		}
+	}
+}
+
+func TestEnforceImageTagPattern(t *testing.T) {
+	t.Parallel()
+
+	pattern := `v\d+\.\d+\.\d+`
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+	tagMessage := `Pod default/web has images with tags that do not match the required pattern (v\d+\.\d+\.\d+): `
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow images with a semantic version tag",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "gcr.io/project/web:v1.2.3", "registry.local:5000/team/sidecar:v0.1.0"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow images pinned to a digest",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "registry.local:5000/team/web@sha256:0123456789abcdef"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject images with a mutable tag",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "gcr.io/project/web:v1.2.3", "nginx:latest"),
+			expectedMessage: tagMessage + `spec.containers[container-1].image: "nginx:latest" does not have a tag matching the pattern`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject images without a tag",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "registry.local:5000/team/web"),
+			expectedMessage: tagMessage + `spec.containers[container-0].image: "registry.local:5000/team/web" does not have a tag matching the pattern`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject tags that only partially match the pattern",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "web:v1.2.3-dirty"),
+			expectedMessage: tagMessage + `spec.containers[container-0].image: "web:v1.2.3-dirty" does not have a tag matching the pattern`,
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow mutable tags in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"sandbox"},
+			object:            newTestPodWithImages("sandbox", "nginx:latest"),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		admitFunc, err := EnforceImageTagPattern(tt.ignoredNamespaces, pattern)
+		if err != nil {
+			t.Fatalf("failed to create the AdmitFunc: %v", err)
+		}
+
+		return admitFunc
+	})
+
+	t.Run("Invalid patterns are rejected", func(t *testing.T) {
+		if _, err := EnforceImageTagPattern(nil, `v(\d+`); err == nil {
+			t.Fatalf("expected an error for an invalid image tag pattern")
+		}
+	})
+}
+
+func TestRequireImageDigest(t *testing.T) {
+	t.Parallel()
+
+	digest := "sha256:" + strings.Repeat("a1", 32)
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+	digestMessage := "Pod default/web has images that are not pinned to a sha256 digest: "
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow images pinned to a digest",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "nginx@"+digest, "gcr.io/project/team/web:v1.0.0@"+digest),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow images from a registry with a port",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "registry.local:5000/team/nested/web@"+digest),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject tag-only images",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "nginx@"+digest, "registry.local:5000/team/web:v1.0.0"),
+			expectedMessage: digestMessage + `spec.containers[container-1].image: "registry.local:5000/team/web:v1.0.0" is not pinned to a sha256 digest`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject malformed digests",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "nginx@sha256:abc123"),
+			expectedMessage: digestMessage + `spec.containers[container-0].image: "nginx@sha256:abc123" is not pinned to a sha256 digest`,
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow tag-only images in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"sandbox"},
+			object:            newTestPodWithImages("sandbox", "nginx:latest"),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return RequireImageDigest(tt.ignoredNamespaces)
+	})
+}
+
+func TestEnforceAntiAffinityTopologyKey(t *testing.T) {
+	t.Parallel()
+
+	requiredKey := "kubernetes.io/hostname"
+	newAntiAffinity := func(required []string, preferred []string) *corev1.Affinity {
+		antiAffinity := &corev1.PodAntiAffinity{}
+		for _, key := range required {
+			antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(
+				antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution,
+				corev1.PodAffinityTerm{TopologyKey: key},
+			)
+		}
+
+		for _, key := range preferred {
+			antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(
+				antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+				corev1.WeightedPodAffinityTerm{Weight: 100, PodAffinityTerm: corev1.PodAffinityTerm{TopologyKey: key}},
+			)
+		}
+
+		return &corev1.Affinity{PodAntiAffinity: antiAffinity}
+	}
+	newPod := func(namespace string, affinity *corev1.Affinity) *corev1.Pod {
+		return &corev1.Pod{
+			TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace},
+			Spec: corev1.PodSpec{
+				Affinity:   affinity,
+				Containers: []corev1.Container{{Name: "nginx", Image: "nginx:latest"}},
+			},
+		}
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow Pods without anti-affinity",
+			kind:            podKind,
+			object:          newPod("default", nil),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName: "Allow Pods with node affinity only",
+			kind:     podKind,
+			object: newPod("default", &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{},
+			}}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow anti-affinity with the required topology key",
+			kind:            podKind,
+			object:          newPod("default", newAntiAffinity([]string{requiredKey}, []string{requiredKey})),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject required anti-affinity with the wrong topology key",
+			kind:            podKind,
+			object:          newPod("default", newAntiAffinity([]string{"kubernetes.io/host"}, nil)),
+			expectedMessage: `Pod default/web must use the "kubernetes.io/hostname" topology key for pod anti-affinity: spec.affinity.podAntiAffinity.requiredDuringSchedulingIgnoredDuringExecution[0].topologyKey: "kubernetes.io/host" is not the required topology key`,
+			shouldAllow:     false,
+		},
+		{
+			testName: "Reject preferred anti-affinity with the wrong topology key in a Deployment",
+			kind:     meta.GroupVersionKind{Group: "apps", Kind: "Deployment", Version: "v1"},
+			object: &appsv1.Deployment{
+				TypeMeta:   meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					Affinity:   newAntiAffinity([]string{requiredKey}, []string{"topology.kubernetes.io/zone"}),
+					Containers: []corev1.Container{{Name: "nginx", Image: "nginx:latest"}},
+				}}},
+			},
+			expectedMessage: `Deployment default/web must use the "kubernetes.io/hostname" topology key for pod anti-affinity: spec.template.spec.affinity.podAntiAffinity.preferredDuringSchedulingIgnoredDuringExecution[0].podAffinityTerm.topologyKey: "topology.kubernetes.io/zone" is not the required topology key`,
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow the wrong topology key in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"sandbox"},
+			object:            newPod("sandbox", newAntiAffinity([]string{"kubernetes.io/host"}, nil)),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+		{
+			testName:        "Unhandled Kinds (Service) are correctly rejected",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "Service", Version: "v1"},
+			rawObject:       []byte(`{"kind":"Service","apiVersion":"v1","metadata":{"name":"web","namespace":"default"}}`),
+			expectedMessage: fmt.Sprintf("%s %s", unsupportedKindError, "Service"),
+			shouldAllow:     false,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceAntiAffinityTopologyKey(tt.ignoredNamespaces, requiredKey)
+	})
+}
+
+func TestDenyControlPlaneScheduling(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, spec corev1.PodSpec) *corev1.Pod {
+		spec.Containers = []corev1.Container{{Name: "nginx", Image: "nginx:latest"}}
+		return &corev1.Pod{
+			TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace},
+			Spec:       spec,
+		}
+	}
+	newNodeAffinity := func(operator corev1.NodeSelectorOperator) *corev1.Affinity {
+		return &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{
+					{Key: "node-role.kubernetes.io/control-plane", Operator: operator},
+				}}},
+			},
+		}}
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+	controlPlaneMessage := "Pod default/web targets control-plane nodes, which are reserved for cluster components: "
+
+	var denyTests = []objectTest{
+		{
+			testName: "Allow Pods with unrelated tolerations & node selectors",
+			kind:     podKind,
+			object: newPod("default", corev1.PodSpec{
+				Tolerations:  []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "gpu", Effect: corev1.TaintEffectNoSchedule}},
+				NodeSelector: map[string]string{"pool": "gpu"},
+			}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName: "Reject Pods tolerating the control-plane taint",
+			kind:     podKind,
+			object: newPod("default", corev1.PodSpec{
+				Tolerations: []corev1.Toleration{{Key: "node-role.kubernetes.io/control-plane", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}},
+			}),
+			expectedMessage: controlPlaneMessage + "spec.tolerations[0]: tolerates the node-role.kubernetes.io/control-plane taint",
+			shouldAllow:     false,
+		},
+		{
+			testName: "Reject Pods tolerating all taints",
+			kind:     podKind,
+			object: newPod("default", corev1.PodSpec{
+				Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			}),
+			expectedMessage: controlPlaneMessage + "spec.tolerations[0]: tolerates all taints, including the control-plane taint",
+			shouldAllow:     false,
+		},
+		{
+			testName: "Reject Pods selecting (legacy) master nodes",
+			kind:     podKind,
+			object: newPod("default", corev1.PodSpec{
+				NodeSelector: map[string]string{"node-role.kubernetes.io/master": ""},
+			}),
+			expectedMessage: controlPlaneMessage + "spec.nodeSelector[node-role.kubernetes.io/master]: selects control-plane nodes",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject Pods with node affinity for control-plane nodes",
+			kind:            podKind,
+			object:          newPod("default", corev1.PodSpec{Affinity: newNodeAffinity(corev1.NodeSelectorOpExists)}),
+			expectedMessage: controlPlaneMessage + "spec.affinity.nodeAffinity.requiredDuringSchedulingIgnoredDuringExecution.nodeSelectorTerms[0].matchExpressions[0]: selects control-plane nodes (node-role.kubernetes.io/control-plane Exists)",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow Pods with node affinity avoiding control-plane nodes",
+			kind:            podKind,
+			object:          newPod("default", corev1.PodSpec{Affinity: newNodeAffinity(corev1.NodeSelectorOpDoesNotExist)}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:          "Allow system components in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"kube-system"},
+			object: newPod("kube-system", corev1.PodSpec{
+				Tolerations: []corev1.Toleration{{Key: "node-role.kubernetes.io/control-plane", Operator: corev1.TolerationOpExists}},
+			}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Unhandled Kinds (Service) are correctly rejected",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "Service", Version: "v1"},
+			rawObject:       []byte(`{"kind":"Service","apiVersion":"v1","metadata":{"name":"web","namespace":"default"}}`),
+			expectedMessage: fmt.Sprintf("%s %s", unsupportedKindError, "Service"),
+			shouldAllow:     false,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return DenyControlPlaneScheduling(tt.ignoredNamespaces)
+	})
+}
+
+func TestRequireOwnerReferences(t *testing.T) {
+	t.Parallel()
+
+	gvk := schema.GroupVersionKind{Group: "", Version: "v1", Kind: "ConfigMap"}
+	owner := schema.GroupKind{Group: "example.com", Kind: "Database"}
+	newConfigMap := func(owners ...meta.OwnerReference) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "db-config", Namespace: "default", OwnerReferences: owners},
+		}
+	}
+	configMapKind := meta.GroupVersionKind{Group: "", Kind: "ConfigMap", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow objects owned by an allowed owner",
+			kind:            configMapKind,
+			operation:       admission.Create,
+			object:          newConfigMap(meta.OwnerReference{APIVersion: "example.com/v1alpha1", Kind: "Database", Name: "db", UID: "1234"}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject objects without an ownerReference",
+			kind:            configMapKind,
+			operation:       admission.Create,
+			object:          newConfigMap(),
+			expectedMessage: "ConfigMap default/db-config must have an ownerReference, so that it is garbage collected along with its owner",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject objects owned by another kind",
+			kind:            configMapKind,
+			operation:       admission.Create,
+			object:          newConfigMap(meta.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", UID: "1234"}),
+			expectedMessage: "ConfigMap default/db-config must have an ownerReference to one of Database.example.com, so that it is garbage collected along with its owner",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow updates to objects without an ownerReference",
+			kind:            configMapKind,
+			operation:       admission.Update,
+			object:          newConfigMap(),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:          "Allow objects without an ownerReference in a whitelisted namespace",
+			kind:              configMapKind,
+			operation:         admission.Create,
+			ignoredNamespaces: []string{"default"},
+			object:            newConfigMap(),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+		{
+			testName:        "Don't reject other kinds",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "Secret", Version: "v1"},
+			operation:       admission.Create,
+			rawObject:       []byte(`{"kind":"Secret","apiVersion":"v1","metadata":{"name":"db-secret","namespace":"default"}}`),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return RequireOwnerReferences(tt.ignoredNamespaces, gvk, owner)
+	})
+}
+
+func TestProtectFinalizers(t *testing.T) {
+	t.Parallel()
+
+	newConfigMap := func(finalizers ...string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "bucket", Namespace: "default", Finalizers: finalizers},
+		}
+	}
+	protected := []string{"example.com/bucket-cleanup"}
+	configMapKind := meta.GroupVersionKind{Group: "", Kind: "ConfigMap", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Reject removing a protected finalizer",
+			kind:            configMapKind,
+			operation:       admission.Update,
+			oldObject:       newConfigMap("example.com/bucket-cleanup", "example.com/other"),
+			object:          newConfigMap("example.com/other"),
+			expectedMessage: `ConfigMap default/bucket: removing the "example.com/bucket-cleanup" finalizer(s) is not allowed: protected finalizers ensure that external resources are cleaned up, and may only be removed by their controller`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow removing other finalizers",
+			kind:            configMapKind,
+			operation:       admission.Update,
+			oldObject:       newConfigMap("example.com/bucket-cleanup", "example.com/other"),
+			object:          newConfigMap("example.com/bucket-cleanup"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow adding a protected finalizer",
+			kind:            configMapKind,
+			operation:       admission.Update,
+			oldObject:       newConfigMap(),
+			object:          newConfigMap("example.com/bucket-cleanup"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:          "Allow removing a protected finalizer in a whitelisted namespace",
+			kind:              configMapKind,
+			operation:         admission.Update,
+			ignoredNamespaces: []string{"default"},
+			oldObject:         newConfigMap("example.com/bucket-cleanup"),
+			object:            newConfigMap(),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+		{
+			testName:        "Allow deletes",
+			kind:            configMapKind,
+			operation:       admission.Delete,
+			oldObject:       newConfigMap("example.com/bucket-cleanup"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return ProtectFinalizers(tt.ignoredNamespaces, protected)
+	})
+}
+
+func TestEnforceContainerArgLimits(t *testing.T) {
+	t.Parallel()
+
+	newEnv := func(n int) []corev1.EnvVar {
+		env := make([]corev1.EnvVar, 0, n)
+		for i := 0; i < n; i++ {
+			env = append(env, corev1.EnvVar{Name: fmt.Sprintf("VAR_%d", i), Value: "value"})
+		}
+
+		return env
+	}
+	newPod := func(namespace string, containers ...corev1.Container) *corev1.Pod {
+		return &corev1.Pod{
+			TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace},
+			Spec:       corev1.PodSpec{Containers: containers},
+		}
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+	limitsMessage := "Pod default/web has containers that exceed the environment & argument limits: "
+
+	var denyTests = []objectTest{
+		{
+			testName: "Allow containers within the limits",
+			kind:     podKind,
+			object: newPod("default", corev1.Container{
+				Name: "web", Image: "web:v1.0.0", Env: newEnv(10), Command: []string{"/web"}, Args: []string{"--port=8080"},
+			}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject containers with too many environment variables",
+			kind:            podKind,
+			object:          newPod("default", corev1.Container{Name: "web", Image: "web:v1.0.0", Env: newEnv(11)}),
+			expectedMessage: limitsMessage + "spec.containers[web].env: declares 11 environment variables (limit: 10)",
+			shouldAllow:     false,
+		},
+		{
+			testName: "Reject containers with overly long arguments",
+			kind:     podKind,
+			object: newPod("default", corev1.Container{
+				Name: "web", Image: "web:v1.0.0", Command: []string{"/web"}, Args: []string{"--config=" + strings.Repeat("x", 60)},
+			}),
+			expectedMessage: limitsMessage + "spec.containers[web].args: the command & args total 73 bytes (limit: 64)",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow containers exceeding the limits in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"sandbox"},
+			object:            newPod("sandbox", corev1.Container{Name: "web", Image: "web:v1.0.0", Env: newEnv(11)}),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+		{
+			testName:        "Unhandled Kinds (Service) are correctly rejected",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "Service", Version: "v1"},
+			rawObject:       []byte(`{"kind":"Service","apiVersion":"v1","metadata":{"name":"web","namespace":"default"}}`),
+			expectedMessage: fmt.Sprintf("%s %s", unsupportedKindError, "Service"),
+			shouldAllow:     false,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceContainerArgLimits(tt.ignoredNamespaces, 10, 64)
+	})
+}
+
+func TestValidateResourceQuantities(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, requests, limits corev1.ResourceList) *corev1.Pod {
+		return &corev1.Pod{
+			TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{
+				Name:      "web",
+				Image:     "web:v1.0.0",
+				Resources: corev1.ResourceRequirements{Requests: requests, Limits: limits},
+			}}},
+		}
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+	quantitiesMessage := "Pod default/web has containers with invalid resource quantities: "
+
+	var denyTests = []objectTest{
+		{
+			testName: "Allow requests within the limits",
+			kind:     podKind,
+			object: newPod("default",
+				corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("256Mi")},
+				corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("256Mi")},
+			),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow containers without resources",
+			kind:            podKind,
+			object:          newPod("default", nil, nil),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName: "Reject requests exceeding the limits",
+			kind:     podKind,
+			object: newPod("default",
+				corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+				corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("512Mi")},
+			),
+			expectedMessage: quantitiesMessage + "spec.containers[web].resources: requests.cpu 2 > limits.cpu 1; spec.containers[web].resources: requests.memory 1Gi > limits.memory 512Mi",
+			shouldAllow:     false,
+		},
+		{
+			testName: "Reject zero & negative quantities",
+			kind:     podKind,
+			object: newPod("default",
+				corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("0")},
+				corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("-1Mi")},
+			),
+			expectedMessage: quantitiesMessage + "spec.containers[web].resources: requests.cpu must be positive (got 0); spec.containers[web].resources: limits.memory must be positive (got -1Mi)",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow invalid quantities in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"sandbox"},
+			object:            newPod("sandbox", corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("0")}, nil),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+		{
+			testName:        "Unhandled Kinds (Service) are correctly rejected",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "Service", Version: "v1"},
+			rawObject:       []byte(`{"kind":"Service","apiVersion":"v1","metadata":{"name":"web","namespace":"default"}}`),
+			expectedMessage: fmt.Sprintf("%s %s", unsupportedKindError, "Service"),
+			shouldAllow:     false,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return ValidateResourceQuantities(tt.ignoredNamespaces)
+	})
+}
+
+func TestRequireIngressHTTPSRedirect(t *testing.T) {
+	t.Parallel()
+
+	newIngress := func(namespace string, annotations map[string]string) *networkingv1.Ingress {
+		return &networkingv1.Ingress{
+			TypeMeta:   meta.TypeMeta{Kind: "Ingress", APIVersion: "networking.k8s.io/v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace, Annotations: annotations},
+		}
+	}
+	ingressKind := meta.GroupVersionKind{Group: "networking.k8s.io", Kind: "Ingress", Version: "v1"}
+	redirectAnnotation := "nginx.ingress.kubernetes.io/ssl-redirect"
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow an Ingress that redirects to HTTPS",
+			kind:            ingressKind,
+			object:          newIngress("default", map[string]string{redirectAnnotation: "true"}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject an Ingress without the redirect annotation",
+			kind:            ingressKind,
+			object:          newIngress("default", nil),
+			expectedMessage: `Ingress default/web does not redirect to HTTPS: metadata.annotations[nginx.ingress.kubernetes.io/ssl-redirect]: must be "true" to redirect plaintext requests to HTTPS`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject an Ingress with redirects disabled",
+			kind:            ingressKind,
+			object:          newIngress("default", map[string]string{redirectAnnotation: "false"}),
+			expectedMessage: `Ingress default/web does not redirect to HTTPS: metadata.annotations[nginx.ingress.kubernetes.io/ssl-redirect]: must be "true" to redirect plaintext requests to HTTPS`,
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow an Ingress in a whitelisted namespace",
+			kind:              ingressKind,
+			ignoredNamespaces: []string{"sandbox"},
+			object:            newIngress("sandbox", nil),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+		{
+			testName:        "Don't reject Services",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "Service", Version: "v1"},
+			rawObject:       []byte(`{"kind":"Service","apiVersion":"v1","metadata":{"name":"web","namespace":"default"}}`),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return RequireIngressHTTPSRedirect(tt.ignoredNamespaces, map[string]string{redirectAnnotation: "true"})
+	})
+}
+
+func TestEnforceMaxReplicas(t *testing.T) {
+	t.Parallel()
+
+	replicas := func(n int32) *int32 { return &n }
+	newDeployment := func(namespace string, replicas *int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			TypeMeta:   meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace},
+			Spec:       appsv1.DeploymentSpec{Replicas: replicas},
+		}
+	}
+	deploymentKind := meta.GroupVersionKind{Group: "apps", Kind: "Deployment", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a Deployment at the maximum",
+			kind:            deploymentKind,
+			object:          newDeployment("default", replicas(50)),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow a Deployment with the default replicas",
+			kind:            deploymentKind,
+			object:          newDeployment("default", nil),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a Deployment beyond the maximum",
+			kind:            deploymentKind,
+			object:          newDeployment("default", replicas(1000)),
+			expectedMessage: "Deployment default/web requests 1000 replicas: the maximum is 50",
+			shouldAllow:     false,
+		},
+		{
+			testName: "Reject a StatefulSet beyond the maximum",
+			kind:     meta.GroupVersionKind{Group: "apps", Kind: "StatefulSet", Version: "v1"},
+			object: &appsv1.StatefulSet{
+				TypeMeta:   meta.TypeMeta{Kind: "StatefulSet", APIVersion: "apps/v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "db", Namespace: "default"},
+				Spec:       appsv1.StatefulSetSpec{Replicas: replicas(51)},
+			},
+			expectedMessage: "StatefulSet default/db requests 51 replicas: the maximum is 50",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow a Deployment in a whitelisted namespace",
+			kind:              deploymentKind,
+			ignoredNamespaces: []string{"batch"},
+			object:            newDeployment("batch", replicas(1000)),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+		{
+			testName:        "Don't reject DaemonSets",
+			kind:            meta.GroupVersionKind{Group: "apps", Kind: "DaemonSet", Version: "v1"},
+			rawObject:       []byte(`{"kind":"DaemonSet","apiVersion":"apps/v1","metadata":{"name":"agent","namespace":"default"}}`),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceMaxReplicas(tt.ignoredNamespaces, 50)
+	})
+}
+
+func TestRequireResourceRequestsForHPA(t *testing.T) {
+	t.Parallel()
+
+	newDeployment := func(name string, requests corev1.ResourceList) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: meta.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:      "web",
+					Image:     "web:v1.0.0",
+					Resources: corev1.ResourceRequirements{Requests: requests},
+				}},
+			}}},
+		}
+	}
+
+	client := fake.NewSimpleClientset(
+		newDeployment("with-requests", corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")}),
+		newDeployment("without-requests", nil),
+	)
+	factory := informers.NewSharedInformerFactory(client, 0)
+	admitFunc := RequireResourceRequestsForHPA(factory)
+
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory.Start(stopCh)
+
+	newHPA := func(target string) *autoscalingv1.HorizontalPodAutoscaler {
+		return &autoscalingv1.HorizontalPodAutoscaler{
+			TypeMeta:   meta.TypeMeta{Kind: "HorizontalPodAutoscaler", APIVersion: "autoscaling/v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: autoscalingv1.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscalingv1.CrossVersionObjectReference{Kind: "Deployment", Name: target, APIVersion: "apps/v1"},
+				MaxReplicas:    10,
+			},
+		}
+	}
+	newHPAv2 := func(target string, metrics ...autoscalingv2beta2.MetricSpec) *autoscalingv2beta2.HorizontalPodAutoscaler {
+		return &autoscalingv2beta2.HorizontalPodAutoscaler{
+			TypeMeta:   meta.TypeMeta{Kind: "HorizontalPodAutoscaler", APIVersion: "autoscaling/v2beta2"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: autoscalingv2beta2.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscalingv2beta2.CrossVersionObjectReference{Kind: "Deployment", Name: target, APIVersion: "apps/v1"},
+				MaxReplicas:    10,
+				Metrics:        metrics,
+			},
+		}
+	}
+	resourceMetric := func(name corev1.ResourceName, target autoscalingv2beta2.MetricTargetType) autoscalingv2beta2.MetricSpec {
+		return autoscalingv2beta2.MetricSpec{
+			Type:     autoscalingv2beta2.ResourceMetricSourceType,
+			Resource: &autoscalingv2beta2.ResourceMetricSource{Name: name, Target: autoscalingv2beta2.MetricTarget{Type: target}},
+		}
+	}
+	hpaKind := meta.GroupVersionKind{Group: "autoscaling", Kind: "HorizontalPodAutoscaler", Version: "v1"}
+	hpav2Kind := meta.GroupVersionKind{Group: "autoscaling", Kind: "HorizontalPodAutoscaler", Version: "v2beta2"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a CPU-based HPA targeting a Deployment with CPU requests",
+			kind:            hpaKind,
+			operation:       admission.Create,
+			object:          newHPA("with-requests"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a CPU-based HPA targeting a Deployment without CPU requests",
+			kind:            hpaKind,
+			operation:       admission.Create,
+			object:          newHPA("without-requests"),
+			expectedMessage: "HorizontalPodAutoscaler default/web scales on cpu utilization, which requires resource requests on the containers of Deployment default/without-requests: spec.template.spec.containers[web].resources.requests.cpu: must be set",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a memory-based HPA targeting a Deployment without memory requests",
+			kind:            hpav2Kind,
+			operation:       admission.Create,
+			object:          newHPAv2("with-requests", resourceMetric(corev1.ResourceMemory, autoscalingv2beta2.UtilizationMetricType)),
+			expectedMessage: "HorizontalPodAutoscaler default/web scales on memory utilization, which requires resource requests on the containers of Deployment default/with-requests: spec.template.spec.containers[web].resources.requests.memory: must be set",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow an HPA scaling on average values",
+			kind:            hpav2Kind,
+			operation:       admission.Create,
+			object:          newHPAv2("without-requests", resourceMetric(corev1.ResourceMemory, autoscalingv2beta2.AverageValueMetricType)),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow an HPA created before its Deployment",
+			kind:            hpaKind,
+			operation:       admission.Create,
+			object:          newHPA("missing"),
+			expectedMessage: "allowing admission: the scale target Deployment default/missing does not exist",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Don't reject Deployments",
+			kind:            meta.GroupVersionKind{Group: "apps", Kind: "Deployment", Version: "v1"},
+			operation:       admission.Create,
+			rawObject:       nil,
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+}
+
+func TestDenyServiceAccountTokenHostMount(t *testing.T) {
+	t.Parallel()
+
+	expiration := func(seconds int64) *int64 { return &seconds }
+	newPod := func(namespace string, expirationSeconds *int64, readOnly bool) *corev1.Pod {
+		return &corev1.Pod{
+			TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:         "web",
+					Image:        "web:v1.0.0",
+					VolumeMounts: []corev1.VolumeMount{{Name: "token", MountPath: "/var/run/secrets/tokens", ReadOnly: readOnly}},
+				}},
+				Volumes: []corev1.Volume{{
+					Name: "token",
+					VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+						Sources: []corev1.VolumeProjection{{
+							ServiceAccountToken: &corev1.ServiceAccountTokenProjection{Path: "token", ExpirationSeconds: expirationSeconds},
+						}},
+					}},
+				}},
+			},
+		}
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a short-lived, read-only token",
+			kind:            podKind,
+			object:          newPod("default", expiration(600), true),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow a token with the default expiration",
+			kind:            podKind,
+			object:          newPod("default", nil, true),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a long-lived token",
+			kind:            podKind,
+			object:          newPod("default", expiration(86400), true),
+			expectedMessage: "Pod default/web projects service account tokens insecurely: spec.volumes[token].projected.sources[0].serviceAccountToken.expirationSeconds: 86400 exceeds the maximum of 3600",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a writable token",
+			kind:            podKind,
+			object:          newPod("default", expiration(600), false),
+			expectedMessage: "Pod default/web projects service account tokens insecurely: spec.containers[web].volumeMounts[token]: service account tokens must be mounted read-only",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow a long-lived token in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"kube-system"},
+			object:            newPod("kube-system", expiration(86400), false),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return DenyServiceAccountTokenHostMount(tt.ignoredNamespaces, time.Hour)
+	})
+
+	// The kube-api-access-* volume, as injected by the API server.
+	newInjectedPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:         "web",
+					Image:        "web:v1.0.0",
+					VolumeMounts: []corev1.VolumeMount{{Name: "kube-api-access-x7k2p", MountPath: "/var/run/secrets/kubernetes.io/serviceaccount", ReadOnly: true}},
+				}},
+				Volumes: []corev1.Volume{{
+					Name: "kube-api-access-x7k2p",
+					VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+						Sources: []corev1.VolumeProjection{
+							{ServiceAccountToken: &corev1.ServiceAccountTokenProjection{Path: "token", ExpirationSeconds: expiration(3607)}},
+							{ConfigMap: &corev1.ConfigMapProjection{
+								LocalObjectReference: corev1.LocalObjectReference{Name: "kube-root-ca.crt"},
+								Items:                []corev1.KeyToPath{{Key: "ca.crt", Path: "ca.crt"}},
+							}},
+							{DownwardAPI: &corev1.DownwardAPIProjection{
+								Items: []corev1.DownwardAPIVolumeFile{{Path: "namespace", FieldRef: &corev1.ObjectFieldSelector{APIVersion: "v1", FieldPath: "metadata.namespace"}}},
+							}},
+						},
+					}},
+				}},
+			},
+		}
+	}
+
+	runObjectTests(t, []objectTest{
+		{
+			testName:        "Allow the injected kube-api-access volume",
+			kind:            podKind,
+			object:          newInjectedPod(),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}, func(tt objectTest) AdmitFunc {
+		return DenyServiceAccountTokenHostMount(nil, time.Hour)
+	})
+
+	runObjectTests(t, []objectTest{
+		{
+			testName:        "Default a zero maximum to 24 hours",
+			kind:            podKind,
+			object:          newPod("default", expiration(86400), true),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject tokens beyond the default maximum",
+			kind:            podKind,
+			object:          newPod("default", expiration(86401), true),
+			expectedMessage: "Pod default/web projects service account tokens insecurely: spec.volumes[token].projected.sources[0].serviceAccountToken.expirationSeconds: 86401 exceeds the maximum of 86400",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow the injected kube-api-access volume by default",
+			kind:            podKind,
+			object:          newInjectedPod(),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}, func(tt objectTest) AdmitFunc {
+		return DenyServiceAccountTokenHostMount(nil, 0)
+	})
+}
+
+func TestRequireInitContainer(t *testing.T) {
+	t.Parallel()
+
+	triggerAnnotation := "secrets.corp/inject"
+	newPod := func(namespace string, annotations map[string]string, initContainers ...string) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0")
+		pod.Annotations = annotations
+		for _, name := range initContainers {
+			pod.Spec.InitContainers = append(pod.Spec.InitContainers, corev1.Container{Name: name, Image: "init:v1.0.0"})
+		}
+
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow an annotated Pod with the init container",
+			kind:            podKind,
+			object:          newPod("default", map[string]string{triggerAnnotation: "true"}, "migrate", "fetch-secrets"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow a Pod without the annotation",
+			kind:            podKind,
+			object:          newPod("default", nil),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject an annotated Pod without the init container",
+			kind:            podKind,
+			object:          newPod("default", map[string]string{triggerAnnotation: "true"}, "migrate"),
+			expectedMessage: `Pod default/web carries the secrets.corp/inject annotation, and must declare the init container "fetch-secrets" (in spec.initContainers)`,
+			shouldAllow:     false,
+		},
+		{
+			testName: "Reject an annotated Deployment template without the init container",
+			kind:     meta.GroupVersionKind{Group: "apps", Kind: "Deployment", Version: "v1"},
+			object: &appsv1.Deployment{
+				TypeMeta:   meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+					ObjectMeta: meta.ObjectMeta{Annotations: map[string]string{triggerAnnotation: "true"}},
+					Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "web:v1.0.0"}}},
+				}},
+			},
+			expectedMessage: `Deployment default/web carries the secrets.corp/inject annotation, and must declare the init container "fetch-secrets" (in spec.template.spec.initContainers)`,
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow an annotated Pod in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"sandbox"},
+			object:            newPod("sandbox", map[string]string{triggerAnnotation: "true"}),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return RequireInitContainer(tt.ignoredNamespaces, "fetch-secrets", triggerAnnotation)
+	})
+}
+
+func TestDenyDuplicateContainerPorts(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, sidecarPorts ...corev1.ContainerPort) *corev1.Pod {
+		return &corev1.Pod{
+			TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: "web", Image: "web:v1.0.0", Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}}},
+				{Name: "proxy", Image: "proxy:v1.0.0", Ports: sidecarPorts},
+			}},
+		}
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow distinct ports",
+			kind:            podKind,
+			object:          newPod("default", corev1.ContainerPort{Name: "proxy", ContainerPort: 9090}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow the same port with a different protocol",
+			kind:            podKind,
+			object:          newPod("default", corev1.ContainerPort{Name: "dns", ContainerPort: 8080, Protocol: corev1.ProtocolUDP}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject the same port & protocol",
+			kind:            podKind,
+			object:          newPod("default", corev1.ContainerPort{Name: "proxy", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}),
+			expectedMessage: "Pod default/web declares conflicting container ports: spec.containers[proxy].ports[0]: containerPort 8080/TCP is also declared by spec.containers[web].ports[0]",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject the same port name",
+			kind:            podKind,
+			object:          newPod("default", corev1.ContainerPort{Name: "http", ContainerPort: 9090}),
+			expectedMessage: `Pod default/web declares conflicting container ports: spec.containers[proxy].ports[0]: the port name "http" is also declared by spec.containers[web].ports[0]`,
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow conflicting ports in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"sandbox"},
+			object:            newPod("sandbox", corev1.ContainerPort{Name: "http", ContainerPort: 8080}),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return DenyDuplicateContainerPorts(tt.ignoredNamespaces)
+	})
+}
+
+func TestDenyServicesWithoutSelector(t *testing.T) {
+	t.Parallel()
+
+	newService := func(namespace string, selector map[string]string, mutate func(*corev1.ServiceSpec)) *corev1.Service {
+		service := &corev1.Service{
+			TypeMeta:   meta.TypeMeta{Kind: "Service", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace},
+			Spec:       corev1.ServiceSpec{Selector: selector},
+		}
+		if mutate != nil {
+			mutate(&service.Spec)
+		}
+
+		return service
+	}
+	headless := func(spec *corev1.ServiceSpec) { spec.ClusterIP = corev1.ClusterIPNone }
+	externalName := func(spec *corev1.ServiceSpec) {
+		spec.Type = corev1.ServiceTypeExternalName
+		spec.ExternalName = "db.example.com"
+	}
+	serviceKind := meta.GroupVersionKind{Group: "", Kind: "Service", Version: "v1"}
+	selectorMessage := "Service default/web has an empty .spec.selector: it will not route traffic to any Pods, and must select the Pods that back it"
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a Service with a selector",
+			kind:            serviceKind,
+			object:          newService("default", map[string]string{"app": "web"}, nil),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a Service without a selector",
+			kind:            serviceKind,
+			object:          newService("default", nil, nil),
+			expectedMessage: selectorMessage,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow a headless Service without a selector",
+			kind:            serviceKind,
+			object:          newService("default", nil, headless),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow an ExternalName Service without a selector",
+			kind:            serviceKind,
+			object:          newService("default", nil, externalName),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:          "Allow a Service without a selector in a whitelisted namespace",
+			kind:              serviceKind,
+			ignoredNamespaces: []string{"sandbox"},
+			object:            newService("sandbox", nil, nil),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return DenyServicesWithoutSelector(tt.ignoredNamespaces, true)
+	})
+
+	t.Run("Reject headless Services without a selector unless allowed", func(t *testing.T) {
+		runObjectTests(t, []objectTest{{
+			testName:        "Reject a headless Service without a selector",
+			kind:            serviceKind,
+			object:          newService("default", nil, headless),
+			expectedMessage: selectorMessage,
+			shouldAllow:     false,
+		}}, func(tt objectTest) AdmitFunc {
+			return DenyServicesWithoutSelector(tt.ignoredNamespaces, false)
+		})
+	})
+}
+
+func TestValidatePVCAccessModes(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		&storagev1.StorageClass{
+			ObjectMeta:  meta.ObjectMeta{Name: "block", Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"}},
+			Provisioner: "ebs.csi.aws.com",
+		},
+		&storagev1.StorageClass{ObjectMeta: meta.ObjectMeta{Name: "shared"}, Provisioner: "efs.csi.aws.com"},
+		&storagev1.StorageClass{ObjectMeta: meta.ObjectMeta{Name: "other"}, Provisioner: "example.com/other"},
+	)
+	factory := informers.NewSharedInformerFactory(client, 0)
+	admitFunc := ValidatePVCAccessModes(factory, []string{"sandbox"}, map[string][]corev1.PersistentVolumeAccessMode{
+		"ebs.csi.aws.com": {corev1.ReadWriteOnce},
+		"efs.csi.aws.com": {corev1.ReadWriteOnce, corev1.ReadOnlyMany, corev1.ReadWriteMany},
+	})
+
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory.Start(stopCh)
+
+	className := func(name string) *string { return &name }
+	newPVC := func(namespace string, storageClassName *string, modes ...corev1.PersistentVolumeAccessMode) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			TypeMeta:   meta.TypeMeta{Kind: "PersistentVolumeClaim", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "data", Namespace: namespace},
+			Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: storageClassName, AccessModes: modes},
+		}
+	}
+	pvcKind := meta.GroupVersionKind{Group: "", Kind: "PersistentVolumeClaim", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a supported access mode",
+			kind:            pvcKind,
+			operation:       admission.Create,
+			object:          newPVC("default", className("shared"), corev1.ReadWriteMany),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject an unsupported access mode",
+			kind:            pvcKind,
+			operation:       admission.Create,
+			object:          newPVC("default", className("block"), corev1.ReadWriteOnce, corev1.ReadWriteMany),
+			expectedMessage: "PersistentVolumeClaim default/data requests the ReadWriteMany access mode(s), which the StorageClass block (provisioner ebs.csi.aws.com) cannot satisfy: the supported access modes are ReadWriteOnce",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject an unsupported access mode on the default StorageClass",
+			kind:            pvcKind,
+			operation:       admission.Create,
+			object:          newPVC("default", nil, corev1.ReadOnlyMany),
+			expectedMessage: "PersistentVolumeClaim default/data requests the ReadOnlyMany access mode(s), which the StorageClass block (provisioner ebs.csi.aws.com) cannot satisfy: the supported access modes are ReadWriteOnce",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow claims for unknown provisioners",
+			kind:            pvcKind,
+			operation:       admission.Create,
+			object:          newPVC("default", className("other"), corev1.ReadWriteMany),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow claims without a StorageClass",
+			kind:            pvcKind,
+			operation:       admission.Create,
+			object:          newPVC("default", className(""), corev1.ReadWriteMany),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow claims in a whitelisted namespace",
+			kind:            pvcKind,
+			operation:       admission.Create,
+			object:          newPVC("sandbox", className("block"), corev1.ReadWriteMany),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+}
+
+func TestEnforceMetadataLimits(t *testing.T) {
+	t.Parallel()
+
+	newConfigMap := func(namespace string, labelCount int, annotations map[string]string) *corev1.ConfigMap {
+		labels := make(map[string]string, labelCount)
+		for i := 0; i < labelCount; i++ {
+			labels[fmt.Sprintf("label-%d", i)] = "value"
+		}
+
+		return &corev1.ConfigMap{
+			TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "settings", Namespace: namespace, Labels: labels, Annotations: annotations},
+		}
+	}
+	configMapKind := meta.GroupVersionKind{Group: "", Kind: "ConfigMap", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow metadata within the limits",
+			kind:            configMapKind,
+			object:          newConfigMap("default", 3, map[string]string{"owner": "payments"}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject too many labels",
+			kind:            configMapKind,
+			object:          newConfigMap("default", 4, nil),
+			expectedMessage: "ConfigMap default/settings exceeds the metadata limits: metadata.labels: 4 labels exceed the maximum of 3",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject too many annotations",
+			kind:            configMapKind,
+			object:          newConfigMap("default", 0, map[string]string{"a": "1", "b": "2", "c": "3"}),
+			expectedMessage: "ConfigMap default/settings exceeds the metadata limits: metadata.annotations: 3 annotations exceed the maximum of 2",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject oversized annotations",
+			kind:            configMapKind,
+			object:          newConfigMap("default", 0, map[string]string{"last-applied": strings.Repeat("x", 64)}),
+			expectedMessage: "ConfigMap default/settings exceeds the metadata limits: metadata.annotations: 76 bytes of annotations exceed the maximum of 64 bytes",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow oversized metadata in a whitelisted namespace",
+			kind:              configMapKind,
+			ignoredNamespaces: []string{"kube-system"},
+			object:            newConfigMap("kube-system", 4, nil),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceMetadataLimits(tt.ignoredNamespaces, 3, 2, 64)
+	})
+}
+
+func TestValidateEnvValueFrom(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		&corev1.ConfigMap{ObjectMeta: meta.ObjectMeta{Name: "settings", Namespace: "default"}, Data: map[string]string{"LOG_LEVEL": "info"}},
+		&corev1.Secret{ObjectMeta: meta.ObjectMeta{Name: "credentials", Namespace: "default"}, Data: map[string][]byte{"password": []byte("hunter2")}},
+	)
+	factory := informers.NewSharedInformerFactory(client, 0)
+	admitFunc := ValidateEnvValueFrom(factory, []string{"sandbox"})
+
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory.Start(stopCh)
+
+	optional := true
+	configMapEnv := func(name, key string, optional *bool) corev1.EnvVar {
+		return corev1.EnvVar{Name: "LOG_LEVEL", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Key:                  key,
+			Optional:             optional,
+		}}}
+	}
+	secretEnv := func(name, key string) corev1.EnvVar {
+		return corev1.EnvVar{Name: "DB_PASSWORD", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Key:                  key,
+		}}}
+	}
+	newPod := func(namespace string, env ...corev1.EnvVar) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0")
+		pod.Spec.Containers[0].Env = env
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow references to existing keys",
+			kind:            podKind,
+			object:          newPod("default", configMapEnv("settings", "LOG_LEVEL", nil), secretEnv("credentials", "password")),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a reference to a missing ConfigMap key",
+			kind:            podKind,
+			object:          newPod("default", configMapEnv("settings", "LOG_LEVLE", nil)),
+			expectedMessage: `Pod default/web references keys that do not exist: spec.containers[container-0].env[0].valueFrom.configMapKeyRef: the key "LOG_LEVLE" does not exist in ConfigMap settings (for LOG_LEVEL)`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a reference to a missing Secret key",
+			kind:            podKind,
+			object:          newPod("default", secretEnv("credentials", "pasword")),
+			expectedMessage: `Pod default/web references keys that do not exist: spec.containers[container-0].env[0].valueFrom.secretKeyRef: the key "pasword" does not exist in Secret credentials (for DB_PASSWORD)`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow optional references to missing keys",
+			kind:            podKind,
+			object:          newPod("default", configMapEnv("settings", "LOG_LEVLE", &optional)),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow references to objects that do not exist yet",
+			kind:            podKind,
+			object:          newPod("default", configMapEnv("missing", "LOG_LEVEL", nil)),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow missing keys in a whitelisted namespace",
+			kind:            podKind,
+			object:          newPod("sandbox", secretEnv("credentials", "pasword")),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+}
+
+func TestEnforceRuntimeClasses(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, runtimeClass string) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0")
+		if runtimeClass != "" {
+			pod.Spec.RuntimeClassName = &runtimeClass
+		}
+
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+	allowed := []string{"gvisor", "kata"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow an allowed RuntimeClass",
+			kind:            podKind,
+			object:          newPod("tenants", "gvisor"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a RuntimeClass that is not allowed",
+			kind:            podKind,
+			object:          newPod("tenants", "runc"),
+			expectedMessage: `Pod tenants/web requests the RuntimeClass "runc", which is not allowed: the allowed RuntimeClasses are gvisor, kata`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a Pod without a RuntimeClass",
+			kind:            podKind,
+			object:          newPod("tenants", ""),
+			expectedMessage: "Pod tenants/web must set spec.runtimeClassName: the allowed RuntimeClasses are gvisor, kata",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow a Pod without a RuntimeClass in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"kube-system"},
+			object:            newPod("kube-system", ""),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceRuntimeClasses(tt.ignoredNamespaces, allowed, true)
+	})
+
+	t.Run("Allow Pods without a RuntimeClass unless required", func(t *testing.T) {
+		runObjectTests(t, []objectTest{{
+			testName:        "Allow a Pod without a RuntimeClass",
+			kind:            podKind,
+			object:          newPod("tenants", ""),
+			expectedMessage: "",
+			shouldAllow:     true,
+		}}, func(tt objectTest) AdmitFunc {
+			return EnforceRuntimeClasses(tt.ignoredNamespaces, allowed, false)
+		})
+	})
+}
+
+func TestDenyDownwardAPISensitiveFields(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, envFieldPath string, volume *corev1.Volume) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0")
+		pod.Spec.Containers[0].Env = []corev1.EnvVar{{
+			Name:      "POD_FIELD",
+			ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: envFieldPath}},
+		}}
+		if volume != nil {
+			pod.Spec.Volumes = []corev1.Volume{*volume}
+		}
+
+		return pod
+	}
+	podInfo := &corev1.Volume{
+		Name: "podinfo",
+		VolumeSource: corev1.VolumeSource{DownwardAPI: &corev1.DownwardAPIVolumeSource{Items: []corev1.DownwardAPIVolumeFile{
+			{Path: "labels", FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.labels"}},
+			{Path: "annotations", FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.annotations"}},
+		}}},
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow non-sensitive field paths",
+			kind:            podKind,
+			object:          newPod("default", "metadata.name", nil),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject an annotation exposed as an environment variable",
+			kind:            podKind,
+			object:          newPod("default", "metadata.annotations['example.com/token']", nil),
+			expectedMessage: "Pod default/web exposes sensitive fields via the downward API: spec.containers[container-0].env[0].valueFrom.fieldRef.fieldPath: metadata.annotations['example.com/token'] must not be exposed via the downward API",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject annotations exposed in a downwardAPI volume",
+			kind:            podKind,
+			object:          newPod("default", "metadata.name", podInfo),
+			expectedMessage: "Pod default/web exposes sensitive fields via the downward API: spec.volumes[podinfo].downwardAPI.items[1].fieldRef.fieldPath: metadata.annotations must not be exposed via the downward API",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow sensitive field paths in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"monitoring"},
+			object:            newPod("monitoring", "metadata.annotations", podInfo),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return DenyDownwardAPISensitiveFields(tt.ignoredNamespaces, nil)
+	})
+
+	t.Run("Deny the configured field paths", func(t *testing.T) {
+		runObjectTests(t, []objectTest{{
+			testName:        "Reject a configured field path",
+			kind:            podKind,
+			object:          newPod("default", "spec.serviceAccountName", nil),
+			expectedMessage: "Pod default/web exposes sensitive fields via the downward API: spec.containers[container-0].env[0].valueFrom.fieldRef.fieldPath: spec.serviceAccountName must not be exposed via the downward API",
+			shouldAllow:     false,
+		}}, func(tt objectTest) AdmitFunc {
+			return DenyDownwardAPISensitiveFields(tt.ignoredNamespaces, []string{"spec.serviceAccountName"})
+		})
+	})
+}
+
+func TestRequirePDBForWorkload(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(&policyv1.PodDisruptionBudget{
+		ObjectMeta: meta.ObjectMeta{Name: "api", Namespace: "default"},
+		Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &meta.LabelSelector{MatchLabels: map[string]string{"app": "api"}}},
+	})
+	factory := informers.NewSharedInformerFactory(client, 0)
+	admitFunc := RequirePDBForWorkload(factory, []string{"sandbox"}, 2)
+
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory.Start(stopCh)
+
+	replicas := func(n int32) *int32 { return &n }
+	newDeployment := func(namespace string, app string, replicas *int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			TypeMeta:   meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+			ObjectMeta: meta.ObjectMeta{Name: app, Namespace: namespace},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: replicas,
+				Template: corev1.PodTemplateSpec{ObjectMeta: meta.ObjectMeta{Labels: map[string]string{"app": app}}},
+			},
+		}
+	}
+	deploymentKind := meta.GroupVersionKind{Group: "apps", Kind: "Deployment", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a workload selected by a PodDisruptionBudget",
+			kind:            deploymentKind,
+			operation:       admission.Create,
+			object:          newDeployment("default", "api", replicas(3)),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a workload without a PodDisruptionBudget",
+			kind:            deploymentKind,
+			operation:       admission.Create,
+			object:          newDeployment("default", "web", replicas(3)),
+			expectedMessage: "Deployment default/web runs 3 replicas, but no PodDisruptionBudget selects its Pods: create a PodDisruptionBudget (e.g. with maxUnavailable: 1) that selects app=web",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow a workload below the replica threshold",
+			kind:            deploymentKind,
+			operation:       admission.Create,
+			object:          newDeployment("default", "web", nil),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow updates",
+			kind:            deploymentKind,
+			operation:       admission.Update,
+			object:          newDeployment("default", "web", replicas(3)),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow a workload in a whitelisted namespace",
+			kind:            deploymentKind,
+			operation:       admission.Create,
+			object:          newDeployment("sandbox", "web", replicas(3)),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+}
+
+func TestDenyMemoryBackedEmptyDir(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, medium corev1.StorageMedium, sizeLimit string) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0")
+		emptyDir := &corev1.EmptyDirVolumeSource{Medium: medium}
+		if sizeLimit != "" {
+			limit := resource.MustParse(sizeLimit)
+			emptyDir.SizeLimit = &limit
+		}
+		pod.Spec.Volumes = []corev1.Volume{{Name: "scratch", VolumeSource: corev1.VolumeSource{EmptyDir: emptyDir}}}
+
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a disk-backed emptyDir without a sizeLimit",
+			kind:            podKind,
+			object:          newPod("default", corev1.StorageMediumDefault, ""),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow a memory-backed emptyDir within the maximum size",
+			kind:            podKind,
+			object:          newPod("default", corev1.StorageMediumMemory, "64Mi"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a memory-backed emptyDir without a sizeLimit",
+			kind:            podKind,
+			object:          newPod("default", corev1.StorageMediumMemory, ""),
+			expectedMessage: "Pod default/web has unbounded memory-backed emptyDir volumes: spec.volumes[scratch].emptyDir.sizeLimit: the emptyDir volume scratch with medium Memory must set a sizeLimit",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a memory-backed emptyDir above the maximum size",
+			kind:            podKind,
+			object:          newPod("default", corev1.StorageMediumMemory, "1Gi"),
+			expectedMessage: "Pod default/web has unbounded memory-backed emptyDir volumes: spec.volumes[scratch].emptyDir.sizeLimit: the emptyDir volume scratch with medium Memory has a sizeLimit of 1Gi: the maximum is 256Mi",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow a memory-backed emptyDir in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"logging"},
+			object:            newPod("logging", corev1.StorageMediumMemory, ""),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return DenyMemoryBackedEmptyDir(tt.ignoredNamespaces, resource.MustParse("256Mi"))
+	})
+}
+
+func TestDenyDefaultServiceAccount(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, serviceAccount string) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0")
+		pod.Spec.ServiceAccountName = serviceAccount
+
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a dedicated ServiceAccount",
+			kind:            podKind,
+			object:          newPod("default", "web"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject the default ServiceAccount",
+			kind:            podKind,
+			object:          newPod("default", "default"),
+			expectedMessage: "Pod default/web uses the default ServiceAccount: create a dedicated ServiceAccount for the workload and reference it in spec.serviceAccountName",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject an omitted ServiceAccount",
+			kind:            podKind,
+			object:          newPod("default", ""),
+			expectedMessage: "Pod default/web uses the default ServiceAccount: create a dedicated ServiceAccount for the workload and reference it in spec.serviceAccountName",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow the default ServiceAccount in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"sandbox"},
+			object:            newPod("sandbox", ""),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return DenyDefaultServiceAccount(tt.ignoredNamespaces)
+	})
+}
+
+func TestRequireReadinessGates(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, conditionTypes ...corev1.PodConditionType) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0")
+		for _, conditionType := range conditionTypes {
+			pod.Spec.ReadinessGates = append(pod.Spec.ReadinessGates, corev1.PodReadinessGate{ConditionType: conditionType})
+		}
+
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a Pod with the required readiness gates",
+			kind:            podKind,
+			object:          newPod("default", "example.com/load-balancer-ready", "example.com/warmed-up", "example.com/other"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a Pod missing a readiness gate",
+			kind:            podKind,
+			object:          newPod("default", "example.com/warmed-up"),
+			expectedMessage: "Pod default/web is missing the required readiness gates in spec.readinessGates: example.com/load-balancer-ready",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a Pod without readiness gates",
+			kind:            podKind,
+			object:          newPod("default"),
+			expectedMessage: "Pod default/web is missing the required readiness gates in spec.readinessGates: example.com/load-balancer-ready, example.com/warmed-up",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow a Pod without readiness gates in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"batch"},
+			object:            newPod("batch"),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return RequireReadinessGates(tt.ignoredNamespaces, []string{"example.com/load-balancer-ready", "example.com/warmed-up"})
+	})
+}
+
+func TestEnforceLoadBalancerClass(t *testing.T) {
+	t.Parallel()
+
+	newService := func(namespace string, serviceType corev1.ServiceType, class string) *corev1.Service {
+		service := &corev1.Service{
+			TypeMeta:   meta.TypeMeta{Kind: "Service", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace},
+			Spec:       corev1.ServiceSpec{Type: serviceType},
+		}
+		if class != "" {
+			service.Spec.LoadBalancerClass = &class
+		}
+
+		return service
+	}
+	serviceKind := meta.GroupVersionKind{Group: "", Kind: "Service", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow an allowed load balancer class",
+			kind:            serviceKind,
+			object:          newService("default", corev1.ServiceTypeLoadBalancer, "metallb.universe.tf/metallb"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow the default load balancer class",
+			kind:            serviceKind,
+			object:          newService("default", corev1.ServiceTypeLoadBalancer, ""),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a load balancer class that is not allowed",
+			kind:            serviceKind,
+			object:          newService("default", corev1.ServiceTypeLoadBalancer, "example.com/internal-lb"),
+			expectedMessage: `Service default/web requests the load balancer class "example.com/internal-lb": the allowed classes are [metallb.universe.tf/metallb, service.k8s.aws/nlb]`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow a ClusterIP Service",
+			kind:            serviceKind,
+			object:          newService("default", corev1.ServiceTypeClusterIP, ""),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:          "Allow any load balancer class in a whitelisted namespace",
+			kind:              serviceKind,
+			ignoredNamespaces: []string{"networking"},
+			object:            newService("networking", corev1.ServiceTypeLoadBalancer, "example.com/internal-lb"),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceLoadBalancerClass(tt.ignoredNamespaces, []string{"metallb.universe.tf/metallb", "service.k8s.aws/nlb"})
+	})
+}
+
+func TestEnforceGuaranteedQoSForPinnedWorkloads(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, pinned bool, requests corev1.ResourceList, limits corev1.ResourceList) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0")
+		if pinned {
+			pod.Annotations = map[string]string{"example.com/cpu-pinning": "true"}
+		}
+		pod.Spec.Containers[0].Resources = corev1.ResourceRequirements{Requests: requests, Limits: limits}
+
+		return pod
+	}
+	resources := func(cpu string, memory string) corev1.ResourceList {
+		return corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu), corev1.ResourceMemory: resource.MustParse(memory)}
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+	summary := "Pod default/web requests CPU pinning (example.com/cpu-pinning), but will not be pinned: pinning requires the Guaranteed QoS class, with integer CPU requests equal to the CPU limits, and memory requests equal to the memory limits, for every container: "
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a pinned Pod with Guaranteed QoS",
+			kind:            podKind,
+			object:          newPod("default", true, resources("2", "1Gi"), resources("2", "1Gi")),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow a pinned Pod with limits only",
+			kind:            podKind,
+			object:          newPod("default", true, nil, resources("2", "1Gi")),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow fractional CPUs without the annotation",
+			kind:            podKind,
+			object:          newPod("default", false, resources("500m", "1Gi"), resources("1500m", "1Gi")),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a pinned Pod with fractional CPUs",
+			kind:            podKind,
+			object:          newPod("default", true, resources("1500m", "1Gi"), resources("1500m", "1Gi")),
+			expectedMessage: summary + "spec.containers[container-0].resources.limits: limits.cpu 1500m must be a whole number of CPUs",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a pinned Pod with requests below the limits",
+			kind:            podKind,
+			object:          newPod("default", true, resources("1", "512Mi"), resources("2", "1Gi")),
+			expectedMessage: summary + "spec.containers[container-0].resources.requests: requests.cpu 1 must equal limits.cpu 2; spec.containers[container-0].resources.requests: requests.memory 512Mi must equal limits.memory 1Gi",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a pinned Pod without limits",
+			kind:            podKind,
+			object:          newPod("default", true, resources("2", "1Gi"), nil),
+			expectedMessage: summary + "spec.containers[container-0].resources.limits: limits.cpu must be set; spec.containers[container-0].resources.limits: limits.memory must be set",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow a pinned Pod with fractional CPUs in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"sandbox"},
+			object:            newPod("sandbox", true, resources("500m", "1Gi"), resources("500m", "1Gi")),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceGuaranteedQoSForPinnedWorkloads(tt.ignoredNamespaces, "example.com/cpu-pinning")
+	})
+}
+
+func TestEnforceNetworkCapabilities(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, allowed string, capabilities ...corev1.Capability) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0")
+		if allowed != "" {
+			pod.Annotations = map[string]string{AllowedNetworkCapabilitiesAnnotation: allowed}
+		}
+		pod.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{
+			Capabilities: &corev1.Capabilities{Add: capabilities},
+		}
+
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow other capabilities",
+			kind:            podKind,
+			object:          newPod("default", "", "NET_BIND_SERVICE"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject NET_RAW",
+			kind:            podKind,
+			object:          newPod("default", "", "NET_RAW"),
+			expectedMessage: "Pod default/web adds raw network capabilities: spec.containers[container-0].securityContext.capabilities.add: container container-0 adds the NET_RAW capability: add it to the config.corp/allowed-network-capabilities annotation to allow it",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject CAP_NET_ADMIN",
+			kind:            podKind,
+			object:          newPod("default", "NET_RAW", "CAP_NET_ADMIN", "NET_RAW"),
+			expectedMessage: "Pod default/web adds raw network capabilities: spec.containers[container-0].securityContext.capabilities.add: container container-0 adds the NET_ADMIN capability: add it to the config.corp/allowed-network-capabilities annotation to allow it",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject ALL",
+			kind:            podKind,
+			object:          newPod("default", "NET_ADMIN", "ALL"),
+			expectedMessage: "Pod default/web adds raw network capabilities: spec.containers[container-0].securityContext.capabilities.add: container container-0 adds the NET_RAW capability: add it to the config.corp/allowed-network-capabilities annotation to allow it",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow annotated capabilities",
+			kind:            podKind,
+			object:          newPod("default", "net_admin, NET_RAW", "NET_ADMIN", "NET_RAW"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:          "Allow NET_RAW in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"kube-system"},
+			object:            newPod("kube-system", "", "NET_RAW"),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceNetworkCapabilities(tt.ignoredNamespaces)
+	})
+}
+
+func TestEnforceAllowedSecretTypes(t *testing.T) {
+	t.Parallel()
+
+	newSecret := func(namespace string, secretType corev1.SecretType) *corev1.Secret {
+		return &corev1.Secret{
+			TypeMeta:   meta.TypeMeta{Kind: "Secret", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "credentials", Namespace: namespace},
+			Type:       secretType,
+		}
+	}
+	secretKind := meta.GroupVersionKind{Group: "", Kind: "Secret", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow an allowed type",
+			kind:            secretKind,
+			operation:       admission.Create,
+			object:          newSecret("default", corev1.SecretTypeTLS),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow an omitted (Opaque) type",
+			kind:            secretKind,
+			operation:       admission.Create,
+			object:          newSecret("default", ""),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a service account token",
+			kind:            secretKind,
+			operation:       admission.Create,
+			object:          newSecret("default", corev1.SecretTypeServiceAccountToken),
+			expectedMessage: `Secret default/credentials has the type "kubernetes.io/service-account-token", which is not allowed: the allowed types are [Opaque, kubernetes.io/tls]`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a custom type",
+			kind:            secretKind,
+			operation:       admission.Create,
+			object:          newSecret("default", "example.com/credentials"),
+			expectedMessage: `Secret default/credentials has the type "example.com/credentials", which is not allowed: the allowed types are [Opaque, kubernetes.io/tls]`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow updates",
+			kind:            secretKind,
+			operation:       admission.Update,
+			object:          newSecret("default", "example.com/credentials"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:          "Allow any type in a whitelisted namespace",
+			kind:              secretKind,
+			operation:         admission.Create,
+			ignoredNamespaces: []string{"kube-system"},
+			object:            newSecret("kube-system", corev1.SecretTypeServiceAccountToken),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceAllowedSecretTypes(tt.ignoredNamespaces, []corev1.SecretType{corev1.SecretTypeOpaque, corev1.SecretTypeTLS})
+	})
+}
+
+func TestEnforceDNSPolicy(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, policy corev1.DNSPolicy, nameservers ...string) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0")
+		pod.Spec.DNSPolicy = policy
+		if len(nameservers) > 0 {
+			pod.Spec.DNSConfig = &corev1.PodDNSConfig{Nameservers: nameservers}
+		}
+
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+	allowed := []corev1.DNSPolicy{corev1.DNSClusterFirst, corev1.DNSNone}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow an omitted (ClusterFirst) policy",
+			kind:            podKind,
+			object:          newPod("default", ""),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow an in-cluster nameserver",
+			kind:            podKind,
+			object:          newPod("default", corev1.DNSNone, "10.96.0.10"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a disallowed policy",
+			kind:            podKind,
+			object:          newPod("default", corev1.DNSDefault),
+			expectedMessage: `Pod default/web has a disallowed DNS configuration: spec.dnsPolicy: the DNS policy "Default" is not allowed: the allowed policies are [ClusterFirst, None]`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject an off-cluster nameserver",
+			kind:            podKind,
+			object:          newPod("default", corev1.DNSNone, "10.96.0.10", "8.8.8.8"),
+			expectedMessage: "Pod default/web has a disallowed DNS configuration: spec.dnsConfig.nameservers[1]: the nameserver 8.8.8.8 is outside of the allowed ranges [10.96.0.0/12]",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow any policy in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"kube-system"},
+			object:            newPod("kube-system", corev1.DNSDefault),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		admitFunc, err := EnforceDNSPolicy(tt.ignoredNamespaces, allowed, []string{"10.96.0.0/12"})
+		if err != nil {
+			t.Fatalf("failed to create the AdmitFunc: %v", err)
+		}
+
+		return admitFunc
+	})
+
+	t.Run("Allow any nameserver without CIDRs", func(t *testing.T) {
+		runObjectTests(t, []objectTest{
+			{
+				testName:        "Allow an off-cluster nameserver",
+				kind:            podKind,
+				object:          newPod("default", corev1.DNSNone, "8.8.8.8"),
+				expectedMessage: "",
+				shouldAllow:     true,
+			},
+		}, func(tt objectTest) AdmitFunc {
+			admitFunc, err := EnforceDNSPolicy(tt.ignoredNamespaces, allowed, nil)
+			if err != nil {
+				t.Fatalf("failed to create the AdmitFunc: %v", err)
+			}
+
+			return admitFunc
+		})
+	})
+
+	if _, err := EnforceDNSPolicy(nil, allowed, []string{"10.96.0.10"}); err == nil {
+		t.Fatalf("expected an error for an invalid CIDR")
+	}
+}
+
+func TestRequirePreStopHook(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, annotated bool, hooks ...bool) *corev1.Pod {
+		images := make([]string, len(hooks))
+		for i := range hooks {
+			images[i] = "web:v1.0.0"
+		}
+
+		pod := newTestPodWithImages(namespace, images...)
+		if annotated {
+			pod.Annotations = map[string]string{"example.com/graceful-shutdown": ""}
+		}
+
+		for i, hook := range hooks {
+			if hook {
+				pod.Spec.Containers[i].Lifecycle = &corev1.Lifecycle{
+					PreStop: &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{"sleep", "10"}}},
+				}
+			}
+		}
+
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow annotated containers with preStop hooks",
+			kind:            podKind,
+			object:          newPod("default", true, true, true),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow containers without preStop hooks without the annotation",
+			kind:            podKind,
+			object:          newPod("default", false, false),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject an annotated container without a preStop hook",
+			kind:            podKind,
+			object:          newPod("default", true, true, false),
+			expectedMessage: "Pod default/web requires a graceful shutdown (example.com/graceful-shutdown): spec.containers[container-1].lifecycle.preStop: container container-1 must define a preStop hook",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow an annotated container without a preStop hook in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"batch"},
+			object:            newPod("batch", true, false),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return RequirePreStopHook(tt.ignoredNamespaces, "example.com/graceful-shutdown")
+	})
+}
+
+func TestEnforceRolloutStrategy(t *testing.T) {
+	t.Parallel()
+
+	newDeployment := func(namespace string, replicas int32, strategy appsv1.DeploymentStrategy) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			TypeMeta:   meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas, Strategy: strategy},
+		}
+	}
+	rollingUpdate := func(maxSurge, maxUnavailable intstr.IntOrString) appsv1.DeploymentStrategy {
+		return appsv1.DeploymentStrategy{
+			Type:          appsv1.RollingUpdateDeploymentStrategyType,
+			RollingUpdate: &appsv1.RollingUpdateDeployment{MaxSurge: &maxSurge, MaxUnavailable: &maxUnavailable},
+		}
+	}
+	maxSurge, maxUnavailable := intstr.FromString("50%"), intstr.FromString("25%")
+	limits := RolloutStrategyLimits{MaxSurge: &maxSurge, MaxUnavailable: &maxUnavailable}
+	deploymentKind := meta.GroupVersionKind{Group: "apps", Kind: "Deployment", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow the default strategy",
+			kind:            deploymentKind,
+			object:          newDeployment("default", 4, appsv1.DeploymentStrategy{}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow an absolute maxUnavailable within the limit",
+			kind:            deploymentKind,
+			object:          newDeployment("default", 8, rollingUpdate(intstr.FromInt(1), intstr.FromInt(2))),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a maxUnavailable of 100%",
+			kind:            deploymentKind,
+			object:          newDeployment("default", 4, rollingUpdate(intstr.FromString("25%"), intstr.FromString("100%"))),
+			expectedMessage: "Deployment default/web has an unsafe rollout strategy: spec.strategy.rollingUpdate.maxUnavailable: 100% (4 of 4 replicas) exceeds the maximum of 25% (1 replicas)",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject an absolute maxSurge above the limit",
+			kind:            deploymentKind,
+			object:          newDeployment("default", 4, rollingUpdate(intstr.FromInt(3), intstr.FromInt(0))),
+			expectedMessage: "Deployment default/web has an unsafe rollout strategy: spec.strategy.rollingUpdate.maxSurge: 3 (3 of 4 replicas) exceeds the maximum of 50% (2 replicas)",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject the Recreate strategy",
+			kind:            deploymentKind,
+			object:          newDeployment("default", 4, appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}),
+			expectedMessage: "Deployment default/web has an unsafe rollout strategy: spec.strategy.type: the Recreate strategy makes every replica unavailable: the maximum is 25%",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow an unsafe strategy in a whitelisted namespace",
+			kind:              deploymentKind,
+			ignoredNamespaces: []string{"sandbox"},
+			object:            newDeployment("sandbox", 4, rollingUpdate(intstr.FromString("25%"), intstr.FromString("100%"))),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		admitFunc, err := EnforceRolloutStrategy(tt.ignoredNamespaces, limits)
+		if err != nil {
+			t.Fatalf("failed to create the AdmitFunc: %v", err)
+		}
+
+		return admitFunc
+	})
+
+	invalid := intstr.FromString("a quarter")
+	if _, err := EnforceRolloutStrategy(nil, RolloutStrategyLimits{MaxUnavailable: &invalid}); err == nil {
+		t.Fatalf("expected an error for an invalid limit")
+	}
+}
+
+func TestRequireResourceQuota(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(&corev1.ResourceQuota{
+		ObjectMeta: meta.ObjectMeta{Name: "compute", Namespace: "team-a"},
+	})
+	factory := informers.NewSharedInformerFactory(client, 0)
+	admitFunc := RequireResourceQuota(factory, []string{"kube-system"}, "https://example.com/quota-template")
+
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory.Start(stopCh)
+
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a Pod in a namespace with a ResourceQuota",
+			kind:            podKind,
+			operation:       admission.Create,
+			namespace:       "team-a",
+			object:          newTestPodWithImages("team-a", "web:v1.0.0"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a Pod in a namespace without a ResourceQuota",
+			kind:            podKind,
+			operation:       admission.Create,
+			namespace:       "team-b",
+			object:          newTestPodWithImages("team-b", "web:v1.0.0"),
+			expectedMessage: "namespace team-b has no ResourceQuota: every namespace must be bounded by a ResourceQuota before Pod objects can be created in it - see https://example.com/quota-template",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow updates in a namespace without a ResourceQuota",
+			kind:            podKind,
+			operation:       admission.Update,
+			namespace:       "team-b",
+			object:          newTestPodWithImages("team-b", "web:v1.0.0"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow a Pod in a whitelisted namespace",
+			kind:            podKind,
+			operation:       admission.Create,
+			namespace:       "kube-system",
+			object:          newTestPodWithImages("kube-system", "web:v1.0.0"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+}
+
+func TestDenyReservedLabels(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, labels map[string]string, owned bool) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0")
+		pod.Labels = labels
+		if owned {
+			controller := true
+			pod.OwnerReferences = []meta.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-5d8f9c", UID: "1234", Controller: &controller}}
+		}
+
+		return pod
+	}
+	deployment := &appsv1.Deployment{
+		TypeMeta:   meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+		ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{ObjectMeta: meta.ObjectMeta{Labels: map[string]string{"app": "web", "pod-template-hash": "5d8f9c"}}},
+		},
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a Pod without reserved labels",
+			kind:            podKind,
+			object:          newPod("default", map[string]string{"app": "web"}, false),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a Pod with a reserved label",
+			kind:            podKind,
+			object:          newPod("default", map[string]string{"app": "web", "controller-revision-hash": "web-7b9d"}, false),
+			expectedMessage: "Pod default/web sets reserved labels: metadata.labels[controller-revision-hash]: the controller-revision-hash label is reserved for the workload controllers, and must not be set",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a Deployment with a reserved label in its template",
+			kind:            meta.GroupVersionKind{Group: "apps", Kind: "Deployment", Version: "v1"},
+			object:          deployment,
+			expectedMessage: "Deployment default/web sets reserved labels: spec.template.metadata.labels[pod-template-hash]: the pod-template-hash label is reserved for the workload controllers, and must not be set",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow a Pod managed by a controller",
+			kind:            podKind,
+			object:          newPod("default", map[string]string{"app": "web", "pod-template-hash": "5d8f9c"}, true),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:          "Allow a reserved label in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"sandbox"},
+			object:            newPod("sandbox", map[string]string{"pod-template-hash": "5d8f9c"}, false),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return DenyReservedLabels(tt.ignoredNamespaces, nil)
+	})
+}
+
+func TestEnforceAppArmorProfile(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, annotations map[string]string) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0", "sidecar:v1.0.0")
+		pod.Annotations = annotations
+
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+	summary := "Pod default/web does not use an allowed AppArmor profile: "
+
+	// The appArmorProfile fields post-date the vendored types, and so are
+	// given as raw objects.
+	withFields := []byte(`{
+		"kind": "Pod",
+		"apiVersion": "v1",
+		"metadata": {"name": "web", "namespace": "default"},
+		"spec": {
+			"securityContext": {"appArmorProfile": {"type": "RuntimeDefault"}},
+			"containers": [
+				{"name": "container-0", "image": "web:v1.0.0"},
+				{"name": "container-1", "image": "sidecar:v1.0.0", "securityContext": {"appArmorProfile": {"type": "Localhost", "localhostProfile": "k8s-untrusted"}}}
+			]
+		}
+	}`)
+	deploymentWithFields := []byte(`{
+		"kind": "Deployment",
+		"apiVersion": "apps/v1",
+		"metadata": {"name": "web", "namespace": "default"},
+		"spec": {"template": {"spec": {
+			"containers": [
+				{"name": "container-0", "image": "web:v1.0.0", "securityContext": {"appArmorProfile": {"type": "Localhost", "localhostProfile": "k8s-web"}}}
+			]
+		}}}
+	}`)
+
+	var denyTests = []objectTest{
+		{
+			testName: "Allow allowed profiles set by annotation",
+			kind:     podKind,
+			object: newPod("default", map[string]string{
+				"container.apparmor.security.beta.kubernetes.io/container-0": "runtime/default",
+				"container.apparmor.security.beta.kubernetes.io/container-1": "localhost/k8s-web",
+			}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName: "Reject an unconfined container",
+			kind:     podKind,
+			object: newPod("default", map[string]string{
+				"container.apparmor.security.beta.kubernetes.io/container-0": "runtime/default",
+				"container.apparmor.security.beta.kubernetes.io/container-1": "unconfined",
+			}),
+			expectedMessage: summary + "spec.containers[container-1].securityContext.appArmorProfile: container container-1 must not run unconfined",
+			shouldAllow:     false,
+		},
+		{
+			testName: "Reject a container without a profile",
+			kind:     podKind,
+			object: newPod("default", map[string]string{
+				"container.apparmor.security.beta.kubernetes.io/container-0": "runtime/default",
+			}),
+			expectedMessage: summary + "spec.containers[container-1].securityContext.appArmorProfile: container container-1 must set an AppArmor profile: the allowed profiles are [runtime/default, localhost/k8s-web]",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a profile that is not allowed, set by field",
+			kind:            podKind,
+			rawObject:       withFields,
+			expectedMessage: summary + `spec.containers[container-1].securityContext.appArmorProfile: container container-1 requests the AppArmor profile "localhost/k8s-untrusted": the allowed profiles are [runtime/default, localhost/k8s-web]`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow a Deployment with an allowed profile set by field",
+			kind:            meta.GroupVersionKind{Group: "apps", Kind: "Deployment", Version: "v1"},
+			rawObject:       deploymentWithFields,
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:          "Allow containers without a profile in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"kube-system"},
+			object:            newPod("kube-system", nil),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceAppArmorProfile(tt.ignoredNamespaces, []string{"runtime/default", "localhost/k8s-web"})
+	})
+}
+
+func BenchmarkPodAdmitFuncs(b *testing.B) {
+	deployment := &appsv1.Deployment{
+		TypeMeta:   meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+		ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{Spec: newTestPodWithImages("default", "registry.example.com/web:v1.0.0", "registry.example.com/sidecar:v1.0.0").Spec},
+		},
+	}
+	raw, err := json.Marshal(deployment)
+	if err != nil {
+		b.Fatalf("could not marshal k8s API object: %v", err)
+	}
+
+	review := &admission.AdmissionReview{Request: &admission.AdmissionRequest{
+		Kind:      meta.GroupVersionKind{Group: "apps", Kind: "Deployment", Version: "v1"},
+		Namespace: "default",
+		Operation: admission.Create,
+	}}
+	review.Request.Object.Raw = raw
+
+	imageTags, err := EnforceImageTagPattern(nil, `v\d+\.\d+\.\d+`)
+	if err != nil {
+		b.Fatalf("failed to create the AdmitFunc: %v", err)
+	}
+
+	var benchmarks = []struct {
+		name      string
+		admitFunc AdmitFunc
+	}{
+		{name: "EnforceImageTagPattern", admitFunc: imageTags},
+		{name: "ValidateResourceQuantities", admitFunc: ValidateResourceQuantities(nil)},
+		{name: "DenyDuplicateContainerPorts", admitFunc: DenyDuplicateContainerPorts(nil)},
+		{name: "EnforceMaxReplicas", admitFunc: EnforceMaxReplicas(nil, 10)},
+	}
+
+	for _, bm := range benchmarks {
+		bm := bm
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := bm.admitFunc(review); err != nil {
+					b.Fatalf("incorrectly rejected admission: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// TestPodAdmitFuncAllocations guards against regressions in the per-request
+// allocations of the pod-walking AdmitFuncs (see BenchmarkPodAdmitFuncs): e.g.
+// constructing a deserializer per request. The limit leaves headroom above the
+// baseline of ~40 allocations.
+func TestPodAdmitFuncAllocations(t *testing.T) {
+	raw, err := json.Marshal(newTestPodWithImages("default", "registry.example.com/web:v1.0.0", "registry.example.com/sidecar:v1.0.0"))
+	if err != nil {
+		t.Fatalf("could not marshal k8s API object: %v", err)
+	}
+
+	review := newTestAdmissionRequest(meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}, raw, true)
+	admitFunc := DenyDuplicateContainerPorts(nil)
+
+	const maxAllocs = 60
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := admitFunc(review); err != nil {
+			t.Fatalf("incorrectly rejected admission: %v", err)
+		}
+	})
+
+	if allocs > maxAllocs {
+		t.Fatalf("DenyDuplicateContainerPorts made %.0f allocations per request: expected at most %d", allocs, maxAllocs)
+	}
+}
+
+func TestRequireEnvVars(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, annotated bool, envs ...[]string) *corev1.Pod {
+		images := make([]string, len(envs))
+		for i := range envs {
+			images[i] = "web:v1.0.0"
+		}
+
+		pod := newTestPodWithImages(namespace, images...)
+		if annotated {
+			pod.Annotations = map[string]string{"example.com/datadog": ""}
+		}
+
+		for i, names := range envs {
+			for _, name := range names {
+				pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, corev1.EnvVar{Name: name, Value: "value"})
+			}
+		}
+
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow annotated containers with the required environment variables",
+			kind:            podKind,
+			object:          newPod("default", true, []string{"DD_API_KEY", "DD_ENV", "LOG_LEVEL"}, []string{"DD_ENV", "DD_API_KEY"}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow containers without the required environment variables without the annotation",
+			kind:            podKind,
+			object:          newPod("default", false, nil),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject annotated containers without the required environment variables",
+			kind:            podKind,
+			object:          newPod("default", true, []string{"DD_API_KEY", "DD_ENV"}, []string{"DD_ENV"}, nil),
+			expectedMessage: "Pod default/web is missing required environment variables (example.com/datadog): spec.containers[container-1].env: container container-1 is missing the environment variables DD_API_KEY; spec.containers[container-2].env: container container-2 is missing the environment variables DD_API_KEY, DD_ENV",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow annotated containers without the required environment variables in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"batch"},
+			object:            newPod("batch", true, nil),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return RequireEnvVars(tt.ignoredNamespaces, []string{"DD_API_KEY", "DD_ENV"}, "example.com/datadog")
+	})
+}
+
+func TestValidateServiceMonitor(t *testing.T) {
+	t.Parallel()
+
+	newServiceMonitor := func(namespace string, endpoints string) []byte {
+		return []byte(fmt.Sprintf(`{"kind":"ServiceMonitor","apiVersion":"monitoring.coreos.com/v1","metadata":{"name":"web","namespace":%q},"spec":{"selector":{"matchLabels":{"app":"web"}},"endpoints":[%s]}}`, namespace, endpoints))
+	}
+	serviceMonitorKind := meta.GroupVersionKind{Group: "monitoring.coreos.com", Kind: "ServiceMonitor", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow HTTPS endpoints",
+			kind:            serviceMonitorKind,
+			rawObject:       newServiceMonitor("default", `{"port":"metrics","scheme":"https"},{"targetPort":8443,"scheme":"https","relabelings":[{"targetLabel":"__address__","replacement":"web.default.svc:8443"}]}`),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject endpoints with a disallowed scheme",
+			kind:            serviceMonitorKind,
+			rawObject:       newServiceMonitor("default", `{"port":"metrics"},{"port":"admin","scheme":"https"}`),
+			expectedMessage: "ServiceMonitor default/web has disallowed endpoints: spec.endpoints[0].scheme: endpoint metrics uses the scheme http: must be one of https",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject endpoints scraped via a denied proxy",
+			kind:            serviceMonitorKind,
+			rawObject:       newServiceMonitor("default", `{"port":"metrics","scheme":"https","proxyUrl":"http://proxy.example.com:3128"}`),
+			expectedMessage: "ServiceMonitor default/web has disallowed endpoints: spec.endpoints[0].proxyUrl: endpoint metrics is scraped via the denied target proxy.example.com",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject endpoints that rewrite their address to a denied target",
+			kind:            serviceMonitorKind,
+			rawObject:       newServiceMonitor("default", `{"targetPort":9090,"scheme":"https","relabelings":[{"sourceLabels":["__meta_kubernetes_pod_ip"],"targetLabel":"__address__","replacement":"203.0.113.10:443"}]}`),
+			expectedMessage: "ServiceMonitor default/web has disallowed endpoints: spec.endpoints[0].relabelings[0]: endpoint 9090 rewrites its target to the denied target 203.0.113.10:443",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow endpoints with a disallowed scheme in a whitelisted namespace",
+			kind:              serviceMonitorKind,
+			ignoredNamespaces: []string{"monitoring"},
+			rawObject:         newServiceMonitor("monitoring", `{"port":"metrics"}`),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+		{
+			testName:        "Allow other kinds",
+			kind:            meta.GroupVersionKind{Group: "monitoring.coreos.com", Kind: "PrometheusRule", Version: "v1"},
+			rawObject:       []byte(`{"kind":"PrometheusRule","apiVersion":"monitoring.coreos.com/v1","metadata":{"name":"web","namespace":"default"}}`),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	admitFunc, err := ValidateServiceMonitor([]string{"monitoring"}, []string{"https"}, []string{"*.example.com", "203.0.113.0/24"})
+	if err != nil {
+		t.Fatalf("failed to create the AdmitFunc: %v", err)
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+
+	if _, err := ValidateServiceMonitor(nil, nil, []string{"10.0.0.0/33"}); err == nil {
+		t.Fatalf("expected an error for an invalid denied CIDR")
+	}
+
+	if _, err := ValidateServiceMonitor(nil, nil, []string{"[example.com"}); err == nil {
+		t.Fatalf("expected an error for an invalid denied target pattern")
+	}
+}
+
+func TestEnforceIngressAnnotations(t *testing.T) {
+	t.Parallel()
+
+	newIngress := func(namespace string, annotations map[string]string) *networkingv1.Ingress {
+		return &networkingv1.Ingress{
+			TypeMeta:   meta.TypeMeta{Kind: "Ingress", APIVersion: "networking.k8s.io/v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace, Annotations: annotations},
+		}
+	}
+	ingressKind := meta.GroupVersionKind{Group: "networking.k8s.io", Kind: "Ingress", Version: "v1"}
+
+	allowed := map[string]func(string) bool{
+		"nginx.ingress.kubernetes.io/limit-rps": func(value string) bool {
+			var rps int
+			_, err := fmt.Sscanf(value, "%d", &rps)
+			return err == nil && rps > 0 && rps <= 100
+		},
+		"nginx.ingress.kubernetes.io/ssl-redirect": nil,
+	}
+	denied := []string{"nginx.ingress.kubernetes.io/configuration-snippet"}
+
+	var denyTests = []objectTest{
+		{
+			testName: "Allow an Ingress with allowlisted annotations",
+			kind:     ingressKind,
+			object: newIngress("default", map[string]string{
+				"nginx.ingress.kubernetes.io/limit-rps":    "50",
+				"nginx.ingress.kubernetes.io/ssl-redirect": "true",
+				"example.com/team":                         "web",
+			}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName: "Reject an Ingress with denied, invalid or unlisted annotations",
+			kind:     ingressKind,
+			object: newIngress("default", map[string]string{
+				"nginx.ingress.kubernetes.io/configuration-snippet": "more_set_headers \"X-Debug: 1\";",
+				"nginx.ingress.kubernetes.io/limit-rps":             "5000",
+				"nginx.ingress.kubernetes.io/rewrite-target":        "/",
+			}),
+			expectedMessage: `Ingress default/web has denied or invalid ingress controller annotations: metadata.annotations[nginx.ingress.kubernetes.io/configuration-snippet]: the annotation is denied; metadata.annotations[nginx.ingress.kubernetes.io/limit-rps]: the value "5000" is invalid; metadata.annotations[nginx.ingress.kubernetes.io/rewrite-target]: the annotation is not in the allowlist for its ingress controller`,
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow an Ingress with denied annotations in a whitelisted namespace",
+			kind:              ingressKind,
+			ignoredNamespaces: []string{"ingress-nginx"},
+			object:            newIngress("ingress-nginx", map[string]string{"nginx.ingress.kubernetes.io/configuration-snippet": ""}),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+		{
+			testName:        "Don't reject Services",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "Service", Version: "v1"},
+			rawObject:       []byte(`{"kind":"Service","apiVersion":"v1","metadata":{"name":"web","namespace":"default","annotations":{"nginx.ingress.kubernetes.io/configuration-snippet":""}}}`),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceIngressAnnotations(tt.ignoredNamespaces, allowed, denied)
+	})
+}
+
+func TestDenyNginxSnippetAnnotations(t *testing.T) {
+	t.Parallel()
+
+	newIngress := func(namespace string, annotations map[string]string) *networkingv1.Ingress {
+		return &networkingv1.Ingress{
+			TypeMeta:   meta.TypeMeta{Kind: "Ingress", APIVersion: "networking.k8s.io/v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace, Annotations: annotations},
+		}
+	}
+	ingressKind := meta.GroupVersionKind{Group: "networking.k8s.io", Kind: "Ingress", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow an Ingress without snippets",
+			kind:            ingressKind,
+			object:          newIngress("default", map[string]string{"nginx.ingress.kubernetes.io/ssl-redirect": "true"}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName: "Reject an Ingress with snippets",
+			kind:     ingressKind,
+			object: newIngress("default", map[string]string{
+				"nginx.ingress.kubernetes.io/server-snippet": "location /secrets { alias /var/run/secrets/; }",
+				"nginx.ingress.kubernetes.io/stream-snippet": "",
+			}),
+			expectedMessage: "Ingress default/web uses ingress-nginx snippets, which inject raw NGINX configuration and can expose the controller's credentials (CVE-2021-25742): metadata.annotations[nginx.ingress.kubernetes.io/server-snippet]: snippet annotations are not allowed; metadata.annotations[nginx.ingress.kubernetes.io/stream-snippet]: snippet annotations are not allowed",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow an Ingress with snippets in a break-glass namespace",
+			kind:              ingressKind,
+			ignoredNamespaces: []string{"break-glass"},
+			object:            newIngress("break-glass", map[string]string{"nginx.ingress.kubernetes.io/configuration-snippet": ""}),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return DenyNginxSnippetAnnotations(tt.ignoredNamespaces)
+	})
+}
+
+func TestEnforceFSGroupRange(t *testing.T) {
+	t.Parallel()
+
+	id := func(value int64) *int64 { return &value }
+	newPod := func(namespace string, podContext *corev1.PodSecurityContext, containerContext *corev1.SecurityContext) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0")
+		pod.Spec.SecurityContext = podContext
+		pod.Spec.Containers[0].SecurityContext = containerContext
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName: "Allow IDs within the allowed ranges",
+			kind:     podKind,
+			object: newPod("default",
+				&corev1.PodSecurityContext{FSGroup: id(2000), RunAsUser: id(1000), RunAsGroup: id(1000), SupplementalGroups: []int64{5000}},
+				&corev1.SecurityContext{RunAsUser: id(5500)},
+			),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow Pods that do not set IDs",
+			kind:            podKind,
+			object:          newPod("default", nil, nil),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName: "Reject IDs outside of the allowed ranges",
+			kind:     podKind,
+			object: newPod("default",
+				&corev1.PodSecurityContext{FSGroup: id(0), RunAsUser: id(1000), SupplementalGroups: []int64{1000, 4000}},
+				&corev1.SecurityContext{RunAsGroup: id(0)},
+			),
+			expectedMessage: "Pod default/web runs with user or group IDs outside of the allowed ranges: spec.securityContext.fsGroup: 0 is outside of the allowed ranges [1000-2999, 5000-5999]; spec.securityContext.supplementalGroups[1]: 4000 is outside of the allowed ranges [1000-2999, 5000-5999]; spec.containers[container-0].securityContext.runAsGroup: 0 is outside of the allowed ranges [1000-2999, 5000-5999]",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow IDs outside of the allowed ranges in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"kube-system"},
+			object:            newPod("kube-system", &corev1.PodSecurityContext{RunAsUser: id(0)}, nil),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		admitFunc, err := EnforceFSGroupRange(tt.ignoredNamespaces, IDRange{Min: 1000, Max: 2999}, IDRange{Min: 5000, Max: 5999})
+		if err != nil {
+			t.Fatalf("failed to create the AdmitFunc: %v", err)
+		}
+
+		return admitFunc
+	})
+
+	if _, err := EnforceFSGroupRange(nil); err == nil {
+		t.Fatalf("expected an error when no ranges are provided")
+	}
+
+	if _, err := EnforceFSGroupRange(nil, IDRange{Min: 2000, Max: 1000}); err == nil {
+		t.Fatalf("expected an error for an invalid range")
+	}
+}
+
+func TestEnforceEphemeralStorageLimits(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, limits ...string) *corev1.Pod {
+		images := make([]string, len(limits))
+		for i := range limits {
+			images[i] = "web:v1.0.0"
+		}
+
+		pod := newTestPodWithImages(namespace, images...)
+		for i, limit := range limits {
+			if limit != "" {
+				pod.Spec.Containers[i].Resources.Limits = corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse(limit)}
+			}
+		}
+
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	requestsTooMuch := newPod("default", "1Gi")
+	requestsTooMuch.Spec.Containers[0].Resources.Requests = corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse("4Gi")}
+	initContainer := newPod("default", "1Gi")
+	initContainer.Spec.InitContainers = []corev1.Container{{Name: "migrate", Image: "web:v1.0.0"}}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow containers with ephemeral-storage limits below the maximum",
+			kind:            podKind,
+			object:          newPod("default", "1Gi", "2Gi"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject containers without an ephemeral-storage limit, or above the maximum",
+			kind:            podKind,
+			object:          newPod("default", "1Gi", "", "10Gi"),
+			expectedMessage: "Pod default/web has disallowed ephemeral-storage resources: spec.containers[container-1].resources.limits.ephemeral-storage: container container-1 must set an ephemeral-storage limit; spec.containers[container-2].resources.limits.ephemeral-storage: container container-2 has an ephemeral-storage limit of 10Gi: the maximum is 2Gi",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject containers that request more than the maximum",
+			kind:            podKind,
+			object:          requestsTooMuch,
+			expectedMessage: "Pod default/web has disallowed ephemeral-storage resources: spec.containers[container-0].resources.requests.ephemeral-storage: container container-0 requests 4Gi of ephemeral-storage: the maximum is 2Gi",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject init containers without an ephemeral-storage limit",
+			kind:            podKind,
+			object:          initContainer,
+			expectedMessage: "Pod default/web has disallowed ephemeral-storage resources: spec.initContainers[migrate].resources.limits.ephemeral-storage: container migrate must set an ephemeral-storage limit",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow containers without an ephemeral-storage limit in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"batch"},
+			object:            newPod("batch", ""),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceEphemeralStorageLimits(tt.ignoredNamespaces, resource.MustParse("2Gi"), true)
+	})
+
+	runObjectTests(t, []objectTest{
+		{
+			testName:        "Allow containers without an ephemeral-storage limit unless required",
+			kind:            podKind,
+			object:          newPod("default", ""),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}, func(tt objectTest) AdmitFunc {
+		return EnforceEphemeralStorageLimits(tt.ignoredNamespaces, resource.MustParse("2Gi"), false)
+	})
+}
+
+func TestEnforceMaxResourceLimits(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, memory ...string) *corev1.Pod {
+		images := make([]string, len(memory))
+		for i := range memory {
+			images[i] = "web:v1.0.0"
+		}
+
+		pod := newTestPodWithImages(namespace, images...)
+		for i, quantity := range memory {
+			pod.Spec.Containers[i].Resources = corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse(quantity)},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(quantity)},
+			}
+		}
+
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	largeInitContainer := newPod("default", "1Gi")
+	largeInitContainer.Spec.InitContainers = []corev1.Container{{
+		Name:      "restore",
+		Image:     "web:v1.0.0",
+		Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("8")}},
+	}}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow containers within the maximums",
+			kind:            podKind,
+			object:          newPod("default", "8Gi", "16Gi"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject containers above the maximums",
+			kind:            podKind,
+			object:          newPod("default", "8Gi", "500Gi"),
+			expectedMessage: "Pod default/web requests resources above the allowed maximums: spec.containers[container-1].resources.requests.memory: container container-1 requests 500Gi of memory: the maximum is 32Gi; spec.containers[container-1].resources.limits.memory: container container-1 has a memory limit of 500Gi: the maximum is 32Gi",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject init containers above the maximums",
+			kind:            podKind,
+			object:          largeInitContainer,
+			expectedMessage: "Pod default/web requests resources above the allowed maximums: spec.initContainers[restore].resources.requests.cpu: container restore requests 8 of cpu: the maximum is 4",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow containers above the maximums in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"batch"},
+			object:            newPod("batch", "500Gi"),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceMaxResourceLimits(tt.ignoredNamespaces, resource.MustParse("4"), resource.MustParse("32Gi"), false)
+	})
+
+	runObjectTests(t, []objectTest{
+		{
+			testName:        "Allow Pods within the total maximums",
+			kind:            podKind,
+			object:          newPod("default", "16Gi", "16Gi"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject Pods above the total maximums",
+			kind:            podKind,
+			object:          newPod("default", "16Gi", "16Gi", "16Gi"),
+			expectedMessage: "Pod default/web requests resources above the allowed maximums: spec.containers[*].resources.requests.memory: the Pod in total requests 48Gi of memory: the maximum is 32Gi; spec.containers[*].resources.limits.memory: the Pod in total has a memory limit of 48Gi: the maximum is 32Gi",
+			shouldAllow:     false,
+		},
+	}, func(tt objectTest) AdmitFunc {
+		return EnforceMaxResourceLimits(tt.ignoredNamespaces, resource.MustParse("4"), resource.MustParse("32Gi"), true)
+	})
+}
+
+func TestEnforceIngressPathType(t *testing.T) {
+	t.Parallel()
+
+	pathType := func(pathType networkingv1.PathType) *networkingv1.PathType { return &pathType }
+	newIngress := func(namespace string, pathTypes ...*networkingv1.PathType) *networkingv1.Ingress {
+		paths := make([]networkingv1.HTTPIngressPath, 0, len(pathTypes))
+		for i, pathType := range pathTypes {
+			paths = append(paths, networkingv1.HTTPIngressPath{Path: fmt.Sprintf("/v%d", i), PathType: pathType})
+		}
+
+		return &networkingv1.Ingress{
+			TypeMeta:   meta.TypeMeta{Kind: "Ingress", APIVersion: "networking.k8s.io/v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace},
+			Spec: networkingv1.IngressSpec{Rules: []networkingv1.IngressRule{
+				{Host: "example.com", IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{Paths: paths}}},
+			}},
+		}
+	}
+	ingressKind := meta.GroupVersionKind{Group: "networking.k8s.io", Kind: "Ingress", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow paths with allowed pathTypes",
+			kind:            ingressKind,
+			object:          newIngress("default", pathType(networkingv1.PathTypePrefix), pathType(networkingv1.PathTypeExact)),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject paths with disallowed pathTypes",
+			kind:            ingressKind,
+			object:          newIngress("default", pathType(networkingv1.PathTypePrefix), pathType(networkingv1.PathTypeImplementationSpecific)),
+			expectedMessage: "Ingress default/web has paths with disallowed pathTypes: spec.rules[0].http.paths[1].pathType: the path example.com/v1 has the pathType ImplementationSpecific: must be one of Exact, Prefix",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject v1beta1 paths without a pathType",
+			kind:            meta.GroupVersionKind{Group: "networking.k8s.io", Kind: "Ingress", Version: "v1beta1"},
+			rawObject:       []byte(`{"kind":"Ingress","apiVersion":"networking.k8s.io/v1beta1","metadata":{"name":"web","namespace":"default"},"spec":{"rules":[{"http":{"paths":[{"path":"/","backend":{"serviceName":"web","servicePort":80}}]}}]}}`),
+			expectedMessage: "Ingress default/web has paths with disallowed pathTypes: spec.rules[0].http.paths[0].pathType: the path */ has the pathType ImplementationSpecific: must be one of Exact, Prefix",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow paths with disallowed pathTypes in a whitelisted namespace",
+			kind:              ingressKind,
+			ignoredNamespaces: []string{"legacy"},
+			object:            newIngress("legacy", pathType(networkingv1.PathTypeImplementationSpecific)),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceIngressPathType(tt.ignoredNamespaces, []networkingv1.PathType{networkingv1.PathTypeExact, networkingv1.PathTypePrefix})
+	})
+}
+
+func TestValidateImagePullSecretsExist(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset(
+		&corev1.Secret{ObjectMeta: meta.ObjectMeta{Name: "registry", Namespace: "default"}, Type: corev1.SecretTypeDockerConfigJson},
+		&corev1.Secret{ObjectMeta: meta.ObjectMeta{Name: "credentials", Namespace: "default"}, Type: corev1.SecretTypeOpaque},
+		&corev1.Secret{ObjectMeta: meta.ObjectMeta{Name: "registry", Namespace: "staging"}, Type: corev1.SecretTypeDockerConfigJson},
+	)
+	factory := informers.NewSharedInformerFactory(client, 0)
+	admitFunc := ValidateImagePullSecretsExist(factory, []string{"sandbox"})
+
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory.Start(stopCh)
+
+	newPod := func(namespace string, pullSecrets ...string) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "registry.example.com/web:v1.0.0")
+		for _, name := range pullSecrets {
+			pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+		}
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow pull Secrets that exist",
+			kind:            podKind,
+			object:          newPod("default", "registry"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow Pods without pull Secrets",
+			kind:            podKind,
+			object:          newPod("default"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject pull Secrets that do not exist in the namespace",
+			kind:            podKind,
+			object:          newPod("default", "registry", "mirror"),
+			expectedMessage: "Pod default/web references pull Secrets that do not exist: spec.imagePullSecrets[1]: the pull Secret mirror does not exist in namespace default",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject pull Secrets that exist in another namespace",
+			kind:            podKind,
+			object:          newPod("production", "registry"),
+			expectedMessage: "Pod production/web references pull Secrets that do not exist: spec.imagePullSecrets[0]: the pull Secret registry does not exist in namespace production",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow missing pull Secrets in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"sandbox"},
+			object:            newPod("sandbox", "mirror"),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+
+	t.Run("Warn about pull Secrets of the wrong type", func(t *testing.T) {
+		review := newTestAdmissionRequest(podKind, marshalTestObject(t, newPod("default", "credentials"), nil), true)
+		resp, err := admitFunc(review)
+		if err != nil || !resp.Allowed {
+			t.Fatalf("expected the request to be allowed: %v", err)
+		}
+
+		expected := "spec.imagePullSecrets[0]: the Secret credentials is of type Opaque, and cannot be used to pull images"
+		if len(resp.Warnings) != 1 || resp.Warnings[0] != expected {
+			t.Fatalf("unexpected warnings: got %q (wanted %q)", resp.Warnings, expected)
+		}
+	})
+}
+
+func TestLimitConcurrentRollouts(t *testing.T) {
+	t.Parallel()
+
+	newDeployment := func(name, image string, generation, observedGeneration int64, paused bool) *appsv1.Deployment {
+		replicas := int32(2)
+		return &appsv1.Deployment{
+			TypeMeta:   meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+			ObjectMeta: meta.ObjectMeta{Name: name, Namespace: "default", Generation: generation},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Paused:   paused,
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: image}}}},
+			},
+			Status: appsv1.DeploymentStatus{
+				ObservedGeneration: observedGeneration,
+				Replicas:           replicas,
+				UpdatedReplicas:    replicas,
+				AvailableReplicas:  replicas,
+			},
+		}
+	}
+
+	client := fake.NewSimpleClientset(
+		newDeployment("api", "api:v2.0.0", 2, 1, false),
+		newDeployment("worker", "worker:v2.0.0", 3, 2, false),
+		newDeployment("cron", "cron:v2.0.0", 2, 2, false),
+		newDeployment("batch", "batch:v2.0.0", 2, 1, true),
+	)
+	factory := informers.NewSharedInformerFactory(client, 0)
+	admitFunc, err := LimitConcurrentRollouts(factory, 2)
+	if err != nil {
+		t.Fatalf("failed to create the AdmitFunc: %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory.Start(stopCh)
+
+	deploymentKind := meta.GroupVersionKind{Group: "apps", Kind: "Deployment", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Reject a rollout when the namespace is at the limit",
+			kind:            deploymentKind,
+			operation:       admission.Update,
+			object:          newDeployment("web", "web:v2.0.0", 2, 1, false),
+			oldObject:       newDeployment("web", "web:v1.0.0", 1, 1, false),
+			expectedMessage: "namespace default already has 2 Deployments rolling out (api, worker): rolling out Deployment web would exceed the limit of 2 concurrent rollouts - retry once a rollout completes",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject resuming a paused Deployment when the namespace is at the limit",
+			kind:            deploymentKind,
+			operation:       admission.Update,
+			object:          newDeployment("web", "web:v2.0.0", 3, 2, false),
+			oldObject:       newDeployment("web", "web:v2.0.0", 2, 2, true),
+			expectedMessage: "namespace default already has 2 Deployments rolling out (api, worker): rolling out Deployment web would exceed the limit of 2 concurrent rollouts - retry once a rollout completes",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow updates that do not start a rollout",
+			kind:            deploymentKind,
+			operation:       admission.Update,
+			object:          newDeployment("web", "web:v1.0.0", 2, 1, false),
+			oldObject:       newDeployment("web", "web:v1.0.0", 1, 1, false),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow updates to a paused Deployment",
+			kind:            deploymentKind,
+			operation:       admission.Update,
+			object:          newDeployment("web", "web:v2.0.0", 2, 1, true),
+			oldObject:       newDeployment("web", "web:v1.0.0", 1, 1, true),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow a Deployment that is already rolling out to be updated",
+			kind:            deploymentKind,
+			operation:       admission.Update,
+			object:          newDeployment("api", "api:v3.0.0", 3, 1, false),
+			oldObject:       newDeployment("api", "api:v2.0.0", 2, 1, false),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+
+	if _, err := LimitConcurrentRollouts(factory, 0); err == nil {
+		t.Fatalf("expected an error for an invalid limit")
+	}
+}
+
+func TestDenyStatefulSetSelectorChange(t *testing.T) {
+	t.Parallel()
+
+	newStatefulSet := func(namespace string, selector map[string]string) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			TypeMeta:   meta.TypeMeta{Kind: "StatefulSet", APIVersion: "apps/v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "db", Namespace: namespace},
+			Spec:       appsv1.StatefulSetSpec{Selector: &meta.LabelSelector{MatchLabels: selector}},
+		}
+	}
+	newDeployment := func(selector map[string]string, replicas int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			TypeMeta:   meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas, Selector: &meta.LabelSelector{MatchLabels: selector}},
+		}
+	}
+	statefulSetKind := meta.GroupVersionKind{Group: "apps", Kind: "StatefulSet", Version: "v1"}
+	deploymentKind := meta.GroupVersionKind{Group: "apps", Kind: "Deployment", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Reject changing the selector of a StatefulSet",
+			kind:            statefulSetKind,
+			operation:       admission.Update,
+			oldObject:       newStatefulSet("default", map[string]string{"app": "db"}),
+			object:          newStatefulSet("default", map[string]string{"app": "db", "tier": "data"}),
+			expectedMessage: `StatefulSet default/db cannot change .spec.selector from "app=db" to "app=db,tier=data": selectors are immutable - delete and re-create the StatefulSet (e.g. with kubectl replace --force) if this change is intended`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow updates to a StatefulSet that keep its selector",
+			kind:            statefulSetKind,
+			operation:       admission.Update,
+			oldObject:       newStatefulSet("default", map[string]string{"app": "db"}),
+			object:          newStatefulSet("default", map[string]string{"app": "db"}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow changing the selector in a whitelisted namespace",
+			kind:            statefulSetKind,
+			operation:       admission.Update,
+			oldObject:       newStatefulSet("kube-system", map[string]string{"app": "db"}),
+			object:          newStatefulSet("kube-system", map[string]string{"app": "cache"}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject changing the selector of a Deployment",
+			kind:            deploymentKind,
+			operation:       admission.Update,
+			oldObject:       newDeployment(map[string]string{"app": "web"}, 2),
+			object:          newDeployment(map[string]string{"app": "frontend"}, 2),
+			expectedMessage: `Deployment default/web cannot change .spec.selector from "app=web" to "app=frontend": selectors are immutable - delete and re-create the Deployment (e.g. with kubectl replace --force) if this change is intended`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow updates to a Deployment that keep its selector",
+			kind:            deploymentKind,
+			operation:       admission.Update,
+			oldObject:       newDeployment(map[string]string{"app": "web"}, 2),
+			object:          newDeployment(map[string]string{"app": "web"}, 3),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return DenyStatefulSetSelectorChange([]string{"kube-system"})
+	})
+}
+
+func TestDenyPrivilegedServicePorts(t *testing.T) {
+	t.Parallel()
+
+	newService := func(namespace string, externalIPs []string, ports ...corev1.ServicePort) *corev1.Service {
+		return &corev1.Service{
+			TypeMeta:   meta.TypeMeta{Kind: "Service", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort, ExternalIPs: externalIPs, Ports: ports},
+		}
+	}
+	serviceKind := meta.GroupVersionKind{Group: "", Kind: "Service", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow node ports in the default range",
+			kind:            serviceKind,
+			object:          newService("default", nil, corev1.ServicePort{Port: 80, NodePort: 30080}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a privileged node port",
+			kind:            serviceKind,
+			object:          newService("default", nil, corev1.ServicePort{Port: 80, NodePort: 30080}, corev1.ServicePort{Port: 2222, NodePort: 22}),
+			expectedMessage: "Service default/web exposes privileged ports on the nodes: spec.ports[1].nodePort: 22 is a privileged port (below 1024) on the nodes",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a privileged port exposed on externalIPs",
+			kind:            serviceKind,
+			object:          newService("default", []string{"10.0.0.10"}, corev1.ServicePort{Port: 443, NodePort: 30443}),
+			expectedMessage: "Service default/web exposes privileged ports on the nodes: spec.ports[0].port: 443 is a privileged port (below 1024) exposed on the externalIPs 10.0.0.10",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow privileged ports in a whitelisted namespace",
+			kind:            serviceKind,
+			object:          newService("kube-system", nil, corev1.ServicePort{Port: 53, NodePort: 53}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow other kinds",
+			kind:            meta.GroupVersionKind{Group: "", Kind: "ConfigMap", Version: "v1"},
+			object:          &corev1.ConfigMap{TypeMeta: meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"}},
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	admitFunc, err := DenyPrivilegedServicePorts([]string{"kube-system"}, 0)
+	if err != nil {
+		t.Fatalf("failed to create the AdmitFunc: %v", err)
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+
+	t.Run("Custom threshold", func(t *testing.T) {
+		admitFunc, err := DenyPrivilegedServicePorts(nil, 30100)
+		if err != nil {
+			t.Fatalf("failed to create the AdmitFunc: %v", err)
+		}
+
+		runObjectTests(t, []objectTest{
+			{
+				testName:        "Reject a node port below a custom threshold",
+				kind:            serviceKind,
+				object:          newService("default", nil, corev1.ServicePort{Port: 80, NodePort: 30080}),
+				expectedMessage: "Service default/web exposes privileged ports on the nodes: spec.ports[0].nodePort: 30080 is a privileged port (below 30100) on the nodes",
+				shouldAllow:     false,
+			},
+		}, func(tt objectTest) AdmitFunc {
+			return admitFunc
+		})
+	})
+
+	if _, err := DenyPrivilegedServicePorts(nil, -1); err == nil {
+		t.Fatalf("expected an error for an invalid threshold")
+	}
+}
+
+func TestDenyStaleUpdates(t *testing.T) {
+	t.Parallel()
+
+	lastUpdate := time.Date(2021, time.March, 1, 12, 0, 0, 0, time.UTC)
+	newDeployment := func(resourceVersion string, observedGeneration int64, updated time.Time) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			TypeMeta: meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+			ObjectMeta: meta.ObjectMeta{
+				Name:            "web",
+				Namespace:       "default",
+				ResourceVersion: resourceVersion,
+				Generation:      3,
+				ManagedFields: []meta.ManagedFieldsEntry{
+					{Manager: "web-operator", Operation: meta.ManagedFieldsOperationUpdate, Time: &meta.Time{Time: updated}},
+				},
+			},
+			Status: appsv1.DeploymentStatus{ObservedGeneration: observedGeneration},
+		}
+	}
+	deploymentKind := meta.GroupVersionKind{Group: "apps", Kind: "Deployment", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow an update based on the current resourceVersion",
+			kind:            deploymentKind,
+			operation:       admission.Update,
+			oldObject:       newDeployment("1042", 2, lastUpdate),
+			object:          newDeployment("1042", 3, lastUpdate),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject an unconditional update",
+			kind:            deploymentKind,
+			operation:       admission.Update,
+			oldObject:       newDeployment("1042", 2, lastUpdate),
+			object:          newDeployment("", 2, lastUpdate),
+			expectedMessage: "Deployment default/web: unconditional updates are not allowed: set metadata.resourceVersion to the version that the update is based on - read the latest Deployment and retry",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject an update based on a stale resourceVersion",
+			kind:            deploymentKind,
+			operation:       admission.Update,
+			oldObject:       newDeployment("1042", 2, lastUpdate),
+			object:          newDeployment("1017", 2, lastUpdate),
+			expectedMessage: "Deployment default/web: the update is based on a stale resourceVersion 1017 (the current resourceVersion is 1042) - read the latest Deployment and retry",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject an update based on a copy from before the last update of a manager",
+			kind:            deploymentKind,
+			operation:       admission.Update,
+			oldObject:       newDeployment("1042", 2, lastUpdate),
+			object:          newDeployment("1042", 2, lastUpdate.Add(-time.Minute)),
+			expectedMessage: "Deployment default/web: the update is based on a copy of the Deployment from before its update by web-operator at 2021-03-01T12:00:00Z - read the latest Deployment and retry",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow managedFields times within the clock skew",
+			kind:            deploymentKind,
+			operation:       admission.Update,
+			oldObject:       newDeployment("1042", 2, lastUpdate),
+			object:          newDeployment("1042", 2, lastUpdate.Add(-time.Second*3)),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject moving the observedGeneration backwards",
+			kind:            deploymentKind,
+			operation:       admission.Update,
+			oldObject:       newDeployment("1042", 3, lastUpdate),
+			object:          newDeployment("1042", 2, lastUpdate),
+			expectedMessage: "Deployment default/web: status.observedGeneration cannot move back from 3 to 2: the status was computed from a stale view of the Deployment - read the latest Deployment and retry",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow unconditional updates of kinds that are not enforced",
+			kind:            meta.GroupVersionKind{Group: "apps", Kind: "StatefulSet", Version: "v1"},
+			operation:       admission.Update,
+			oldObject:       newDeployment("1042", 2, lastUpdate),
+			object:          newDeployment("", 2, lastUpdate),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow creates",
+			kind:            deploymentKind,
+			operation:       admission.Create,
+			object:          newDeployment("", 0, lastUpdate),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		admitFunc, err := DenyStaleUpdates(time.Second*5, meta.GroupKind{Group: "apps", Kind: "Deployment"})
+		if err != nil {
+			t.Fatalf("failed to create the AdmitFunc: %v", err)
+		}
+
+		return admitFunc
+	})
+
+	if _, err := DenyStaleUpdates(time.Second * 5); err == nil {
+		t.Fatalf("expected an error when no kinds are given")
+	}
+
+	if _, err := DenyStaleUpdates(-time.Second, meta.GroupKind{Group: "apps", Kind: "Deployment"}); err == nil {
+		t.Fatalf("expected an error for a negative maxClockSkew")
+	}
+}
+
+func TestEnforceRegistryPerNamespace(t *testing.T) {
+	t.Parallel()
+
+	lookup := newTestNamespaceLookup(t,
+		&corev1.Namespace{ObjectMeta: meta.ObjectMeta{
+			Name:        "payments",
+			Annotations: map[string]string{"example.com/allowed-registries": "registry.example.com, ghcr.io/payments/"},
+		}},
+		&corev1.Namespace{ObjectMeta: meta.ObjectMeta{
+			Name:        "public",
+			Annotations: map[string]string{"example.com/allowed-registries": "docker.io/library"},
+		}},
+		&corev1.Namespace{ObjectMeta: meta.ObjectMeta{Name: "untenanted"}},
+	)
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow images from the namespace's registries",
+			kind:            podKind,
+			object:          newTestPodWithImages("payments", "registry.example.com/team/app:v1.0.0", "ghcr.io/payments/api@sha256:8f08a8cb2902ab8e0d9b5fc1ded5a0c1bd8ec2ba6d8c1f8fa2b6aead2d1e1b4f"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject images from registries the namespace is not entitled to",
+			kind:            podKind,
+			object:          newTestPodWithImages("payments", "registry.example.com/team/app:v1.0.0", "ghcr.io/payments-fork/api:v1.0.0", "nginx:1.21"),
+			expectedMessage: "Pod payments/web has images from registries that are not allowed: spec.containers[container-1].image: ghcr.io/payments-fork/api:v1.0.0 is not from a registry that namespace payments is entitled to (registry.example.com, ghcr.io/payments); spec.containers[container-2].image: nginx:1.21 is not from a registry that namespace payments is entitled to (registry.example.com, ghcr.io/payments)",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow official images without a registry host",
+			kind:            podKind,
+			object:          newTestPodWithImages("public", "nginx:1.21", "docker.io/library/redis:6"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject images in namespaces without the annotation",
+			kind:            podKind,
+			object:          newTestPodWithImages("untenanted", "registry.example.com/team/app:v1.0.0"),
+			expectedMessage: "Pod untenanted/web cannot be admitted: namespace untenanted is not entitled to any registries (set the example.com/allowed-registries annotation of the namespace)",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject images in namespaces that do not exist",
+			kind:            podKind,
+			object:          newTestPodWithImages("missing", "registry.example.com/team/app:v1.0.0"),
+			expectedMessage: `could not read the annotations of namespace missing: namespace "missing" not found`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow images in whitelisted namespaces",
+			kind:            podKind,
+			object:          newTestPodWithImages("kube-system", "k8s.gcr.io/coredns:1.8.0"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceRegistryPerNamespace(lookup, []string{"kube-system"}, "example.com/allowed-registries")
+	})
+}
+
+func TestEnforceMaxPodsPerNamespace(t *testing.T) {
+	t.Parallel()
+
+	newPod := func(namespace string, name string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: name, Namespace: namespace},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+
+	terminating := newPod("default", "web-old", corev1.PodRunning)
+	terminating.DeletionTimestamp = &meta.Time{Time: time.Now()}
+
+	controlled := newPod("full", "web-7d4b9-x2x8z", "")
+	isController := true
+	controlled.OwnerReferences = []meta.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-7d4b9", Controller: &isController}}
+
+	client := fake.NewSimpleClientset(
+		newPod("full", "web-0", corev1.PodRunning),
+		newPod("full", "web-1", corev1.PodPending),
+		newPod("default", "web-0", corev1.PodRunning),
+		newPod("default", "migrate", corev1.PodSucceeded),
+		newPod("default", "backup", corev1.PodFailed),
+		terminating,
+	)
+	factory := informers.NewSharedInformerFactory(client, 0)
+	admitFunc, err := EnforceMaxPodsPerNamespace(factory, 2)
+	if err != nil {
+		t.Fatalf("failed to create the AdmitFunc: %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory.Start(stopCh)
+
+	for _, limit := range []int{0, -1} {
+		if _, err := EnforceMaxPodsPerNamespace(factory, limit); err == nil {
+			t.Fatalf("expected an error for a limit of %d", limit)
+		}
+	}
+
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a Pod within the limit, excluding terminated & Terminating Pods",
+			kind:            podKind,
+			operation:       admission.Create,
+			namespace:       "default",
+			object:          newPod("default", "web-1", ""),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a Pod beyond the limit",
+			kind:            podKind,
+			operation:       admission.Create,
+			namespace:       "full",
+			object:          newPod("full", "web-2", ""),
+			expectedMessage: "namespace full already has 2 Pods: creating another would exceed the limit of 2",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a controller-created Pod beyond the limit",
+			kind:            podKind,
+			operation:       admission.Create,
+			namespace:       "full",
+			object:          controlled,
+			expectedMessage: "namespace full already has 2 Pods: creating another (for ReplicaSet web-7d4b9) would exceed the limit of 2",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow updates to existing Pods",
+			kind:            podKind,
+			operation:       admission.Update,
+			namespace:       "full",
+			object:          newPod("full", "web-0", corev1.PodRunning),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+}
+
+func TestEnforceContainerPortRange(t *testing.T) {
+	t.Parallel()
+
+	newPodWithPorts := func(namespace string, ports ...int32) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0")
+		for _, port := range ports {
+			pod.Spec.Containers[0].Ports = append(pod.Spec.Containers[0].Ports, corev1.ContainerPort{ContainerPort: port})
+		}
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow ports within the range",
+			kind:            podKind,
+			object:          newPodWithPorts("default", 8000, 8080, 8999),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow containers without ports",
+			kind:            podKind,
+			object:          newPodWithPorts("default"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject ports outside of the range",
+			kind:            podKind,
+			object:          newPodWithPorts("default", 80, 8080, 9090),
+			expectedMessage: "Pod default/web declares container ports outside of the allowed range: spec.containers[container-0].ports[0].containerPort: container container-0 declares port 80, outside of the allowed range 8000-8999; spec.containers[container-0].ports[2].containerPort: container container-0 declares port 9090, outside of the allowed range 8000-8999",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow ports outside of the range in a whitelisted namespace",
+			kind:            podKind,
+			object:          newPodWithPorts("kube-system", 53),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName: "Reject ports outside of the range in a Deployment",
+			kind:     meta.GroupVersionKind{Group: "apps", Kind: "Deployment", Version: "v1"},
+			object: &appsv1.Deployment{
+				TypeMeta:   meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "web", Image: "web:v1.0.0", Ports: []corev1.ContainerPort{{ContainerPort: 443}}}},
+				}}},
+			},
+			expectedMessage: "Deployment default/web declares container ports outside of the allowed range: spec.template.spec.containers[web].ports[0].containerPort: container web declares port 443, outside of the allowed range 8000-8999",
+			shouldAllow:     false,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceContainerPortRange([]string{"kube-system"}, 8000, 8999)
+	})
+}
+
+func TestEnforceMaxVolumes(t *testing.T) {
+	t.Parallel()
+
+	newPodWithVolumes := func(namespace string, volumes ...corev1.VolumeSource) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0")
+		for i, source := range volumes {
+			pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{Name: fmt.Sprintf("volume-%d", i), VolumeSource: source})
+		}
+		return pod
+	}
+	emptyDir := corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+	claim := corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}}
+	ephemeral := corev1.VolumeSource{Ephemeral: &corev1.EphemeralVolumeSource{}}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow Pods within the limits",
+			kind:            podKind,
+			object:          newPodWithVolumes("default", emptyDir, emptyDir, claim, ephemeral),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject Pods with too many volumes",
+			kind:            podKind,
+			object:          newPodWithVolumes("default", emptyDir, emptyDir, emptyDir, emptyDir, claim),
+			expectedMessage: "Pod default/web has too many volumes: spec.volumes: 5 volumes exceed the limit of 4",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject Pods with too many persistent volumes, including ephemeral volumes",
+			kind:            podKind,
+			object:          newPodWithVolumes("default", claim, claim, ephemeral),
+			expectedMessage: "Pod default/web has too many volumes: spec.volumes: 3 persistent volumes exceed the limit of 2 that can be attached per Pod",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow Pods with too many volumes in a whitelisted namespace",
+			kind:            podKind,
+			object:          newPodWithVolumes("kube-system", claim, claim, claim, claim, claim),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return EnforceMaxVolumes([]string{"kube-system"}, 4, 2)
+	})
+
+	runObjectTests(t, []objectTest{
+		{
+			testName:        "Do not enforce a limit of 0",
+			kind:            podKind,
+			object:          newPodWithVolumes("default", claim, claim, claim, emptyDir, emptyDir),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}, func(tt objectTest) AdmitFunc {
+		return EnforceMaxVolumes(nil, 0, 0)
+	})
+}
+
+func TestDenyPVCStorageClassChange(t *testing.T) {
+	t.Parallel()
+
+	newPVC := func(namespace string, storageClassName *string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			TypeMeta:   meta.TypeMeta{Kind: "PersistentVolumeClaim", APIVersion: "v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "data", Namespace: namespace},
+			Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: storageClassName},
+		}
+	}
+	standard, fast := "standard", "fast"
+	pvcKind := meta.GroupVersionKind{Group: "", Kind: "PersistentVolumeClaim", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Reject changing the storage class",
+			kind:            pvcKind,
+			operation:       admission.Update,
+			oldObject:       newPVC("default", &standard),
+			object:          newPVC("default", &fast),
+			expectedMessage: `PersistentVolumeClaim default/data cannot change storageClassName from "standard" to "fast": the storage class of a claim is immutable after creation - create a new claim with the storage class, and migrate the data to it`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject unsetting the storage class",
+			kind:            pvcKind,
+			operation:       admission.Update,
+			oldObject:       newPVC("default", &standard),
+			object:          newPVC("default", nil),
+			expectedMessage: `PersistentVolumeClaim default/data cannot change storageClassName from "standard" to "": the storage class of a claim is immutable after creation - create a new claim with the storage class, and migrate the data to it`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow setting the storage class of a claim without one",
+			kind:            pvcKind,
+			operation:       admission.Update,
+			oldObject:       newPVC("default", nil),
+			object:          newPVC("default", &standard),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow updates that keep the storage class",
+			kind:            pvcKind,
+			operation:       admission.Update,
+			oldObject:       newPVC("default", &standard),
+			object:          newPVC("default", &standard),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow changing the storage class in a whitelisted namespace",
+			kind:            pvcKind,
+			operation:       admission.Update,
+			oldObject:       newPVC("kube-system", &standard),
+			object:          newPVC("kube-system", &fast),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return DenyPVCStorageClassChange([]string{"kube-system"})
+	})
+}
+
+func TestEnforceIngressTLSVersion(t *testing.T) {
+	t.Parallel()
+
+	const sslProtocols = "nginx.ingress.kubernetes.io/ssl-protocols"
+	newIngress := func(namespace string, annotations map[string]string, tls bool) *networkingv1.Ingress {
+		ingress := &networkingv1.Ingress{
+			TypeMeta:   meta.TypeMeta{Kind: "Ingress", APIVersion: "networking.k8s.io/v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace, Annotations: annotations},
+		}
+		if tls {
+			ingress.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{"example.com"}, SecretName: "example-com-tls"}}
+		}
+		return ingress
+	}
+	ingressKind := meta.GroupVersionKind{Group: "networking.k8s.io", Kind: "Ingress", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow Ingresses restricted to the minimum version or later",
+			kind:            ingressKind,
+			object:          newIngress("default", map[string]string{sslProtocols: "TLSv1.2 TLSv1.3"}, true),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject Ingresses that allow older protocols",
+			kind:            ingressKind,
+			object:          newIngress("default", map[string]string{sslProtocols: "TLSv1 TLSv1.1 TLSv1.2"}, true),
+			expectedMessage: `Ingress default/web allows TLS protocols older than TLSv1.2: metadata.annotations[nginx.ingress.kubernetes.io/ssl-protocols]: TLSv1 is older than the minimum of TLSv1.2 (configured: "TLSv1 TLSv1.1 TLSv1.2"); metadata.annotations[nginx.ingress.kubernetes.io/ssl-protocols]: TLSv1.1 is older than the minimum of TLSv1.2 (configured: "TLSv1 TLSv1.1 TLSv1.2")`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject Ingresses with unknown protocols",
+			kind:            ingressKind,
+			object:          newIngress("default", map[string]string{sslProtocols: "TLSv1.3,QUIC"}, true),
+			expectedMessage: `Ingress default/web allows TLS protocols older than TLSv1.2: metadata.annotations[nginx.ingress.kubernetes.io/ssl-protocols]: QUIC is not a known TLS protocol (configured: "TLSv1.3,QUIC")`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject Ingresses that terminate TLS without the annotation",
+			kind:            ingressKind,
+			object:          newIngress("default", nil, true),
+			expectedMessage: "Ingress default/web allows TLS protocols older than TLSv1.2: metadata.annotations[nginx.ingress.kubernetes.io/ssl-protocols]: must restrict the TLS protocols to TLSv1.2 or later",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow Ingresses that do not terminate TLS",
+			kind:            ingressKind,
+			object:          newIngress("default", nil, false),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow older protocols in a whitelisted namespace",
+			kind:            ingressKind,
+			object:          newIngress("kube-system", map[string]string{sslProtocols: "SSLv3 TLSv1"}, true),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	admitFunc, err := EnforceIngressTLSVersion([]string{"kube-system"}, "TLSv1.2", sslProtocols)
+	if err != nil {
+		t.Fatalf("failed to create the AdmitFunc: %v", err)
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+
+	for _, minVersion := range []string{"SSLv3", "TLSv2", ""} {
+		if _, err := EnforceIngressTLSVersion(nil, minVersion, sslProtocols); err == nil {
+			t.Fatalf("expected an error for a minimum version of %q", minVersion)
+		}
+	}
+
+	if _, err := EnforceIngressTLSVersion(nil, "1.2", ""); err == nil {
+		t.Fatalf("expected an error for an empty annotation key")
+	}
+}
+
+func TestEnforceMaxSkew(t *testing.T) {
+	t.Parallel()
+
+	newPodWithConstraints := func(namespace string, constraints ...corev1.TopologySpreadConstraint) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0")
+		pod.Spec.TopologySpreadConstraints = constraints
+		return pod
+	}
+	spread := func(topologyKey string, maxSkew int32) corev1.TopologySpreadConstraint {
+		return corev1.TopologySpreadConstraint{TopologyKey: topologyKey, MaxSkew: maxSkew, WhenUnsatisfiable: corev1.DoNotSchedule}
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow constraints within the maxSkew across the topology key",
+			kind:            podKind,
+			object:          newPodWithConstraints("default", spread("topology.kubernetes.io/zone", 1), spread("kubernetes.io/hostname", 2)),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow Pods without constraints",
+			kind:            podKind,
+			object:          newPodWithConstraints("default"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject constraints with a loose maxSkew",
+			kind:            podKind,
+			object:          newPodWithConstraints("default", spread("topology.kubernetes.io/zone", 100)),
+			expectedMessage: `Pod default/web has ineffective topologySpreadConstraints: spec.topologySpreadConstraints[0].maxSkew: the constraint across "topology.kubernetes.io/zone" has a maxSkew of 100, above the maximum of 2`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject constraints that do not use the topology key",
+			kind:            podKind,
+			object:          newPodWithConstraints("default", spread("kubernetes.io/hostname", 1)),
+			expectedMessage: `Pod default/web has ineffective topologySpreadConstraints: spec.topologySpreadConstraints: no constraint spreads the Pods across the required topology key "topology.kubernetes.io/zone"`,
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow loose constraints in a whitelisted namespace",
+			kind:            podKind,
+			object:          newPodWithConstraints("kube-system", spread("kubernetes.io/hostname", 100)),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	admitFunc, err := EnforceMaxSkew([]string{"kube-system"}, "topology.kubernetes.io/zone", 2)
+	if err != nil {
+		t.Fatalf("failed to create the AdmitFunc: %v", err)
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+
+	if _, err := EnforceMaxSkew(nil, "topology.kubernetes.io/zone", 0); err == nil {
+		t.Fatalf("expected an error for a maxSkew of 0")
+	}
+}
+
+func TestEnforcePullPolicyForDigestImages(t *testing.T) {
+	t.Parallel()
+
+	digest := "sha256:" + strings.Repeat("a", 64)
+	newPodWithPolicy := func(namespace string, image string, policy corev1.PullPolicy) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, image)
+		pod.Spec.Containers[0].ImagePullPolicy = policy
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow digest-pinned images pulled if not present",
+			kind:            podKind,
+			object:          newPodWithPolicy("default", "web@"+digest, corev1.PullIfNotPresent),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow tag-based images that are always pulled",
+			kind:            podKind,
+			object:          newPodWithPolicy("default", "web:v1.0.0", corev1.PullAlways),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject digest-pinned images that are always pulled",
+			kind:            podKind,
+			object:          newPodWithPolicy("default", "web@"+digest, corev1.PullAlways),
+			expectedMessage: fmt.Sprintf("Pod default/web always pulls images that are pinned to a digest: spec.containers[container-0].imagePullPolicy: web@%s is pinned to a digest, which cannot change: set imagePullPolicy: IfNotPresent rather than Always", digest),
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow digest-pinned images that are always pulled in a whitelisted namespace",
+			kind:            podKind,
+			object:          newPodWithPolicy("kube-system", "web@"+digest, corev1.PullAlways),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	admitFunc := EnforcePullPolicyForDigestImages([]string{"kube-system"})
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+
+	t.Run("Warn about tag-based images pulled if not present", func(t *testing.T) {
+		review := newTestAdmissionRequest(podKind, marshalTestObject(t, newPodWithPolicy("default", "web:v1.0.0", corev1.PullIfNotPresent), nil), true)
+		resp, err := admitFunc(review)
+		if err != nil || !resp.Allowed {
+			t.Fatalf("expected the request to be allowed: %v", err)
+		}
+
+		expected := "spec.containers[container-0].imagePullPolicy: web:v1.0.0 is not pinned to a digest, and nodes may run a stale image if its tag is moved: set imagePullPolicy: Always rather than IfNotPresent, or pin the image to a digest"
+		if len(resp.Warnings) != 1 || resp.Warnings[0] != expected {
+			t.Fatalf("unexpected warnings: got %q (wanted %q)", resp.Warnings, expected)
+		}
+	})
+}
+
+func TestEnforceInitContainerLimits(t *testing.T) {
+	t.Parallel()
+
+	newPodWithInit := func(namespace string, requests, limits corev1.ResourceList) *corev1.Pod {
+		pod := newTestPodWithImages(namespace, "web:v1.0.0")
+		pod.Spec.InitContainers = []corev1.Container{{
+			Name:      "migrate",
+			Image:     "migrate:v1.0.0",
+			Resources: corev1.ResourceRequirements{Requests: requests, Limits: limits},
+		}}
+		return pod
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow init containers within the maximums",
+			kind:            podKind,
+			object:          newPodWithInit("default", corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m")}, corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow init containers without resources",
+			kind:            podKind,
+			object:          newPodWithInit("default", nil, nil),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject init containers that over-request",
+			kind:            podKind,
+			object:          newPodWithInit("default", corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")}, nil),
+			expectedMessage: "Pod default/web has init containers with resources above the allowed maximums: spec.initContainers[migrate].resources.requests.cpu: init container migrate requests 4 of cpu: the maximum is 500m",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject init containers with limits above the maximum",
+			kind:            podKind,
+			object:          newPodWithInit("default", nil, corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("8Gi")}),
+			expectedMessage: "Pod default/web has init containers with resources above the allowed maximums: spec.initContainers[migrate].resources.limits.memory: init container migrate has a memory limit of 8Gi: the maximum is 2Gi",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow over-requesting init containers in a whitelisted namespace",
+			kind:            podKind,
+			object:          newPodWithInit("kube-system", corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")}, nil),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+	}
+
+	admitFunc := EnforceInitContainerLimits([]string{"kube-system"}, corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+	})
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+}
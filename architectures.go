@@ -0,0 +1,210 @@
+package admissioncontrol
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+	admission "k8s.io/api/admission/v1"
+)
+
+var (
+	// defaultManifestResolutionTimeout bounds the time spent resolving the
+	// manifests of the images of a request. It must remain below the webhook
+	// timeoutSeconds configured on the API server.
+	defaultManifestResolutionTimeout = time.Second * 5
+	// maxArchitectureCacheEntries is the number of cached digests beyond which
+	// the cache is cleared.
+	maxArchitectureCacheEntries = 4096
+)
+
+// ManifestResolver reads the manifests of container images from their
+// registry: e.g. using go-containerregistry.
+//
+// Both methods are called with a context bounded by the resolution timeout
+// (see ManifestResolutionTimeout), and must be safe for concurrent use.
+type ManifestResolver interface {
+	// Resolve returns the digest (e.g. "sha256:...") that the image reference
+	// (e.g. "registry.example.com/team/app:v1.0.0") currently refers to.
+	Resolve(ctx context.Context, image string) (string, error)
+	// Architectures returns the CPU architectures (e.g. "amd64", "arm64") that
+	// the image - a repository and digest, e.g.
+	// "registry.example.com/team/app@sha256:..." - provides: those of each of
+	// the manifests of an image index, or that of a single image manifest.
+	Architectures(ctx context.Context, image string) ([]string, error)
+}
+
+// architecturePolicy holds the configuration of EnforceImageArchitecture.
+type architecturePolicy struct {
+	resolver      ManifestResolver
+	timeout       time.Duration
+	failurePolicy FailurePolicy
+
+	mu    sync.Mutex
+	cache map[string][]string
+}
+
+// ManifestResolutionOption configures EnforceImageArchitecture.
+type ManifestResolutionOption func(*architecturePolicy)
+
+// ManifestResolutionTimeout sets the time allowed for resolving the manifests
+// of the images of a request. Defaults to 5 seconds.
+func ManifestResolutionTimeout(timeout time.Duration) ManifestResolutionOption {
+	return func(ap *architecturePolicy) {
+		ap.timeout = timeout
+	}
+}
+
+// ManifestResolutionFailurePolicy determines whether requests are allowed
+// (FailOpen) or denied (FailClosed) when the manifests of an image cannot be
+// resolved. Defaults to FailClosed.
+func ManifestResolutionFailurePolicy(failurePolicy FailurePolicy) ManifestResolutionOption {
+	return func(ap *architecturePolicy) {
+		ap.failurePolicy = failurePolicy
+	}
+}
+
+// EnforceImageArchitecture denies Pods (and the Pod templates of workloads)
+// with container images that do not provide an image for each of the required
+// architectures (e.g. "arm64") that the cluster's nodes run, as reported by the
+// resolver. Such Pods are scheduled, but crash-loop on the nodes whose
+// architecture their image lacks. An error is returned if the resolver is nil,
+// or if no architectures are required.
+//
+// Images pinned to a digest are inspected at that digest, and other images are
+// first resolved to the digest they currently refer to. As the manifests at a
+// digest cannot change, the architectures of each digest are cached. Images
+// that cannot be resolved are denied by default: see
+// ManifestResolutionFailurePolicy. With FailOpen, only the images that cannot
+// be resolved are allowed: the other images of the request must still provide
+// the required architectures.
+//
+// EnforceImageArchitecture inspects init, regular & ephemeral containers, in
+// Pods and the Pod templates of Deployments, StatefulSets, DaemonSets,
+// ReplicaSets, ReplicationControllers, Jobs & CronJobs. Unknown object kinds
+// are answered according to AdmissionHandler.UnknownKinds (allowed by default).
+func EnforceImageArchitecture(resolver ManifestResolver, ignoredNamespaces []string, required []string, opts ...ManifestResolutionOption) (AdmitFunc, error) {
+	if resolver == nil {
+		return nil, xerrors.New("a ManifestResolver must be provided")
+	}
+
+	if len(required) == 0 {
+		return nil, xerrors.New("at least one architecture must be required")
+	}
+
+	ap := &architecturePolicy{
+		resolver: resolver,
+		timeout:  defaultManifestResolutionTimeout,
+		cache:    make(map[string][]string),
+	}
+
+	for _, opt := range opts {
+		opt(ap)
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), ap.timeout)
+		defer cancel()
+
+		var violations ViolationList
+		var unresolved []string
+		failed := make(map[string]bool)
+		for _, container := range pod.containers() {
+			if failed[container.Image] {
+				continue
+			}
+
+			provided, err := ap.architectures(ctx, container.Image)
+			if err != nil {
+				reason := fmt.Sprintf("the architectures of the image %s could not be resolved", container.Image)
+				if ap.failurePolicy != FailOpen {
+					return resp, xerrors.Errorf("%s (%s): %w", reason, ap.failurePolicy, err)
+				}
+
+				// Fail open for this image only: the architectures of the
+				// other images are still enforced.
+				failed[container.Image] = true
+				unresolved = append(unresolved, fmt.Sprintf("%s (%s): %s", reason, ap.failurePolicy, err))
+				continue
+			}
+
+			provides := make(map[string]bool, len(provided))
+			for _, architecture := range provided {
+				provides[architecture] = true
+			}
+
+			var missing []string
+			for _, architecture := range required {
+				if !provides[architecture] {
+					missing = append(missing, architecture)
+				}
+			}
+
+			if len(missing) > 0 {
+				violations.Add(container.field+".image", fmt.Sprintf("%s does not provide an image for %s", container.Image, strings.Join(missing, ", ")))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s has images missing required architectures", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		if len(unresolved) > 0 {
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s", strings.Join(unresolved, "; "))
+		}
+		return resp, nil
+	}, nil
+}
+
+// architectures returns the (possibly cached) architectures provided by the
+// image, resolving it to its digest unless it is pinned to one.
+func (ap *architecturePolicy) architectures(ctx context.Context, image string) ([]string, error) {
+	ref := parseImageReference(image)
+	digest := ref.digest
+	if digest == "" {
+		resolved, err := ap.resolver.Resolve(ctx, image)
+		if err != nil {
+			return nil, xerrors.Errorf("could not resolve the digest: %w", err)
+		}
+		digest = resolved
+	}
+
+	ap.mu.Lock()
+	architectures, ok := ap.cache[digest]
+	ap.mu.Unlock()
+	if ok {
+		return architectures, nil
+	}
+
+	architectures, err := ap.resolver.Architectures(ctx, ref.repository+"@"+digest)
+	if err != nil {
+		return nil, err
+	}
+
+	ap.mu.Lock()
+	if len(ap.cache) >= maxArchitectureCacheEntries {
+		ap.cache = make(map[string][]string)
+	}
+	ap.cache[digest] = architectures
+	ap.mu.Unlock()
+
+	return architectures, nil
+}
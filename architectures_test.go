@@ -0,0 +1,135 @@
+package admissioncontrol
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeResolver resolves tags to the configured digests, and returns the
+// configured architectures for each repository@digest reference.
+type fakeResolver struct {
+	digests       map[string]string
+	architectures map[string][]string
+
+	mu      sync.Mutex
+	lookups int
+}
+
+func (fr *fakeResolver) Resolve(ctx context.Context, image string) (string, error) {
+	digest, ok := fr.digests[image]
+	if !ok {
+		return "", errors.New("manifest unknown")
+	}
+
+	return digest, nil
+}
+
+func (fr *fakeResolver) Architectures(ctx context.Context, image string) ([]string, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	fr.lookups++
+
+	return fr.architectures[image], nil
+}
+
+func TestEnforceImageArchitecture(t *testing.T) {
+	t.Parallel()
+
+	const (
+		multiArchDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		amd64Digest     = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	)
+
+	resolver := &fakeResolver{
+		digests: map[string]string{
+			"registry.example.com/web:v1.0.0": multiArchDigest,
+			"registry.example.com/api:v1.0.0": amd64Digest,
+		},
+		architectures: map[string][]string{
+			"registry.example.com/web@" + multiArchDigest: {"amd64", "arm64"},
+			"registry.example.com/api@" + amd64Digest:     {"amd64"},
+		},
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow images that provide the required architectures",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "registry.example.com/web:v1.0.0", "registry.example.com/web@"+multiArchDigest),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject an image that does not provide a required architecture",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "registry.example.com/web:v1.0.0", "registry.example.com/api:v1.0.0"),
+			expectedMessage: "Pod default/web has images missing required architectures: spec.containers[container-1].image: registry.example.com/api:v1.0.0 does not provide an image for arm64",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject an image that cannot be resolved",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "registry.example.com/unknown:v1.0.0"),
+			expectedMessage: "the architectures of the image registry.example.com/unknown:v1.0.0 could not be resolved (FailClosed): could not resolve the digest: manifest unknown",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow images in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"legacy"},
+			object:            newTestPodWithImages("legacy", "registry.example.com/api:v1.0.0"),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	admitFunc, err := EnforceImageArchitecture(resolver, []string{"legacy"}, []string{"arm64"})
+	if err != nil {
+		t.Fatalf("failed to create the AdmitFunc: %v", err)
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+
+	if resolver.lookups != 2 {
+		t.Fatalf("the architectures were looked up %d times: expected them to be cached by digest", resolver.lookups)
+	}
+
+	runObjectTests(t, []objectTest{
+		{
+			testName:        "Allow images that cannot be resolved with FailOpen",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "registry.example.com/unknown:v1.0.0"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject missing architectures alongside an image that cannot be resolved with FailOpen",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "registry.example.com/api:v1.0.0", "registry.example.com/unknown:v1.0.0"),
+			expectedMessage: "Pod default/web has images missing required architectures: spec.containers[container-0].image: registry.example.com/api:v1.0.0 does not provide an image for arm64",
+			shouldAllow:     false,
+		},
+	}, func(tt objectTest) AdmitFunc {
+		admitFunc, err := EnforceImageArchitecture(resolver, nil, []string{"arm64"}, ManifestResolutionFailurePolicy(FailOpen))
+		if err != nil {
+			t.Fatalf("failed to create the AdmitFunc: %v", err)
+		}
+
+		return admitFunc
+	})
+
+	if _, err := EnforceImageArchitecture(nil, nil, []string{"arm64"}); err == nil {
+		t.Fatalf("expected an error for a nil resolver")
+	}
+
+	if _, err := EnforceImageArchitecture(resolver, nil, nil); err == nil {
+		t.Fatalf("expected an error when no architectures are required")
+	}
+}
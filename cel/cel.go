@@ -0,0 +1,135 @@
+// Package cel provides an AdmitFunc wrapper that pre-filters admission requests
+// with a CEL (Common Expression Language) expression, in the style of the
+// matchConditions of a Kubernetes (1.28+) webhook configuration. This narrows
+// the requests that an AdmitFunc evaluates without changing the webhook
+// configuration, and on clusters that do not support matchConditions.
+//
+// It is a separate module, so that users of admissioncontrol that do not use
+// CEL do not depend on cel-go.
+package cel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	admissioncontrol "github.com/tonyo/admission-control"
+	"golang.org/x/xerrors"
+	admission "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MatchCondition wraps an AdmitFunc, evaluating it only for requests that
+// match the CEL expression. Requests that do not match are allowed without
+// evaluating the AdmitFunc.
+//
+// As with matchConditions, the expression has access to the variables object
+// and oldObject (null for CREATE and DELETE requests, respectively), and
+// request (the AdmissionRequest, e.g. request.operation and
+// request.userInfo.username), and must evaluate to a bool - e.g.
+//
+//	!request.userInfo.username.startsWith("system:")
+//
+// The expression is compiled once: invalid expressions, and expressions that do
+// not evaluate to a bool, are returned as an error. Requests for which the
+// expression fails to evaluate (e.g. a missing field) are rejected.
+func MatchCondition(expression string, admitFunc admissioncontrol.AdmitFunc) (admissioncontrol.AdmitFunc, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("object", cel.DynType),
+		cel.Variable("oldObject", cel.DynType),
+		cel.Variable("request", cel.DynType),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, xerrors.Errorf("invalid CEL expression %q: %w", expression, issues.Err())
+	}
+
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, xerrors.Errorf("invalid CEL expression %q: it must evaluate to a bool, not %s", expression, ast.OutputType())
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid CEL expression %q: %w", expression, err)
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		resp := &admission.AdmissionResponse{
+			Allowed: false,
+			Result:  &metav1.Status{},
+		}
+
+		activation, err := newActivation(admissionReview.Request)
+		if err != nil {
+			return nil, err
+		}
+
+		out, _, err := program.Eval(activation)
+		if err != nil {
+			return nil, xerrors.Errorf("failed to evaluate the CEL expression %q: %w", expression, err)
+		}
+
+		matched, ok := out.Value().(bool)
+		if !ok {
+			return nil, xerrors.Errorf("the CEL expression %q must evaluate to a bool, got %T", expression, out.Value())
+		}
+
+		if !matched {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: the request does not match %q", expression)
+			return resp, nil
+		}
+
+		return admitFunc(admissionReview)
+	}, nil
+}
+
+// newActivation returns the variables that the expression is evaluated
+// against: the request, and its (decoded) object & oldObject.
+func newActivation(request *admission.AdmissionRequest) (map[string]interface{}, error) {
+	object, err := decodeJSON(request.Object.Raw)
+	if err != nil {
+		return nil, xerrors.Errorf("could not decode the object: %w", err)
+	}
+
+	oldObject, err := decodeJSON(request.OldObject.Raw)
+	if err != nil {
+		return nil, xerrors.Errorf("could not decode the oldObject: %w", err)
+	}
+
+	raw, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := decodeJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"object":    object,
+		"oldObject": oldObject,
+		"request":   req,
+	}, nil
+}
+
+// decodeJSON decodes the (optional) JSON document, returning nil if it is
+// empty.
+func decodeJSON(raw []byte) (interface{}, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, nil
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
@@ -0,0 +1,118 @@
+package cel
+
+import (
+	"encoding/json"
+	"testing"
+
+	"golang.org/x/xerrors"
+	admission "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// denyAll is an AdmitFunc that denies every request.
+func denyAll(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+	return &admission.AdmissionResponse{Allowed: false, Result: &meta.Status{}}, xerrors.New("denied")
+}
+
+// newTestReview returns an AdmissionReview for a Pod named "web" in the
+// namespace, made by the user.
+func newTestReview(t *testing.T, namespace string, username string) *admission.AdmissionReview {
+	t.Helper()
+
+	pod := &corev1.Pod{
+		TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+		ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: namespace, Labels: map[string]string{"team": "payments"}},
+	}
+
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatalf("could not marshal k8s API object: %v", err)
+	}
+
+	review := &admission.AdmissionReview{Request: &admission.AdmissionRequest{
+		Kind:      meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+		Operation: admission.Create,
+		Namespace: namespace,
+		Name:      "web",
+		UserInfo:  authenticationv1.UserInfo{Username: username},
+	}}
+	review.Request.Object.Raw = raw
+
+	return review
+}
+
+func TestMatchCondition(t *testing.T) {
+	t.Parallel()
+
+	var matchTests = []struct {
+		testName    string
+		expression  string
+		namespace   string
+		username    string
+		shouldAllow bool
+	}{
+		{
+			testName:    "Evaluate requests that match",
+			expression:  `!request.userInfo.username.startsWith("system:")`,
+			namespace:   "default",
+			username:    "jane@example.com",
+			shouldAllow: false,
+		},
+		{
+			testName:    "Allow requests that do not match",
+			expression:  `!request.userInfo.username.startsWith("system:")`,
+			namespace:   "default",
+			username:    "system:serviceaccount:kube-system:replicaset-controller",
+			shouldAllow: true,
+		},
+		{
+			testName:    "Match on the object",
+			expression:  `object.metadata.labels.team == "payments" && request.namespace != "sandbox"`,
+			namespace:   "default",
+			shouldAllow: false,
+		},
+		{
+			testName:    "Allow requests that do not match on the object",
+			expression:  `object.metadata.labels.team == "payments" && request.namespace != "sandbox"`,
+			namespace:   "sandbox",
+			shouldAllow: true,
+		},
+		{
+			testName:    "Reject requests that fail to evaluate",
+			expression:  `object.metadata.annotations.owner == "payments"`,
+			namespace:   "default",
+			shouldAllow: false,
+		},
+	}
+
+	for _, tt := range matchTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			admitFunc, err := MatchCondition(tt.expression, denyAll)
+			if err != nil {
+				t.Fatalf("failed to compile the CEL expression: %v", err)
+			}
+
+			resp, err := admitFunc(newTestReview(t, tt.namespace, tt.username))
+			if allowed := err == nil && resp.Allowed; allowed != tt.shouldAllow {
+				t.Fatalf("admission mismatch: got allowed=%t (err: %v) - wanted allowed=%t", allowed, err, tt.shouldAllow)
+			}
+		})
+	}
+}
+
+func TestMatchConditionInvalidExpression(t *testing.T) {
+	t.Parallel()
+
+	for _, expression := range []string{
+		`request.operation ==`,
+		`request.operation + 1 == "CREATE"`,
+		`"CREATE"`,
+	} {
+		if _, err := MatchCondition(expression, denyAll); err == nil {
+			t.Fatalf("expected an error for the CEL expression %q", expression)
+		}
+	}
+}
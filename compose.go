@@ -0,0 +1,307 @@
+package admissioncontrol
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/xerrors"
+	admission "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// serviceAccountUsernamePrefix prefixes the usernames of service accounts in
+// admission requests: "system:serviceaccount:<namespace>:<name>".
+const serviceAccountUsernamePrefix = "system:serviceaccount:"
+
+// BypassForServiceAccounts wraps an AdmitFunc, allowing requests made by any of
+// the serviceAccounts - specified as "namespace/name" - without evaluating the
+// AdmitFunc. This allows trusted controllers to perform operations that the
+// AdmitFunc denies to everyone else.
+//
+// Requests from all other users (and service accounts) are passed to the
+// AdmitFunc.
+func BypassForServiceAccounts(admitFunc AdmitFunc, serviceAccounts ...string) AdmitFunc {
+	usernames := make(map[string]bool, len(serviceAccounts))
+	for _, serviceAccount := range serviceAccounts {
+		usernames[serviceAccountUsernamePrefix+strings.Replace(serviceAccount, "/", ":", 1)] = true
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		username := admissionReview.Request.UserInfo.Username
+		if !usernames[username] {
+			return admitFunc(admissionReview)
+		}
+
+		resp := newDefaultDenyResponse()
+		resp.Allowed = true
+		resp.Result.Message = fmt.Sprintf("allowing admission: %s is an allowed service account", username)
+		return resp, nil
+	}
+}
+
+// OnlyNamespacesLabeled wraps an AdmitFunc, evaluating it only for requests in
+// namespaces whose labels match the selector - e.g. "env=prod". Requests in
+// other namespaces, and requests for cluster-scoped objects, are allowed
+// without evaluating the AdmitFunc.
+//
+// The namespace labels are read from the lookup: requests are rejected if their
+// namespace does not exist, and answered according to the lookup's
+// ClientFailurePolicy if the lookup's cache has not synced.
+func OnlyNamespacesLabeled(lookup *NamespaceLookup, selector labels.Selector, admitFunc AdmitFunc) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		namespace := admissionReview.Request.Namespace
+		resp := newDefaultDenyResponse()
+
+		if namespace == "" {
+			resp.Allowed = true
+			return resp, nil
+		}
+
+		namespaceLabels, err := lookup.NamespaceLabels(namespace)
+		if xerrors.Is(err, ErrCacheNotSynced) {
+			return lookup.policy.unavailable(resp, fmt.Sprintf("could not read the labels of namespace %s: %s", namespace, err))
+		} else if err != nil {
+			return nil, xerrors.Errorf("could not read the labels of namespace %s: %w", namespace, err)
+		}
+
+		if !selector.Matches(labels.Set(namespaceLabels)) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace does not match %q", namespace, selector.String())
+			return resp, nil
+		}
+
+		return admitFunc(admissionReview)
+	}
+}
+
+// AllOf combines AdmitFuncs, allowing a request only if each of them allows it.
+// Every AdmitFunc is evaluated (in order), so that a denial reports each of the
+// reasons that the request was denied, separated by semicolons. An AdmitFunc
+// that returns an error (or an empty response) denies the request, as it would
+// if it were passed to an AdmissionHandler directly.
+//
+// AdmitFuncs that do not support the kind of the request (returning an error
+// wrapping ErrUnsupportedKind) are skipped, as they do not apply to it: if none
+// of the AdmitFuncs support it, the first such error is returned, so that the
+// request is answered according to the handler's UnknownKinds.
+//
+// The audit annotations & warnings of each response are combined, with the
+// audit annotations of later AdmitFuncs taking precedence. AllOf with no
+// AdmitFuncs allows every request.
+func AllOf(funcs ...AdmitFunc) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		resp := newDefaultDenyResponse()
+
+		var reasons []string
+		var unsupported error
+		evaluated := 0
+		for _, fn := range funcs {
+			result, err := fn(admissionReview)
+			if xerrors.Is(err, ErrUnsupportedKind) {
+				if unsupported == nil {
+					unsupported = err
+				}
+				continue
+			}
+			evaluated++
+
+			mergeResponse(resp, result)
+			if allowed, reason := evaluateResult(result, err); !allowed {
+				reasons = append(reasons, reason)
+			}
+		}
+
+		if evaluated == 0 && unsupported != nil {
+			return nil, unsupported
+		}
+
+		if len(reasons) > 0 {
+			return resp, xerrors.New(strings.Join(reasons, "; "))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// CollectAll combines AdmitFuncs, allowing a request only if each of them
+// allows it. Like AllOf, every AdmitFunc is evaluated, so that a developer can
+// fix each of the reasons that a request was denied at once: the denial lists
+// the reason of each AdmitFunc that denied it on its own line, as displayed by
+// kubectl.
+//
+// An AdmitFunc that returns an error (or an empty response) denies the request,
+// and its error is listed alongside the other reasons. AdmitFuncs that do not
+// support the kind of the request (returning an error wrapping
+// ErrUnsupportedKind) are skipped, as they do not apply to it: if none of the
+// AdmitFuncs support it, the first such error is returned, so that the request
+// is answered according to the handler's UnknownKinds.
+//
+// The violations that each AdmitFunc recorded (see ViolationsAuditAnnotation)
+// are combined into a single audit annotation; other audit annotations &
+// warnings are combined as they are by AllOf. CollectAll with no AdmitFuncs
+// allows every request.
+func CollectAll(funcs ...AdmitFunc) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		resp := newDefaultDenyResponse()
+
+		var reasons []string
+		var violations ViolationList
+		var unsupported error
+		evaluated := 0
+		for _, fn := range funcs {
+			result, err := fn(admissionReview)
+			if xerrors.Is(err, ErrUnsupportedKind) {
+				if unsupported == nil {
+					unsupported = err
+				}
+				continue
+			}
+			evaluated++
+
+			mergeResponse(resp, result)
+			if result != nil {
+				if recorded, ok := result.AuditAnnotations[ViolationsAuditAnnotation]; ok {
+					var vl ViolationList
+					if err := json.Unmarshal([]byte(recorded), &vl); err == nil {
+						violations = append(violations, vl...)
+					}
+				}
+			}
+
+			if allowed, reason := evaluateResult(result, err); !allowed {
+				reasons = append(reasons, reason)
+			}
+		}
+
+		if evaluated == 0 && unsupported != nil {
+			return nil, unsupported
+		}
+
+		if len(violations) > 0 {
+			if err := SetAuditAnnotation(resp, ViolationsAuditAnnotation, violations.AuditAnnotation()); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(reasons) > 0 {
+			return resp, xerrors.Errorf("%d of %d policies denied the request:\n- %s", len(reasons), evaluated, strings.Join(reasons, "\n- "))
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
+
+// AnyOf combines AdmitFuncs, allowing a request if any of them allows it. The
+// AdmitFuncs are evaluated in order, and the response of the first to allow the
+// request is returned, without evaluating the rest. An AdmitFunc that returns
+// an error (or an empty response) does not allow the request, but nor does it
+// prevent the AdmitFuncs after it from allowing it.
+//
+// If none of the AdmitFuncs allow the request, the denial reports each of
+// their reasons, separated by semicolons. AdmitFuncs that do not support the
+// kind of the request are skipped, as they are by AllOf: if none of them
+// support it, the first such error is returned. AnyOf with no AdmitFuncs
+// denies every request.
+func AnyOf(funcs ...AdmitFunc) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		resp := newDefaultDenyResponse()
+
+		reasons := make([]string, 0, len(funcs))
+		var unsupported error
+		for _, fn := range funcs {
+			result, err := fn(admissionReview)
+			if xerrors.Is(err, ErrUnsupportedKind) {
+				if unsupported == nil {
+					unsupported = err
+				}
+				continue
+			}
+
+			allowed, reason := evaluateResult(result, err)
+			if allowed {
+				return result, nil
+			}
+
+			mergeResponse(resp, result)
+			reasons = append(reasons, reason)
+		}
+
+		if len(reasons) == 0 {
+			if unsupported != nil {
+				return nil, unsupported
+			}
+			return resp, xerrors.New("no AdmitFuncs were provided to allow the request")
+		}
+
+		return resp, xerrors.Errorf("none of the AdmitFuncs allowed the request: %s", strings.Join(reasons, "; "))
+	}
+}
+
+// evaluateResult reports whether the result of an AdmitFunc allows the request
+// and, if not, the reason.
+func evaluateResult(resp *admission.AdmissionResponse, err error) (bool, string) {
+	switch {
+	case err != nil:
+		return false, err.Error()
+	case resp == nil:
+		return false, "the AdmitFunc returned an empty AdmissionResponse"
+	case !resp.Allowed:
+		if resp.Result != nil && resp.Result.Message != "" {
+			return false, resp.Result.Message
+		}
+		return false, "the request was denied"
+	}
+
+	return true, ""
+}
+
+// mergeResponse copies the audit annotations & warnings of the result (if any)
+// into the response.
+func mergeResponse(resp *admission.AdmissionResponse, result *admission.AdmissionResponse) {
+	if result == nil {
+		return
+	}
+
+	for key, value := range result.AuditAnnotations {
+		if resp.AuditAnnotations == nil {
+			resp.AuditAnnotations = make(map[string]string, len(result.AuditAnnotations))
+		}
+		resp.AuditAnnotations[key] = value
+	}
+
+	resp.Warnings = append(resp.Warnings, result.Warnings...)
+}
+
+// Not inverts an AdmitFunc, denying the requests that it allows (with the
+// message), and allowing the requests that it denies. This builds "deny unless"
+// policies from "allow if" checks: e.g. Not(usesHostNetwork, "hostNetwork is
+// not allowed"), where usesHostNetwork allows Pods that use the host network.
+//
+// Only denials - responses with Allowed unset - are inverted. Errors are not:
+// an AdmitFunc that returns an error (or an empty response) has failed to
+// evaluate the request, which remains denied. As the built-in AdmitFuncs deny
+// requests by returning an error, inverting them is not meaningful.
+func Not(fn AdmitFunc, message string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		result, err := fn(admissionReview)
+		if err != nil {
+			return result, err
+		}
+
+		if result == nil {
+			return nil, xerrors.New("the AdmitFunc returned an empty AdmissionResponse")
+		}
+
+		resp := newDefaultDenyResponse()
+		mergeResponse(resp, result)
+		if result.Allowed {
+			return resp, xerrors.New(message)
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}
+}
@@ -0,0 +1,364 @@
+package admissioncontrol
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/xerrors"
+	admission "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// denyAll is an AdmitFunc that denies every request.
+func denyAll(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+	return newDefaultDenyResponse(), xerrors.New("denied")
+}
+
+func TestBypassForServiceAccounts(t *testing.T) {
+	t.Parallel()
+
+	admitFunc := BypassForServiceAccounts(denyAll, "example-system/example-controller")
+
+	var bypassTests = []struct {
+		testName    string
+		username    string
+		shouldAllow bool
+	}{
+		{testName: "Allow an allowed service account", username: "system:serviceaccount:example-system:example-controller", shouldAllow: true},
+		{testName: "Evaluate other service accounts", username: "system:serviceaccount:default:example-controller", shouldAllow: false},
+		{testName: "Evaluate users", username: "jane@example.com", shouldAllow: false},
+	}
+
+	for _, tt := range bypassTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			review := newTestAdmissionRequest(meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}, nil, tt.shouldAllow)
+			review.Request.UserInfo = authenticationv1.UserInfo{Username: tt.username}
+
+			resp, err := admitFunc(review)
+			if allowed := err == nil && resp.Allowed; allowed != tt.shouldAllow {
+				t.Fatalf("admission mismatch for %s: got allowed=%t - wanted allowed=%t", tt.username, allowed, tt.shouldAllow)
+			}
+		})
+	}
+}
+
+func TestOnlyNamespacesLabeled(t *testing.T) {
+	t.Parallel()
+
+	lookup := newTestNamespaceLookup(t,
+		&corev1.Namespace{ObjectMeta: meta.ObjectMeta{Name: "payments", Labels: map[string]string{"env": "prod"}}},
+		&corev1.Namespace{ObjectMeta: meta.ObjectMeta{Name: "sandbox", Labels: map[string]string{"env": "dev"}}},
+	)
+	admitFunc := OnlyNamespacesLabeled(lookup, labels.SelectorFromSet(labels.Set{"env": "prod"}), denyAll)
+
+	var labeledTests = []struct {
+		testName        string
+		namespace       string
+		expectedMessage string
+		shouldAllow     bool
+	}{
+		{testName: "Evaluate requests in matching namespaces", namespace: "payments", expectedMessage: "denied", shouldAllow: false},
+		{testName: "Allow requests in other namespaces", namespace: "sandbox", expectedMessage: `allowing admission: sandbox namespace does not match "env=prod"`, shouldAllow: true},
+		{testName: "Allow requests for cluster-scoped objects", namespace: "", expectedMessage: "", shouldAllow: true},
+		{testName: "Reject requests in unknown namespaces", namespace: "missing", expectedMessage: `could not read the labels of namespace missing: namespace "missing" not found`, shouldAllow: false},
+	}
+
+	for _, tt := range labeledTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			review := newTestAdmissionRequest(meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}, nil, tt.shouldAllow)
+			review.Request.Namespace = tt.namespace
+
+			resp, err := admitFunc(review)
+			if allowed := err == nil && resp.Allowed; allowed != tt.shouldAllow {
+				t.Fatalf("admission mismatch for %q: got allowed=%t - wanted allowed=%t", tt.namespace, allowed, tt.shouldAllow)
+			}
+
+			var message string
+			if err != nil {
+				message = err.Error()
+			} else {
+				message = resp.Result.Message
+			}
+
+			if message != tt.expectedMessage {
+				t.Fatalf(testErrMessageMismatch, message, tt.expectedMessage)
+			}
+		})
+	}
+}
+
+// allowAll is an AdmitFunc that allows every request.
+func allowAll(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+	resp := newDefaultDenyResponse()
+	resp.Allowed = true
+	return resp, nil
+}
+
+// denyWithMessage returns an AdmitFunc that denies every request with the
+// message, without returning an error.
+func denyWithMessage(message string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		resp := newDefaultDenyResponse()
+		resp.Result.Message = message
+		resp.Warnings = []string{message}
+		return resp, nil
+	}
+}
+
+// failWithError returns an AdmitFunc that fails to evaluate every request.
+func failWithError(message string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		return nil, xerrors.New(message)
+	}
+}
+
+// unsupportedKindFunc is an AdmitFunc that does not support the kind of any
+// request.
+func unsupportedKindFunc(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+	return nil, unsupportedKind(admissionReview.Request.Kind.Kind)
+}
+
+// composeTest is a test case for the AdmitFunc combinators.
+type composeTest struct {
+	testName        string
+	admitFunc       AdmitFunc
+	expectedMessage string
+	shouldAllow     bool
+}
+
+func runComposeTests(t *testing.T, tests []composeTest) {
+	t.Helper()
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			review := newTestAdmissionRequest(meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}, nil, tt.shouldAllow)
+
+			resp, err := tt.admitFunc(review)
+			if allowed := err == nil && resp.Allowed; allowed != tt.shouldAllow {
+				t.Fatalf("admission mismatch: got allowed=%t - wanted allowed=%t (%v)", allowed, tt.shouldAllow, err)
+			}
+
+			if err != nil && err.Error() != tt.expectedMessage {
+				t.Fatalf(testErrMessageMismatch, err.Error(), tt.expectedMessage)
+			}
+		})
+	}
+}
+
+func TestAllOf(t *testing.T) {
+	t.Parallel()
+
+	runComposeTests(t, []composeTest{
+		{testName: "Allow when every AdmitFunc allows", admitFunc: AllOf(allowAll, allowAll), shouldAllow: true},
+		{testName: "Allow with no AdmitFuncs", admitFunc: AllOf(), shouldAllow: true},
+		{
+			testName:        "Deny when any AdmitFunc denies, aggregating the reasons",
+			admitFunc:       AllOf(allowAll, denyWithMessage("no Ingresses"), denyAll, failWithError("cache not synced")),
+			expectedMessage: "no Ingresses; denied; cache not synced",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Skip the AdmitFuncs that do not support the kind",
+			admitFunc:       AllOf(unsupportedKindFunc, denyWithMessage("no Ingresses")),
+			expectedMessage: "no Ingresses",
+			shouldAllow:     false,
+		},
+	})
+
+	resp, _ := AllOf(denyWithMessage("first"), allowAll, denyWithMessage("second"))(newTestAdmissionRequest(meta.GroupVersionKind{Kind: "Pod", Version: "v1"}, nil, false))
+	if len(resp.Warnings) != 2 {
+		t.Fatalf("expected the warnings of each AdmitFunc to be combined: got %v", resp.Warnings)
+	}
+}
+
+func TestAnyOf(t *testing.T) {
+	t.Parallel()
+
+	evaluated := false
+	recordEvaluation := func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		evaluated = true
+		return allowAll(admissionReview)
+	}
+
+	runComposeTests(t, []composeTest{
+		{testName: "Allow when any AdmitFunc allows", admitFunc: AnyOf(denyAll, allowAll, recordEvaluation), shouldAllow: true},
+		{testName: "Allow when an AdmitFunc after an error allows", admitFunc: AnyOf(failWithError("cache not synced"), allowAll), shouldAllow: true},
+		{
+			testName:        "Deny when every AdmitFunc denies, aggregating the reasons",
+			admitFunc:       AnyOf(denyWithMessage("untrusted registry"), denyAll, failWithError("cache not synced")),
+			expectedMessage: "none of the AdmitFuncs allowed the request: untrusted registry; denied; cache not synced",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Deny with no AdmitFuncs",
+			admitFunc:       AnyOf(),
+			expectedMessage: "no AdmitFuncs were provided to allow the request",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Skip the AdmitFuncs that do not support the kind",
+			admitFunc:       AnyOf(unsupportedKindFunc, denyWithMessage("untrusted registry")),
+			expectedMessage: "none of the AdmitFuncs allowed the request: untrusted registry",
+			shouldAllow:     false,
+		},
+	})
+
+	if evaluated {
+		t.Fatalf("expected AnyOf to stop evaluating once an AdmitFunc allowed the request")
+	}
+}
+
+func TestNot(t *testing.T) {
+	t.Parallel()
+
+	runComposeTests(t, []composeTest{
+		{
+			testName:        "Deny requests that the AdmitFunc allows",
+			admitFunc:       Not(allowAll, "hostNetwork is not allowed"),
+			expectedMessage: "hostNetwork is not allowed",
+			shouldAllow:     false,
+		},
+		{testName: "Allow requests that the AdmitFunc denies", admitFunc: Not(denyWithMessage("uses the Pod network"), "hostNetwork is not allowed"), shouldAllow: true},
+		{
+			testName:        "Propagate errors",
+			admitFunc:       Not(failWithError("cache not synced"), "hostNetwork is not allowed"),
+			expectedMessage: "cache not synced",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Compose with AnyOf",
+			admitFunc:       AnyOf(Not(allowAll, "hostNetwork is not allowed"), denyWithMessage("not a system namespace")),
+			expectedMessage: "none of the AdmitFuncs allowed the request: hostNetwork is not allowed; not a system namespace",
+			shouldAllow:     false,
+		},
+	})
+}
+
+// denyWithViolation returns an AdmitFunc that denies every request on account
+// of a violation of the field.
+func denyWithViolation(field, message string) AdmitFunc {
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		resp := newDefaultDenyResponse()
+		var violations ViolationList
+		violations.Add(field, message)
+		return resp, violations.deny(resp, "Pod default/web is denied")
+	}
+}
+
+func TestCollectAll(t *testing.T) {
+	t.Parallel()
+
+	runComposeTests(t, []composeTest{
+		{testName: "Allow when every AdmitFunc allows", admitFunc: CollectAll(allowAll, allowAll), shouldAllow: true},
+		{testName: "Allow with no AdmitFuncs", admitFunc: CollectAll(), shouldAllow: true},
+		{
+			testName:        "Deny when any AdmitFunc denies, listing each reason",
+			admitFunc:       CollectAll(allowAll, denyWithMessage("no Ingresses"), denyWithViolation("spec.hostNetwork", "is not allowed")),
+			expectedMessage: "2 of 3 policies denied the request:\n- no Ingresses\n- Pod default/web is denied: spec.hostNetwork: is not allowed",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "List the AdmitFuncs that fail to evaluate the request as reasons",
+			admitFunc:       CollectAll(denyWithMessage("no Ingresses"), failWithError("cache not synced"), denyAll),
+			expectedMessage: "3 of 3 policies denied the request:\n- no Ingresses\n- cache not synced\n- denied",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Skip the AdmitFuncs that do not support the kind",
+			admitFunc:       CollectAll(denyWithMessage("no Ingresses"), unsupportedKindFunc),
+			expectedMessage: "1 of 1 policies denied the request:\n- no Ingresses",
+			shouldAllow:     false,
+		},
+	})
+
+	resp, _ := CollectAll(
+		denyWithViolation("spec.hostNetwork", "is not allowed"),
+		denyWithViolation("spec.containers[web].image", "is not pinned to a digest"),
+	)(newTestAdmissionRequest(meta.GroupVersionKind{Kind: "Pod", Version: "v1"}, nil, false))
+
+	expected := `[{"field":"spec.hostNetwork","message":"is not allowed"},{"field":"spec.containers[web].image","message":"is not pinned to a digest"}]`
+	if got := resp.AuditAnnotations[ViolationsAuditAnnotation]; got != expected {
+		t.Fatalf("expected the violations of each AdmitFunc to be combined: got %s", got)
+	}
+
+	review := newTestAdmissionRequest(meta.GroupVersionKind{Kind: "Service", Version: "v1"}, []byte(`{"kind":"Service","apiVersion":"v1"}`), false)
+	if _, err := CollectAll(RequireImageDigest(nil), ValidateResourceQuantities(nil))(review); !xerrors.Is(err, ErrUnsupportedKind) {
+		t.Fatalf("expected a kind that no AdmitFunc supports to be returned as unsupported: got %v", err)
+	}
+}
+
+// serveTestIngress serves an Ingress CREATE request with the AdmitFunc, via an
+// AdmissionHandler with the default UnknownKinds, and returns the response.
+func serveTestIngress(t *testing.T, admitFunc AdmitFunc) *admission.AdmissionResponse {
+	t.Helper()
+
+	review := &admission.AdmissionReview{Request: &admission.AdmissionRequest{
+		UID:       "mixed-kinds",
+		Kind:      meta.GroupVersionKind{Group: "networking.k8s.io", Kind: "Ingress", Version: "v1"},
+		Operation: admission.Create,
+	}}
+	review.Request.Object.Raw = []byte(`{"kind":"Ingress","apiVersion":"networking.k8s.io/v1","metadata":{"name":"web","namespace":"default"}}`)
+
+	handler := &AdmissionHandler{AdmitFunc: admitFunc, Logger: &noopLogger{}}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newTestReviewRequest(t, review))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: got %d (wanted %d)", rr.Code, http.StatusOK)
+	}
+
+	outgoingReview := &admission.AdmissionReview{}
+	if err := json.Unmarshal(rr.Body.Bytes(), outgoingReview); err != nil {
+		t.Fatalf("couldn't unmarshal the review response: %v", err)
+	}
+
+	return outgoingReview.Response
+}
+
+func TestAllOfAnyOfMixedKinds(t *testing.T) {
+	t.Parallel()
+
+	for name, admitFunc := range map[string]AdmitFunc{
+		"AllOf": AllOf(RequireImageDigest(nil), DenyIngresses(nil)),
+		"AnyOf": AnyOf(RequireImageDigest(nil), DenyIngresses(nil)),
+	} {
+		if resp := serveTestIngress(t, admitFunc); resp.Allowed || !strings.Contains(resp.Result.Message, "Ingress objects cannot be deployed") {
+			t.Fatalf("expected %s to deny the Ingress via DenyIngresses: %v", name, resp.Result)
+		}
+	}
+
+	for name, admitFunc := range map[string]AdmitFunc{
+		"AllOf": AllOf(RequireImageDigest(nil)),
+		"AnyOf": AnyOf(RequireImageDigest(nil), ValidateResourceQuantities(nil)),
+	} {
+		if resp := serveTestIngress(t, admitFunc); !resp.Allowed {
+			t.Fatalf("expected %s to allow a kind that no AdmitFunc supports by default: %v", name, resp.Result)
+		}
+	}
+}
+
+func TestCollectAllMixedKinds(t *testing.T) {
+	t.Parallel()
+
+	resp := serveTestIngress(t, CollectAll(RequireImageDigest(nil), DenyIngresses(nil)))
+	if resp.Allowed {
+		t.Fatalf("expected the Ingress to be denied by DenyIngresses: %v", resp.Result)
+	}
+
+	expected := "1 of 1 policies denied the request:\n- Ingress objects cannot be deployed"
+	if !strings.Contains(resp.Result.Message, expected) {
+		t.Fatalf(testErrMessageMismatch, resp.Result.Message, expected)
+	}
+
+	if resp := serveTestIngress(t, CollectAll(RequireImageDigest(nil), ValidateResourceQuantities(nil))); !resp.Allowed {
+		t.Fatalf("expected a kind that no AdmitFunc supports to be allowed by default: %v", resp.Result)
+	}
+}
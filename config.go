@@ -0,0 +1,189 @@
+package admissioncontrol
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	log "github.com/go-kit/kit/log"
+	"golang.org/x/xerrors"
+	"sigs.k8s.io/yaml"
+)
+
+// NamedHandler is an AdmissionHandler loaded from a policy config (see
+// LoadPoliciesFromConfig), and the name - a path relative to the prefix passed
+// to RegisterHandlers - that it should be mounted at.
+type NamedHandler struct {
+	// Name is the path of the handler, relative to the prefix it is mounted
+	// at: e.g. "deny-public-services/gcp".
+	Name string
+	// Policy is the name of the registered AdmitFunc that the handler
+	// evaluates: e.g. "deny-public-load-balancers".
+	Policy string
+	// Handler is the configured AdmissionHandler.
+	Handler *AdmissionHandler
+}
+
+// policyConfig is the (YAML or JSON) config read by LoadPoliciesFromConfig.
+type policyConfig struct {
+	Policies []policyEntry `json:"policies"`
+}
+
+// policyEntry configures a single handler of a policyConfig.
+type policyEntry struct {
+	Name          string                 `json:"name"`
+	Policy        string                 `json:"policy"`
+	Params        map[string]interface{} `json:"params"`
+	AuditOnly     bool                   `json:"auditOnly"`
+	Timeout       string                 `json:"timeout"`
+	TimeoutPolicy string                 `json:"timeoutPolicy"`
+	RecoverPanics bool                   `json:"recoverPanics"`
+	UnknownKinds  string                 `json:"unknownKinds"`
+}
+
+// LoadPoliciesFromConfig reads a (YAML or JSON) config describing the policies
+// to serve, and returns an AdmissionHandler for each of them, in the order of
+// the config. This allows the webhook to be run as an off-the-shelf server,
+// configured declaratively rather than in code, e.g.
+//
+//	policies:
+//	  - name: deny-public-services/gcp
+//	    policy: deny-public-load-balancers
+//	    params:
+//	      ignoredNamespaces: ["kube-system"]
+//	      provider: gcp
+//	  - name: require-image-digest
+//	    policy: require-image-digest
+//	    auditOnly: true
+//	    timeout: 2s
+//	    timeoutPolicy: FailOpen
+//
+// Each policy is built by BuildAdmitFunc from its (registered) name and its
+// params: see RegisteredAdmitFuncs. The handler of each policy can optionally
+// be configured with auditOnly, timeout (a duration, with a timeoutPolicy of
+// FailClosed or FailOpen), recoverPanics & unknownKinds (Allow, Deny or Error):
+// see AdmissionHandler.
+//
+// An error - identifying the invalid policy - is returned if the config cannot
+// be parsed, defines no policies, has unknown fields, or if a policy is
+// invalid: e.g. its name is not a unique, relative path, or its params are
+// rejected.
+//
+// The handlers log to a no-op logger: set the Logger of each handler (e.g. to
+// log.With(logger, "admit_func", name)) before mounting them with
+// RegisterHandlers.
+func LoadPoliciesFromConfig(r io.Reader) ([]NamedHandler, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, xerrors.Errorf("could not read the policy config: %w", err)
+	}
+
+	encoded, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, xerrors.Errorf("could not parse the policy config: %w", err)
+	}
+
+	var config policyConfig
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, xerrors.Errorf("could not parse the policy config: %w", err)
+	}
+
+	if len(config.Policies) == 0 {
+		return nil, xerrors.New("the policy config does not define any policies")
+	}
+
+	handlers := make([]NamedHandler, 0, len(config.Policies))
+	names := make(map[string]int, len(config.Policies))
+	for i, entry := range config.Policies {
+		if entry.Name == "" {
+			return nil, xerrors.Errorf("policies[%d]: a name must be provided", i)
+		}
+
+		if strings.HasPrefix(entry.Name, "/") || path.Clean(entry.Name) != entry.Name || entry.Name == "." || entry.Name == ".." || strings.HasPrefix(entry.Name, "../") {
+			return nil, xerrors.Errorf("policies[%d]: invalid name %q: the name must be a relative path, e.g. deny-public-services/gcp", i, entry.Name)
+		}
+
+		if j, ok := names[entry.Name]; ok {
+			return nil, xerrors.Errorf("policies[%d]: the name %q is already used by policies[%d]", i, entry.Name, j)
+		}
+		names[entry.Name] = i
+
+		handler, err := entry.handler()
+		if err != nil {
+			return nil, xerrors.Errorf("policies[%d] (%s): %w", i, entry.Name, err)
+		}
+
+		handlers = append(handlers, NamedHandler{
+			Name:    entry.Name,
+			Policy:  entry.Policy,
+			Handler: handler,
+		})
+	}
+
+	return handlers, nil
+}
+
+// handler builds the AdmitFunc of the policy, and its AdmissionHandler.
+func (entry policyEntry) handler() (*AdmissionHandler, error) {
+	if entry.Policy == "" {
+		return nil, xerrors.New("a policy must be provided")
+	}
+
+	admitFunc, err := BuildAdmitFunc(entry.Policy, entry.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []HandlerOption
+	if entry.AuditOnly {
+		opts = append(opts, WithAuditMode())
+	}
+
+	if entry.RecoverPanics {
+		opts = append(opts, WithPanicRecovery())
+	}
+
+	if entry.Timeout != "" {
+		timeout, err := time.ParseDuration(entry.Timeout)
+		if err != nil {
+			return nil, xerrors.Errorf("invalid timeout %q: %w", entry.Timeout, err)
+		}
+
+		var policy FailurePolicy
+		switch entry.TimeoutPolicy {
+		case "", FailClosed.String():
+			policy = FailClosed
+		case FailOpen.String():
+			policy = FailOpen
+		default:
+			return nil, xerrors.Errorf("invalid timeoutPolicy %q: must be %s or %s", entry.TimeoutPolicy, FailClosed, FailOpen)
+		}
+
+		opts = append(opts, WithTimeout(timeout, policy))
+	} else if entry.TimeoutPolicy != "" {
+		return nil, xerrors.New("a timeoutPolicy requires a timeout")
+	}
+
+	if entry.UnknownKinds != "" {
+		var behavior UnknownKindBehavior
+		switch entry.UnknownKinds {
+		case UnknownKindAllow.String():
+			behavior = UnknownKindAllow
+		case UnknownKindDeny.String():
+			behavior = UnknownKindDeny
+		case UnknownKindError.String():
+			behavior = UnknownKindError
+		default:
+			return nil, xerrors.Errorf("invalid unknownKinds %q: must be one of %s, %s or %s", entry.UnknownKinds, UnknownKindAllow, UnknownKindDeny, UnknownKindError)
+		}
+
+		opts = append(opts, WithUnknownKindBehavior(behavior))
+	}
+
+	return NewAdmissionHandler(admitFunc, log.NewNopLogger(), opts...)
+}
@@ -0,0 +1,151 @@
+package admissioncontrol
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPoliciesFromConfig(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Load policies from YAML", func(t *testing.T) {
+		handlers, err := LoadPoliciesFromConfig(strings.NewReader(`
+policies:
+  - name: deny-public-services/gcp
+    policy: deny-public-load-balancers
+    params:
+      ignoredNamespaces: ["kube-system"]
+      provider: gcp
+  - name: require-image-digest
+    policy: require-image-digest
+    auditOnly: true
+    timeout: 2s
+    timeoutPolicy: FailOpen
+    recoverPanics: true
+    unknownKinds: Deny
+`))
+		if err != nil {
+			t.Fatalf("failed to load the policies: %v", err)
+		}
+
+		if len(handlers) != 2 {
+			t.Fatalf("unexpected number of handlers: got %d (wanted %d)", len(handlers), 2)
+		}
+
+		if handlers[0].Name != "deny-public-services/gcp" || handlers[0].Policy != "deny-public-load-balancers" {
+			t.Fatalf("handlers are not in the order of the config: got %s (%s)", handlers[0].Name, handlers[0].Policy)
+		}
+
+		handler := handlers[1].Handler
+		if !handler.AuditOnly || !handler.RecoverPanics || handler.Timeout != time.Second*2 || handler.TimeoutPolicy != FailOpen || handler.UnknownKinds != UnknownKindDeny {
+			t.Fatalf("the handler was not configured from the config: got %+v", handler)
+		}
+
+		if handler.Logger == nil {
+			t.Fatalf("the handler must have a logger")
+		}
+	})
+
+	t.Run("Load policies from JSON", func(t *testing.T) {
+		handlers, err := LoadPoliciesFromConfig(strings.NewReader(`{"policies": [{"name": "deny-ingresses", "policy": "deny-ingresses"}]}`))
+		if err != nil {
+			t.Fatalf("failed to load the policies: %v", err)
+		}
+
+		if len(handlers) != 1 || handlers[0].Handler.AuditOnly || handlers[0].Handler.Timeout != 0 {
+			t.Fatalf("unexpected handlers: got %+v", handlers)
+		}
+	})
+
+	var errorTests = []struct {
+		testName        string
+		config          string
+		expectedMessage string
+	}{
+		{
+			testName:        "Reject configs that cannot be parsed",
+			config:          "policies: [",
+			expectedMessage: "could not parse the policy config: ",
+		},
+		{
+			testName:        "Reject unknown fields",
+			config:          "policies:\n  - name: deny-ingresses\n    policy: deny-ingresses\n    parameters: {}\n",
+			expectedMessage: `could not parse the policy config: json: unknown field "parameters"`,
+		},
+		{
+			testName:        "Reject configs without policies",
+			config:          "policies: []",
+			expectedMessage: "the policy config does not define any policies",
+		},
+		{
+			testName:        "Reject policies without a name",
+			config:          "policies:\n  - policy: deny-ingresses\n",
+			expectedMessage: "policies[0]: a name must be provided",
+		},
+		{
+			testName:        "Reject names that are not relative paths",
+			config:          "policies:\n  - name: /deny-ingresses\n    policy: deny-ingresses\n",
+			expectedMessage: `policies[0]: invalid name "/deny-ingresses": the name must be a relative path, e.g. deny-public-services/gcp`,
+		},
+		{
+			testName:        "Reject names that escape the prefix",
+			config:          "policies:\n  - name: ../deny-ingresses\n    policy: deny-ingresses\n",
+			expectedMessage: `policies[0]: invalid name "../deny-ingresses": the name must be a relative path, e.g. deny-public-services/gcp`,
+		},
+		{
+			testName:        "Reject duplicate names",
+			config:          "policies:\n  - name: deny\n    policy: deny-ingresses\n  - name: deny\n    policy: deny-latest-tag\n",
+			expectedMessage: `policies[1]: the name "deny" is already used by policies[0]`,
+		},
+		{
+			testName:        "Reject policies without a policy",
+			config:          "policies:\n  - name: deny-ingresses\n",
+			expectedMessage: "policies[0] (deny-ingresses): a policy must be provided",
+		},
+		{
+			testName:        "Reject unregistered policies",
+			config:          "policies:\n  - name: deny\n    policy: deny-everything\n",
+			expectedMessage: `policies[0] (deny): no AdmitFunc is registered as "deny-everything"`,
+		},
+		{
+			testName:        "Reject invalid params",
+			config:          "policies:\n  - name: lbs\n    policy: deny-public-load-balancers\n    params:\n      provider: on-prem\n",
+			expectedMessage: `policies[0] (lbs): invalid parameters for deny-public-load-balancers: invalid provider "on-prem": must be one of gcp, azure, aws or openstack`,
+		},
+		{
+			testName:        "Reject invalid timeouts",
+			config:          "policies:\n  - name: deny-ingresses\n    policy: deny-ingresses\n    timeout: -1s\n",
+			expectedMessage: "policies[0] (deny-ingresses): invalid timeout -1s: the timeout must be positive",
+		},
+		{
+			testName:        "Reject unknown timeout policies",
+			config:          "policies:\n  - name: deny-ingresses\n    policy: deny-ingresses\n    timeout: 1s\n    timeoutPolicy: Ignore\n",
+			expectedMessage: `policies[0] (deny-ingresses): invalid timeoutPolicy "Ignore": must be FailClosed or FailOpen`,
+		},
+		{
+			testName:        "Reject timeout policies without a timeout",
+			config:          "policies:\n  - name: deny-ingresses\n    policy: deny-ingresses\n    timeoutPolicy: FailOpen\n",
+			expectedMessage: "policies[0] (deny-ingresses): a timeoutPolicy requires a timeout",
+		},
+		{
+			testName:        "Reject unknown UnknownKindBehaviors",
+			config:          "policies:\n  - name: deny-ingresses\n    policy: deny-ingresses\n    unknownKinds: Ignore\n",
+			expectedMessage: `policies[0] (deny-ingresses): invalid unknownKinds "Ignore": must be one of Allow, Deny or Error`,
+		},
+	}
+
+	for _, tt := range errorTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			_, err := LoadPoliciesFromConfig(strings.NewReader(tt.config))
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+
+			if !strings.HasPrefix(err.Error(), tt.expectedMessage) {
+				t.Fatalf(testErrMessageMismatch, err.Error(), tt.expectedMessage)
+			}
+		})
+	}
+}
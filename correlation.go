@@ -0,0 +1,99 @@
+package admissioncontrol
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	admission "k8s.io/api/admission/v1"
+)
+
+const (
+	// CorrelationIDHeader is the HTTP header that carries the correlation ID
+	// of an admission request.
+	CorrelationIDHeader = "X-Correlation-ID"
+	// CorrelationIDAuditAnnotation is the audit annotation key under which the
+	// AdmissionHandler records the correlation ID of an admission request.
+	CorrelationIDAuditAnnotation = "correlation-id"
+	// maxCorrelationIDLength bounds the length of a client-provided
+	// correlation ID: longer IDs are replaced.
+	maxCorrelationIDLength = 128
+)
+
+// correlationIDKey is the context key for the correlation ID of a request.
+type correlationIDKey struct{}
+
+// CorrelationIDMiddleware assigns each request a correlation ID, which ties a
+// denial reported by a user (e.g. via kubectl) back to the webhook's logs. The
+// ID is read from the X-Correlation-ID header - or generated, if the header is
+// missing or invalid - stored in the request context (see CorrelationID), and
+// echoed in the X-Correlation-ID response header.
+//
+// The AdmissionHandler includes the correlation ID in its logs, in the message
+// of any denial, and in the correlation-id audit annotation. Place the
+// middleware before (outside) LoggingMiddleware so that both log the ID:
+//
+//	handler := admissioncontrol.CorrelationIDMiddleware()(admissioncontrol.LoggingMiddleware(logger)(r))
+//
+// AdmitFuncs are not passed the request context, and so cannot read the ID.
+func CorrelationIDMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(CorrelationIDHeader)
+			if !isValidCorrelationID(id) {
+				id = newCorrelationID()
+			}
+
+			w.Header().Set(CorrelationIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), correlationIDKey{}, id)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// CorrelationID returns the correlation ID stored in the context by
+// CorrelationIDMiddleware, or an empty string if there is none.
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// isValidCorrelationID returns true if the (client-provided) correlation ID is
+// non-empty, bounded in length, and made of printable ASCII characters - so
+// that it can be safely logged and echoed.
+func isValidCorrelationID(id string) bool {
+	if id == "" || len(id) > maxCorrelationIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newCorrelationID returns a random (128-bit, hex-encoded) correlation ID.
+func newCorrelationID() string {
+	id := make([]byte, 16)
+	// crypto/rand.Read does not fail on supported platforms.
+	rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+// recordCorrelationID records the correlation ID on the response: as an audit
+// annotation, and in the message of a denial.
+func recordCorrelationID(resp *admission.AdmissionResponse, id string) {
+	// The key is a valid, constant qualified name.
+	SetAuditAnnotation(resp, CorrelationIDAuditAnnotation, id)
+
+	if !resp.Allowed && resp.Result != nil {
+		resp.Result.Message = fmt.Sprintf("%s (correlation ID: %s)", resp.Result.Message, id)
+	}
+}
@@ -0,0 +1,81 @@
+package admissioncontrol
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	admission "k8s.io/api/admission/v1"
+)
+
+func TestCorrelationIDMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var correlationTests = []struct {
+		testName   string
+		header     string
+		shouldEcho bool
+	}{
+		{testName: "Echo a provided correlation ID", header: "kubectl-4242", shouldEcho: true},
+		{testName: "Generate a missing correlation ID", header: "", shouldEcho: false},
+		{testName: "Replace an invalid correlation ID", header: "bad id\n", shouldEcho: false},
+		{testName: "Replace an overlong correlation ID", header: strings.Repeat("a", 129), shouldEcho: false},
+	}
+
+	for _, tt := range correlationTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			var stored string
+			handler := CorrelationIDMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				stored = CorrelationID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(CorrelationIDHeader, tt.header)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			echoed := rr.Header().Get(CorrelationIDHeader)
+			if echoed == "" || echoed != stored {
+				t.Fatalf("the correlation ID was not stored & echoed: stored %q, echoed %q", stored, echoed)
+			}
+
+			if echoedProvided := echoed == tt.header; echoedProvided != tt.shouldEcho {
+				t.Fatalf("echoed %q for the header %q: expected the provided ID to be echoed=%t", echoed, tt.header, tt.shouldEcho)
+			}
+		})
+	}
+}
+
+func TestAdmissionHandlerCorrelationID(t *testing.T) {
+	t.Parallel()
+
+	handler := CorrelationIDMiddleware()(&AdmissionHandler{
+		AdmitFunc: newTestAdmitFunc(false, true),
+		Logger:    &noopLogger{},
+	})
+
+	req := newTestReviewRequest(t, &admission.AdmissionReview{
+		Request: &admission.AdmissionRequest{UID: "correlated"},
+	})
+	req.Header.Set(CorrelationIDHeader, "kubectl-4242")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	review := &admission.AdmissionReview{}
+	if err := json.Unmarshal(rr.Body.Bytes(), review); err != nil {
+		t.Fatalf("couldn't marshal the review response: %v", err)
+	}
+
+	if !strings.HasSuffix(review.Response.Result.Message, "(correlation ID: kubectl-4242)") {
+		t.Fatalf("the denial does not include the correlation ID: %q", review.Response.Result.Message)
+	}
+
+	if id := review.Response.AuditAnnotations[CorrelationIDAuditAnnotation]; id != "kubectl-4242" {
+		t.Fatalf("the correlation ID audit annotation = %q: expected %q", id, "kubectl-4242")
+	}
+}
@@ -0,0 +1,89 @@
+package admissioncontrol
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	admission "k8s.io/api/admission/v1"
+)
+
+// EnableDebugEcho enables the handler's DebugEchoHandler, which echoes the
+// decoded AdmissionReview of each request - as indented JSON - instead of
+// evaluating it, so that policy authors can see exactly what the API server
+// sent: e.g. by replaying a captured AdmissionReview with curl. It is never
+// enabled by default, and logs a warning when the handler is created.
+//
+// The option does not change how the AdmissionHandler itself answers requests:
+// the echo is only served by the DebugEchoHandler, which must be mounted on a
+// path of its own. As the webhook configuration of the API server refers to
+// the admission path, its requests are always evaluated.
+func EnableDebugEcho() HandlerOption {
+	return func(ah *AdmissionHandler) error {
+		ah.debugEcho = true
+		ah.Logger.Log(
+			"msg", "debug echo is enabled: AdmissionReviews sent to the DebugEchoHandler will be echoed rather than evaluated",
+		)
+		return nil
+	}
+}
+
+// DebugEchoHandler returns an http.Handler that decodes AdmissionReviews - as
+// the AdmissionHandler does - and echoes them, at the version of the request,
+// as indented JSON. Mount it on a separate path from the AdmissionHandler, e.g.
+//
+//	r.Handle("/debug/echo", handler.DebugEchoHandler()).Methods(http.MethodPost)
+//
+// Unless the AdmissionHandler was created with EnableDebugEcho, the returned
+// handler answers every request with a HTTP 404. Echoed requests are not
+// evaluated, and are not recorded: see WithDecisionRecorders.
+func (ah *AdmissionHandler) DebugEchoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ah.debugEcho {
+			http.Error(w, "the debug echo is not enabled: see EnableDebugEcho", http.StatusNotFound)
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, fmt.Sprintf("method %s is not allowed: AdmissionReviews must be POSTed", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, ah.limitBytes()))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("could not read the request body: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		review := &admission.AdmissionReview{}
+		_, gvk, err := ah.serializer().Decode(body, nil, review)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("decoding the AdmissionReview failed: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		if review.Request == nil {
+			http.Error(w, "no AdmissionRequest was found in the AdmissionReview", http.StatusBadRequest)
+			return
+		}
+
+		review.SetGroupVersionKind(*gvk)
+		res, err := json.MarshalIndent(review, "", "  ")
+		if err != nil {
+			http.Error(w, fmt.Sprintf("marshalling the AdmissionReview failed: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		ah.Logger.Log(
+			"msg", "echoed an AdmissionReview",
+			"uid", review.Request.UID,
+			"kind", review.Request.Kind.String(),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(append(res, '\n'))
+	})
+}
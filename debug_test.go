@@ -0,0 +1,97 @@
+package admissioncontrol
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	admission "k8s.io/api/admission/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestEnableDebugEcho(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewAdmissionHandler(DenyIngresses(nil), &noopLogger{}, EnableDebugEcho())
+	if err != nil {
+		t.Fatalf("failed to create the handler: %v", err)
+	}
+
+	newReview := func() *admission.AdmissionReview {
+		review := &admission.AdmissionReview{
+			Request: &admission.AdmissionRequest{
+				UID:       "echoed",
+				Kind:      meta.GroupVersionKind{Group: "networking.k8s.io", Kind: "Ingress", Version: "v1"},
+				Namespace: "default",
+				Name:      "web",
+				Operation: admission.Create,
+				Object:    runtime.RawExtension{Raw: []byte(`{"kind":"Ingress","apiVersion":"networking.k8s.io/v1","metadata":{"name":"web"}}`)},
+			},
+		}
+		review.SetGroupVersionKind(admission.SchemeGroupVersion.WithKind("AdmissionReview"))
+		return review
+	}
+
+	t.Run("Echo requests to the DebugEchoHandler", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handler.DebugEchoHandler().ServeHTTP(rr, newTestReviewRequest(t, newReview()))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("unexpected status code: got %d (wanted %d)", rr.Code, http.StatusOK)
+		}
+
+		echoed := admission.AdmissionReview{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &echoed); err != nil {
+			t.Fatalf("could not decode the echoed AdmissionReview: %v", err)
+		}
+
+		if echoed.Response != nil {
+			t.Fatalf("expected the request to be echoed rather than evaluated: got %+v", echoed.Response)
+		}
+
+		if echoed.APIVersion != "admission.k8s.io/v1" || echoed.Request.UID != "echoed" || !strings.Contains(string(echoed.Request.Object.Raw), `"networking.k8s.io/v1"`) {
+			t.Fatalf("the echo does not match the AdmissionReview: %s", rr.Body.String())
+		}
+
+		if !strings.Contains(rr.Body.String(), "\n  ") {
+			t.Fatalf("expected the echo to be indented: %s", rr.Body.String())
+		}
+	})
+
+	t.Run("Evaluate requests to the AdmissionHandler, including from loopback addresses", func(t *testing.T) {
+		for _, remoteAddr := range []string{"127.0.0.1:52044", "[::1]:52044", "10.0.0.1:52044"} {
+			req := newTestReviewRequest(t, newReview())
+			req.RemoteAddr = remoteAddr
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			resp := admission.AdmissionReview{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("could not decode the AdmissionReview: %v", err)
+			}
+
+			if resp.Response == nil || resp.Response.Allowed || resp.Request != nil {
+				t.Fatalf("expected the request from %s to be evaluated and denied: %s", remoteAddr, rr.Body.String())
+			}
+		}
+	})
+
+	t.Run("Reject requests to the DebugEchoHandler when the echo is not enabled", func(t *testing.T) {
+		handler, err := NewAdmissionHandler(DenyIngresses(nil), &noopLogger{})
+		if err != nil {
+			t.Fatalf("failed to create the handler: %v", err)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.DebugEchoHandler().ServeHTTP(rr, newTestReviewRequest(t, newReview()))
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("unexpected status code: got %d (wanted %d)", rr.Code, http.StatusNotFound)
+		}
+
+		if strings.Contains(rr.Body.String(), "echoed") {
+			t.Fatalf("expected the request not to be echoed: %s", rr.Body.String())
+		}
+	})
+}
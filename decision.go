@@ -0,0 +1,97 @@
+package admissioncontrol
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+	admission "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Decision describes how an AdmissionHandler answered an admission request.
+type Decision struct {
+	// UID identifies the admission request.
+	UID types.UID
+	// Kind is the kind of the object in the request.
+	Kind meta.GroupVersionKind
+	// Namespace & Name identify the object in the request. Namespace is empty
+	// for cluster-scoped objects.
+	Namespace string
+	Name      string
+	// Operation is the operation being performed - e.g. CREATE.
+	Operation admission.Operation
+	// UserInfo describes the user that made the request.
+	UserInfo authenticationv1.UserInfo
+	// Allowed reports whether the request was admitted.
+	Allowed bool
+	// Reason is the message returned with the response, if any: e.g. the
+	// reason for a denial.
+	Reason string
+	// Latency is how long the handler took to reach the decision.
+	Latency time.Duration
+}
+
+// DecisionRecorder records the decisions made by an AdmissionHandler: e.g. to
+// ship them to a message queue or an audit database for compliance.
+//
+// Record is called on the request path, after the response has been written,
+// and so should not block: implementations that talk to remote sinks should
+// buffer decisions and send them asynchronously. Record must be safe for
+// concurrent use.
+type DecisionRecorder interface {
+	Record(ctx context.Context, decision Decision)
+}
+
+// DecisionRecorderFunc is an adapter to allow the use of ordinary functions as
+// DecisionRecorders.
+type DecisionRecorderFunc func(ctx context.Context, decision Decision)
+
+// Record calls f(ctx, decision).
+func (f DecisionRecorderFunc) Record(ctx context.Context, decision Decision) {
+	f(ctx, decision)
+}
+
+// WithDecisionRecorders registers recorders to be invoked (in order) with each
+// decision made by the handler. See AdmissionHandler.Recorders.
+func WithDecisionRecorders(recorders ...DecisionRecorder) HandlerOption {
+	return func(ah *AdmissionHandler) error {
+		for _, recorder := range recorders {
+			if recorder == nil {
+				return xerrors.New("a DecisionRecorder must not be nil")
+			}
+		}
+
+		ah.Recorders = append(ah.Recorders, recorders...)
+		return nil
+	}
+}
+
+// recordDecision invokes each of the Recorders with the decision for the
+// request.
+func (ah *AdmissionHandler) recordDecision(ctx context.Context, req *admission.AdmissionRequest, resp *admission.AdmissionResponse, latency time.Duration) {
+	if len(ah.Recorders) == 0 {
+		return
+	}
+
+	decision := Decision{
+		UID:       req.UID,
+		Kind:      req.Kind,
+		Namespace: req.Namespace,
+		Name:      req.Name,
+		Operation: req.Operation,
+		UserInfo:  req.UserInfo,
+		Allowed:   resp.Allowed,
+		Latency:   latency,
+	}
+
+	if resp.Result != nil {
+		decision.Reason = resp.Result.Message
+	}
+
+	for _, recorder := range ah.Recorders {
+		recorder.Record(ctx, decision)
+	}
+}
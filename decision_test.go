@@ -0,0 +1,64 @@
+package admissioncontrol
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	admission "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDecisionRecorders(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var decisions []Decision
+	recorder := DecisionRecorderFunc(func(ctx context.Context, decision Decision) {
+		mu.Lock()
+		defer mu.Unlock()
+		decisions = append(decisions, decision)
+	})
+
+	handler, err := NewAdmissionHandler(DenyIngresses(nil), &noopLogger{}, WithDecisionRecorders(recorder, recorder))
+	if err != nil {
+		t.Fatalf("failed to create the handler: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newTestReviewRequest(t, &admission.AdmissionReview{
+		Request: &admission.AdmissionRequest{
+			UID:       "recorded",
+			Kind:      meta.GroupVersionKind{Group: "networking.k8s.io", Kind: "Ingress", Version: "v1"},
+			Namespace: "default",
+			Name:      "web",
+			Operation: admission.Create,
+			UserInfo:  authenticationv1.UserInfo{Username: "jane"},
+		},
+	}))
+
+	if len(decisions) != 2 {
+		t.Fatalf("got %d decisions: expected each recorder to record 1", len(decisions))
+	}
+
+	decision := decisions[0]
+	if decision.UID != "recorded" || decision.Namespace != "default" || decision.Name != "web" ||
+		decision.Operation != admission.Create || decision.Kind.Kind != "Ingress" || decision.UserInfo.Username != "jane" {
+		t.Fatalf("the decision does not describe the request: %+v", decision)
+	}
+
+	if decision.Allowed || !strings.Contains(decision.Reason, "Ingress objects cannot be deployed") {
+		t.Fatalf("the decision does not describe the denial: %+v", decision)
+	}
+
+	if decision.Latency <= 0 {
+		t.Fatalf("the decision has no latency: %+v", decision)
+	}
+
+	if _, err := NewAdmissionHandler(DenyIngresses(nil), &noopLogger{}, WithDecisionRecorders(nil)); err == nil {
+		t.Fatalf("expected an error for a nil DecisionRecorder")
+	}
+}
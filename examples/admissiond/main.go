@@ -22,6 +22,7 @@ type conf struct {
 	HTTPOnly    bool
 	Port        string
 	Host        string
+	ConfigPath  string
 }
 
 func main() {
@@ -34,6 +35,7 @@ func main() {
 	flag.BoolVar(&conf.HTTPOnly, "http-only", false, "Only listen on unencrypted HTTP (e.g. for proxied environments)")
 	flag.StringVar(&conf.Port, "port", "8443", "The port to listen on (HTTPS).")
 	flag.StringVar(&conf.Host, "host", "admissiond.questionable.services", "The hostname for the service")
+	flag.StringVar(&conf.ConfigPath, "config", "", "The path to a (YAML or JSON) config of the policies to serve, instead of the example policies")
 	flag.Parse()
 
 	// Set up logging
@@ -64,38 +66,34 @@ func main() {
 	// Default health-check endpoint
 	r.HandleFunc("/healthz", healthCheckHandler).Methods(http.MethodGet)
 
-	// Example admission handler endpoints
-	admissions := r.PathPrefix("/admission-control").Subrouter()
-	admissions.Handle("/deny-ingresses", &admissioncontrol.AdmissionHandler{
-		AdmitFunc: admissioncontrol.DenyIngresses(nil),
-		Logger:    logger,
-	}).Methods(http.MethodPost)
-	admissions.Handle("/deny-public-services/gcp", &admissioncontrol.AdmissionHandler{
-		// nil = don't whitelist any namespace.
-		AdmitFunc: admissioncontrol.DenyPublicLoadBalancers(nil, admissioncontrol.GCP),
-		Logger:    logger,
-	}).Methods(http.MethodPost)
-	admissions.Handle("/deny-public-services/azure", &admissioncontrol.AdmissionHandler{
-		AdmitFunc: admissioncontrol.DenyPublicLoadBalancers(nil, admissioncontrol.Azure),
-		Logger:    logger,
-	}).Methods(http.MethodPost)
-	admissions.Handle("/deny-public-services/aws", &admissioncontrol.AdmissionHandler{
-		AdmitFunc: admissioncontrol.DenyPublicLoadBalancers(nil, admissioncontrol.AWS),
-		Logger:    logger,
-	}).Methods(http.MethodPost)
-	admissions.Handle("/enforce-pod-annotations", &admissioncontrol.AdmissionHandler{
-		AdmitFunc: admissioncontrol.EnforcePodAnnotations(
-			[]string{"kube-system"},
-			map[string]func(string) bool{
-				"k8s.questionable.services/hostname": func(string) bool { return true },
-			}),
-		Logger: logger,
-	}).Methods(http.MethodPost)
+	// Example admission handler endpoints, or those of the policy config.
+	var paths []string
+	if conf.ConfigPath != "" {
+		handlers, err := loadPolicies(conf.ConfigPath, logger)
+		if err != nil {
+			fatal(logger, err)
+			return
+		}
+		paths = admissioncontrol.RegisterHandlers(r, "/admission-control", handlers)
+	} else {
+		paths = admissioncontrol.RegisterAdmitFuncs(r, "/admission-control", logger, map[string]admissioncontrol.AdmitFunc{
+			"deny-ingresses": admissioncontrol.DenyIngresses(nil),
+			// nil = don't whitelist any namespace.
+			"deny-public-services/gcp":   admissioncontrol.DenyPublicLoadBalancers(nil, admissioncontrol.GCP),
+			"deny-public-services/azure": admissioncontrol.DenyPublicLoadBalancers(nil, admissioncontrol.Azure),
+			"deny-public-services/aws":   admissioncontrol.DenyPublicLoadBalancers(nil, admissioncontrol.AWS),
+			"enforce-pod-annotations": admissioncontrol.EnforcePodAnnotations(
+				[]string{"kube-system"},
+				map[string]func(string) bool{
+					"k8s.questionable.services/hostname": func(string) bool { return true },
+				}),
+		})
+	}
 
 	// HTTP server
 	timeout := time.Second * 15
 	srv := &http.Server{
-		Handler:           admissioncontrol.LoggingMiddleware(logger)(r),
+		Handler:           admissioncontrol.CorrelationIDMiddleware()(admissioncontrol.LoggingMiddleware(logger)(r)),
 		TLSConfig:         tlsConf,
 		Addr:              ":" + conf.Port,
 		IdleTimeout:       timeout,
@@ -107,6 +105,8 @@ func main() {
 	admissionServer, err := admissioncontrol.NewServer(
 		srv,
 		log.With(logger, "component", "server"),
+		// Help debug webhook configurations that call the wrong path.
+		admissioncontrol.WithNotFoundHandler(r, paths...),
 	)
 	if err != nil {
 		fatal(logger, err)
@@ -129,6 +129,29 @@ func fatal(logger log.Logger, err error) {
 	return
 }
 
+// loadPolicies loads the AdmissionHandlers of the policy config at path, each
+// logging to the provided logger.
+func loadPolicies(path string, logger log.Logger) (map[string]*admissioncontrol.AdmissionHandler, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	loaded, err := admissioncontrol.LoadPoliciesFromConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the policies from %s: %w", path, err)
+	}
+
+	handlers := make(map[string]*admissioncontrol.AdmissionHandler, len(loaded))
+	for _, nh := range loaded {
+		nh.Handler.Logger = log.With(logger, "admit_func", nh.Name, "policy", nh.Policy)
+		handlers[nh.Name] = nh.Handler
+	}
+
+	return handlers, nil
+}
+
 // healthCheckHandler returns a HTTP 200, everytime.
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
@@ -1,23 +1,75 @@
 package admissioncontrol
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
-	"io"
-	"io/ioutil"
+	"mime"
 	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/xerrors"
 
 	admission "k8s.io/api/admission/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/runtime/serializer"
+	k8sjson "k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/apimachinery/pkg/util/validation"
 
 	log "github.com/go-kit/kit/log"
 )
 
+// defaultLimitBytes is the default maximum size of an admission request body.
+// An AdmissionReview can carry both the object and its previous version
+// (oldObject), each of which can be up to ~1.5MB when stored in etcd.
+const defaultLimitBytes = 6 * 1024 * 1024 // 6MB
+
+// maxPooledBufferBytes is the largest request buffer that is returned to the
+// pool, so that an occasional large request does not pin its buffer in memory.
+const maxPooledBufferBytes = 1024 * 1024 // 1MB
+
+// bodyBufferPool holds the buffers that request bodies are read into, and
+// reviewPool the AdmissionReviews that they are decoded into, to reduce the
+// allocations made for each admission request. Pooled objects are reset before
+// they are reused, so that no data from one request is visible to another.
+var (
+	bodyBufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+	reviewPool     = sync.Pool{New: func() interface{} { return new(admission.AdmissionReview) }}
+)
+
+// getBodyBuffer returns an empty buffer from the pool.
+func getBodyBuffer() *bytes.Buffer {
+	buf := bodyBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBodyBuffer returns the buffer to the pool, unless it is too large.
+func putBodyBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferBytes {
+		return
+	}
+
+	buf.Reset()
+	bodyBufferPool.Put(buf)
+}
+
+// getReview returns an empty AdmissionReview from the pool.
+func getReview() *admission.AdmissionReview {
+	review := reviewPool.Get().(*admission.AdmissionReview)
+	*review = admission.AdmissionReview{}
+	return review
+}
+
+// putReview clears the AdmissionReview, and returns it to the pool.
+func putReview(review *admission.AdmissionReview) {
+	*review = admission.AdmissionReview{}
+	reviewPool.Put(review)
+}
+
 // AdmitFunc is a type for building Kubernetes admission webhooks. An AdmitFunc
 // should check whether an admission request is valid, and shall return an
 // admission response that sets AdmissionResponse.Allowed to true or false as
@@ -26,10 +78,39 @@ import (
 // Users wishing to build their own admission handlers should satisfy the
 // AdmitFunc type, and pass it to an AdmissionHandler for serving over HTTP.
 //
+// An AdmitFunc must not retain the AdmissionReview (or the objects it refers
+// to) once it returns: the AdmissionHandler reuses it for subsequent requests.
+//
 // Note: this mirrors the type in k8s source:
 // https://github.com/kubernetes/kubernetes/blob/v1.13.0/test/images/webhook/main.go#L43-L44
 type AdmitFunc func(reviewRequest *admission.AdmissionReview) (*admission.AdmissionResponse, error)
 
+// admissionScheme registers the AdmissionReview at each version served by the
+// AdmissionHandler. The admission.k8s.io v1 & v1beta1 AdmissionReviews are
+// structurally identical, and so both are decoded into (and encoded from) the
+// v1 type.
+var admissionScheme = newAdmissionScheme()
+
+// defaultAdmissionSerializer is shared by AdmissionHandlers without a
+// Serializer: serializers are safe for concurrent use.
+var defaultAdmissionSerializer = NewAdmissionSerializer()
+
+func newAdmissionScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(admission.SchemeGroupVersion, &admission.AdmissionReview{})
+	scheme.AddKnownTypeWithName(admissionv1beta1.SchemeGroupVersion.WithKind("AdmissionReview"), &admission.AdmissionReview{})
+
+	return scheme
+}
+
+// NewAdmissionSerializer returns the default Serializer of an
+// AdmissionHandler: a JSON serializer for admission.k8s.io/v1 and
+// admission.k8s.io/v1beta1 AdmissionReviews. Requests without an apiVersion
+// & kind are decoded as admission.k8s.io/v1.
+func NewAdmissionSerializer() runtime.Serializer {
+	return k8sjson.NewSerializerWithOptions(k8sjson.DefaultMetaFactory, admissionScheme, admissionScheme, k8sjson.SerializerOptions{})
+}
+
 // AdmissionHandler represents the configuration & associated endpoint for an
 // k8s ValidatingAdmissionController (or MutatingAdmissionController) webhook.
 //
@@ -40,29 +121,197 @@ type AdmissionHandler struct {
 	AdmitFunc AdmitFunc
 	// A kitlog.Logger compatible interface
 	Logger log.Logger
-	// LimitBytes limits the size of objects the webhook will handle.
+	// LimitBytes limits the size of the request bodies the webhook will handle;
+	// larger requests are rejected without being evaluated. Defaults to 6MB if
+	// left unset.
 	LimitBytes int64
-	// deserializer supports deserializing k8s objects. It can be left null; the
-	// ServeHTTP function will lazily instantiate a decoder instance.
-	deserializer runtime.Decoder
+	// MaxInFlight limits the number of admission requests that can be evaluated
+	// by the AdmitFunc concurrently. Requests beyond this limit are shed, and
+	// answered according to the ShedPolicy. A value <= 0 disables load shedding.
+	// AdmitFuncs that time out (see Timeout) count towards the limit until they
+	// return.
+	MaxInFlight int
+	// ShedPolicy determines whether shed requests are allowed (FailOpen) or
+	// denied (FailClosed). Defaults to FailClosed.
+	ShedPolicy FailurePolicy
+	// inFlight is a semaphore bounding the number of concurrent AdmitFunc
+	// calls to MaxInFlight.
+	inFlight     chan struct{}
+	inFlightOnce sync.Once
+	// Timeout bounds how long the AdmitFunc may evaluate a request: requests
+	// that are not evaluated in time are answered according to the
+	// TimeoutPolicy. As an AdmitFunc cannot be cancelled, it continues to run
+	// (in the background) until it returns. A value <= 0 disables the timeout.
+	Timeout time.Duration
+	// TimeoutPolicy determines whether timed out requests are allowed
+	// (FailOpen) or denied (FailClosed). Defaults to FailClosed.
+	TimeoutPolicy FailurePolicy
+	// AuditOnly allows the requests that the AdmitFunc denies, returning the
+	// denial to the client (e.g. kubectl) as a warning and logging it. This
+	// allows a new policy to be rolled out without blocking admission.
+	AuditOnly bool
+	// RecoverPanics recovers from panics in the AdmitFunc, denying the request
+	// rather than dropping the connection (which the API server answers
+	// according to the webhook's failurePolicy).
+	RecoverPanics bool
+	// UnknownKinds determines how requests for object kinds that the AdmitFunc
+	// does not support (see ErrUnsupportedKind) are answered: e.g. when the
+	// webhook configuration sends it kinds that it was not written for.
+	// Defaults to UnknownKindAllow.
+	UnknownKinds UnknownKindBehavior
+	// Serializer decodes the AdmissionReview requests, and encodes the
+	// responses, which are returned at the version of the request. Defaults to
+	// NewAdmissionSerializer if left unset. A custom Serializer must decode both
+	// versions of the AdmissionReview into the admission.k8s.io/v1 type.
+	Serializer runtime.Serializer
+	// Recorders are invoked (in order) with the Decision for each admission
+	// request that was decoded, after the response has been written.
+	Recorders []DecisionRecorder
+	// ReadinessCheckers report whether the dependencies of the AdmitFunc - e.g.
+	// its informer caches - are ready. See Ready and ReadinessHandler.
+	ReadinessCheckers []ReadinessChecker
+	// debugEcho enables the DebugEchoHandler. It is unexported so that it can
+	// only be enabled via EnableDebugEcho.
+	debugEcho bool
+}
+
+// HandlerOption configures an AdmissionHandler created by NewAdmissionHandler.
+type HandlerOption func(*AdmissionHandler) error
+
+// WithTimeout bounds how long the AdmitFunc may evaluate a request, answering
+// timed out requests according to the policy. See AdmissionHandler.Timeout.
+func WithTimeout(timeout time.Duration, policy FailurePolicy) HandlerOption {
+	return func(ah *AdmissionHandler) error {
+		if timeout <= 0 {
+			return xerrors.Errorf("invalid timeout %s: the timeout must be positive", timeout)
+		}
+
+		ah.Timeout = timeout
+		ah.TimeoutPolicy = policy
+		return nil
+	}
+}
+
+// WithAuditMode allows the requests that the AdmitFunc denies, returning the
+// denials as warnings. See AdmissionHandler.AuditOnly.
+func WithAuditMode() HandlerOption {
+	return func(ah *AdmissionHandler) error {
+		ah.AuditOnly = true
+		return nil
+	}
+}
+
+// WithLimitBytes limits the size of the request bodies the handler will
+// handle. See AdmissionHandler.LimitBytes.
+func WithLimitBytes(limitBytes int64) HandlerOption {
+	return func(ah *AdmissionHandler) error {
+		if limitBytes <= 0 {
+			return xerrors.Errorf("invalid limit of %d bytes: the limit must be positive", limitBytes)
+		}
+
+		ah.LimitBytes = limitBytes
+		return nil
+	}
+}
+
+// WithMaxInFlight limits the number of admission requests that are evaluated
+// concurrently, answering shed requests according to the policy. See
+// AdmissionHandler.MaxInFlight.
+func WithMaxInFlight(maxInFlight int, policy FailurePolicy) HandlerOption {
+	return func(ah *AdmissionHandler) error {
+		if maxInFlight <= 0 {
+			return xerrors.Errorf("invalid maximum of %d in-flight requests: the maximum must be positive", maxInFlight)
+		}
+
+		ah.MaxInFlight = maxInFlight
+		ah.ShedPolicy = policy
+		return nil
+	}
+}
+
+// WithPanicRecovery denies requests for which the AdmitFunc panics. See
+// AdmissionHandler.RecoverPanics.
+func WithPanicRecovery() HandlerOption {
+	return func(ah *AdmissionHandler) error {
+		ah.RecoverPanics = true
+		return nil
+	}
+}
+
+// WithUnknownKindBehavior sets how requests for object kinds that the
+// AdmitFunc does not support are answered. See AdmissionHandler.UnknownKinds.
+func WithUnknownKindBehavior(behavior UnknownKindBehavior) HandlerOption {
+	return func(ah *AdmissionHandler) error {
+		if behavior < UnknownKindAllow || behavior > UnknownKindError {
+			return xerrors.Errorf("invalid UnknownKindBehavior: %s", behavior)
+		}
+
+		ah.UnknownKinds = behavior
+		return nil
+	}
+}
+
+// NewAdmissionHandler returns an AdmissionHandler for the AdmitFunc, configured
+// with the provided options. An error is returned if the AdmitFunc or logger
+// are nil, or if any of the options are invalid.
+func NewAdmissionHandler(admitFunc AdmitFunc, logger log.Logger, opts ...HandlerOption) (*AdmissionHandler, error) {
+	if admitFunc == nil {
+		return nil, xerrors.New("an AdmitFunc must be provided")
+	}
+
+	if logger == nil {
+		return nil, xerrors.New("a Logger must be provided")
+	}
+
+	ah := &AdmissionHandler{
+		AdmitFunc: admitFunc,
+		Logger:    logger,
+	}
+
+	for _, opt := range opts {
+		if err := opt(ah); err != nil {
+			return nil, err
+		}
+	}
+
+	return ah, nil
 }
 
 func (ah *AdmissionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if ah.deserializer == nil {
-		runtimeScheme := runtime.NewScheme()
-		ah.deserializer = serializer.NewCodecFactory(runtimeScheme).UniversalDeserializer()
+	logger := ah.Logger
+	correlationID := CorrelationID(r.Context())
+	if correlationID != "" {
+		logger = log.With(logger, "correlation_id", correlationID)
 	}
 
-	if ah.LimitBytes <= 0 {
-		ah.LimitBytes = 1024 * 1024 * 1024 // 1MB
+	// Fail fast on requests that cannot be an AdmissionReview sent by the API
+	// server: e.g. a misconfigured webhook client.
+	if r.Method != http.MethodPost {
+		logger.Log(
+			"msg", "rejected a non-POST admission request",
+			"method", r.Method,
+		)
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, fmt.Sprintf("method %s is not allowed: admission requests must be POSTed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		logger.Log(
+			"msg", "rejected a non-JSON admission request",
+			"content_type", r.Header.Get("Content-Type"),
+		)
+		http.Error(w, fmt.Sprintf("unsupported Content-Type %q: admission requests must be application/json", r.Header.Get("Content-Type")), http.StatusUnsupportedMediaType)
+		return
 	}
 
 	outgoingReview := &admission.AdmissionReview{
 		Response: &admission.AdmissionResponse{},
 	}
+	outgoingReview.SetGroupVersionKind(admission.SchemeGroupVersion.WithKind("AdmissionReview"))
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := ah.handleAdmissionRequest(w, r); err != nil {
+	if err := ah.handleAdmissionRequest(w, r, logger); err != nil {
 		outgoingReview.Response.Allowed = false
 		outgoingReview.Response.Result = &meta.Status{
 			Message: err.Error(),
@@ -70,17 +319,17 @@ func (ah *AdmissionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 		admissionErr, ok := err.(AdmissionError)
 		if ok {
-			ah.Logger.Log(
+			logger.Log(
 				"msg", admissionErr.Message,
 				"debug", admissionErr.Debug,
 			)
 			outgoingReview.Response.Allowed = admissionErr.Allowed
 		}
 
-		res, err := json.Marshal(outgoingReview)
+		res, err := runtime.Encode(ah.serializer(), outgoingReview)
 		if err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
-			ah.Logger.Log(
+			logger.Log(
 				"err", err.Error(),
 				"msg", "failed to marshal review response",
 			)
@@ -93,6 +342,58 @@ func (ah *AdmissionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// FailurePolicy determines how an admission request is answered when it cannot
+// be evaluated: e.g. when the handler is overloaded, or a dependency is
+// unavailable.
+type FailurePolicy int
+
+const (
+	// FailClosed denies admission when a request cannot be evaluated.
+	FailClosed FailurePolicy = iota
+	// FailOpen allows admission when a request cannot be evaluated.
+	FailOpen
+)
+
+func (fp FailurePolicy) String() string {
+	switch fp {
+	case FailClosed:
+		return "FailClosed"
+	case FailOpen:
+		return "FailOpen"
+	default:
+		return fmt.Sprintf("FailurePolicy(%d)", int(fp))
+	}
+}
+
+// UnknownKindBehavior determines how an AdmissionHandler answers requests for
+// object kinds that its AdmitFunc does not support.
+type UnknownKindBehavior int
+
+const (
+	// UnknownKindAllow allows requests for unsupported kinds, which the
+	// AdmitFunc does not evaluate.
+	UnknownKindAllow UnknownKindBehavior = iota
+	// UnknownKindDeny denies requests for unsupported kinds.
+	UnknownKindDeny
+	// UnknownKindError fails requests for unsupported kinds with a HTTP 500,
+	// so that the API server answers them according to the failurePolicy of
+	// the webhook - as it does for other webhook errors.
+	UnknownKindError
+)
+
+func (b UnknownKindBehavior) String() string {
+	switch b {
+	case UnknownKindAllow:
+		return "Allow"
+	case UnknownKindDeny:
+		return "Deny"
+	case UnknownKindError:
+		return "Error"
+	default:
+		return fmt.Sprintf("UnknownKindBehavior(%d)", int(b))
+	}
+}
+
 // AdmissionError represents an error (rejection, serialization error, etc) from
 // an AdmissionHandler endpoint/handler.
 type AdmissionError struct {
@@ -105,14 +406,31 @@ func (e AdmissionError) Error() string {
 	return fmt.Sprintf("admission error: %s (allowed: %t)", e.Message, e.Allowed)
 }
 
-func (ah *AdmissionHandler) handleAdmissionRequest(w http.ResponseWriter, r *http.Request) error {
-	limitReader := io.LimitReader(r.Body, ah.LimitBytes)
-	body, err := ioutil.ReadAll(limitReader)
-	if err != nil {
+func (ah *AdmissionHandler) handleAdmissionRequest(w http.ResponseWriter, r *http.Request, logger log.Logger) error {
+	start := time.Now()
+	limitBytes := ah.limitBytes()
+
+	buf := getBodyBuffer()
+	defer putBodyBuffer(buf)
+	if r.ContentLength > 0 && r.ContentLength <= limitBytes {
+		buf.Grow(int(r.ContentLength))
+	}
+
+	if _, err := buf.ReadFrom(http.MaxBytesReader(w, r.Body, limitBytes)); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if xerrors.As(err, &maxBytesErr) {
+			return AdmissionError{
+				false,
+				fmt.Sprintf("the request body exceeds the maximum size of %d bytes", maxBytesErr.Limit),
+				err.Error(),
+			}
+		}
+
 		return AdmissionError{false, "could not read the request body", err.Error()}
 	}
 
-	if body == nil || len(body) == 0 {
+	body := buf.Bytes()
+	if len(body) == 0 {
 		return AdmissionError{
 			false,
 			"no request body was received",
@@ -120,9 +438,19 @@ func (ah *AdmissionHandler) handleAdmissionRequest(w http.ResponseWriter, r *htt
 		}
 	}
 
-	incomingReview := admission.AdmissionReview{}
-	var gvk *schema.GroupVersionKind
-	_, gvk, err = ah.deserializer.Decode(body, nil, &incomingReview)
+	// The decoded review does not refer to the body: its objects are copied
+	// out of the buffer.
+	incomingReview := getReview()
+	recycle := true
+	defer func() {
+		// A review that is still being evaluated by a timed out AdmitFunc
+		// cannot be reused.
+		if recycle {
+			putReview(incomingReview)
+		}
+	}()
+
+	_, gvk, err := ah.serializer().Decode(body, nil, incomingReview)
 	if err != nil {
 		return AdmissionError{false, "decoding the review request failed", err.Error()}
 	}
@@ -131,29 +459,295 @@ func (ah *AdmissionHandler) handleAdmissionRequest(w http.ResponseWriter, r *htt
 		return xerrors.New("received invalid request: no AdmissionReview was found")
 	}
 
-	reviewResponse, err := ah.AdmitFunc(&incomingReview)
-	if err != nil {
-		return AdmissionError{false, err.Error(), "the AdmitFunc returned an error"}
+	var reviewResponse *admission.AdmissionResponse
+	if ah.acquireInFlight() {
+		var timedOut bool
+		reviewResponse, timedOut, err = ah.admit(incomingReview, logger)
+		recycle = !timedOut
+		if xerrors.Is(err, ErrUnsupportedKind) {
+			logger.Log(
+				"msg", "the AdmitFunc does not support the kind of the request",
+				"kind", incomingReview.Request.Kind.String(),
+				"behavior", ah.UnknownKinds,
+				"uid", incomingReview.Request.UID,
+			)
+
+			switch ah.UnknownKinds {
+			case UnknownKindAllow:
+				reviewResponse = &admission.AdmissionResponse{
+					Allowed: true,
+					Result: &meta.Status{
+						Message: fmt.Sprintf("allowing admission: %s", err),
+					},
+				}
+				err = nil
+			case UnknownKindError:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return nil
+			}
+		}
+
+		if err != nil {
+			reviewResponse = ah.denialResponse(reviewResponse, err, logger)
+		}
+
+		if ah.AuditOnly && reviewResponse != nil && !reviewResponse.Allowed {
+			reviewResponse = ah.auditOnlyResponse(reviewResponse, logger)
+		}
+	} else {
+		reviewResponse = ah.shedResponse(incomingReview, logger)
 	}
 
 	if reviewResponse == nil {
 		return AdmissionError{false, "the AdmitFunc returned an empty AdmissionReview", ""}
 	}
 
+	if correlationID := CorrelationID(r.Context()); correlationID != "" {
+		recordCorrelationID(reviewResponse, correlationID)
+	}
+
 	reviewResponse.UID = incomingReview.Request.UID
 	review := admission.AdmissionReview{
 		Response: reviewResponse,
 	}
 	review.SetGroupVersionKind(*gvk)
 
-	res, err := json.Marshal(&review)
-
+	res, err := runtime.Encode(ah.serializer(), &review)
 	if err != nil {
 		return AdmissionError{false, "marshalling the review response failed", err.Error()}
 	}
 
+	latency := time.Since(start)
 	w.WriteHeader(http.StatusOK)
 	w.Write(res)
 
+	ah.recordDecision(r.Context(), incomingReview.Request, reviewResponse, latency)
+	return nil
+}
+
+// limitBytes returns the configured LimitBytes, or the default if unset.
+func (ah *AdmissionHandler) limitBytes() int64 {
+	if ah.LimitBytes <= 0 {
+		return defaultLimitBytes
+	}
+
+	return ah.LimitBytes
+}
+
+// serializer returns the configured Serializer, or the default
+// (NewAdmissionSerializer) if unset.
+func (ah *AdmissionHandler) serializer() runtime.Serializer {
+	if ah.Serializer == nil {
+		return defaultAdmissionSerializer
+	}
+
+	return ah.Serializer
+}
+
+// admitResult is the outcome of evaluating an AdmitFunc.
+type admitResult struct {
+	resp *admission.AdmissionResponse
+	err  error
+	// panicked holds the recovered value (and the stack) if the AdmitFunc
+	// panicked.
+	panicked interface{}
+	stack    []byte
+}
+
+// admit evaluates the AdmitFunc, bounded by the Timeout. It reports whether
+// the AdmitFunc timed out, in which case it continues to evaluate the review in
+// the background.
+//
+// The in-flight slot reserved by acquireInFlight is released once the
+// AdmitFunc returns - rather than when the request is answered - so that timed
+// out AdmitFuncs still count towards MaxInFlight.
+func (ah *AdmissionHandler) admit(review *admission.AdmissionReview, logger log.Logger) (*admission.AdmissionResponse, bool, error) {
+	if ah.Timeout <= 0 {
+		result := ah.evaluate(review)
+		ah.releaseInFlight()
+		resp, err := ah.handleResult(review, result, logger)
+		return resp, false, err
+	}
+
+	done := make(chan admitResult, 1)
+	go func() {
+		defer ah.releaseInFlight()
+		done <- ah.evaluate(review)
+	}()
+
+	timer := time.NewTimer(ah.Timeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-done:
+		resp, err := ah.handleResult(review, result, logger)
+		return resp, false, err
+	case <-timer.C:
+		allowed := ah.TimeoutPolicy == FailOpen
+		logger.Log(
+			"msg", "admission request timed out: the AdmitFunc did not return in time",
+			"timeout", ah.Timeout,
+			"policy", ah.TimeoutPolicy,
+			"allowed", allowed,
+			"uid", review.Request.UID,
+		)
+
+		return &admission.AdmissionResponse{
+			Allowed: allowed,
+			Result: &meta.Status{
+				Message: fmt.Sprintf("the admission webhook timed out after %s: request was not evaluated (%s)", ah.Timeout, ah.TimeoutPolicy),
+			},
+		}, true, nil
+	}
+}
+
+// evaluate calls the AdmitFunc, recovering from (and recording) any panic.
+func (ah *AdmissionHandler) evaluate(review *admission.AdmissionReview) (result admitResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = admitResult{panicked: r, stack: debug.Stack()}
+		}
+	}()
+
+	resp, err := ah.AdmitFunc(review)
+	return admitResult{resp: resp, err: err}
+}
+
+// handleResult returns the outcome of the AdmitFunc. Panics are denied if
+// RecoverPanics is set, and re-raised (on the calling goroutine) otherwise.
+func (ah *AdmissionHandler) handleResult(review *admission.AdmissionReview, result admitResult, logger log.Logger) (*admission.AdmissionResponse, error) {
+	if result.panicked == nil {
+		return result.resp, result.err
+	}
+
+	if !ah.RecoverPanics {
+		panic(result.panicked)
+	}
+
+	logger.Log(
+		"msg", "recovered from a panic in the AdmitFunc",
+		"panic", fmt.Sprint(result.panicked),
+		"stack", string(result.stack),
+		"uid", review.Request.UID,
+	)
+
+	return nil, xerrors.Errorf("the AdmitFunc failed to evaluate the request: %v", result.panicked)
+}
+
+// auditOnlyResponse allows a denied request, returning the denial as a warning.
+func (ah *AdmissionHandler) auditOnlyResponse(resp *admission.AdmissionResponse, logger log.Logger) *admission.AdmissionResponse {
+	var message string
+	if resp.Result != nil {
+		message = resp.Result.Message
+	}
+
+	logger.Log(
+		"msg", "allowing a denied admission request: the handler is audit-only",
+		"denial", message,
+	)
+
+	resp.Allowed = true
+	resp.Warnings = append(resp.Warnings, fmt.Sprintf("audit-only: this request would be denied: %s", message))
+	resp.Result = &meta.Status{Message: "allowing admission: the admission webhook is audit-only"}
+	return resp
+}
+
+// acquireInFlight reserves one of the MaxInFlight slots for evaluating an
+// admission request, returning false if the handler is at capacity.
+func (ah *AdmissionHandler) acquireInFlight() bool {
+	if ah.MaxInFlight <= 0 {
+		return true
+	}
+
+	ah.inFlightOnce.Do(func() {
+		ah.inFlight = make(chan struct{}, ah.MaxInFlight)
+	})
+
+	select {
+	case ah.inFlight <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseInFlight releases a slot reserved by acquireInFlight.
+func (ah *AdmissionHandler) releaseInFlight() {
+	if ah.MaxInFlight <= 0 {
+		return
+	}
+
+	<-ah.inFlight
+}
+
+// shedResponse returns the response for an admission request that was shed
+// without being evaluated, based on the configured ShedPolicy.
+func (ah *AdmissionHandler) shedResponse(review *admission.AdmissionReview, logger log.Logger) *admission.AdmissionResponse {
+	allowed := ah.ShedPolicy == FailOpen
+	logger.Log(
+		"msg", "admission request shed: too many requests in flight",
+		"max_in_flight", ah.MaxInFlight,
+		"policy", ah.ShedPolicy,
+		"allowed", allowed,
+		"uid", review.Request.UID,
+	)
+
+	return &admission.AdmissionResponse{
+		Allowed: allowed,
+		Result: &meta.Status{
+			Message: fmt.Sprintf("the admission webhook is overloaded: request was not evaluated (%s)", ah.ShedPolicy),
+		},
+	}
+}
+
+// denialResponse returns the response for an AdmitFunc that denied admission by
+// returning an error. Any audit annotations set by the AdmitFunc on its
+// response are retained, so that denials are attributable in the audit log.
+func (ah *AdmissionHandler) denialResponse(resp *admission.AdmissionResponse, err error, logger log.Logger) *admission.AdmissionResponse {
+	admissionErr := AdmissionError{false, err.Error(), "the AdmitFunc returned an error"}
+	logger.Log(
+		"msg", admissionErr.Message,
+		"debug", admissionErr.Debug,
+	)
+
+	denial := &admission.AdmissionResponse{
+		Allowed: false,
+		Result: &meta.Status{
+			Message: admissionErr.Error(),
+		},
+	}
+
+	if resp != nil {
+		denial.AuditAnnotations = resp.AuditAnnotations
+	}
+
+	return denial
+}
+
+// SetAuditAnnotation adds a key/value audit annotation to the response, which
+// is recorded in the API server's audit log for the admission request: e.g.
+// the policy rule that matched.
+//
+// The API server prefixes each key with the name of the webhook (e.g.
+// "deny-ingresses.questionable.services/policy-rule"), and so keys must be
+// valid (un-prefixed) qualified names.
+func SetAuditAnnotation(resp *admission.AdmissionResponse, key string, value string) error {
+	if resp == nil {
+		return xerrors.New("cannot set an audit annotation on a nil AdmissionResponse")
+	}
+
+	if strings.Contains(key, "/") {
+		return xerrors.Errorf("invalid audit annotation key %q: keys are prefixed with the webhook name by the API server, and must not contain a prefix", key)
+	}
+
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return xerrors.Errorf("invalid audit annotation key %q: %s", key, strings.Join(errs, "; "))
+	}
+
+	if resp.AuditAnnotations == nil {
+		resp.AuditAnnotations = make(map[string]string)
+	}
+	resp.AuditAnnotations[key] = value
+
 	return nil
 }
@@ -4,12 +4,18 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	log "github.com/go-kit/kit/log"
 	admission "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 func newTestAdmitFunc(allowed bool, returnError bool) AdmitFunc {
@@ -96,6 +102,7 @@ func TestAdmissionHandler(t *testing.T) {
 				"/",
 				buf,
 			)
+			req.Header.Set("Content-Type", "application/json")
 
 			handler.ServeHTTP(rr, req)
 
@@ -120,3 +127,713 @@ func TestAdmissionHandler(t *testing.T) {
 	}
 
 }
+
+// newTestReviewRequest returns a POST request for the given AdmissionReview.
+func newTestReviewRequest(t *testing.T, incomingReview *admission.AdmissionReview) *http.Request {
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(incomingReview); err != nil {
+		t.Fatalf("error marshalling incomingReview: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", buf)
+	req.Header.Set("Content-Type", "application/json")
+
+	return req
+}
+
+func TestAdmissionHandlerLoadShedding(t *testing.T) {
+	t.Parallel()
+	var sheddingTests = []struct {
+		testName   string
+		policy     FailurePolicy
+		shouldPass bool
+	}{
+		{
+			testName:   "Shed requests are denied when failing closed",
+			policy:     FailClosed,
+			shouldPass: false,
+		},
+		{
+			testName:   "Shed requests are allowed when failing open",
+			policy:     FailOpen,
+			shouldPass: true,
+		},
+	}
+
+	for _, tt := range sheddingTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+			started := make(chan struct{})
+			unblock := make(chan struct{})
+			handler := &AdmissionHandler{
+				// The AdmitFunc denies, so that shed (allowed) requests can be
+				// distinguished from those that were evaluated.
+				AdmitFunc: func(_ *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+					close(started)
+					<-unblock
+					return nil, errors.New("admission not allowed")
+				},
+				Logger:      &noopLogger{},
+				MaxInFlight: 1,
+				ShedPolicy:  tt.policy,
+			}
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				handler.ServeHTTP(httptest.NewRecorder(), newTestReviewRequest(t, &admission.AdmissionReview{
+					Request: &admission.AdmissionRequest{UID: "in-flight"},
+				}))
+			}()
+			<-started
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, newTestReviewRequest(t, &admission.AdmissionReview{
+				Request: &admission.AdmissionRequest{UID: "shed"},
+			}))
+			close(unblock)
+			<-done
+
+			review := &admission.AdmissionReview{}
+			if err := json.Unmarshal(rr.Body.Bytes(), review); err != nil {
+				t.Fatalf("couldn't marshal the review response: %v", err)
+			}
+
+			if allowed := review.Response.Allowed; allowed != tt.shouldPass {
+				t.Fatalf("invalid review response: got allowed: %t (want %t)", allowed, tt.shouldPass)
+			}
+
+			if uid := review.Response.UID; uid != "shed" {
+				t.Fatalf("invalid review response UID: got %q (want %q)", uid, "shed")
+			}
+		})
+	}
+}
+
+func TestAdmissionHandlerLimitBytes(t *testing.T) {
+	t.Parallel()
+	handler := &AdmissionHandler{
+		AdmitFunc:  newTestAdmitFunc(true, false),
+		Logger:     &noopLogger{},
+		LimitBytes: 64,
+	}
+
+	// A long object name pads the AdmissionReview beyond LimitBytes.
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newTestReviewRequest(t, &admission.AdmissionReview{
+		Request: &admission.AdmissionRequest{
+			UID:  "too-large",
+			Name: strings.Repeat("a", 128),
+		},
+	}))
+
+	review := &admission.AdmissionReview{}
+	if err := json.Unmarshal(rr.Body.Bytes(), review); err != nil {
+		t.Fatalf("couldn't marshal the review response: %v", err)
+	}
+
+	if review.Response.Allowed {
+		t.Fatalf("invalid review response: got allowed: %t (want %t)", review.Response.Allowed, false)
+	}
+
+	expected := "the request body exceeds the maximum size of 64 bytes"
+	if msg := review.Response.Result.Message; !strings.Contains(msg, expected) {
+		t.Fatalf("error message does not match: got %q - expected %q", msg, expected)
+	}
+}
+
+func TestAdmissionHandlerRejectsInvalidRequests(t *testing.T) {
+	t.Parallel()
+	var requestTests = []struct {
+		testName       string
+		method         string
+		contentType    string
+		expectedStatus int
+	}{
+		{
+			testName:       "Accept application/json",
+			method:         http.MethodPost,
+			contentType:    "application/json",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName:       "Accept application/json with parameters",
+			method:         http.MethodPost,
+			contentType:    "application/json; charset=utf-8",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			testName:       "Reject a missing Content-Type",
+			method:         http.MethodPost,
+			contentType:    "",
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			testName:       "Reject a non-JSON Content-Type",
+			method:         http.MethodPost,
+			contentType:    "application/yaml",
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			testName:       "Reject non-POST methods",
+			method:         http.MethodGet,
+			contentType:    "application/json",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range requestTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			handler := &AdmissionHandler{
+				AdmitFunc: newTestAdmitFunc(true, false),
+				Logger:    &noopLogger{},
+			}
+
+			req := newTestReviewRequest(t, &admission.AdmissionReview{
+				Request: &admission.AdmissionRequest{},
+			})
+			req.Method = tt.method
+			req.Header.Set("Content-Type", tt.contentType)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("unexpected status code: got %d (wanted %d)", status, tt.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestAdmissionHandlerAuditAnnotations(t *testing.T) {
+	t.Parallel()
+	var auditTests = []struct {
+		testName   string
+		shouldPass bool
+	}{
+		{
+			testName:   "Audit annotations are returned when admission is allowed",
+			shouldPass: true,
+		},
+		{
+			testName:   "Audit annotations are returned when admission is denied",
+			shouldPass: false,
+		},
+	}
+
+	for _, tt := range auditTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			handler := &AdmissionHandler{
+				AdmitFunc: func(_ *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+					resp := &admission.AdmissionResponse{Allowed: tt.shouldPass}
+					if err := SetAuditAnnotation(resp, "policy-rule", "test-rule"); err != nil {
+						t.Fatalf("failed to set an audit annotation: %v", err)
+					}
+
+					if !tt.shouldPass {
+						return resp, errors.New("admission not allowed")
+					}
+
+					return resp, nil
+				},
+				Logger: &noopLogger{},
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, newTestReviewRequest(t, &admission.AdmissionReview{
+				Request: &admission.AdmissionRequest{UID: "audited"},
+			}))
+
+			review := &admission.AdmissionReview{}
+			if err := json.Unmarshal(rr.Body.Bytes(), review); err != nil {
+				t.Fatalf("couldn't marshal the review response: %v", err)
+			}
+
+			if allowed := review.Response.Allowed; allowed != tt.shouldPass {
+				t.Fatalf("invalid review response: got allowed: %t (want %t)", allowed, tt.shouldPass)
+			}
+
+			if uid := review.Response.UID; uid != "audited" {
+				t.Fatalf("invalid review response UID: got %q (want %q)", uid, "audited")
+			}
+
+			if val := review.Response.AuditAnnotations["policy-rule"]; val != "test-rule" {
+				t.Fatalf("audit annotation does not match: got %q (want %q)", val, "test-rule")
+			}
+		})
+	}
+}
+
+func TestAdmissionHandlerVersions(t *testing.T) {
+	t.Parallel()
+	var versionTests = []struct {
+		testName           string
+		body               string
+		expectedAPIVersion string
+		shouldPass         bool
+	}{
+		{
+			testName:           "Respond to admission.k8s.io/v1 requests at v1",
+			body:               `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"versioned"}}`,
+			expectedAPIVersion: "admission.k8s.io/v1",
+			shouldPass:         true,
+		},
+		{
+			testName:           "Respond to admission.k8s.io/v1beta1 requests at v1beta1",
+			body:               `{"apiVersion":"admission.k8s.io/v1beta1","kind":"AdmissionReview","request":{"uid":"versioned"}}`,
+			expectedAPIVersion: "admission.k8s.io/v1beta1",
+			shouldPass:         true,
+		},
+		{
+			testName:           "Respond to unversioned requests at v1",
+			body:               `{"request":{"uid":"versioned"}}`,
+			expectedAPIVersion: "admission.k8s.io/v1",
+			shouldPass:         true,
+		},
+		{
+			testName:           "Respond to invalid requests at v1",
+			body:               `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview"}`,
+			expectedAPIVersion: "admission.k8s.io/v1",
+			shouldPass:         false,
+		},
+	}
+
+	for _, tt := range versionTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			handler := &AdmissionHandler{
+				AdmitFunc: newTestAdmitFunc(true, false),
+				Logger:    &noopLogger{},
+			}
+
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			handler.ServeHTTP(rr, req)
+
+			review := &admission.AdmissionReview{}
+			if err := json.Unmarshal(rr.Body.Bytes(), review); err != nil {
+				t.Fatalf("couldn't marshal the review response: %v", err)
+			}
+
+			if review.APIVersion != tt.expectedAPIVersion || review.Kind != "AdmissionReview" {
+				t.Fatalf("invalid review response type: got %s, Kind=%s (want %s, Kind=AdmissionReview)", review.APIVersion, review.Kind, tt.expectedAPIVersion)
+			}
+
+			if allowed := review.Response.Allowed; allowed != tt.shouldPass {
+				t.Fatalf("invalid review response: got allowed: %t (want %t)", allowed, tt.shouldPass)
+			}
+
+			if tt.shouldPass && review.Response.UID != "versioned" {
+				t.Fatalf("invalid review response UID: got %q (want %q)", review.Response.UID, "versioned")
+			}
+		})
+	}
+}
+
+// countingSerializer is a Serializer that counts the responses it encodes.
+type countingSerializer struct {
+	runtime.Serializer
+	encoded int
+}
+
+func (s *countingSerializer) Encode(obj runtime.Object, w io.Writer) error {
+	s.encoded++
+	return s.Serializer.Encode(obj, w)
+}
+
+func TestAdmissionHandlerSerializer(t *testing.T) {
+	t.Parallel()
+
+	serializer := &countingSerializer{Serializer: NewAdmissionSerializer()}
+	handler := &AdmissionHandler{
+		AdmitFunc:  newTestAdmitFunc(true, false),
+		Logger:     &noopLogger{},
+		Serializer: serializer,
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newTestReviewRequest(t, &admission.AdmissionReview{
+		Request: &admission.AdmissionRequest{UID: "serialized"},
+	}))
+
+	if serializer.encoded != 1 {
+		t.Fatalf("the custom Serializer encoded %d responses: expected 1", serializer.encoded)
+	}
+}
+
+func TestSetAuditAnnotation(t *testing.T) {
+	t.Parallel()
+	var keyTests = []struct {
+		key       string
+		shouldErr bool
+	}{
+		{key: "policy-rule", shouldErr: false},
+		{key: "denied.containers", shouldErr: false},
+		{key: "questionable.services/policy-rule", shouldErr: true},
+		{key: "not a valid key", shouldErr: true},
+		{key: "", shouldErr: true},
+	}
+
+	for _, tt := range keyTests {
+		resp := &admission.AdmissionResponse{}
+		err := SetAuditAnnotation(resp, tt.key, "value")
+		if (err != nil) != tt.shouldErr {
+			t.Fatalf("unexpected result for key %q: got err %v (want error: %t)", tt.key, err, tt.shouldErr)
+		}
+	}
+
+	if err := SetAuditAnnotation(nil, "policy-rule", "value"); err == nil {
+		t.Fatalf("a nil AdmissionResponse did not return an error")
+	}
+}
+
+func TestNewAdmissionHandler(t *testing.T) {
+	t.Parallel()
+
+	admitFunc := newTestAdmitFunc(true, false)
+	var constructorTests = []struct {
+		testName   string
+		admitFunc  AdmitFunc
+		logger     log.Logger
+		opts       []HandlerOption
+		shouldPass bool
+	}{
+		{testName: "Create a handler", admitFunc: admitFunc, logger: &noopLogger{}, shouldPass: true},
+		{
+			testName:  "Create a handler with options",
+			admitFunc: admitFunc,
+			logger:    &noopLogger{},
+			opts: []HandlerOption{
+				WithTimeout(time.Second, FailOpen),
+				WithAuditMode(),
+				WithLimitBytes(1024),
+				WithMaxInFlight(10, FailClosed),
+				WithPanicRecovery(),
+			},
+			shouldPass: true,
+		},
+		{testName: "Reject a nil AdmitFunc", admitFunc: nil, logger: &noopLogger{}, shouldPass: false},
+		{testName: "Reject a nil Logger", admitFunc: admitFunc, logger: nil, shouldPass: false},
+		{testName: "Reject an invalid timeout", admitFunc: admitFunc, logger: &noopLogger{}, opts: []HandlerOption{WithTimeout(0, FailClosed)}, shouldPass: false},
+		{testName: "Reject an invalid limit", admitFunc: admitFunc, logger: &noopLogger{}, opts: []HandlerOption{WithLimitBytes(-1)}, shouldPass: false},
+		{testName: "Reject an invalid in-flight maximum", admitFunc: admitFunc, logger: &noopLogger{}, opts: []HandlerOption{WithMaxInFlight(0, FailOpen)}, shouldPass: false},
+	}
+
+	for _, tt := range constructorTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			handler, err := NewAdmissionHandler(tt.admitFunc, tt.logger, tt.opts...)
+			if tt.shouldPass && err != nil {
+				t.Fatalf("failed to create the handler: %v", err)
+			}
+
+			if !tt.shouldPass && (err == nil || handler != nil) {
+				t.Fatalf("expected an error: got a handler %v", handler)
+			}
+		})
+	}
+}
+
+// serveTestReview serves an AdmissionReview request with the handler, and
+// returns the response.
+func serveTestReview(t *testing.T, handler *AdmissionHandler) *admission.AdmissionResponse {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, newTestReviewRequest(t, &admission.AdmissionReview{
+		Request: &admission.AdmissionRequest{UID: "handled"},
+	}))
+
+	review := &admission.AdmissionReview{}
+	if err := json.Unmarshal(rr.Body.Bytes(), review); err != nil {
+		t.Fatalf("couldn't marshal the review response: %v", err)
+	}
+
+	if review.Response == nil {
+		t.Fatalf("the review has no response: %s", rr.Body.String())
+	}
+
+	return review.Response
+}
+
+func TestAdmissionHandlerTimeout(t *testing.T) {
+	t.Parallel()
+
+	var timeoutTests = []struct {
+		testName   string
+		policy     FailurePolicy
+		shouldPass bool
+	}{
+		{testName: "Deny timed out requests with FailClosed", policy: FailClosed, shouldPass: false},
+		{testName: "Allow timed out requests with FailOpen", policy: FailOpen, shouldPass: true},
+	}
+
+	for _, tt := range timeoutTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+
+			// The AdmitFunc blocks until the test completes.
+			release := make(chan struct{})
+			defer close(release)
+
+			handler, err := NewAdmissionHandler(func(_ *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+				<-release
+				return &admission.AdmissionResponse{Allowed: true}, nil
+			}, &noopLogger{}, WithTimeout(time.Millisecond*20, tt.policy))
+			if err != nil {
+				t.Fatalf("failed to create the handler: %v", err)
+			}
+
+			resp := serveTestReview(t, handler)
+			if resp.Allowed != tt.shouldPass {
+				t.Fatalf("invalid review response: got allowed: %t (want %t)", resp.Allowed, tt.shouldPass)
+			}
+
+			if !strings.Contains(resp.Result.Message, "timed out") {
+				t.Fatalf("the response does not report the timeout: %q", resp.Result.Message)
+			}
+		})
+	}
+}
+
+func TestAdmissionHandlerTimeoutHoldsInFlightSlot(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	handler, err := NewAdmissionHandler(func(_ *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		<-release
+		return nil, errors.New("admission not allowed")
+	}, &noopLogger{}, WithTimeout(time.Millisecond*20, FailClosed), WithMaxInFlight(1, FailOpen))
+	if err != nil {
+		t.Fatalf("failed to create the handler: %v", err)
+	}
+
+	if resp := serveTestReview(t, handler); resp.Allowed || !strings.Contains(resp.Result.Message, "timed out") {
+		t.Fatalf("expected the first request to time out: %v", resp.Result)
+	}
+
+	// The timed out AdmitFunc is still running, and holds the only slot.
+	if resp := serveTestReview(t, handler); !resp.Allowed || !strings.Contains(resp.Result.Message, "overloaded") {
+		t.Fatalf("expected the request to be shed while the timed out AdmitFunc runs: %v", resp.Result)
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for len(handler.inFlight) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("the in-flight slot was not released once the AdmitFunc returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if resp := serveTestReview(t, handler); resp.Allowed || !strings.Contains(resp.Result.Message, "admission not allowed") {
+		t.Fatalf("expected the request to be evaluated once the slot was released: %v", resp.Result)
+	}
+}
+
+func TestAdmissionHandlerAuditMode(t *testing.T) {
+	t.Parallel()
+
+	handler, err := NewAdmissionHandler(newTestAdmitFunc(false, true), &noopLogger{}, WithAuditMode())
+	if err != nil {
+		t.Fatalf("failed to create the handler: %v", err)
+	}
+
+	resp := serveTestReview(t, handler)
+	if !resp.Allowed {
+		t.Fatalf("an audit-only handler denied admission: %v", resp)
+	}
+
+	if len(resp.Warnings) != 1 || !strings.Contains(resp.Warnings[0], "admission not allowed") {
+		t.Fatalf("the denial was not returned as a warning: %v", resp.Warnings)
+	}
+}
+
+func TestAdmissionHandlerPanicRecovery(t *testing.T) {
+	t.Parallel()
+
+	panics := func(_ *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		panic("nil map")
+	}
+
+	for _, opts := range [][]HandlerOption{
+		{WithPanicRecovery()},
+		{WithPanicRecovery(), WithTimeout(time.Second, FailOpen)},
+	} {
+		handler, err := NewAdmissionHandler(panics, &noopLogger{}, opts...)
+		if err != nil {
+			t.Fatalf("failed to create the handler: %v", err)
+		}
+
+		resp := serveTestReview(t, handler)
+		if resp.Allowed || !strings.Contains(resp.Result.Message, "nil map") {
+			t.Fatalf("invalid review response for a panicking AdmitFunc: %v", resp)
+		}
+	}
+
+	handler, err := NewAdmissionHandler(panics, &noopLogger{}, WithTimeout(time.Second, FailOpen))
+	if err != nil {
+		t.Fatalf("failed to create the handler: %v", err)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatalf("expected the panic to propagate without WithPanicRecovery")
+		}
+	}()
+	serveTestReview(t, handler)
+}
+
+func TestAdmissionHandlerUnknownKinds(t *testing.T) {
+	t.Parallel()
+
+	var unknownKindTests = []struct {
+		testName       string
+		behavior       UnknownKindBehavior
+		expectedStatus int
+		shouldPass     bool
+	}{
+		{testName: "Allow unknown kinds by default", behavior: UnknownKindAllow, expectedStatus: http.StatusOK, shouldPass: true},
+		{testName: "Deny unknown kinds with UnknownKindDeny", behavior: UnknownKindDeny, expectedStatus: http.StatusOK, shouldPass: false},
+		{testName: "Fail unknown kinds with UnknownKindError", behavior: UnknownKindError, expectedStatus: http.StatusInternalServerError, shouldPass: false},
+	}
+
+	for _, tt := range unknownKindTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+
+			handler, err := NewAdmissionHandler(ValidateResourceQuantities(nil), &noopLogger{}, WithUnknownKindBehavior(tt.behavior))
+			if err != nil {
+				t.Fatalf("failed to create the handler: %v", err)
+			}
+
+			review := &admission.AdmissionReview{Request: &admission.AdmissionRequest{
+				UID:  "unknown-kind",
+				Kind: metav1.GroupVersionKind{Group: "", Kind: "Service", Version: "v1"},
+			}}
+			review.Request.Object.Raw = []byte(`{"kind":"Service","apiVersion":"v1","metadata":{"name":"web","namespace":"default"}}`)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, newTestReviewRequest(t, review))
+			if rr.Code != tt.expectedStatus {
+				t.Fatalf("unexpected status code: got %d (wanted %d)", rr.Code, tt.expectedStatus)
+			}
+
+			if !strings.Contains(rr.Body.String(), "the submitted Kind is not supported by this admission handler: Service") {
+				t.Fatalf("the response does not report the unsupported kind: %s", rr.Body.String())
+			}
+
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			incomingReview := &admission.AdmissionReview{}
+			if err := json.Unmarshal(rr.Body.Bytes(), incomingReview); err != nil {
+				t.Fatalf("couldn't marshal the review response: %v", err)
+			}
+
+			if incomingReview.Response.Allowed != tt.shouldPass {
+				t.Fatalf("invalid review response: got allowed: %t (want %t)", incomingReview.Response.Allowed, tt.shouldPass)
+			}
+		})
+	}
+
+	if _, err := NewAdmissionHandler(DenyIngresses(nil), &noopLogger{}, WithUnknownKindBehavior(UnknownKindBehavior(7))); err == nil {
+		t.Fatalf("expected an error for an invalid UnknownKindBehavior")
+	}
+}
+
+func TestAdmissionHandlerResetsPooledReviews(t *testing.T) {
+	t.Parallel()
+
+	var seen []admission.AdmissionRequest
+	handler, err := NewAdmissionHandler(func(review *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		seen = append(seen, *review.Request)
+		return &admission.AdmissionResponse{Allowed: true}, nil
+	}, &noopLogger{})
+	if err != nil {
+		t.Fatalf("failed to create the handler: %v", err)
+	}
+
+	dryRun := true
+	first := &admission.AdmissionReview{Request: &admission.AdmissionRequest{
+		UID:       "first",
+		Namespace: "secret-team",
+		Operation: admission.Update,
+		UserInfo:  authenticationv1.UserInfo{Username: "jane", Groups: []string{"admins"}},
+		DryRun:    &dryRun,
+	}}
+	first.Request.Object.Raw = []byte(`{"kind":"Secret","apiVersion":"v1","data":{"password":"aHVudGVyMg=="}}`)
+	first.Request.OldObject.Raw = []byte(`{"kind":"Secret","apiVersion":"v1"}`)
+
+	second := &admission.AdmissionReview{Request: &admission.AdmissionRequest{UID: "second"}}
+
+	for _, review := range []*admission.AdmissionReview{first, second} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, newTestReviewRequest(t, review))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("unexpected status code: got %d (wanted %d)", rr.Code, http.StatusOK)
+		}
+	}
+
+	if len(seen) != 2 {
+		t.Fatalf("the AdmitFunc was called %d times: expected 2", len(seen))
+	}
+
+	if !bytes.Contains(seen[0].Object.Raw, []byte("aHVudGVyMg==")) {
+		t.Fatalf("the first request was not decoded: %s", seen[0].Object.Raw)
+	}
+
+	req := seen[1]
+	if req.UID != "second" || req.Namespace != "" || req.Operation != "" || req.UserInfo.Username != "" ||
+		len(req.UserInfo.Groups) != 0 || req.DryRun != nil || req.Object.Raw != nil || req.OldObject.Raw != nil {
+		t.Fatalf("the second request contains data from the first: %+v", req)
+	}
+}
+
+func BenchmarkAdmissionHandler(b *testing.B) {
+	pod := newTestPodWithImages("default", "registry.example.com/web:v1.0.0", "registry.example.com/sidecar:v1.0.0")
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		b.Fatalf("could not marshal k8s API object: %v", err)
+	}
+
+	review := &admission.AdmissionReview{Request: &admission.AdmissionRequest{
+		UID:       "benchmark",
+		Kind:      metav1.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+		Namespace: "default",
+		Name:      "web",
+		Operation: admission.Create,
+	}}
+	review.Request.Object.Raw = raw
+	review.SetGroupVersionKind(admission.SchemeGroupVersion.WithKind("AdmissionReview"))
+
+	body, err := json.Marshal(review)
+	if err != nil {
+		b.Fatalf("could not marshal the AdmissionReview: %v", err)
+	}
+
+	handler := &AdmissionHandler{
+		AdmitFunc: ValidateResourceQuantities(nil),
+		Logger:    &noopLogger{},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			b.Fatalf("unexpected status code: got %d (wanted %d)", rr.Code, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package admissioncontrol
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/xerrors"
+	admission "k8s.io/api/admission/v1"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// cacheSyncTimeout is the default bound on how long an AdmitFunc waits for its
+// informer cache to sync before failing the admission request. It must remain
+// well below the webhook timeoutSeconds configured on the API server.
+var cacheSyncTimeout = time.Second * 3
+
+// ErrCacheNotSynced is returned (wrapped) when an informer cache has not synced
+// in time: e.g. because the API server is unreachable.
+var ErrCacheNotSynced = xerrors.New("the informer cache has not synced")
+
+// clientPolicy holds the configuration shared by the client-backed AdmitFuncs:
+// those that read from an informer cache.
+type clientPolicy struct {
+	failurePolicy FailurePolicy
+	syncTimeout   time.Duration
+}
+
+// ClientOption configures a client-backed AdmitFunc: e.g.
+// EnforceMaxPVCsPerNamespace.
+//
+// Client-backed AdmitFuncs read objects from informers that they register
+// against the SharedInformerFactory they are passed, which may be shared with
+// other AdmitFuncs. The caller owns the factory: it must be started (via Start)
+// after the AdmitFuncs that use it are constructed, and is shut down by closing
+// the stop channel passed to Start. The factory's client must be authorized to
+// list & watch the objects each AdmitFunc reads: see its documentation. As the
+// caches are eventually consistent, an AdmitFunc may not yet observe objects
+// created (or deleted) moments before the request. Requests are denied if the
+// caches have not synced: see ClientFailurePolicy.
+type ClientOption func(*clientPolicy)
+
+// ClientFailurePolicy determines whether requests are allowed (FailOpen) or
+// denied (FailClosed) when the informer cache has not synced - e.g. when the
+// API server is unreachable - and the request cannot be evaluated. Defaults to
+// FailClosed.
+//
+// FailOpen keeps workloads deployable during an API server outage, but allows
+// requests that the policy would deny: it should not be used for security
+// policies, where an attacker able to degrade the API server could bypass the
+// policy.
+func ClientFailurePolicy(failurePolicy FailurePolicy) ClientOption {
+	return func(cp *clientPolicy) {
+		cp.failurePolicy = failurePolicy
+	}
+}
+
+// ClientSyncTimeout bounds how long the AdmitFunc waits for its informer cache
+// to sync before applying the ClientFailurePolicy. Defaults to 3 seconds.
+func ClientSyncTimeout(timeout time.Duration) ClientOption {
+	return func(cp *clientPolicy) {
+		cp.syncTimeout = timeout
+	}
+}
+
+func newClientPolicy(opts []ClientOption) *clientPolicy {
+	cp := &clientPolicy{syncTimeout: cacheSyncTimeout}
+	for _, opt := range opts {
+		opt(cp)
+	}
+
+	return cp
+}
+
+// waitForCacheSync waits (up to the sync timeout) for the informer caches to
+// sync, returning false if they have not.
+func (cp *clientPolicy) waitForCacheSync(synced ...cache.InformerSynced) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), cp.syncTimeout)
+	defer cancel()
+
+	return cache.WaitForCacheSync(ctx.Done(), synced...)
+}
+
+// unavailable answers a request that could not be evaluated - for the reason -
+// according to the failure policy.
+func (cp *clientPolicy) unavailable(resp *admission.AdmissionResponse, reason string) (*admission.AdmissionResponse, error) {
+	if cp.failurePolicy == FailOpen {
+		resp.Allowed = true
+		resp.Result.Message = fmt.Sprintf("allowing admission: %s (%s)", reason, cp.failurePolicy)
+		return resp, nil
+	}
+
+	return resp, xerrors.Errorf("%s (%s)", reason, cp.failurePolicy)
+}
+
+// NamespaceLookup reads the metadata (labels & annotations) of Namespaces from
+// an in-memory cache, so that namespace-scoped policies can depend on the
+// metadata of the target Namespace without querying the API server on each
+// admission request.
+//
+// The cache is an informer registered against a caller-owned
+// SharedInformerFactory, which may be shared with other AdmitFuncs. The factory
+// must be started (via Start) after the NamespaceLookup is constructed, and is
+// shut down by closing the stop channel passed to Start. The factory's client
+// must be authorized to list & watch namespaces (a ClusterRole granting "list"
+// and "watch" on "namespaces").
+type NamespaceLookup struct {
+	lister corelisters.NamespaceLister
+	synced cache.InformerSynced
+	policy *clientPolicy
+}
+
+// NewNamespaceLookup registers a Namespace informer against the factory, and
+// returns a NamespaceLookup backed by it. The ClientOptions apply to the
+// AdmitFuncs that use the NamespaceLookup: e.g. OnlyNamespacesLabeled.
+func NewNamespaceLookup(factory informers.SharedInformerFactory, opts ...ClientOption) *NamespaceLookup {
+	namespaces := factory.Core().V1().Namespaces()
+
+	return &NamespaceLookup{
+		lister: namespaces.Lister(),
+		synced: namespaces.Informer().HasSynced,
+		policy: newClientPolicy(opts),
+	}
+}
+
+// NamespaceLabels returns the labels of the named Namespace. An error is
+// returned if the cache has not synced (wrapping ErrCacheNotSynced), or the
+// Namespace does not exist.
+func (l *NamespaceLookup) NamespaceLabels(name string) (map[string]string, error) {
+	if !l.policy.waitForCacheSync(l.synced) {
+		return nil, xerrors.Errorf("cannot read the namespace labels: %w", ErrCacheNotSynced)
+	}
+
+	namespace, err := l.lister.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.Labels, nil
+}
+
+// NamespaceAnnotations returns the annotations of the named Namespace. An error
+// is returned if the cache has not synced (wrapping ErrCacheNotSynced), or the
+// Namespace does not exist.
+func (l *NamespaceLookup) NamespaceAnnotations(name string) (map[string]string, error) {
+	if !l.policy.waitForCacheSync(l.synced) {
+		return nil, xerrors.Errorf("cannot read the namespace annotations: %w", ErrCacheNotSynced)
+	}
+
+	namespace, err := l.lister.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.Annotations, nil
+}
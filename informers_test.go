@@ -0,0 +1,172 @@
+package admissioncontrol
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/xerrors"
+	admission "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newTestNamespaceLookup returns a started NamespaceLookup over the namespaces.
+func newTestNamespaceLookup(t *testing.T, namespaces ...*corev1.Namespace) *NamespaceLookup {
+	t.Helper()
+
+	client := fake.NewSimpleClientset()
+	for _, namespace := range namespaces {
+		if err := client.Tracker().Add(namespace); err != nil {
+			t.Fatalf("could not add the namespace %s: %v", namespace.Name, err)
+		}
+	}
+
+	factory := informers.NewSharedInformerFactory(client, 0)
+	lookup := NewNamespaceLookup(factory)
+
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory.Start(stopCh)
+
+	return lookup
+}
+
+func TestNamespaceLookup(t *testing.T) {
+	t.Parallel()
+
+	lookup := newTestNamespaceLookup(t, &corev1.Namespace{ObjectMeta: meta.ObjectMeta{
+		Name:        "payments",
+		Labels:      map[string]string{"env": "prod"},
+		Annotations: map[string]string{"owner": "payments-team"},
+	}})
+
+	namespaceLabels, err := lookup.NamespaceLabels("payments")
+	if err != nil {
+		t.Fatalf("could not read the namespace labels: %v", err)
+	}
+
+	if expected := map[string]string{"env": "prod"}; !reflect.DeepEqual(namespaceLabels, expected) {
+		t.Fatalf("NamespaceLabels() = %v: expected %v", namespaceLabels, expected)
+	}
+
+	annotations, err := lookup.NamespaceAnnotations("payments")
+	if err != nil {
+		t.Fatalf("could not read the namespace annotations: %v", err)
+	}
+
+	if expected := map[string]string{"owner": "payments-team"}; !reflect.DeepEqual(annotations, expected) {
+		t.Fatalf("NamespaceAnnotations() = %v: expected %v", annotations, expected)
+	}
+
+	if _, err := lookup.NamespaceLabels("missing"); err == nil {
+		t.Fatalf("expected an error for a missing namespace")
+	}
+}
+
+// newUnavailableFactory returns a SharedInformerFactory whose client fails to
+// list any resource, as if the API server were unreachable.
+func newUnavailableFactory(t *testing.T) informers.SharedInformerFactory {
+	t.Helper()
+
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("list", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewServiceUnavailable("the API server is unavailable")
+	})
+
+	return informers.NewSharedInformerFactory(client, 0)
+}
+
+func TestClientFailurePolicy(t *testing.T) {
+	t.Parallel()
+
+	var failureTests = []struct {
+		testName        string
+		policy          FailurePolicy
+		expectedMessage string
+		shouldAllow     bool
+	}{
+		{
+			testName:        "Deny requests with FailClosed",
+			policy:          FailClosed,
+			expectedMessage: "the PersistentVolumeClaim cache has not synced: cannot count existing claims (FailClosed)",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Allow requests with FailOpen",
+			policy:          FailOpen,
+			expectedMessage: "allowing admission: the PersistentVolumeClaim cache has not synced: cannot count existing claims (FailOpen)",
+			shouldAllow:     true,
+		},
+	}
+
+	for _, tt := range failureTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+
+			factory := newUnavailableFactory(t)
+			admitFunc, err := EnforceMaxPVCsPerNamespace(factory, 1, ClientFailurePolicy(tt.policy), ClientSyncTimeout(time.Millisecond*50))
+			if err != nil {
+				t.Fatalf("failed to create the AdmitFunc: %v", err)
+			}
+
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			factory.Start(stopCh)
+
+			review := newTestAdmissionRequest(
+				meta.GroupVersionKind{Group: "", Kind: "PersistentVolumeClaim", Version: "v1"},
+				[]byte(`{"kind":"PersistentVolumeClaim","apiVersion":"v1","metadata":{"name":"data","namespace":"default"}}`),
+				tt.shouldAllow,
+			)
+			review.Request.Operation = admission.Create
+			review.Request.Namespace = "default"
+
+			resp, err := admitFunc(review)
+			if allowed := err == nil && resp.Allowed; allowed != tt.shouldAllow {
+				t.Fatalf("admission mismatch: got allowed=%t (err: %v) - wanted allowed=%t", allowed, err, tt.shouldAllow)
+			}
+
+			var message string
+			if err != nil {
+				message = err.Error()
+			} else {
+				message = resp.Result.Message
+			}
+
+			if message != tt.expectedMessage {
+				t.Fatalf(testErrMessageMismatch, message, tt.expectedMessage)
+			}
+		})
+	}
+}
+
+func TestNamespaceLookupUnavailable(t *testing.T) {
+	t.Parallel()
+
+	factory := newUnavailableFactory(t)
+	lookup := NewNamespaceLookup(factory, ClientSyncTimeout(time.Millisecond*50), ClientFailurePolicy(FailOpen))
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+
+	if _, err := lookup.NamespaceLabels("payments"); !xerrors.Is(err, ErrCacheNotSynced) {
+		t.Fatalf("expected ErrCacheNotSynced: got %v", err)
+	}
+
+	admitFunc := OnlyNamespacesLabeled(lookup, labels.Everything(), denyAll)
+	review := newTestAdmissionRequest(meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}, nil, true)
+	review.Request.Namespace = "payments"
+
+	if resp, err := admitFunc(review); err != nil || !resp.Allowed {
+		t.Fatalf("expected the request to be allowed with FailOpen: got %v (err: %v)", resp, err)
+	}
+}
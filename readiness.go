@@ -0,0 +1,132 @@
+package admissioncontrol
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ReadinessChecker reports whether a dependency of the webhook - e.g. the
+// informer cache of a client-backed AdmitFunc - is ready, returning an error
+// describing why it is not.
+//
+// Ready is called on each readiness probe, and so must not block: it should
+// report the current state, rather than wait for it to change. Ready must be
+// safe for concurrent use.
+type ReadinessChecker interface {
+	Ready() error
+}
+
+// ReadinessCheckerFunc is an adapter to allow the use of ordinary functions as
+// ReadinessCheckers.
+type ReadinessCheckerFunc func() error
+
+// Ready calls f().
+func (f ReadinessCheckerFunc) Ready() error {
+	return f()
+}
+
+// InformersSynced returns a ReadinessChecker that is ready once each of the
+// informer caches has synced: e.g. informer.HasSynced.
+func InformersSynced(synced ...cache.InformerSynced) ReadinessChecker {
+	return ReadinessCheckerFunc(func() error {
+		for _, hasSynced := range synced {
+			if !hasSynced() {
+				return ErrCacheNotSynced
+			}
+		}
+
+		return nil
+	})
+}
+
+// InformerFactorySynced returns a ReadinessChecker that is ready once each of
+// the informers started by the factory has synced. This covers the informers
+// that the client-backed AdmitFuncs (e.g. EnforceMaxPVCsPerNamespace) register
+// against the factory. The factory is not ready until it has been started.
+func InformerFactorySynced(factory informers.SharedInformerFactory) ReadinessChecker {
+	return ReadinessCheckerFunc(func() error {
+		// A closed channel reports the current state, without waiting.
+		stopCh := make(chan struct{})
+		close(stopCh)
+
+		synced := factory.WaitForCacheSync(stopCh)
+		if len(synced) == 0 {
+			return xerrors.New("no informers have been started")
+		}
+
+		var pending []string
+		for informerType, ok := range synced {
+			if !ok {
+				pending = append(pending, informerType.String())
+			}
+		}
+
+		if len(pending) > 0 {
+			sort.Strings(pending)
+			return xerrors.Errorf("%w: %s", ErrCacheNotSynced, strings.Join(pending, ", "))
+		}
+
+		return nil
+	})
+}
+
+// WithReadinessCheckers registers checkers that report whether the
+// dependencies of the AdmitFunc are ready. See AdmissionHandler.Ready.
+func WithReadinessCheckers(checkers ...ReadinessChecker) HandlerOption {
+	return func(ah *AdmissionHandler) error {
+		for _, checker := range checkers {
+			if checker == nil {
+				return xerrors.New("a ReadinessChecker must not be nil")
+			}
+		}
+
+		ah.ReadinessCheckers = append(ah.ReadinessCheckers, checkers...)
+		return nil
+	}
+}
+
+// Ready reports whether each of the handler's ReadinessCheckers is ready, so
+// that an AdmissionHandler can itself be passed to ReadinessHandler.
+func (ah *AdmissionHandler) Ready() error {
+	for _, checker := range ah.ReadinessCheckers {
+		if err := checker.Ready(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadinessHandler returns a http.Handler - e.g. for "/readyz" - that responds
+// with a HTTP 200 once each of the checkers is ready, and a HTTP 503 (listing
+// the reason each checker is not ready) until then.
+//
+// Use it as the readinessProbe of the webhook's Pods, so that the webhook does
+// not receive admission requests - and deny them, with a failurePolicy of Fail
+// - before its informer caches have synced.
+func ReadinessHandler(checkers ...ReadinessChecker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reasons []string
+		for i, checker := range checkers {
+			if err := checker.Ready(); err != nil {
+				reasons = append(reasons, fmt.Sprintf("check %d is not ready: %s", i, err))
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if len(reasons) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, strings.Join(reasons, "\n"))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	})
+}
@@ -0,0 +1,80 @@
+package admissioncontrol
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/xerrors"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestInformerFactorySynced(t *testing.T) {
+	t.Parallel()
+
+	factory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	admitFunc, err := EnforceMaxPVCsPerNamespace(factory, 10)
+	if err != nil {
+		t.Fatalf("failed to create the AdmitFunc: %v", err)
+	}
+
+	handler, err := NewAdmissionHandler(admitFunc, &noopLogger{}, WithReadinessCheckers(InformerFactorySynced(factory)))
+	if err != nil {
+		t.Fatalf("failed to create the handler: %v", err)
+	}
+
+	if err := handler.Ready(); err == nil {
+		t.Fatalf("expected the handler not to be ready before the factory is started")
+	}
+
+	stopCh := make(chan struct{})
+	t.Cleanup(func() { close(stopCh) })
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+
+	if err := handler.Ready(); err != nil {
+		t.Fatalf("expected the handler to be ready once the caches have synced: %v", err)
+	}
+
+	unavailable := newUnavailableFactory(t)
+	unavailable.Core().V1().PersistentVolumeClaims().Informer()
+	unavailable.Start(stopCh)
+
+	err = InformerFactorySynced(unavailable).Ready()
+	if !xerrors.Is(err, ErrCacheNotSynced) || !strings.Contains(err.Error(), "PersistentVolumeClaim") {
+		t.Fatalf("expected the unsynced informer to be reported: %v", err)
+	}
+}
+
+func TestReadinessHandler(t *testing.T) {
+	t.Parallel()
+
+	synced := false
+	checker := InformersSynced(func() bool { return synced })
+
+	serve := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ReadinessHandler(ReadinessCheckerFunc(func() error { return nil }), checker).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rr
+	}
+
+	rr := serve()
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status code: got %d (wanted %d)", rr.Code, http.StatusServiceUnavailable)
+	}
+
+	if expected := "check 1 is not ready: " + ErrCacheNotSynced.Error(); !strings.Contains(rr.Body.String(), expected) {
+		t.Fatalf("the response does not report the reason: got %q (wanted %q)", rr.Body.String(), expected)
+	}
+
+	synced = true
+	if rr := serve(); rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status code: got %d (wanted %d)", rr.Code, http.StatusOK)
+	}
+
+	if _, err := NewAdmissionHandler(DenyIngresses(nil), &noopLogger{}, WithReadinessCheckers(nil)); err == nil {
+		t.Fatalf("expected an error for a nil ReadinessChecker")
+	}
+}
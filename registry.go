@@ -0,0 +1,129 @@
+package admissioncontrol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// AdmitFuncFactory constructs an AdmitFunc from its parameters, as parsed from
+// a (YAML or JSON) config file: see BuildAdmitFunc. A factory should return an
+// error for invalid or unknown parameters: DecodeParams decodes the parameters
+// into a struct, rejecting unknown fields.
+type AdmitFuncFactory func(params map[string]interface{}) (AdmitFunc, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]AdmitFuncFactory)
+)
+
+// RegisterAdmitFuncFactory makes an AdmitFuncFactory available by name to
+// BuildAdmitFunc, so that the AdmitFunc can be enabled & configured from a
+// config file rather than in code. The built-in AdmitFuncs whose parameters
+// can be expressed as config - e.g. "require-image-digest" - are registered by
+// this package: see RegisteredAdmitFuncs.
+//
+// Register your own AdmitFuncs from an init function, or before loading the
+// config, e.g.
+//
+//	admissioncontrol.RegisterAdmitFuncFactory("deny-latest-tag", func(params map[string]interface{}) (admissioncontrol.AdmitFunc, error) {
+//		var p struct {
+//			IgnoredNamespaces []string `json:"ignoredNamespaces"`
+//		}
+//		if err := admissioncontrol.DecodeParams(params, &p); err != nil {
+//			return nil, err
+//		}
+//
+//		return DenyLatestTag(p.IgnoredNamespaces), nil
+//	})
+//
+// AdmitFuncs that depend on Go values - e.g. a SharedInformerFactory, or an
+// ImageSignatureVerifier - can be registered by closing over them.
+//
+// As with database/sql.Register, RegisterAdmitFuncFactory panics if the name
+// is empty or already registered, or if the factory is nil.
+func RegisterAdmitFuncFactory(name string, factory AdmitFuncFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	if name == "" {
+		panic("admissioncontrol: an AdmitFuncFactory must be registered with a name")
+	}
+
+	if factory == nil {
+		panic(fmt.Sprintf("admissioncontrol: the AdmitFuncFactory %q is nil", name))
+	}
+
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("admissioncontrol: the AdmitFuncFactory %q is already registered", name))
+	}
+
+	factories[name] = factory
+}
+
+// RegisteredAdmitFuncs returns the (sorted) names of the registered
+// AdmitFuncFactories.
+func RegisteredAdmitFuncs() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// BuildAdmitFunc constructs the AdmitFunc registered by name (see
+// RegisterAdmitFuncFactory) from its parameters. An error is returned if no
+// AdmitFunc is registered by that name, or if the factory rejects the
+// parameters.
+func BuildAdmitFunc(name string, params map[string]interface{}) (AdmitFunc, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+
+	if !ok {
+		return nil, xerrors.Errorf("no AdmitFunc is registered as %q", name)
+	}
+
+	admitFunc, err := factory(params)
+	if err != nil {
+		return nil, xerrors.Errorf("invalid parameters for %s: %w", name, err)
+	}
+
+	if admitFunc == nil {
+		return nil, xerrors.Errorf("the AdmitFuncFactory %q returned a nil AdmitFunc", name)
+	}
+
+	return admitFunc, nil
+}
+
+// DecodeParams decodes the parameters of an AdmitFuncFactory into the struct
+// pointed to by into, as encoding/json would decode them: field names are
+// matched case-insensitively, or by their `json` tags. An error is returned
+// for parameters that do not match a field, or that are of the wrong type.
+func DecodeParams(params map[string]interface{}, into interface{}) error {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return xerrors.Errorf("could not encode the parameters: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(into); err != nil {
+		return xerrors.Errorf("could not decode the parameters: %w", err)
+	}
+
+	return nil
+}
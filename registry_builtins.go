@@ -0,0 +1,633 @@
+package admissioncontrol
+
+import (
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+	core "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// cloudProviderNames maps the (lower-case) names of the CloudProviders, as
+// accepted in config, to their values.
+var cloudProviderNames = map[string]CloudProvider{
+	"gcp":       GCP,
+	"azure":     Azure,
+	"aws":       AWS,
+	"openstack": OpenStack,
+}
+
+// The built-in AdmitFuncs whose parameters can be expressed as config register
+// themselves under the kebab-case form of their name. AdmitFuncs that take Go
+// values - funcs (e.g. EnforcePodAnnotations), informers (e.g.
+// EnforceMaxPVCsPerNamespace), or interfaces (e.g. RequireSignedImages) - are
+// not registered: register them yourself with RegisterAdmitFuncFactory.
+func init() {
+	for name, constructor := range map[string]func([]string) AdmitFunc{
+		"deny-control-plane-scheduling":         DenyControlPlaneScheduling,
+		"deny-default-service-account":          DenyDefaultServiceAccount,
+		"deny-duplicate-container-ports":        DenyDuplicateContainerPorts,
+		"deny-ingresses":                        DenyIngresses,
+		"deny-mutable-config-data":              DenyMutableConfigData,
+		"deny-nginx-snippet-annotations":        DenyNginxSnippetAnnotations,
+		"deny-pvc-storage-class-change":         DenyPVCStorageClassChange,
+		"deny-statefulset-selector-change":      DenyStatefulSetSelectorChange,
+		"enforce-network-capabilities":          EnforceNetworkCapabilities,
+		"enforce-pull-policy-for-digest-images": EnforcePullPolicyForDigestImages,
+		"require-image-digest":                  RequireImageDigest,
+		"validate-resource-quantities":          ValidateResourceQuantities,
+	} {
+		RegisterAdmitFuncFactory(name, namespacedFactory(constructor))
+	}
+
+	RegisterAdmitFuncFactory("deny-public-load-balancers", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Provider          string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		provider, ok := cloudProviderNames[strings.ToLower(p.Provider)]
+		if !ok {
+			return nil, xerrors.Errorf("invalid provider %q: must be one of gcp, azure, aws or openstack", p.Provider)
+		}
+
+		return DenyPublicLoadBalancers(p.IgnoredNamespaces, provider), nil
+	})
+
+	RegisterAdmitFuncFactory("enforce-cronjob-policy", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			CronJobPolicy
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceCronJobPolicy(p.IgnoredNamespaces, p.CronJobPolicy), nil
+	})
+
+	RegisterAdmitFuncFactory("enforce-job-policy", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			JobPolicy
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceJobPolicy(p.IgnoredNamespaces, p.JobPolicy), nil
+	})
+
+	RegisterAdmitFuncFactory("deny-host-ports", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			AllowedPorts      []int32
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return DenyHostPorts(p.IgnoredNamespaces, p.AllowedPorts), nil
+	})
+
+	RegisterAdmitFuncFactory("deny-service-type-change", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces  []string
+			AllowedTransitions []ServiceTypeTransition
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return DenyServiceTypeChange(p.IgnoredNamespaces, p.AllowedTransitions...), nil
+	})
+
+	RegisterAdmitFuncFactory("require-load-balancer-source-ranges", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			PublicAnnotations []string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return RequireLoadBalancerSourceRanges(p.IgnoredNamespaces, p.PublicAnnotations...), nil
+	})
+
+	RegisterAdmitFuncFactory("deny-annotations", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Denied            []string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return DenyAnnotations(p.IgnoredNamespaces, p.Denied)
+	})
+
+	RegisterAdmitFuncFactory("enforce-container-naming", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Pattern           string
+			DeniedNames       []string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceContainerNaming(p.IgnoredNamespaces, p.Pattern, p.DeniedNames...)
+	})
+
+	RegisterAdmitFuncFactory("enforce-image-tag-pattern", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Pattern           string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceImageTagPattern(p.IgnoredNamespaces, p.Pattern)
+	})
+
+	RegisterAdmitFuncFactory("enforce-termination-grace-period", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Min               int64
+			Max               int64
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceTerminationGracePeriod(p.IgnoredNamespaces, p.Min, p.Max), nil
+	})
+
+	RegisterAdmitFuncFactory("enforce-anti-affinity-topology-key", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			RequiredKey       string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceAntiAffinityTopologyKey(p.IgnoredNamespaces, p.RequiredKey), nil
+	})
+
+	RegisterAdmitFuncFactory("protect-finalizers", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces   []string
+			ProtectedFinalizers []string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return ProtectFinalizers(p.IgnoredNamespaces, p.ProtectedFinalizers), nil
+	})
+
+	RegisterAdmitFuncFactory("enforce-container-arg-limits", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			MaxEnvVars        int
+			MaxArgLength      int
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceContainerArgLimits(p.IgnoredNamespaces, p.MaxEnvVars, p.MaxArgLength), nil
+	})
+
+	RegisterAdmitFuncFactory("require-ingress-https-redirect", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces     []string
+			ControllerAnnotations map[string]string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return RequireIngressHTTPSRedirect(p.IgnoredNamespaces, p.ControllerAnnotations), nil
+	})
+
+	RegisterAdmitFuncFactory("enforce-ingress-path-type", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Allowed           []networking.PathType
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceIngressPathType(p.IgnoredNamespaces, p.Allowed), nil
+	})
+
+	RegisterAdmitFuncFactory("enforce-ingress-tls-version", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces    []string
+			MinVersion           string
+			ControllerAnnotation string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceIngressTLSVersion(p.IgnoredNamespaces, p.MinVersion, p.ControllerAnnotation)
+	})
+
+	RegisterAdmitFuncFactory("enforce-max-replicas", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Max               int32
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceMaxReplicas(p.IgnoredNamespaces, p.Max), nil
+	})
+
+	RegisterAdmitFuncFactory("deny-service-account-token-host-mount", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			// MaxExpiration is a duration, e.g. "1h": it defaults to 24h if
+			// unset.
+			MaxExpiration string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		maxExpiration, err := parseDurationParam("maxExpiration", p.MaxExpiration)
+		if err != nil {
+			return nil, err
+		}
+
+		return DenyServiceAccountTokenHostMount(p.IgnoredNamespaces, maxExpiration), nil
+	})
+
+	RegisterAdmitFuncFactory("require-init-container", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Name              string
+			TriggerAnnotation string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return RequireInitContainer(p.IgnoredNamespaces, p.Name, p.TriggerAnnotation), nil
+	})
+
+	RegisterAdmitFuncFactory("deny-services-without-selector", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces            []string
+			AllowHeadlessAndExternalName bool
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return DenyServicesWithoutSelector(p.IgnoredNamespaces, p.AllowHeadlessAndExternalName), nil
+	})
+
+	RegisterAdmitFuncFactory("enforce-metadata-limits", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces  []string
+			MaxLabels          int
+			MaxAnnotations     int
+			MaxAnnotationBytes int
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceMetadataLimits(p.IgnoredNamespaces, p.MaxLabels, p.MaxAnnotations, p.MaxAnnotationBytes), nil
+	})
+
+	RegisterAdmitFuncFactory("enforce-runtime-classes", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces   []string
+			Allowed             []string
+			RequireRuntimeClass bool
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceRuntimeClasses(p.IgnoredNamespaces, p.Allowed, p.RequireRuntimeClass), nil
+	})
+
+	RegisterAdmitFuncFactory("deny-downward-api-sensitive-fields", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			DeniedFieldPaths  []string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return DenyDownwardAPISensitiveFields(p.IgnoredNamespaces, p.DeniedFieldPaths), nil
+	})
+
+	RegisterAdmitFuncFactory("deny-memory-backed-empty-dir", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			MaxSize           resource.Quantity
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return DenyMemoryBackedEmptyDir(p.IgnoredNamespaces, p.MaxSize), nil
+	})
+
+	RegisterAdmitFuncFactory("require-readiness-gates", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces      []string
+			RequiredConditionTypes []string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return RequireReadinessGates(p.IgnoredNamespaces, p.RequiredConditionTypes), nil
+	})
+
+	RegisterAdmitFuncFactory("enforce-load-balancer-class", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Allowed           []string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceLoadBalancerClass(p.IgnoredNamespaces, p.Allowed), nil
+	})
+
+	for name, constructor := range map[string]func([]string, string) AdmitFunc{
+		"enforce-guaranteed-qos-for-pinned-workloads": EnforceGuaranteedQoSForPinnedWorkloads,
+		"require-pre-stop-hook":                       RequirePreStopHook,
+	} {
+		constructor := constructor
+		RegisterAdmitFuncFactory(name, func(params map[string]interface{}) (AdmitFunc, error) {
+			var p struct {
+				IgnoredNamespaces []string
+				TriggerAnnotation string
+			}
+			if err := DecodeParams(params, &p); err != nil {
+				return nil, err
+			}
+
+			return constructor(p.IgnoredNamespaces, p.TriggerAnnotation), nil
+		})
+	}
+
+	RegisterAdmitFuncFactory("enforce-allowed-secret-types", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Allowed           []core.SecretType
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceAllowedSecretTypes(p.IgnoredNamespaces, p.Allowed), nil
+	})
+
+	RegisterAdmitFuncFactory("enforce-dns-policy", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces      []string
+			Allowed                []core.DNSPolicy
+			AllowedNameserverCIDRs []string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceDNSPolicy(p.IgnoredNamespaces, p.Allowed, p.AllowedNameserverCIDRs)
+	})
+
+	RegisterAdmitFuncFactory("enforce-rollout-strategy", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			RolloutStrategyLimits
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceRolloutStrategy(p.IgnoredNamespaces, p.RolloutStrategyLimits)
+	})
+
+	RegisterAdmitFuncFactory("deny-reserved-labels", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Reserved          []string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return DenyReservedLabels(p.IgnoredNamespaces, p.Reserved), nil
+	})
+
+	RegisterAdmitFuncFactory("enforce-app-armor-profile", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Allowed           []string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceAppArmorProfile(p.IgnoredNamespaces, p.Allowed), nil
+	})
+
+	RegisterAdmitFuncFactory("require-env-vars", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Required          []string
+			TriggerAnnotation string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return RequireEnvVars(p.IgnoredNamespaces, p.Required, p.TriggerAnnotation), nil
+	})
+
+	RegisterAdmitFuncFactory("validate-service-monitor", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			AllowedSchemes    []string
+			DeniedTargets     []string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return ValidateServiceMonitor(p.IgnoredNamespaces, p.AllowedSchemes, p.DeniedTargets)
+	})
+
+	RegisterAdmitFuncFactory("enforce-fs-group-range", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Ranges            []IDRange
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceFSGroupRange(p.IgnoredNamespaces, p.Ranges...)
+	})
+
+	RegisterAdmitFuncFactory("enforce-ephemeral-storage-limits", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Max               resource.Quantity
+			RequireLimit      bool
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceEphemeralStorageLimits(p.IgnoredNamespaces, p.Max, p.RequireLimit), nil
+	})
+
+	RegisterAdmitFuncFactory("enforce-max-resource-limits", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			MaxCPU            resource.Quantity
+			MaxMemory         resource.Quantity
+			CapPodTotal       bool
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceMaxResourceLimits(p.IgnoredNamespaces, p.MaxCPU, p.MaxMemory, p.CapPodTotal), nil
+	})
+
+	RegisterAdmitFuncFactory("deny-stale-updates", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			// MaxClockSkew is a duration, e.g. "5s".
+			MaxClockSkew string
+			// Kinds are e.g. [{"group": "example.com", "kind": "Widget"}].
+			Kinds []metav1.GroupKind
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		maxClockSkew, err := parseDurationParam("maxClockSkew", p.MaxClockSkew)
+		if err != nil {
+			return nil, err
+		}
+
+		return DenyStaleUpdates(maxClockSkew, p.Kinds...)
+	})
+
+	RegisterAdmitFuncFactory("enforce-init-container-limits", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Max               core.ResourceRequirements
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceInitContainerLimits(p.IgnoredNamespaces, p.Max), nil
+	})
+
+	RegisterAdmitFuncFactory("deny-privileged-service-ports", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Threshold         int32
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return DenyPrivilegedServicePorts(p.IgnoredNamespaces, p.Threshold)
+	})
+
+	RegisterAdmitFuncFactory("enforce-container-port-range", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			Min               int32
+			Max               int32
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceContainerPortRange(p.IgnoredNamespaces, p.Min, p.Max), nil
+	})
+
+	RegisterAdmitFuncFactory("enforce-max-volumes", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			MaxVolumes        int
+			MaxClaims         int
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceMaxVolumes(p.IgnoredNamespaces, p.MaxVolumes, p.MaxClaims), nil
+	})
+
+	RegisterAdmitFuncFactory("enforce-max-skew", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+			TopologyKey       string
+			MaxSkew           int32
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return EnforceMaxSkew(p.IgnoredNamespaces, p.TopologyKey, p.MaxSkew)
+	})
+}
+
+// namespacedFactory returns an AdmitFuncFactory for a constructor whose only
+// parameter is its ignoredNamespaces.
+func namespacedFactory(constructor func(ignoredNamespaces []string) AdmitFunc) AdmitFuncFactory {
+	return func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			IgnoredNamespaces []string
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return constructor(p.IgnoredNamespaces), nil
+	}
+}
+
+// parseDurationParam parses a duration parameter (e.g. "90s"), which is 0 if
+// unset.
+func parseDurationParam(name string, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, xerrors.Errorf("invalid %s %q: %w", name, value, err)
+	}
+
+	return duration, nil
+}
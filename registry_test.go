@@ -0,0 +1,207 @@
+package admissioncontrol
+
+import (
+	"strings"
+	"testing"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBuildAdmitFunc(t *testing.T) {
+	t.Parallel()
+
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	t.Run("Build a built-in AdmitFunc from its parameters", func(t *testing.T) {
+		admitFunc, err := BuildAdmitFunc("require-image-digest", map[string]interface{}{
+			"ignoredNamespaces": []interface{}{"kube-system"},
+		})
+		if err != nil {
+			t.Fatalf("failed to build the AdmitFunc: %v", err)
+		}
+
+		runObjectTests(t, []objectTest{
+			{
+				testName:        "Reject images that are not pinned to a digest",
+				kind:            podKind,
+				object:          newTestPodWithImages("default", "web:v1.0.0"),
+				expectedMessage: `Pod default/web has images that are not pinned to a sha256 digest: spec.containers[container-0].image: "web:v1.0.0" is not pinned to a sha256 digest`,
+				shouldAllow:     false,
+			},
+			{
+				testName:        "Allow images in the ignored namespaces",
+				kind:            podKind,
+				object:          newTestPodWithImages("kube-system", "web:v1.0.0"),
+				expectedMessage: "",
+				shouldAllow:     true,
+			},
+		}, func(tt objectTest) AdmitFunc {
+			return admitFunc
+		})
+	})
+
+	t.Run("Decode quantities and nested parameters", func(t *testing.T) {
+		admitFunc, err := BuildAdmitFunc("enforce-max-resource-limits", map[string]interface{}{
+			"maxCPU":    "2",
+			"maxMemory": "4Gi",
+		})
+		if err != nil {
+			t.Fatalf("failed to build the AdmitFunc: %v", err)
+		}
+
+		runObjectTests(t, []objectTest{
+			{
+				testName:        "Allow Pods without resources",
+				kind:            podKind,
+				object:          newTestPodWithImages("default", "web:v1.0.0"),
+				expectedMessage: "",
+				shouldAllow:     true,
+			},
+		}, func(tt objectTest) AdmitFunc {
+			return admitFunc
+		})
+
+		if _, err := BuildAdmitFunc("enforce-fs-group-range", map[string]interface{}{
+			"ranges": []interface{}{map[string]interface{}{"min": 1000, "max": 2000}},
+		}); err != nil {
+			t.Fatalf("failed to build the AdmitFunc: %v", err)
+		}
+	})
+
+	t.Run("Default unset parameters", func(t *testing.T) {
+		admitFunc, err := BuildAdmitFunc("deny-service-account-token-host-mount", map[string]interface{}{
+			"ignoredNamespaces": []interface{}{"kube-system"},
+		})
+		if err != nil {
+			t.Fatalf("failed to build the AdmitFunc: %v", err)
+		}
+
+		runObjectTests(t, []objectTest{
+			{
+				testName:        "Allow Pods without projected tokens",
+				kind:            podKind,
+				object:          newTestPodWithImages("default", "web:v1.0.0"),
+				expectedMessage: "",
+				shouldAllow:     true,
+			},
+			{
+				testName:        "Reject tokens beyond the default maximum expiration",
+				kind:            podKind,
+				rawObject:       []byte(`{"kind":"Pod","apiVersion":"v1","metadata":{"name":"web","namespace":"default"},"spec":{"containers":[{"name":"web","image":"web:v1.0.0"}],"volumes":[{"name":"token","projected":{"sources":[{"serviceAccountToken":{"path":"token","expirationSeconds":172800}}]}}]}}`),
+				expectedMessage: "Pod default/web projects service account tokens insecurely: spec.volumes[token].projected.sources[0].serviceAccountToken.expirationSeconds: 172800 exceeds the maximum of 86400",
+				shouldAllow:     false,
+			},
+		}, func(tt objectTest) AdmitFunc {
+			return admitFunc
+		})
+	})
+
+	var errorTests = []struct {
+		testName        string
+		name            string
+		params          map[string]interface{}
+		expectedMessage string
+	}{
+		{
+			testName:        "Reject unregistered names",
+			name:            "deny-everything",
+			expectedMessage: `no AdmitFunc is registered as "deny-everything"`,
+		},
+		{
+			testName:        "Reject unknown parameters",
+			name:            "deny-ingresses",
+			params:          map[string]interface{}{"ignoredNamespace": []interface{}{"kube-system"}},
+			expectedMessage: `invalid parameters for deny-ingresses: could not decode the parameters: json: unknown field "ignoredNamespace"`,
+		},
+		{
+			testName:        "Reject parameters of the wrong type",
+			name:            "enforce-max-replicas",
+			params:          map[string]interface{}{"max": "ten"},
+			expectedMessage: "invalid parameters for enforce-max-replicas: could not decode the parameters: json: cannot unmarshal string into Go struct field",
+		},
+		{
+			testName:        "Reject invalid durations",
+			name:            "deny-service-account-token-host-mount",
+			params:          map[string]interface{}{"maxExpiration": "an hour"},
+			expectedMessage: `invalid parameters for deny-service-account-token-host-mount: invalid maxExpiration "an hour": time: invalid duration "an hour"`,
+		},
+		{
+			testName:        "Reject parameters that the constructor rejects",
+			name:            "enforce-max-skew",
+			params:          map[string]interface{}{"topologyKey": "topology.kubernetes.io/zone"},
+			expectedMessage: "invalid parameters for enforce-max-skew: invalid maxSkew 0: the maxSkew must be at least 1",
+		},
+		{
+			testName:        "Reject unknown cloud providers",
+			name:            "deny-public-load-balancers",
+			params:          map[string]interface{}{"provider": "on-prem"},
+			expectedMessage: `invalid parameters for deny-public-load-balancers: invalid provider "on-prem": must be one of gcp, azure, aws or openstack`,
+		},
+	}
+
+	for _, tt := range errorTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			_, err := BuildAdmitFunc(tt.name, tt.params)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+
+			// The messages of encoding/json vary across Go versions beyond
+			// their prefix.
+			if !strings.HasPrefix(err.Error(), tt.expectedMessage) {
+				t.Fatalf(testErrMessageMismatch, err.Error(), tt.expectedMessage)
+			}
+		})
+	}
+}
+
+func TestRegisterAdmitFuncFactory(t *testing.T) {
+	t.Parallel()
+
+	RegisterAdmitFuncFactory("test-deny-all", func(params map[string]interface{}) (AdmitFunc, error) {
+		var p struct {
+			Message string `json:"message"`
+		}
+		if err := DecodeParams(params, &p); err != nil {
+			return nil, err
+		}
+
+		return Not(allowAll, p.Message), nil
+	})
+
+	admitFunc, err := BuildAdmitFunc("test-deny-all", map[string]interface{}{"message": "denied by config"})
+	if err != nil {
+		t.Fatalf("failed to build the AdmitFunc: %v", err)
+	}
+
+	if _, err := admitFunc(newTestAdmissionRequest(meta.GroupVersionKind{Kind: "Pod", Version: "v1"}, nil, false)); err == nil || err.Error() != "denied by config" {
+		t.Fatalf("expected the registered AdmitFunc to deny the request: got %v", err)
+	}
+
+	names := strings.Join(RegisteredAdmitFuncs(), ",")
+	for _, name := range []string{"deny-ingresses", "enforce-max-skew", "test-deny-all"} {
+		if !strings.Contains(names, name) {
+			t.Fatalf("expected %s to be registered: got %s", name, names)
+		}
+	}
+
+	for _, register := range []func(){
+		func() {
+			RegisterAdmitFuncFactory("test-deny-all", func(map[string]interface{}) (AdmitFunc, error) { return allowAll, nil })
+		},
+		func() {
+			RegisterAdmitFuncFactory("", func(map[string]interface{}) (AdmitFunc, error) { return allowAll, nil })
+		},
+		func() { RegisterAdmitFuncFactory("test-nil", nil) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected RegisterAdmitFuncFactory to panic")
+				}
+			}()
+			register()
+		}()
+	}
+}
@@ -0,0 +1,105 @@
+// Package rego provides an AdmitFunc that evaluates admission requests against
+// Rego (Open Policy Agent) policies, allowing existing Rego policies to be
+// reused without deploying a full policy engine.
+//
+// It is a separate module, so that users of admissioncontrol that do not use
+// Rego do not depend on OPA.
+package rego
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	opa "github.com/open-policy-agent/opa/v1/rego"
+	admissioncontrol "github.com/tonyo/admission-control"
+	"golang.org/x/xerrors"
+	admission "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RegoAdmitFunc evaluates admission requests against a Rego policy. The policy
+// is compiled once: compilation errors are returned to the caller.
+//
+// The query is evaluated with the AdmissionRequest as its input (e.g.
+// input.object, input.oldObject, input.operation and input.userInfo), and is
+// expected to produce a list (or set) of violation messages, e.g.
+//
+//	package admission
+//
+//	deny contains msg if {
+//		input.object.spec.hostNetwork
+//		msg := sprintf("%s may not use the host network", [input.object.metadata.name])
+//	}
+//
+// evaluated with the query "data.admission.deny". The request is denied if any
+// messages are produced, and the messages are included in the denial. An
+// undefined query result allows the request.
+func RegoAdmitFunc(policy string, query string) (admissioncontrol.AdmitFunc, error) {
+	prepared, err := opa.New(
+		opa.Query(query),
+		opa.Module("policy.rego", policy),
+	).PrepareForEval(context.Background())
+	if err != nil {
+		return nil, xerrors.Errorf("invalid Rego policy: %w", err)
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		resp := &admission.AdmissionResponse{
+			Allowed: false,
+			Result:  &metav1.Status{},
+		}
+
+		// Round-trip the request through JSON, so that the policy sees the same
+		// document as the API server sent. Numbers are decoded as json.Number to
+		// retain their precision.
+		raw, err := json.Marshal(admissionReview.Request)
+		if err != nil {
+			return nil, err
+		}
+
+		var input interface{}
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		if err := decoder.Decode(&input); err != nil {
+			return nil, err
+		}
+
+		results, err := prepared.Eval(context.Background(), opa.EvalInput(input))
+		if err != nil {
+			return nil, xerrors.Errorf("failed to evaluate the Rego policy: %w", err)
+		}
+
+		var violations []string
+		for _, result := range results {
+			for _, expression := range result.Expressions {
+				messages, ok := expression.Value.([]interface{})
+				if !ok {
+					return nil, xerrors.Errorf("the Rego query %q must produce a list of violation messages, got %T", query, expression.Value)
+				}
+
+				for _, message := range messages {
+					msg, ok := message.(string)
+					if !ok {
+						return nil, xerrors.Errorf("the Rego query %q must produce a list of violation messages, got a %T", query, message)
+					}
+					violations = append(violations, msg)
+				}
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, xerrors.Errorf(
+				"%s %s/%s violates the Rego policy: %s",
+				admissionReview.Request.Kind.Kind,
+				admissionReview.Request.Namespace,
+				admissionReview.Request.Name,
+				strings.Join(violations, "; "),
+			)
+		}
+
+		resp.Allowed = true
+		return resp, nil
+	}, nil
+}
@@ -0,0 +1,139 @@
+package rego
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	admission "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testPolicy = `
+package admission
+
+deny contains msg if {
+	input.object.spec.hostNetwork
+	msg := sprintf("%s may not use the host network", [input.object.metadata.name])
+}
+
+deny contains msg if {
+	input.operation == "CREATE"
+	not input.object.metadata.labels.team
+	msg := "the team label is required"
+}
+`
+
+// newTestReview returns an AdmissionReview for a Pod named "web" in the
+// default namespace.
+func newTestReview(t *testing.T, operation admission.Operation, hostNetwork bool, labels map[string]string) *admission.AdmissionReview {
+	t.Helper()
+
+	pod := &corev1.Pod{
+		TypeMeta:   meta.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+		ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default", Labels: labels},
+		Spec:       corev1.PodSpec{HostNetwork: hostNetwork},
+	}
+
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatalf("could not marshal k8s API object: %v", err)
+	}
+
+	review := &admission.AdmissionReview{Request: &admission.AdmissionRequest{
+		Kind:      meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+		Operation: operation,
+		Namespace: "default",
+		Name:      "web",
+	}}
+	review.Request.Object.Raw = raw
+
+	return review
+}
+
+func TestRegoAdmitFunc(t *testing.T) {
+	t.Parallel()
+
+	admitFunc, err := RegoAdmitFunc(testPolicy, "data.admission.deny")
+	if err != nil {
+		t.Fatalf("failed to compile the Rego policy: %v", err)
+	}
+
+	var regoTests = []struct {
+		testName        string
+		operation       admission.Operation
+		hostNetwork     bool
+		labels          map[string]string
+		expectedMessage string
+		shouldAllow     bool
+	}{
+		{
+			testName:    "Allow a Pod without violations",
+			operation:   admission.Create,
+			labels:      map[string]string{"team": "platform"},
+			shouldAllow: true,
+		},
+		{
+			testName:    "Allow an update without violations",
+			operation:   admission.Update,
+			shouldAllow: true,
+		},
+		{
+			testName:        "Reject a Pod with a violation",
+			operation:       admission.Update,
+			hostNetwork:     true,
+			expectedMessage: "Pod default/web violates the Rego policy: web may not use the host network",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject a Pod with multiple violations",
+			operation:       admission.Create,
+			hostNetwork:     true,
+			expectedMessage: "Pod default/web violates the Rego policy: the team label is required; web may not use the host network",
+			shouldAllow:     false,
+		},
+	}
+
+	for _, tt := range regoTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			resp, err := admitFunc(newTestReview(t, tt.operation, tt.hostNetwork, tt.labels))
+			if err != nil {
+				if tt.shouldAllow {
+					t.Fatalf("incorrectly rejected admission: %s", err.Error())
+				}
+
+				if err.Error() != tt.expectedMessage {
+					t.Fatalf("error message does not match: got %q - expected %q", err.Error(), tt.expectedMessage)
+				}
+				return
+			}
+
+			if !tt.shouldAllow || !resp.Allowed {
+				t.Fatalf("incorrectly allowed admission: %v", resp)
+			}
+		})
+	}
+}
+
+func TestRegoAdmitFuncInvalidPolicy(t *testing.T) {
+	t.Parallel()
+
+	if _, err := RegoAdmitFunc("package admission\n\ndeny contains msg if {", "data.admission.deny"); err == nil {
+		t.Fatalf("expected an error for an invalid Rego policy")
+	}
+}
+
+func TestRegoAdmitFuncNonListResult(t *testing.T) {
+	t.Parallel()
+
+	admitFunc, err := RegoAdmitFunc("package admission\n\nallow := true", "data.admission.allow")
+	if err != nil {
+		t.Fatalf("failed to compile the Rego policy: %v", err)
+	}
+
+	if _, err := admitFunc(newTestReview(t, admission.Create, false, nil)); err == nil || !strings.Contains(err.Error(), "must produce a list of violation messages") {
+		t.Fatalf("expected an error for a non-list query result, got: %v", err)
+	}
+}
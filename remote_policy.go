@@ -0,0 +1,205 @@
+package admissioncontrol
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/xerrors"
+	admission "k8s.io/api/admission/v1"
+)
+
+var (
+	// defaultRemotePolicyTimeout bounds the total time spent evaluating a
+	// request against a remote policy service, including retries. It must
+	// remain below the webhook timeoutSeconds configured on the API server.
+	defaultRemotePolicyTimeout = time.Second * 5
+	// defaultRemotePolicyRetries is the number of times a failed request to a
+	// remote policy service is retried.
+	defaultRemotePolicyRetries = 2
+	// defaultRemotePolicyBackoff is the delay before the first retry; it doubles
+	// on each subsequent retry.
+	defaultRemotePolicyBackoff = time.Millisecond * 100
+	// maxRemotePolicyResponseBytes limits the size of a remote policy service
+	// response.
+	maxRemotePolicyResponseBytes int64 = 1024 * 1024
+)
+
+// remotePolicy holds the configuration of a RemotePolicyAdmitFunc.
+type remotePolicy struct {
+	endpoint      string
+	client        *http.Client
+	timeout       time.Duration
+	failurePolicy FailurePolicy
+	retries       int
+	backoff       time.Duration
+}
+
+// RemotePolicyOption configures a RemotePolicyAdmitFunc.
+type RemotePolicyOption func(*remotePolicy)
+
+// RemotePolicyTimeout sets the total time allowed for evaluating a request,
+// including retries. Defaults to 5 seconds.
+func RemotePolicyTimeout(timeout time.Duration) RemotePolicyOption {
+	return func(rp *remotePolicy) {
+		rp.timeout = timeout
+	}
+}
+
+// RemotePolicyFailurePolicy determines whether requests are allowed (FailOpen)
+// or denied (FailClosed) when the remote policy service cannot be reached, or
+// does not return a verdict. Defaults to FailClosed.
+func RemotePolicyFailurePolicy(failurePolicy FailurePolicy) RemotePolicyOption {
+	return func(rp *remotePolicy) {
+		rp.failurePolicy = failurePolicy
+	}
+}
+
+// RemotePolicyRetries sets the number of retries for failed requests, and the
+// delay before the first retry, which doubles on each subsequent retry.
+// Defaults to 2 retries, with an initial backoff of 100ms.
+func RemotePolicyRetries(retries int, backoff time.Duration) RemotePolicyOption {
+	return func(rp *remotePolicy) {
+		rp.retries = retries
+		rp.backoff = backoff
+	}
+}
+
+// RemotePolicyAdmitFunc forwards admission requests to an external policy
+// service, and returns its verdict. This allows policy logic to be maintained
+// in a separate service, with the webhook acting as a dispatcher.
+//
+// The contract with the policy service is:
+//
+//   - The AdmissionReview is sent as a POST request to the endpoint, with a
+//     Content-Type of application/json.
+//   - The service responds with a 200 OK and an AdmissionReview (JSON) with its
+//     response set. The response's allowed field is the verdict, and
+//     status.message is included in the denial (or allowance). Any
+//     auditAnnotations on the response are retained. Patches are ignored.
+//   - Any other status code, or a response without a verdict, is treated as a
+//     failure to evaluate the request. Connection errors, 429 and 5xx
+//     responses are retried with an exponential backoff.
+//
+// When the service cannot be reached - or all retries have failed - the
+// request is denied by default. Pass RemotePolicyFailurePolicy(FailOpen) to
+// allow requests instead.
+//
+// AdmitFuncs are not passed the context of the incoming request: requests to
+// the policy service are instead bounded by the configured timeout (see
+// RemotePolicyTimeout), which should be shorter than the webhook's
+// timeoutSeconds. If the client is nil, a default http.Client is used.
+func RemotePolicyAdmitFunc(endpoint string, client *http.Client, opts ...RemotePolicyOption) AdmitFunc {
+	rp := &remotePolicy{
+		endpoint: endpoint,
+		client:   client,
+		timeout:  defaultRemotePolicyTimeout,
+		retries:  defaultRemotePolicyRetries,
+		backoff:  defaultRemotePolicyBackoff,
+	}
+	if rp.client == nil {
+		rp.client = &http.Client{}
+	}
+
+	for _, opt := range opts {
+		opt(rp)
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		resp := newDefaultDenyResponse()
+
+		ctx, cancel := context.WithTimeout(context.Background(), rp.timeout)
+		defer cancel()
+
+		verdict, err := rp.evaluate(ctx, admissionReview)
+		if err != nil {
+			if rp.failurePolicy == FailOpen {
+				resp.Allowed = true
+				resp.Result.Message = fmt.Sprintf("allowing admission: the remote policy service could not evaluate the request (%s): %s", rp.failurePolicy, err)
+				return resp, nil
+			}
+
+			return resp, xerrors.Errorf("the remote policy service could not evaluate the request (%s): %w", rp.failurePolicy, err)
+		}
+
+		resp.AuditAnnotations = verdict.AuditAnnotations
+		var message string
+		if verdict.Result != nil {
+			message = verdict.Result.Message
+		}
+
+		if !verdict.Allowed {
+			return resp, xerrors.Errorf("denied by the remote policy service: %s", message)
+		}
+
+		resp.Allowed = true
+		resp.Result.Message = message
+		return resp, nil
+	}
+}
+
+// evaluate sends the AdmissionReview to the policy service, retrying failed
+// requests until the retries are exhausted or the context expires.
+func (rp *remotePolicy) evaluate(ctx context.Context, admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+	review := admission.AdmissionReview{Request: admissionReview.Request}
+	review.APIVersion = "admission.k8s.io/v1"
+	review.Kind = "AdmissionReview"
+
+	body, err := json.Marshal(review)
+	if err != nil {
+		return nil, err
+	}
+
+	var verdict *admission.AdmissionResponse
+	err = RetryWithBackoff(ctx, rp.retries, rp.backoff, func() (bool, error) {
+		resp, retryable, sendErr := rp.send(ctx, body)
+		verdict = resp
+		return retryable, sendErr
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return verdict, nil
+}
+
+// send makes a single request to the policy service, returning its verdict, or
+// an error & whether the request can be retried.
+func (rp *remotePolicy) send(ctx context.Context, body []byte) (*admission.AdmissionResponse, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rp.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := rp.client.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		retryable := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError
+		return nil, retryable, xerrors.Errorf("unexpected response status: %s", res.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxRemotePolicyResponseBytes))
+	if err != nil {
+		return nil, true, err
+	}
+
+	verdict := admission.AdmissionReview{}
+	if err := json.Unmarshal(data, &verdict); err != nil {
+		return nil, false, xerrors.Errorf("could not decode the response: %w", err)
+	}
+
+	if verdict.Response == nil {
+		return nil, false, xerrors.New("the response did not include a verdict")
+	}
+
+	return verdict.Response, false, nil
+}
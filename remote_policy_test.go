@@ -0,0 +1,204 @@
+package admissioncontrol
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	admission "k8s.io/api/admission/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTestPolicyService returns a policy service that fails the first failures
+// requests with the status code, and then responds with the verdict. The
+// number of requests received is recorded in requests.
+func newTestPolicyService(t *testing.T, failures int32, status int, verdict *admission.AdmissionResponse, requests *int32) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(requests, 1)
+		if n <= failures {
+			w.WriteHeader(status)
+			return
+		}
+
+		review := admission.AdmissionReview{}
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil || review.Request == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		resp := *verdict
+		resp.UID = review.Request.UID
+		json.NewEncoder(w).Encode(admission.AdmissionReview{Response: &resp})
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestRemotePolicyAdmitFunc(t *testing.T) {
+	t.Parallel()
+
+	allowed := &admission.AdmissionResponse{Allowed: true, Result: &meta.Status{Message: "looks good"}}
+	denied := &admission.AdmissionResponse{
+		Allowed:          false,
+		Result:           &meta.Status{Message: "hostNetwork is not allowed"},
+		AuditAnnotations: map[string]string{"policy": "no-host-network"},
+	}
+	retries := RemotePolicyRetries(2, time.Millisecond)
+
+	var remoteTests = []struct {
+		testName         string
+		failures         int32
+		status           int
+		verdict          *admission.AdmissionResponse
+		opts             []RemotePolicyOption
+		unreachable      bool
+		expectedRequests int32
+		expectedMessage  string
+		shouldAllow      bool
+	}{
+		{
+			testName:         "Allow when the policy service allows",
+			verdict:          allowed,
+			opts:             []RemotePolicyOption{retries},
+			expectedRequests: 1,
+			shouldAllow:      true,
+		},
+		{
+			testName:         "Reject when the policy service denies",
+			verdict:          denied,
+			opts:             []RemotePolicyOption{retries},
+			expectedRequests: 1,
+			expectedMessage:  "denied by the remote policy service: hostNetwork is not allowed",
+			shouldAllow:      false,
+		},
+		{
+			testName:         "Retry server errors",
+			failures:         2,
+			status:           http.StatusServiceUnavailable,
+			verdict:          allowed,
+			opts:             []RemotePolicyOption{retries},
+			expectedRequests: 3,
+			shouldAllow:      true,
+		},
+		{
+			testName:         "Reject when the retries are exhausted",
+			failures:         3,
+			status:           http.StatusServiceUnavailable,
+			verdict:          allowed,
+			opts:             []RemotePolicyOption{retries},
+			expectedRequests: 3,
+			expectedMessage:  "the remote policy service could not evaluate the request (FailClosed): unexpected response status: 503 Service Unavailable",
+			shouldAllow:      false,
+		},
+		{
+			testName:         "Do not retry client errors",
+			failures:         1,
+			status:           http.StatusBadRequest,
+			verdict:          allowed,
+			opts:             []RemotePolicyOption{retries},
+			expectedRequests: 1,
+			expectedMessage:  "the remote policy service could not evaluate the request (FailClosed): unexpected response status: 400 Bad Request",
+			shouldAllow:      false,
+		},
+		{
+			testName:         "Allow when the retries are exhausted with FailOpen",
+			failures:         3,
+			status:           http.StatusInternalServerError,
+			verdict:          denied,
+			opts:             []RemotePolicyOption{retries, RemotePolicyFailurePolicy(FailOpen)},
+			expectedRequests: 3,
+			shouldAllow:      true,
+		},
+		{
+			testName:        "Reject when the policy service is unreachable",
+			unreachable:     true,
+			opts:            []RemotePolicyOption{RemotePolicyRetries(0, 0)},
+			expectedMessage: "the remote policy service could not evaluate the request (FailClosed)",
+			shouldAllow:     false,
+		},
+	}
+
+	for _, tt := range remoteTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+
+			var requests int32
+			srv := newTestPolicyService(t, tt.failures, tt.status, tt.verdict, &requests)
+			endpoint := srv.URL
+			if tt.unreachable {
+				srv.Close()
+			}
+
+			review := newTestAdmissionRequest(
+				meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+				[]byte(`{"kind":"Pod","apiVersion":"v1"}`),
+				tt.shouldAllow,
+			)
+
+			resp, err := RemotePolicyAdmitFunc(endpoint, srv.Client(), tt.opts...)(review)
+			if got := atomic.LoadInt32(&requests); !tt.unreachable && got != tt.expectedRequests {
+				t.Fatalf("policy service received %d requests: expected %d", got, tt.expectedRequests)
+			}
+
+			if err != nil {
+				if tt.shouldAllow {
+					t.Fatalf("incorrectly rejected admission: %s", err.Error())
+				}
+
+				if !strings.HasPrefix(err.Error(), tt.expectedMessage) {
+					t.Fatalf(testErrMessageMismatch, err.Error(), tt.expectedMessage)
+				}
+
+				if tt.verdict == denied && resp.AuditAnnotations["policy"] != "no-host-network" {
+					t.Fatalf("audit annotations were not retained: %v", resp.AuditAnnotations)
+				}
+				return
+			}
+
+			if !tt.shouldAllow || !resp.Allowed {
+				t.Fatalf("incorrectly allowed admission: %v", resp)
+			}
+		})
+	}
+}
+
+func TestRemotePolicyAdmitFuncTimeout(t *testing.T) {
+	t.Parallel()
+
+	// The handler blocks until the test completes, so that only the client-side
+	// timeout can end the request.
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	review := newTestAdmissionRequest(
+		meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"},
+		[]byte(`{"kind":"Pod","apiVersion":"v1"}`),
+		false,
+	)
+
+	admitFunc := RemotePolicyAdmitFunc(srv.URL, srv.Client(), RemotePolicyTimeout(time.Millisecond*50))
+
+	start := time.Now()
+	if _, err := admitFunc(review); err == nil {
+		t.Fatalf("expected a timed out request to be rejected")
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("the request took %v: expected it to be bounded by the timeout", elapsed)
+	}
+}
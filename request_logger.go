@@ -53,12 +53,16 @@ func LoggingMiddleware(logger log.Logger) func(http.Handler) http.Handler {
 			start := time.Now()
 			wrapped := wrapResponseWriter(w)
 			next.ServeHTTP(wrapped, r)
-			logger.Log(
+			keyvals := []interface{}{
 				"status", wrapped.status,
 				"method", r.Method,
 				"path", r.URL.EscapedPath(),
 				"duration", time.Since(start),
-			)
+			}
+			if correlationID := CorrelationID(r.Context()); correlationID != "" {
+				keyvals = append(keyvals, "correlation_id", correlationID)
+			}
+			logger.Log(keyvals...)
 		}
 
 		return http.HandlerFunc(fn)
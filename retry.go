@@ -0,0 +1,36 @@
+package admissioncontrol
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// RetryWithBackoff calls fn until it succeeds, it returns an error that is not
+// retryable, the retries are exhausted, or the context expires - returning the
+// last error. The delay before the first retry is the backoff, which doubles
+// on each subsequent retry.
+//
+// This is intended for AdmitFuncs that query the API server (or another
+// service) directly: the retries and backoff should be bounded so that the
+// AdmitFunc returns well within the webhook's timeoutSeconds.
+func RetryWithBackoff(ctx context.Context, retries int, backoff time.Duration, fn func() (retryable bool, err error)) error {
+	for attempt := 0; ; attempt++ {
+		retryable, err := fn()
+		if err == nil {
+			return nil
+		}
+
+		if !retryable || attempt >= retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return xerrors.Errorf("%w (after %d attempts)", err, attempt+1)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
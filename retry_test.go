@@ -0,0 +1,74 @@
+package admissioncontrol
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+func TestRetryWithBackoff(t *testing.T) {
+	t.Parallel()
+
+	errUnavailable := xerrors.New("unavailable")
+	var retryTests = []struct {
+		testName         string
+		failures         int
+		retryable        bool
+		retries          int
+		expectedAttempts int
+		shouldSucceed    bool
+	}{
+		{testName: "Succeed on the first attempt", failures: 0, retryable: true, retries: 2, expectedAttempts: 1, shouldSucceed: true},
+		{testName: "Retry retryable errors", failures: 2, retryable: true, retries: 2, expectedAttempts: 3, shouldSucceed: true},
+		{testName: "Fail when the retries are exhausted", failures: 3, retryable: true, retries: 2, expectedAttempts: 3, shouldSucceed: false},
+		{testName: "Do not retry other errors", failures: 1, retryable: false, retries: 2, expectedAttempts: 1, shouldSucceed: false},
+	}
+
+	for _, tt := range retryTests {
+		tt := tt
+		t.Run(tt.testName, func(t *testing.T) {
+			t.Parallel()
+
+			var attempts int
+			err := RetryWithBackoff(context.Background(), tt.retries, time.Millisecond, func() (bool, error) {
+				attempts++
+				if attempts <= tt.failures {
+					return tt.retryable, errUnavailable
+				}
+
+				return false, nil
+			})
+
+			if attempts != tt.expectedAttempts {
+				t.Fatalf("fn was called %d times: expected %d", attempts, tt.expectedAttempts)
+			}
+
+			if succeeded := err == nil; succeeded != tt.shouldSucceed {
+				t.Fatalf("RetryWithBackoff returned %v: expected success=%t", err, tt.shouldSucceed)
+			}
+
+			if err != nil && !xerrors.Is(err, errUnavailable) {
+				t.Fatalf("the last error was not returned: got %v", err)
+			}
+		})
+	}
+}
+
+func TestRetryWithBackoffContext(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var attempts int
+	err := RetryWithBackoff(ctx, 5, time.Hour, func() (bool, error) {
+		attempts++
+		return true, xerrors.New("unavailable")
+	})
+
+	if err == nil || attempts != 1 {
+		t.Fatalf("expected a single attempt and an error once the context expired: got %d attempts, err=%v", attempts, err)
+	}
+}
@@ -0,0 +1,54 @@
+package admissioncontrol
+
+import (
+	"net/http"
+	"path"
+	"sort"
+
+	log "github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
+)
+
+// RegisterAdmitFuncs mounts an AdmissionHandler for each of the named AdmitFuncs
+// on the router, at prefix/name, accepting POST requests only. Each handler
+// logs to the provided logger.
+//
+// The registered paths are returned (sorted), so that they can be used to
+// generate the matching webhook configuration. Use RegisterHandlers to
+// configure each handler (e.g. its timeout) individually.
+func RegisterAdmitFuncs(router *mux.Router, prefix string, logger log.Logger, funcs map[string]AdmitFunc) []string {
+	handlers := make(map[string]*AdmissionHandler, len(funcs))
+	for name, admitFunc := range funcs {
+		handlers[name] = &AdmissionHandler{
+			AdmitFunc: admitFunc,
+			Logger:    log.With(logger, "admit_func", name),
+		}
+	}
+
+	return RegisterHandlers(router, prefix, handlers)
+}
+
+// RegisterHandlers mounts each of the named, fully-configured AdmissionHandlers
+// on the router, at prefix/name, accepting POST requests only. This allows the
+// same policy to be served with different parameters on different paths -
+// e.g. "restricted" and "baseline" - from a single router.
+//
+// The registered paths are returned (sorted), so that they can be used to
+// generate the matching webhook configuration. The handlers must be non-nil:
+// see NewAdmissionHandler.
+func RegisterHandlers(router *mux.Router, prefix string, handlers map[string]*AdmissionHandler) []string {
+	names := make([]string, 0, len(handlers))
+	for name := range handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		p := path.Join("/", prefix, name)
+		router.Handle(p, handlers[name]).Methods(http.MethodPost)
+		paths = append(paths, p)
+	}
+
+	return paths
+}
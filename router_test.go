@@ -0,0 +1,92 @@
+package admissioncontrol
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+	admission "k8s.io/api/admission/v1"
+)
+
+func TestRegisterAdmitFuncs(t *testing.T) {
+	t.Parallel()
+
+	router := mux.NewRouter()
+	paths := RegisterAdmitFuncs(router, "/admission-control", &noopLogger{}, map[string]AdmitFunc{
+		"deny-ingresses":      DenyIngresses(nil),
+		"allow-everything":    newTestAdmitFunc(true, false),
+		"deny-public-lbs/gcp": DenyPublicLoadBalancers(nil, GCP),
+	})
+
+	expected := []string{
+		"/admission-control/allow-everything",
+		"/admission-control/deny-ingresses",
+		"/admission-control/deny-public-lbs/gcp",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("registered paths do not match: got %v - expected %v", paths, expected)
+	}
+
+	t.Run("Registered paths accept admission requests", func(t *testing.T) {
+		req := newTestReviewRequest(t, &admission.AdmissionReview{
+			Request: &admission.AdmissionRequest{},
+		})
+		req.URL.Path = "/admission-control/allow-everything"
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("unexpected status code: got %d (wanted %d)", status, http.StatusOK)
+		}
+	})
+
+	t.Run("Registered paths only accept POST requests", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/admission-control/allow-everything", nil)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusMethodNotAllowed {
+			t.Fatalf("unexpected status code: got %d (wanted %d)", status, http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func TestRegisterHandlers(t *testing.T) {
+	t.Parallel()
+
+	restricted, err := NewAdmissionHandler(DenyIngresses(nil), &noopLogger{})
+	if err != nil {
+		t.Fatalf("could not create the handler: %v", err)
+	}
+
+	baseline, err := NewAdmissionHandler(newTestAdmitFunc(true, false), &noopLogger{}, WithAuditMode())
+	if err != nil {
+		t.Fatalf("could not create the handler: %v", err)
+	}
+
+	router := mux.NewRouter()
+	paths := RegisterHandlers(router, "policies", map[string]*AdmissionHandler{
+		"restricted": restricted,
+		"baseline":   baseline,
+	})
+
+	expected := []string{"/policies/baseline", "/policies/restricted"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("registered paths do not match: got %v - expected %v", paths, expected)
+	}
+
+	for _, p := range paths {
+		req := newTestReviewRequest(t, &admission.AdmissionReview{
+			Request: &admission.AdmissionRequest{},
+		})
+		req.URL.Path = p
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusOK {
+			t.Fatalf("unexpected status code for %s: got %d (wanted %d)", p, status, http.StatusOK)
+		}
+	}
+}
@@ -4,13 +4,16 @@ import (
 	"context"
 	"fmt"
 	"golang.org/x/xerrors"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	log "github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
 )
 
 var (
@@ -27,6 +30,10 @@ type AdmissionServer struct {
 	// GracePeriod is defines how long the server allows for in-flight connections
 	// to complete before exiting.
 	GracePeriod time.Duration
+	// socketPath & socketMode configure a Unix domain socket listener: see
+	// WithUnixSocket.
+	socketPath string
+	socketMode os.FileMode
 }
 
 func (as *AdmissionServer) shutdown(ctx context.Context, gracePeriod time.Duration) error {
@@ -35,15 +42,117 @@ func (as *AdmissionServer) shutdown(ctx context.Context, gracePeriod time.Durati
 	as.logger.Log(
 		"msg", "server shutting down",
 	)
-	return as.srv.Shutdown(timeoutCtx)
+
+	err := as.srv.Shutdown(timeoutCtx)
+	if as.socketPath != "" {
+		// Closing the listener normally removes the socket: this covers
+		// listeners that were never closed, e.g. if the grace period expired.
+		if rmErr := os.Remove(as.socketPath); rmErr != nil && !os.IsNotExist(rmErr) && err == nil {
+			err = rmErr
+		}
+	}
+
+	return err
+}
+
+// ServerOption configures an AdmissionServer. Options are applied by NewServer.
+type ServerOption func(*AdmissionServer) error
+
+// WithNotFoundHandler installs a handler on the router for requests to unknown
+// paths - e.g. from a misconfigured webhook - that logs the attempted path, and
+// responds with a message listing the valid admission paths (such as the paths
+// returned by RegisterAdmitFuncs).
+//
+// The router should be the (possibly wrapped) Handler of the *http.Server.
+func WithNotFoundHandler(router *mux.Router, admissionPaths ...string) ServerOption {
+	return func(as *AdmissionServer) error {
+		if router == nil {
+			return xerrors.New("WithNotFoundHandler requires a non-nil *mux.Router")
+		}
+
+		router.NotFoundHandler = notFoundHandler(as.logger, admissionPaths)
+		return nil
+	}
+}
+
+// WithUnixSocket serves the AdmissionServer on a Unix domain socket at the path,
+// rather than on the TCP address of the *http.Server. The socket's permissions
+// are set to mode (e.g. 0660), and the socket is removed when the server shuts
+// down. A stale socket left at the path (e.g. by a crashed process) is
+// replaced.
+//
+// Connections on the socket are served over plaintext HTTP: the TLSConfig of
+// the *http.Server is ignored. This suits deployments where a proxy - e.g. a
+// sidecar in the same Pod - terminates TLS for the API server, and forwards
+// requests to the socket, as well as local testing. Restrict the mode (and the
+// directory containing the socket) to the proxy's user or group.
+func WithUnixSocket(path string, mode os.FileMode) ServerOption {
+	return func(as *AdmissionServer) error {
+		if path == "" {
+			return xerrors.New("WithUnixSocket requires a non-empty socket path")
+		}
+
+		as.socketPath = path
+		as.socketMode = mode
+		return nil
+	}
+}
+
+// listenUnix listens on the configured Unix domain socket, replacing any stale
+// socket at its path.
+func (as *AdmissionServer) listenUnix() (net.Listener, error) {
+	if info, err := os.Lstat(as.socketPath); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, xerrors.Errorf("cannot listen on %s: the path exists and is not a socket", as.socketPath)
+		}
+
+		if err := os.Remove(as.socketPath); err != nil {
+			return nil, xerrors.Errorf("could not remove the stale socket at %s: %w", as.socketPath, err)
+		}
+	}
+
+	listener, err := net.Listen("unix", as.socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(as.socketPath, as.socketMode); err != nil {
+		listener.Close()
+		return nil, xerrors.Errorf("could not set the permissions of the socket at %s: %w", as.socketPath, err)
+	}
+
+	return listener, nil
+}
+
+// notFoundHandler returns a handler that logs requests to unknown paths, and
+// responds with the valid admission paths.
+func notFoundHandler(logger log.Logger, admissionPaths []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.Log(
+			"msg", "request to an unknown path: check the path in the webhook configuration",
+			"path", r.URL.Path,
+			"method", r.Method,
+			"valid_paths", strings.Join(admissionPaths, ","),
+		)
+
+		message := fmt.Sprintf("no admission webhook is served at %q", r.URL.Path)
+		if len(admissionPaths) > 0 {
+			message = fmt.Sprintf("%s: the valid admission paths are %s", message, strings.Join(admissionPaths, ", "))
+		}
+		http.Error(w, message, http.StatusNotFound)
+	})
 }
 
 // NewServer creates an unstarted AdmissionServer, ready to be started (via the 'Run' method).
 //
 // The provided *http.Server must have its Handler field set, as well as a valid
 // and non-nil TLSConfig. Kubernetes requires that Admission Controllers are
-// only reachable over HTTPS (TLS), whether running in-cluster or externally.
-func NewServer(srv *http.Server, logger log.Logger) (*AdmissionServer, error) {
+// only reachable over HTTPS (TLS), whether running in-cluster or externally:
+// see WithUnixSocket for serving from behind a TLS-terminating proxy.
+//
+// Any ServerOptions are applied in order: an error is returned if any option
+// fails to apply.
+func NewServer(srv *http.Server, logger log.Logger, opts ...ServerOption) (*AdmissionServer, error) {
 	if srv == nil {
 		return nil, xerrors.New("a non-nil *http.Server must be provided")
 	}
@@ -52,19 +161,25 @@ func NewServer(srv *http.Server, logger log.Logger) (*AdmissionServer, error) {
 		return nil, xerrors.New("a non-nil log.Logger must be provided")
 	}
 
-	if srv.TLSConfig == nil {
-		// Warn that TLS termination is required
-		logger.Log(
-			"msg", "the provided *http.Server has a nil TLSConfig. Admission webhooks must be served over TLS, or from behind a TLS-terminating proxy",
-		)
-	}
-
 	as := &AdmissionServer{
 		srv:         srv,
 		logger:      logger,
 		GracePeriod: defaultGracePeriod,
 	}
 
+	for _, opt := range opts {
+		if err := opt(as); err != nil {
+			return nil, err
+		}
+	}
+
+	if srv.TLSConfig == nil && as.socketPath == "" {
+		// Warn that TLS termination is required
+		logger.Log(
+			"msg", "the provided *http.Server has a nil TLSConfig. Admission webhooks must be served over TLS, or from behind a TLS-terminating proxy",
+		)
+	}
+
 	return as, nil
 }
 
@@ -95,9 +210,29 @@ func (as *AdmissionServer) Run(ctx context.Context) error {
 	errs := make(chan error)
 	defer close(errs)
 	go func() {
-		// Start a plaintext listener if no TLSConfig is provided
-		switch as.srv.TLSConfig {
-		case nil:
+		// Serve plaintext HTTP on a Unix domain socket if configured, or if no
+		// TLSConfig is provided.
+		switch {
+		case as.socketPath != "":
+			listener, err := as.listenUnix()
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			as.logger.Log(
+				"msg", fmt.Sprintf("admission control listening on unix socket '%s' (plaintext HTTP)", as.socketPath),
+			)
+
+			if err := as.srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+				errs <- err
+				as.logger.Log(
+					"err", err.Error(),
+					"msg", "the server exited",
+				)
+				return
+			}
+		case as.srv.TLSConfig == nil:
 			as.logger.Log(
 				"msg", fmt.Sprintf("admission control listening on '%s' (plaintext HTTP)", as.srv.Addr),
 			)
@@ -7,8 +7,14 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	log "github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
 )
 
 // noopLogger is a no-op type that satifies the kit.Logger interface
@@ -160,3 +166,114 @@ func TestAdmissionServer(t *testing.T) {
 	})
 
 }
+
+func TestWithNotFoundHandler(t *testing.T) {
+	t.Parallel()
+
+	router := mux.NewRouter()
+	paths := RegisterAdmitFuncs(router, "/admission-control", &noopLogger{}, map[string]AdmitFunc{
+		"deny-ingresses": DenyIngresses(nil),
+	})
+
+	var logged strings.Builder
+	srv := &http.Server{Handler: LoggingMiddleware(&noopLogger{})(router)}
+	if _, err := NewServer(srv, log.NewLogfmtLogger(&logged), WithNotFoundHandler(router, paths...)); err != nil {
+		t.Fatalf("admission server creation failed: %s", err)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admission-control/deny-ingress", nil))
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status code: got %d (wanted %d)", rr.Code, http.StatusNotFound)
+	}
+
+	expected := `no admission webhook is served at "/admission-control/deny-ingress": the valid admission paths are /admission-control/deny-ingresses`
+	if body := strings.TrimSpace(rr.Body.String()); body != expected {
+		t.Fatalf("unexpected response body: got %q (wanted %q)", body, expected)
+	}
+
+	if !strings.Contains(logged.String(), "path=/admission-control/deny-ingress ") {
+		t.Fatalf("the attempted path was not logged: %q", logged.String())
+	}
+
+	t.Run("Requires a *mux.Router", func(t *testing.T) {
+		srv := &http.Server{Handler: router}
+		if _, err := NewServer(srv, &noopLogger{}, WithNotFoundHandler(nil, paths...)); err == nil {
+			t.Fatalf("expected an error for a nil *mux.Router")
+		}
+	})
+}
+
+func TestWithUnixSocket(t *testing.T) {
+	t.Parallel()
+
+	socketPath := filepath.Join(t.TempDir(), "admission.sock")
+	// A stale socket from a previous process is replaced.
+	stale, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("failed to create a stale socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "OK")
+	})}
+	admissionServer, err := NewServer(srv, &noopLogger{}, WithUnixSocket(socketPath, 0660))
+	if err != nil {
+		t.Fatalf("admission server creation failed: %s", err)
+	}
+	admissionServer.GracePeriod = time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- admissionServer.Run(ctx)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+		},
+	}}
+
+	var resp *http.Response
+	for attempt := 0; attempt < 20; attempt++ {
+		if resp, err = client.Get("http://admission-control/"); err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	if err != nil {
+		t.Fatalf("failed to make a request over the socket: %v", err)
+	}
+	resp.Body.Close()
+
+	if status := resp.StatusCode; status != http.StatusOK {
+		t.Fatalf("unexpected status code: got %d (wanted %d)", status, http.StatusOK)
+	}
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("could not stat the socket: %v", err)
+	}
+
+	if mode := info.Mode().Perm(); mode != 0660 {
+		t.Fatalf("unexpected socket permissions: got %v (wanted %v)", mode, os.FileMode(0660))
+	}
+
+	cancel()
+	if err := <-stopped; err != nil {
+		t.Fatalf("the server did not shut down cleanly: %v", err)
+	}
+
+	if _, err := os.Stat(socketPath); !os.IsNotExist(err) {
+		t.Fatalf("the socket was not removed on shutdown: %v", err)
+	}
+
+	if _, err := NewServer(srv, &noopLogger{}, WithUnixSocket("", 0660)); err == nil {
+		t.Fatalf("expected an error for an empty socket path")
+	}
+}
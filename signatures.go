@@ -0,0 +1,185 @@
+package admissioncontrol
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+	admission "k8s.io/api/admission/v1"
+)
+
+// defaultVerificationTimeout bounds the time spent resolving & verifying the
+// images of a request. It must remain below the webhook timeoutSeconds
+// configured on the API server.
+var defaultVerificationTimeout = time.Second * 5
+
+// ImageSignatureVerifier verifies the signatures of container images: e.g.
+// using cosign and the keys (or keyless identities) that your organization
+// trusts.
+//
+// Both methods are called with a context bounded by the verification timeout
+// (see VerificationTimeout), and must be safe for concurrent use.
+type ImageSignatureVerifier interface {
+	// Resolve returns the digest (e.g. "sha256:...") that the image reference
+	// (e.g. "registry.example.com/team/app:v1.0.0") currently refers to, by
+	// querying its registry.
+	Resolve(ctx context.Context, image string) (string, error)
+	// Verify reports whether the image - a repository and digest, e.g.
+	// "registry.example.com/team/app@sha256:..." - is signed by a trusted key.
+	// An error is returned if the signatures could not be checked (e.g. the
+	// registry is unreachable), rather than if the image is not signed.
+	Verify(ctx context.Context, image string) (bool, error)
+}
+
+// signaturePolicy holds the configuration of RequireSignedImages.
+type signaturePolicy struct {
+	verifier      ImageSignatureVerifier
+	timeout       time.Duration
+	failurePolicy FailurePolicy
+}
+
+// SignatureVerificationOption configures RequireSignedImages.
+type SignatureVerificationOption func(*signaturePolicy)
+
+// VerificationTimeout sets the time allowed for resolving & verifying the
+// images of a request. Defaults to 5 seconds.
+func VerificationTimeout(timeout time.Duration) SignatureVerificationOption {
+	return func(sp *signaturePolicy) {
+		sp.timeout = timeout
+	}
+}
+
+// VerificationFailurePolicy determines whether requests are allowed
+// (FailOpen) or denied (FailClosed) when an image cannot be resolved or
+// verified. Defaults to FailClosed.
+func VerificationFailurePolicy(failurePolicy FailurePolicy) SignatureVerificationOption {
+	return func(sp *signaturePolicy) {
+		sp.failurePolicy = failurePolicy
+	}
+}
+
+// RequireSignedImages denies Pods (and the Pod templates of workloads) with
+// container images that are not signed by a trusted key, as reported by the
+// verifier. An error is returned if the verifier is nil.
+//
+// Signatures are verified against the digest of each image, rather than its
+// tag, as a tag can be moved to an unsigned image: images pinned to a digest
+// are verified at that digest, and other images are first resolved to the
+// digest they currently refer to. As the kubelet resolves tags again when it
+// pulls the image, pair RequireSignedImages with RequireImageDigest to ensure
+// that the verified image is the one that runs. Images that cannot be resolved
+// or verified are denied by default: see VerificationFailurePolicy. With
+// FailOpen, only the images that cannot be verified are allowed: the other
+// images of the request must still be signed.
+//
+// AdmitFuncs are not passed the context of the incoming request: verification
+// is instead bounded by the configured timeout (see VerificationTimeout),
+// which should be shorter than the webhook's timeoutSeconds.
+//
+// RequireSignedImages inspects init, regular & ephemeral containers, in Pods
+// and the Pod templates of Deployments, StatefulSets, DaemonSets, ReplicaSets,
+// ReplicationControllers, Jobs & CronJobs. Unknown object kinds are answered
+// according to AdmissionHandler.UnknownKinds (allowed by default).
+func RequireSignedImages(verifier ImageSignatureVerifier, ignoredNamespaces []string, opts ...SignatureVerificationOption) (AdmitFunc, error) {
+	if verifier == nil {
+		return nil, xerrors.New("an ImageSignatureVerifier must be provided")
+	}
+
+	sp := &signaturePolicy{
+		verifier: verifier,
+		timeout:  defaultVerificationTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(sp)
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		if isIgnoredNamespace(pod.namespace, ignoredNamespaces) {
+			resp.Allowed = true
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s namespace is whitelisted", pod.namespace)
+			return resp, nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), sp.timeout)
+		defer cancel()
+
+		var violations ViolationList
+		var unverified []string
+		type verification struct {
+			resolved string
+			signed   bool
+		}
+		verified := make(map[string]verification)
+		failed := make(map[string]bool)
+		for _, container := range pod.containers() {
+			if failed[container.Image] {
+				continue
+			}
+
+			result, ok := verified[container.Image]
+			if !ok {
+				result.resolved, result.signed, err = sp.verify(ctx, container.Image)
+				if err != nil {
+					reason := fmt.Sprintf("the signature of the image %s could not be verified", container.Image)
+					if sp.failurePolicy != FailOpen {
+						return resp, xerrors.Errorf("%s (%s): %w", reason, sp.failurePolicy, err)
+					}
+
+					// Fail open for this image only: the other images are
+					// still verified, and their missing signatures denied.
+					failed[container.Image] = true
+					unverified = append(unverified, fmt.Sprintf("%s (%s): %s", reason, sp.failurePolicy, err))
+					continue
+				}
+				verified[container.Image] = result
+			}
+
+			if !result.signed {
+				violations.Add(container.field+".image", fmt.Sprintf("%s (%s) is not signed by a trusted key", container.Image, result.resolved))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s has unsigned images", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		if len(unverified) > 0 {
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s", strings.Join(unverified, "; "))
+		}
+		return resp, nil
+	}, nil
+}
+
+// verify resolves the image to its digest (unless it is pinned to one), and
+// verifies its signature, returning the resolved repository@digest reference.
+func (sp *signaturePolicy) verify(ctx context.Context, image string) (string, bool, error) {
+	ref := parseImageReference(image)
+	digest := ref.digest
+	if digest == "" {
+		resolved, err := sp.verifier.Resolve(ctx, image)
+		if err != nil {
+			return "", false, xerrors.Errorf("could not resolve the digest: %w", err)
+		}
+		digest = resolved
+	}
+
+	resolved := ref.repository + "@" + digest
+	signed, err := sp.verifier.Verify(ctx, resolved)
+	if err != nil {
+		return "", false, err
+	}
+
+	return resolved, signed, nil
+}
@@ -0,0 +1,157 @@
+package admissioncontrol
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeVerifier resolves tags to the configured digests, and reports the
+// configured repository@digest references as signed - or fails to verify them,
+// with the configured errors.
+type fakeVerifier struct {
+	digests map[string]string
+	signed  map[string]bool
+	errs    map[string]error
+	err     error
+}
+
+func (fv *fakeVerifier) Resolve(ctx context.Context, image string) (string, error) {
+	digest, ok := fv.digests[image]
+	if !ok {
+		return "", errors.New("manifest unknown")
+	}
+
+	return digest, nil
+}
+
+func (fv *fakeVerifier) Verify(ctx context.Context, image string) (bool, error) {
+	if err, ok := fv.errs[image]; ok {
+		return false, err
+	}
+
+	return fv.signed[image], fv.err
+}
+
+func TestRequireSignedImages(t *testing.T) {
+	t.Parallel()
+
+	const (
+		signedDigest   = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+		unsignedDigest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	)
+
+	verifier := &fakeVerifier{
+		digests: map[string]string{
+			"registry.example.com/web:v1.0.0":   signedDigest,
+			"registry.example.com/web:unsigned": unsignedDigest,
+		},
+		signed: map[string]bool{"registry.example.com/web@" + signedDigest: true},
+	}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow a tagged image that resolves to a signed digest",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "registry.example.com/web:v1.0.0"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Allow a signed image pinned to a digest",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "registry.example.com/web@"+signedDigest),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject a tagged image that resolves to an unsigned digest",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "registry.example.com/web:v1.0.0", "registry.example.com/web:unsigned"),
+			expectedMessage: "Pod default/web has unsigned images: spec.containers[container-1].image: registry.example.com/web:unsigned (registry.example.com/web@" + unsignedDigest + ") is not signed by a trusted key",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject an image pinned to an unsigned digest, regardless of its tag",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "registry.example.com/web:v1.0.0@"+unsignedDigest),
+			expectedMessage: "Pod default/web has unsigned images: spec.containers[container-0].image: registry.example.com/web:v1.0.0@" + unsignedDigest + " (registry.example.com/web@" + unsignedDigest + ") is not signed by a trusted key",
+			shouldAllow:     false,
+		},
+		{
+			testName:        "Reject an image that cannot be resolved",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "registry.example.com/web:missing"),
+			expectedMessage: "the signature of the image registry.example.com/web:missing could not be verified (FailClosed): could not resolve the digest: manifest unknown",
+			shouldAllow:     false,
+		},
+		{
+			testName:          "Allow an unsigned image in a whitelisted namespace",
+			kind:              podKind,
+			ignoredNamespaces: []string{"sandbox"},
+			object:            newTestPodWithImages("sandbox", "registry.example.com/web:unsigned"),
+			expectedMessage:   "",
+			shouldAllow:       true,
+		},
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		admitFunc, err := RequireSignedImages(verifier, tt.ignoredNamespaces)
+		if err != nil {
+			t.Fatalf("failed to create the AdmitFunc: %v", err)
+		}
+
+		return admitFunc
+	})
+
+	t.Run("Allow images that cannot be verified with FailOpen", func(t *testing.T) {
+		failing := &fakeVerifier{digests: verifier.digests, err: errors.New("the registry is unavailable")}
+		runObjectTests(t, []objectTest{
+			{
+				testName:        "Allow an image that cannot be verified",
+				kind:            podKind,
+				object:          newTestPodWithImages("default", "registry.example.com/web:v1.0.0"),
+				expectedMessage: "",
+				shouldAllow:     true,
+			},
+		}, func(tt objectTest) AdmitFunc {
+			admitFunc, err := RequireSignedImages(failing, nil, VerificationFailurePolicy(FailOpen))
+			if err != nil {
+				t.Fatalf("failed to create the AdmitFunc: %v", err)
+			}
+
+			return admitFunc
+		})
+
+		partial := &fakeVerifier{
+			digests: map[string]string{
+				"registry.example.com/web:unsigned": unsignedDigest,
+				"registry.example.com/sidecar:v1.0": signedDigest,
+			},
+			errs: map[string]error{"registry.example.com/sidecar@" + signedDigest: errors.New("the registry is unavailable")},
+		}
+		runObjectTests(t, []objectTest{
+			{
+				testName:        "Reject an unsigned image alongside an image that cannot be verified",
+				kind:            podKind,
+				object:          newTestPodWithImages("default", "registry.example.com/web:unsigned", "registry.example.com/sidecar:v1.0"),
+				expectedMessage: "Pod default/web has unsigned images: spec.containers[container-0].image: registry.example.com/web:unsigned (registry.example.com/web@" + unsignedDigest + ") is not signed by a trusted key",
+				shouldAllow:     false,
+			},
+		}, func(tt objectTest) AdmitFunc {
+			admitFunc, err := RequireSignedImages(partial, nil, VerificationFailurePolicy(FailOpen))
+			if err != nil {
+				t.Fatalf("failed to create the AdmitFunc: %v", err)
+			}
+
+			return admitFunc
+		})
+	})
+
+	if _, err := RequireSignedImages(nil, nil); err == nil {
+		t.Fatalf("expected an error for a nil verifier")
+	}
+}
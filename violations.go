@@ -0,0 +1,93 @@
+package admissioncontrol
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/xerrors"
+	admission "k8s.io/api/admission/v1"
+)
+
+// ViolationsAuditAnnotation is the audit annotation key under which the
+// built-in AdmitFuncs record the (JSON-encoded) ViolationList of a denial.
+const ViolationsAuditAnnotation = "violations"
+
+// Violation is a single policy violation.
+type Violation struct {
+	// Field is the path of the field (or element, e.g. a container) that the
+	// violation relates to - e.g. "spec.template.spec.containers[nginx].image".
+	// It may be empty for violations of the object as a whole.
+	Field string `json:"field,omitempty"`
+	// Message describes the violation.
+	Message string `json:"message"`
+}
+
+// String formats the Violation as "field: message".
+func (v Violation) String() string {
+	if v.Field == "" {
+		return v.Message
+	}
+
+	return fmt.Sprintf("%s: %s", v.Field, v.Message)
+}
+
+// ViolationList aggregates the violations of a policy, so that denials with
+// multiple violations read consistently across AdmitFuncs.
+//
+// A ViolationList can be used as an error: use Error (or wrap it) for a
+// single-line denial, and ToStatusMessage for a message listing each violation
+// on its own line.
+type ViolationList []Violation
+
+// Add appends a violation of the field to the list.
+func (vl *ViolationList) Add(field, message string) {
+	*vl = append(*vl, Violation{Field: field, Message: message})
+}
+
+// Error formats the violations on a single line, separated by semicolons.
+func (vl ViolationList) Error() string {
+	violations := make([]string, 0, len(vl))
+	for _, v := range vl {
+		violations = append(violations, v.String())
+	}
+
+	return strings.Join(violations, "; ")
+}
+
+// ToStatusMessage formats the violations for the message of an admission
+// response, with each violation on its own line - as displayed by kubectl.
+func (vl ViolationList) ToStatusMessage() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d policy violation(s):", len(vl))
+	for _, v := range vl {
+		b.WriteString("\n- ")
+		b.WriteString(v.String())
+	}
+
+	return b.String()
+}
+
+// AuditAnnotation returns the machine-readable (JSON) form of the violations,
+// suitable for the value of an audit annotation. See SetAuditAnnotation.
+func (vl ViolationList) AuditAnnotation() string {
+	if vl == nil {
+		return "[]"
+	}
+
+	// A []Violation always marshals cleanly.
+	encoded, _ := json.Marshal([]Violation(vl))
+	return string(encoded)
+}
+
+// deny denies admission on account of the violations: the violations are
+// recorded as an audit annotation on the response (under the
+// ViolationsAuditAnnotation key), and the returned error combines the summary
+// with the violations.
+func (vl ViolationList) deny(resp *admission.AdmissionResponse, summary string) error {
+	if err := SetAuditAnnotation(resp, ViolationsAuditAnnotation, vl.AuditAnnotation()); err != nil {
+		return err
+	}
+
+	return xerrors.Errorf("%s: %s", summary, vl.Error())
+}
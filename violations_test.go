@@ -0,0 +1,64 @@
+package admissioncontrol
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestViolationList(t *testing.T) {
+	t.Parallel()
+
+	var violations ViolationList
+	violations.Add("spec.backoffLimit", "must be set")
+	violations.Add("", "the object is invalid")
+
+	if got, expected := violations.Error(), "spec.backoffLimit: must be set; the object is invalid"; got != expected {
+		t.Fatalf("Error() = %q: expected %q", got, expected)
+	}
+
+	if got, expected := violations.ToStatusMessage(), "2 policy violation(s):\n- spec.backoffLimit: must be set\n- the object is invalid"; got != expected {
+		t.Fatalf("ToStatusMessage() = %q: expected %q", got, expected)
+	}
+
+	var decoded []Violation
+	if err := json.Unmarshal([]byte(violations.AuditAnnotation()), &decoded); err != nil {
+		t.Fatalf("AuditAnnotation() is not valid JSON: %v", err)
+	}
+
+	if !reflect.DeepEqual(decoded, []Violation(violations)) {
+		t.Fatalf("AuditAnnotation() decoded to %v: expected %v", decoded, violations)
+	}
+
+	if got := (ViolationList(nil)).AuditAnnotation(); got != "[]" {
+		t.Fatalf("AuditAnnotation() of an empty list = %q: expected []", got)
+	}
+}
+
+func TestBuiltInViolationsAuditAnnotation(t *testing.T) {
+	t.Parallel()
+
+	job := &batchv1.Job{
+		TypeMeta:   meta.TypeMeta{Kind: "Job", APIVersion: "batch/v1"},
+		ObjectMeta: meta.ObjectMeta{Name: "migrate", Namespace: "default"},
+	}
+
+	raw, err := json.Marshal(job)
+	if err != nil {
+		t.Fatalf("could not marshal k8s API object: %v", err)
+	}
+
+	review := newTestAdmissionRequest(meta.GroupVersionKind{Group: "batch", Kind: "Job", Version: "v1"}, raw, false)
+	resp, err := EnforceJobPolicy(nil, JobPolicy{})(review)
+	if err == nil {
+		t.Fatalf("expected the Job to be rejected")
+	}
+
+	expected := `[{"field":"spec.backoffLimit","message":"must be set"},{"field":"spec.ttlSecondsAfterFinished","message":"must be set"}]`
+	if got := resp.AuditAnnotations[ViolationsAuditAnnotation]; got != expected {
+		t.Fatalf("violations audit annotation = %q: expected %q", got, expected)
+	}
+}
@@ -0,0 +1,296 @@
+package admissioncontrol
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+	admission "k8s.io/api/admission/v1"
+)
+
+var (
+	// defaultScanTimeout bounds the time spent scanning the images of a
+	// request. It must remain below the webhook timeoutSeconds configured on
+	// the API server.
+	defaultScanTimeout = time.Second * 5
+	// defaultScanCacheTTL is how long the findings for an image are cached.
+	defaultScanCacheTTL = time.Minute * 10
+	// maxScanCacheEntries is the number of cached images beyond which expired
+	// entries are evicted.
+	maxScanCacheEntries = 1024
+	// maxReportedFindings limits the number of findings listed in a denial.
+	maxReportedFindings = 5
+)
+
+// Severity is the severity of a vulnerability, ordered from SeverityUnknown to
+// SeverityCritical.
+type Severity int
+
+const (
+	// SeverityUnknown is the severity of vulnerabilities that have not been
+	// assessed.
+	SeverityUnknown Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = []string{"UNKNOWN", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+func (s Severity) String() string {
+	if s < SeverityUnknown || s > SeverityCritical {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+
+	return severityNames[s]
+}
+
+// ParseSeverity parses a (case-insensitive) severity name - one of UNKNOWN,
+// LOW, MEDIUM, HIGH or CRITICAL, as reported by scanners such as Trivy.
+func ParseSeverity(name string) (Severity, error) {
+	for i, severityName := range severityNames {
+		if strings.EqualFold(name, severityName) {
+			return Severity(i), nil
+		}
+	}
+
+	return SeverityUnknown, xerrors.Errorf("invalid severity %q: must be one of %s", name, strings.Join(severityNames, ", "))
+}
+
+// VulnerabilityFinding is a vulnerability found in an image.
+type VulnerabilityFinding struct {
+	// ID identifies the vulnerability - e.g. "CVE-2021-44228".
+	ID string
+	// Severity is the severity of the vulnerability.
+	Severity Severity
+	// Package is the affected package, if known.
+	Package string
+}
+
+// VulnerabilityScanner returns the vulnerabilities found in an image: e.g. by
+// querying a Trivy server, or Clair. The image is the reference of a container
+// image, as it appears in the Pod.
+//
+// Scan is called with a context bounded by the scan timeout (see ScanTimeout),
+// and must be safe for concurrent use.
+type VulnerabilityScanner interface {
+	Scan(ctx context.Context, image string) ([]VulnerabilityFinding, error)
+}
+
+// vulnerabilityPolicy holds the configuration of DenyVulnerableImages.
+type vulnerabilityPolicy struct {
+	scanner       VulnerabilityScanner
+	threshold     Severity
+	timeout       time.Duration
+	cacheTTL      time.Duration
+	failurePolicy FailurePolicy
+
+	mu    sync.Mutex
+	cache map[string]scanResult
+}
+
+// scanResult is a cached set of findings.
+type scanResult struct {
+	findings []VulnerabilityFinding
+	expires  time.Time
+}
+
+// VulnerabilityScanOption configures DenyVulnerableImages.
+type VulnerabilityScanOption func(*vulnerabilityPolicy)
+
+// ScanTimeout sets the time allowed for scanning the images of a request.
+// Defaults to 5 seconds.
+func ScanTimeout(timeout time.Duration) VulnerabilityScanOption {
+	return func(vp *vulnerabilityPolicy) {
+		vp.timeout = timeout
+	}
+}
+
+// ScanCacheTTL sets how long the findings for an image are cached. Defaults to
+// 10 minutes. A ttl <= 0 disables the cache.
+func ScanCacheTTL(ttl time.Duration) VulnerabilityScanOption {
+	return func(vp *vulnerabilityPolicy) {
+		vp.cacheTTL = ttl
+	}
+}
+
+// ScanFailurePolicy determines whether requests are allowed (FailOpen) or
+// denied (FailClosed) when an image cannot be scanned. Defaults to FailClosed.
+func ScanFailurePolicy(failurePolicy FailurePolicy) VulnerabilityScanOption {
+	return func(vp *vulnerabilityPolicy) {
+		vp.failurePolicy = failurePolicy
+	}
+}
+
+// DenyVulnerableImages denies Pods (and the Pod templates of workloads) with
+// container images that have vulnerabilities at or above the severityThreshold
+// (e.g. "HIGH"), as reported by the scanner. An error is returned if the
+// threshold is not a valid severity: see ParseSeverity.
+//
+// The findings for each image are cached (see ScanCacheTTL): images pinned to a
+// digest are cached by their digest, and other images by their reference - as
+// a tag can be moved to a new image, the findings for a tagged image may be
+// out of date until they expire. Images that cannot be scanned are denied by
+// default: see ScanFailurePolicy. With FailOpen, only the images that cannot
+// be scanned are allowed: the vulnerabilities of the other images of the
+// request are still denied.
+//
+// AdmitFuncs are not passed the context of the incoming request: scans are
+// instead bounded by the configured timeout (see ScanTimeout), which should be
+// shorter than the webhook's timeoutSeconds.
+//
+// DenyVulnerableImages inspects init, regular & ephemeral containers, in Pods
+// and the Pod templates of Deployments, StatefulSets, DaemonSets, ReplicaSets,
+// ReplicationControllers, Jobs & CronJobs. Unknown object kinds are answered
+// according to AdmissionHandler.UnknownKinds (allowed by default).
+func DenyVulnerableImages(scanner VulnerabilityScanner, severityThreshold string, opts ...VulnerabilityScanOption) (AdmitFunc, error) {
+	if scanner == nil {
+		return nil, xerrors.New("a VulnerabilityScanner must be provided")
+	}
+
+	threshold, err := ParseSeverity(severityThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	vp := &vulnerabilityPolicy{
+		scanner:   scanner,
+		threshold: threshold,
+		timeout:   defaultScanTimeout,
+		cacheTTL:  defaultScanCacheTTL,
+		cache:     make(map[string]scanResult),
+	}
+
+	for _, opt := range opts {
+		opt(vp)
+	}
+
+	return func(admissionReview *admission.AdmissionReview) (*admission.AdmissionResponse, error) {
+		kind := admissionReview.Request.Kind.Kind
+		resp := newDefaultDenyResponse()
+
+		pod, err := decodePodObject(kind, admissionReview.Request.Object.Raw)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), vp.timeout)
+		defer cancel()
+
+		var violations ViolationList
+		var unscanned []string
+		scanned := make(map[string][]VulnerabilityFinding)
+		failed := make(map[string]bool)
+		for _, container := range pod.containers() {
+			if failed[container.Image] {
+				continue
+			}
+
+			findings, ok := scanned[container.Image]
+			if !ok {
+				findings, err = vp.scan(ctx, container.Image)
+				if err != nil {
+					reason := fmt.Sprintf("the image %s could not be scanned", container.Image)
+					if vp.failurePolicy != FailOpen {
+						return resp, xerrors.Errorf("%s (%s): %w", reason, vp.failurePolicy, err)
+					}
+
+					// Fail open for this image only: the other images are
+					// still scanned, and their vulnerabilities denied.
+					failed[container.Image] = true
+					unscanned = append(unscanned, fmt.Sprintf("%s (%s): %s", reason, vp.failurePolicy, err))
+					continue
+				}
+				scanned[container.Image] = findings
+			}
+
+			if summary := vp.summarize(findings); summary != "" {
+				violations.Add(container.field+".image", fmt.Sprintf("%s has %s", container.Image, summary))
+			}
+		}
+
+		if len(violations) > 0 {
+			return resp, violations.deny(resp, fmt.Sprintf("%s %s/%s has images with vulnerabilities", kind, pod.namespace, pod.name))
+		}
+
+		resp.Allowed = true
+		if len(unscanned) > 0 {
+			resp.Result.Message = fmt.Sprintf("allowing admission: %s", strings.Join(unscanned, "; "))
+		}
+		return resp, nil
+	}, nil
+}
+
+// scan returns the (possibly cached) findings for the image.
+func (vp *vulnerabilityPolicy) scan(ctx context.Context, image string) ([]VulnerabilityFinding, error) {
+	key := image
+	if ref := parseImageReference(image); ref.digest != "" {
+		key = ref.digest
+	}
+
+	now := time.Now()
+	if vp.cacheTTL > 0 {
+		vp.mu.Lock()
+		result, ok := vp.cache[key]
+		vp.mu.Unlock()
+
+		if ok && now.Before(result.expires) {
+			return result.findings, nil
+		}
+	}
+
+	findings, err := vp.scanner.Scan(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+
+	if vp.cacheTTL > 0 {
+		vp.mu.Lock()
+		if len(vp.cache) >= maxScanCacheEntries {
+			for cached, result := range vp.cache {
+				if !now.Before(result.expires) {
+					delete(vp.cache, cached)
+				}
+			}
+		}
+		vp.cache[key] = scanResult{findings: findings, expires: now.Add(vp.cacheTTL)}
+		vp.mu.Unlock()
+	}
+
+	return findings, nil
+}
+
+// summarize describes the findings at or above the threshold - most severe
+// first - or returns an empty string if there are none.
+func (vp *vulnerabilityPolicy) summarize(findings []VulnerabilityFinding) string {
+	var denied []VulnerabilityFinding
+	for _, finding := range findings {
+		if finding.Severity >= vp.threshold {
+			denied = append(denied, finding)
+		}
+	}
+
+	if len(denied) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(denied, func(i, j int) bool {
+		return denied[i].Severity > denied[j].Severity
+	})
+
+	reported := make([]string, 0, maxReportedFindings)
+	for i, finding := range denied {
+		if i == maxReportedFindings {
+			reported = append(reported, fmt.Sprintf("and %d more", len(denied)-maxReportedFindings))
+			break
+		}
+		reported = append(reported, fmt.Sprintf("%s (%s)", finding.ID, finding.Severity))
+	}
+
+	return fmt.Sprintf("%d vulnerabilities at or above %s: %s", len(denied), vp.threshold, strings.Join(reported, ", "))
+}
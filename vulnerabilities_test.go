@@ -0,0 +1,195 @@
+package admissioncontrol
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeScanner returns the findings (or the error) configured for each image,
+// and records the number of scans of each image.
+type fakeScanner struct {
+	findings map[string][]VulnerabilityFinding
+	errs     map[string]error
+	err      error
+
+	mu    sync.Mutex
+	scans map[string]int
+}
+
+func (fs *fakeScanner) Scan(ctx context.Context, image string) ([]VulnerabilityFinding, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.scans == nil {
+		fs.scans = make(map[string]int)
+	}
+	fs.scans[image]++
+
+	if err, ok := fs.errs[image]; ok {
+		return nil, err
+	}
+
+	return fs.findings[image], fs.err
+}
+
+func (fs *fakeScanner) scanCount(image string) int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.scans[image]
+}
+
+func TestParseSeverity(t *testing.T) {
+	t.Parallel()
+
+	if severity, err := ParseSeverity("high"); err != nil || severity != SeverityHigh {
+		t.Fatalf("ParseSeverity(%q) = %v, %v: expected %v", "high", severity, err, SeverityHigh)
+	}
+
+	if _, err := ParseSeverity("severe"); err == nil {
+		t.Fatalf("expected an error for an invalid severity")
+	}
+}
+
+func TestDenyVulnerableImages(t *testing.T) {
+	t.Parallel()
+
+	scanner := &fakeScanner{findings: map[string][]VulnerabilityFinding{
+		"web:v1.0.0": {{ID: "CVE-2021-0001", Severity: SeverityLow}},
+		"api:v1.0.0": {
+			{ID: "CVE-2021-0002", Severity: SeverityHigh},
+			{ID: "CVE-2021-0003", Severity: SeverityMedium},
+			{ID: "CVE-2021-44228", Severity: SeverityCritical},
+		},
+	}}
+	podKind := meta.GroupVersionKind{Group: "", Kind: "Pod", Version: "v1"}
+
+	var denyTests = []objectTest{
+		{
+			testName:        "Allow images without vulnerabilities above the threshold",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "web:v1.0.0", "web:v1.0.0"),
+			expectedMessage: "",
+			shouldAllow:     true,
+		},
+		{
+			testName:        "Reject an image with vulnerabilities above the threshold",
+			kind:            podKind,
+			object:          newTestPodWithImages("default", "web:v1.0.0", "api:v1.0.0"),
+			expectedMessage: "Pod default/web has images with vulnerabilities: spec.containers[container-1].image: api:v1.0.0 has 2 vulnerabilities at or above HIGH: CVE-2021-44228 (CRITICAL), CVE-2021-0002 (HIGH)",
+			shouldAllow:     false,
+		},
+	}
+
+	admitFunc, err := DenyVulnerableImages(scanner, "high")
+	if err != nil {
+		t.Fatalf("failed to create the AdmitFunc: %v", err)
+	}
+
+	runObjectTests(t, denyTests, func(tt objectTest) AdmitFunc {
+		return admitFunc
+	})
+
+	if got := scanner.scanCount("web:v1.0.0"); got != 1 {
+		t.Fatalf("web:v1.0.0 was scanned %d times: expected the findings to be cached", got)
+	}
+
+	t.Run("Scan failures", func(t *testing.T) {
+		failing := &fakeScanner{err: errors.New("the scanner is unavailable")}
+		runObjectTests(t, []objectTest{
+			{
+				testName:        "Reject images that cannot be scanned",
+				kind:            podKind,
+				object:          newTestPodWithImages("default", "web:v1.0.0"),
+				expectedMessage: "the image web:v1.0.0 could not be scanned (FailClosed): the scanner is unavailable",
+				shouldAllow:     false,
+			},
+		}, func(tt objectTest) AdmitFunc {
+			admitFunc, err := DenyVulnerableImages(failing, "HIGH")
+			if err != nil {
+				t.Fatalf("failed to create the AdmitFunc: %v", err)
+			}
+
+			return admitFunc
+		})
+
+		runObjectTests(t, []objectTest{
+			{
+				testName:        "Allow images that cannot be scanned with FailOpen",
+				kind:            podKind,
+				object:          newTestPodWithImages("default", "web:v1.0.0"),
+				expectedMessage: "",
+				shouldAllow:     true,
+			},
+		}, func(tt objectTest) AdmitFunc {
+			admitFunc, err := DenyVulnerableImages(failing, "HIGH", ScanFailurePolicy(FailOpen))
+			if err != nil {
+				t.Fatalf("failed to create the AdmitFunc: %v", err)
+			}
+
+			return admitFunc
+		})
+
+		partial := &fakeScanner{
+			findings: scanner.findings,
+			errs:     map[string]error{"sidecar:v1.0.0": errors.New("the scanner is unavailable")},
+		}
+		runObjectTests(t, []objectTest{
+			{
+				testName:        "Reject vulnerable images alongside an image that cannot be scanned with FailOpen",
+				kind:            podKind,
+				object:          newTestPodWithImages("default", "sidecar:v1.0.0", "api:v1.0.0"),
+				expectedMessage: "Pod default/web has images with vulnerabilities: spec.containers[container-1].image: api:v1.0.0 has 2 vulnerabilities at or above HIGH: CVE-2021-44228 (CRITICAL), CVE-2021-0002 (HIGH)",
+				shouldAllow:     false,
+			},
+			{
+				testName:        "Allow the image that cannot be scanned with FailOpen",
+				kind:            podKind,
+				object:          newTestPodWithImages("default", "sidecar:v1.0.0", "web:v1.0.0"),
+				expectedMessage: "",
+				shouldAllow:     true,
+			},
+		}, func(tt objectTest) AdmitFunc {
+			admitFunc, err := DenyVulnerableImages(partial, "HIGH", ScanFailurePolicy(FailOpen), ScanCacheTTL(0))
+			if err != nil {
+				t.Fatalf("failed to create the AdmitFunc: %v", err)
+			}
+
+			return admitFunc
+		})
+	})
+
+	t.Run("Cache expiry", func(t *testing.T) {
+		expiring := &fakeScanner{}
+		admitFunc, err := DenyVulnerableImages(expiring, "HIGH", ScanCacheTTL(time.Millisecond))
+		if err != nil {
+			t.Fatalf("failed to create the AdmitFunc: %v", err)
+		}
+
+		tests := []objectTest{{
+			testName:    "Allow an image without vulnerabilities",
+			kind:        podKind,
+			object:      newTestPodWithImages("default", "registry.example.com/web@sha256:8f08a8cb2902ab8e0d9b5fc1ded5a0c1bd8ec2ba6d8c1f8fa2b6aead2d1e1b4f"),
+			shouldAllow: true,
+		}}
+		runObjectTests(t, tests, func(tt objectTest) AdmitFunc { return admitFunc })
+		time.Sleep(time.Millisecond * 5)
+		runObjectTests(t, tests, func(tt objectTest) AdmitFunc { return admitFunc })
+
+		if got := expiring.scanCount("registry.example.com/web@sha256:8f08a8cb2902ab8e0d9b5fc1ded5a0c1bd8ec2ba6d8c1f8fa2b6aead2d1e1b4f"); got != 2 {
+			t.Fatalf("the image was scanned %d times: expected the cached findings to expire", got)
+		}
+	})
+
+	if _, err := DenyVulnerableImages(scanner, "severe"); err == nil {
+		t.Fatalf("expected an error for an invalid severity threshold")
+	}
+
+	if _, err := DenyVulnerableImages(nil, "HIGH"); err == nil {
+		t.Fatalf("expected an error for a nil scanner")
+	}
+}
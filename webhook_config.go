@@ -0,0 +1,106 @@
+package admissioncontrol
+
+import (
+	"bytes"
+	"context"
+
+	"golang.org/x/xerrors"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// WebhookConfigurationKind identifies the kind of a webhook configuration.
+type WebhookConfigurationKind string
+
+const (
+	// ValidatingWebhookConfiguration identifies a
+	// admissionregistration.k8s.io/v1 ValidatingWebhookConfiguration.
+	ValidatingWebhookConfiguration WebhookConfigurationKind = "ValidatingWebhookConfiguration"
+	// MutatingWebhookConfiguration identifies a
+	// admissionregistration.k8s.io/v1 MutatingWebhookConfiguration.
+	MutatingWebhookConfiguration WebhookConfigurationKind = "MutatingWebhookConfiguration"
+)
+
+// PatchCABundle sets the clientConfig.caBundle of every webhook in the
+// existing webhook configuration of the given kind & name to caBundle (the
+// PEM-encoded CA certificate(s) that the API server should trust when calling
+// the webhooks).
+//
+// Call PatchCABundle on startup and whenever the serving certificate is
+// rotated, so that an admission controller that manages its own certificates
+// can keep its registration current. The update is retried on conflicts, and
+// is skipped if every webhook already has the caBundle. The client must be
+// authorized to get & update the webhook configuration.
+func PatchCABundle(ctx context.Context, client kubernetes.Interface, kind WebhookConfigurationKind, name string, caBundle []byte) error {
+	if len(caBundle) == 0 {
+		return xerrors.New("the caBundle must not be empty")
+	}
+
+	var patch func() error
+	switch kind {
+	case ValidatingWebhookConfiguration:
+		configs := client.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+		patch = func() error {
+			config, err := configs.Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+
+			clientConfigs := make([]*admissionregistrationv1.WebhookClientConfig, len(config.Webhooks))
+			for i := range config.Webhooks {
+				clientConfigs[i] = &config.Webhooks[i].ClientConfig
+			}
+
+			if !setCABundle(clientConfigs, caBundle) {
+				return nil
+			}
+
+			_, err = configs.Update(ctx, config, metav1.UpdateOptions{})
+			return err
+		}
+	case MutatingWebhookConfiguration:
+		configs := client.AdmissionregistrationV1().MutatingWebhookConfigurations()
+		patch = func() error {
+			config, err := configs.Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+
+			clientConfigs := make([]*admissionregistrationv1.WebhookClientConfig, len(config.Webhooks))
+			for i := range config.Webhooks {
+				clientConfigs[i] = &config.Webhooks[i].ClientConfig
+			}
+
+			if !setCABundle(clientConfigs, caBundle) {
+				return nil
+			}
+
+			_, err = configs.Update(ctx, config, metav1.UpdateOptions{})
+			return err
+		}
+	default:
+		return xerrors.Errorf("unsupported webhook configuration kind %q: must be one of %s or %s", kind, ValidatingWebhookConfiguration, MutatingWebhookConfiguration)
+	}
+
+	if err := retry.RetryOnConflict(retry.DefaultRetry, patch); err != nil {
+		return xerrors.Errorf("could not patch the caBundle of %s %s: %w", kind, name, err)
+	}
+
+	return nil
+}
+
+// setCABundle sets the caBundle of each of the webhook client configs, and
+// reports whether any of them changed.
+func setCABundle(clientConfigs []*admissionregistrationv1.WebhookClientConfig, caBundle []byte) bool {
+	changed := false
+	for _, clientConfig := range clientConfigs {
+		if !bytes.Equal(clientConfig.CABundle, caBundle) {
+			clientConfig.CABundle = caBundle
+			changed = true
+		}
+	}
+
+	return changed
+}
@@ -0,0 +1,72 @@
+package admissioncontrol
+
+import (
+	"context"
+	"testing"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPatchCABundle(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	caBundle := []byte("-----BEGIN CERTIFICATE-----\nnew\n-----END CERTIFICATE-----\n")
+	client := fake.NewSimpleClientset(
+		&admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: meta.ObjectMeta{Name: "admission-control"},
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{
+				{Name: "pods.example.com", ClientConfig: admissionregistrationv1.WebhookClientConfig{CABundle: []byte("old")}},
+				{Name: "services.example.com"},
+			},
+		},
+		&admissionregistrationv1.MutatingWebhookConfiguration{
+			ObjectMeta: meta.ObjectMeta{Name: "admission-control"},
+			Webhooks: []admissionregistrationv1.MutatingWebhook{
+				{Name: "pods.example.com"},
+			},
+		},
+	)
+
+	if err := PatchCABundle(ctx, client, ValidatingWebhookConfiguration, "admission-control", caBundle); err != nil {
+		t.Fatalf("failed to patch the ValidatingWebhookConfiguration: %v", err)
+	}
+
+	validating, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, "admission-control", meta.GetOptions{})
+	if err != nil {
+		t.Fatalf("could not get the ValidatingWebhookConfiguration: %v", err)
+	}
+
+	for _, webhook := range validating.Webhooks {
+		if string(webhook.ClientConfig.CABundle) != string(caBundle) {
+			t.Fatalf("the caBundle of webhook %s was not patched: got %q", webhook.Name, webhook.ClientConfig.CABundle)
+		}
+	}
+
+	if err := PatchCABundle(ctx, client, MutatingWebhookConfiguration, "admission-control", caBundle); err != nil {
+		t.Fatalf("failed to patch the MutatingWebhookConfiguration: %v", err)
+	}
+
+	mutating, err := client.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, "admission-control", meta.GetOptions{})
+	if err != nil {
+		t.Fatalf("could not get the MutatingWebhookConfiguration: %v", err)
+	}
+
+	if got := string(mutating.Webhooks[0].ClientConfig.CABundle); got != string(caBundle) {
+		t.Fatalf("the caBundle of the mutating webhook was not patched: got %q", got)
+	}
+
+	if err := PatchCABundle(ctx, client, ValidatingWebhookConfiguration, "missing", caBundle); err == nil {
+		t.Fatalf("expected an error for a missing webhook configuration")
+	}
+
+	if err := PatchCABundle(ctx, client, WebhookConfigurationKind("Webhook"), "admission-control", caBundle); err == nil {
+		t.Fatalf("expected an error for an unsupported kind")
+	}
+
+	if err := PatchCABundle(ctx, client, ValidatingWebhookConfiguration, "admission-control", nil); err == nil {
+		t.Fatalf("expected an error for an empty caBundle")
+	}
+}